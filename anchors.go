@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"./lint"
+	"./parser"
+)
+
+// anchorIndex holds the anchors of the headings in markdown files, each
+// file parsed the first time a link into it is checked
+type anchorIndex struct {
+	p     *parser.Parser
+	files *tree // Where the files are read from
+
+	mu      sync.Mutex
+	anchors map[string]*fileAnchors
+}
+
+// fileAnchors are the heading anchors of a file, ready once loaded is done
+type fileAnchors struct {
+	loaded sync.Once
+	ids    map[string]bool
+	sorted []string
+	err    error
+}
+
+// newAnchorIndex returns an index of the anchors of the files in t
+func newAnchorIndex(t *tree) *anchorIndex {
+	return &anchorIndex{
+		p:       parser.New(parser.WithExtensions(withDefaults()...)),
+		files:   t,
+		anchors: make(map[string]*fileAnchors),
+	}
+}
+
+// check returns why no heading of the markdown file has the anchor
+// fragment, suggesting the closest one if there is one, or "" if a heading
+// has it
+func (x *anchorIndex) check(file, fragment string) string {
+	if fragment == "" || fragment == "top" { // Browsers know #top
+		return ""
+	}
+	x.mu.Lock()
+	a, ok := x.anchors[file]
+	if !ok {
+		a = &fileAnchors{}
+		x.anchors[file] = a
+	}
+	x.mu.Unlock()
+	a.loaded.Do(func() { a.err = a.load(x.p, x.files, file) })
+	if a.err != nil {
+		if os.IsNotExist(a.err) {
+			return "no such file"
+		}
+		return a.err.Error()
+	}
+	if a.ids[fragment] {
+		return ""
+	}
+	if s := lint.Suggest(fragment, a.sorted); s != "" {
+		return fmt.Sprintf("no heading has the anchor #%s; did you mean #%s?", fragment, s)
+	}
+	return fmt.Sprintf("no heading has the anchor #%s", fragment)
+}
+
+func (a *fileAnchors) load(p *parser.Parser, t *tree, file string) error {
+	doc, err := t.parse(p, file)
+	if err != nil {
+		return err
+	}
+	a.ids = make(map[string]bool)
+	for _, id := range parser.HeadingIDs(doc.Root) {
+		a.ids[id] = true
+		a.sorted = append(a.sorted, id)
+	}
+	sort.Strings(a.sorted)
+	return nil
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"./parser"
+)
+
+// assetsDir is the directory of a build's output that images from outside
+// its source tree are copied into
+const assetsDir = "_assets"
+
+// imageExts are the extensions of the images that may be copied into
+// assetsDir. Nothing else outside the source tree is published
+var imageExts = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true,
+	".webp": true, ".avif": true, ".bmp": true, ".ico": true,
+}
+
+// assets finds the local files a build's documents refer to by images &
+// links, and checks that links to other documents' #fragments have
+// headings to go to. Images from outside the source tree are copied into
+// the output, if external says to, with the references pointed at the
+// copies; other references outside it are reported. It's an Extension of
+// the build's parser, which must keep source ranges
+type assets struct {
+	files     *tree
+	src, dest string
+	destOf    func(path string) string // Where each document is written
+	anchors   *anchorIndex
+	external  bool // Whether to copy images from outside src
+
+	mu       sync.Mutex
+	copied   map[string]string // Where each file from outside src was copied to
+	problems []assetProblem    // References to files that are missing or couldn't be copied
+}
+
+// assetProblem is a reference to a file that's missing or couldn't be
+// copied, or to a heading another document doesn't have
+type assetProblem struct {
+	file string
+	pos  parser.Position
+	msg  string
+	kind int // Which of the kinds of problem below it is
+}
+
+// Kinds of assetProblem, counted separately
+const (
+	brokenLink   = iota // A link to a file that's missing or couldn't be copied
+	brokenImage         // An image that's missing or couldn't be copied
+	brokenAnchor        // A link to a heading another document doesn't have
+	outsideRef          // A reference to a file outside the tree that isn't published
+)
+
+// newAssets returns the assets of a build of the files in t into dest,
+// copying images from outside t if external is set
+func newAssets(t *tree, dest string, destOf func(string) string, external bool) *assets {
+	return &assets{files: t, src: t.dir, dest: dest, destOf: destOf, anchors: newAnchorIndex(t), external: external, copied: make(map[string]string)}
+}
+
+func (a *assets) Extend(p *parser.Parser) {
+	p.AddTransformer(parser.TransformerFunc(func(doc *parser.Document) {
+		var walk func(n *parser.Node)
+		walk = func(n *parser.Node) {
+			if n.Kind == parser.NodeImage || n.Kind == parser.NodeLink {
+				n.Dest = a.resolve(doc, n)
+			}
+			for _, c := range n.Children {
+				walk(c)
+			}
+		}
+		walk(doc.Root)
+	}))
+}
+
+// resolve returns where the reference n in doc should point in the output,
+// noting any problem with the file it refers to
+func (a *assets) resolve(doc *parser.Document, n *parser.Node) string {
+	u, err := url.Parse(n.Dest)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" || strings.HasPrefix(u.Path, "/") {
+		return n.Dest
+	}
+	file := filepath.Join(filepath.Dir(doc.Name), filepath.FromSlash(u.Path))
+	info, err := a.files.stat(file)
+	if err != nil {
+		a.report(doc, n, brokenKind(n), "%s doesn't exist", u.Path)
+		return n.Dest
+	}
+	if n.Kind == parser.NodeLink && isMarkdown(u.Path) {
+		if msg := a.anchors.check(file, u.Fragment); msg != "" {
+			a.report(doc, n, brokenAnchor, "%s: %s", u.Path, msg)
+		}
+		return n.Dest // Converted, not copied
+	}
+	if rel, err := filepath.Rel(a.src, file); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return n.Dest // Copied with the rest of the tree
+	}
+	switch {
+	case info.IsDir():
+		a.report(doc, n, outsideRef, "%s is a directory outside %s, so isn't copied", u.Path, a.src)
+		return n.Dest
+	case isHidden(u.Path):
+		a.report(doc, n, outsideRef, "%s is a hidden file outside %s, so isn't copied", u.Path, a.src)
+		return n.Dest
+	case n.Kind != parser.NodeImage || !imageExts[strings.ToLower(filepath.Ext(file))]:
+		a.report(doc, n, outsideRef, "%s is outside %s, and only images are copied", u.Path, a.src)
+		return n.Dest
+	case !a.external:
+		a.report(doc, n, outsideRef, "%s is outside %s; copy it with -external-assets", u.Path, a.src)
+		return n.Dest
+	}
+	target, err := a.copy(file)
+	if err != nil {
+		a.report(doc, n, brokenKind(n), "%v", err)
+		return n.Dest
+	}
+	rel, err := filepath.Rel(filepath.Dir(a.destOf(doc.Name)), target)
+	if err != nil {
+		return n.Dest
+	}
+	suffix := ""
+	if i := strings.IndexAny(n.Dest, "?#"); i >= 0 {
+		suffix = n.Dest[i:]
+	}
+	return filepath.ToSlash(rel) + suffix
+}
+
+// copy copies file, from outside the source tree, into the assets
+// directory once, returning where it's copied to. Files are kept apart by
+// a hash of where they came from, so files of the same name don't clash
+func (a *assets) copy(file string) (string, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if target, ok := a.copied[abs]; ok {
+		return target, nil
+	}
+	sum := sha1.Sum([]byte(filepath.ToSlash(abs)))
+	target := filepath.Join(a.dest, assetsDir, hex.EncodeToString(sum[:4]), filepath.Base(file))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", err
+	}
+	if err := copyFile(file, target); err != nil {
+		return "", err
+	}
+	a.copied[abs] = target
+	return target, nil
+}
+
+// isHidden reports whether any file or directory named in the slash
+// separated path, other than . & .., is hidden by starting with a dot
+func isHidden(path string) bool {
+	for _, name := range strings.Split(path, "/") {
+		if strings.HasPrefix(name, ".") && name != "." && name != ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// brokenKind returns the kind of problem a missing file n refers to is
+func brokenKind(n *parser.Node) int {
+	if n.Kind == parser.NodeImage {
+		return brokenImage
+	}
+	return brokenLink
+}
+
+// report notes a problem of kind with what n in doc refers to
+func (a *assets) report(doc *parser.Document, n *parser.Node, kind int, format string, args ...interface{}) {
+	p := assetProblem{doc.Name, doc.Position(n.Range.Start), fmt.Sprintf(format, args...), kind}
+	a.mu.Lock()
+	a.problems = append(a.problems, p)
+	a.mu.Unlock()
+}
+
+// check prints the problems found, in order, returning an error if there
+// were any
+func (a *assets) check() error {
+	if len(a.problems) == 0 {
+		return nil
+	}
+	sort.Slice(a.problems, func(i, j int) bool {
+		p, q := a.problems[i], a.problems[j]
+		if p.file != q.file {
+			return p.file < q.file
+		}
+		return p.pos.Offset < q.pos.Offset
+	})
+	var counts [4]int
+	for _, p := range a.problems {
+		kind := "broken link"
+		switch p.kind {
+		case brokenImage:
+			kind = "broken image"
+		case brokenAnchor:
+			kind = "broken anchor"
+		case outsideRef:
+			kind = "unpublished file"
+		}
+		logger.Error(fmt.Sprintf("%s:%v: %s: %s", p.file, p.pos, kind, p.msg), "file", p.file, "line", p.pos.Line, "kind", kind)
+		counts[p.kind]++
+	}
+	var found []string
+	if n := counts[brokenImage]; n > 0 {
+		found = append(found, plural(n, "broken image"))
+	}
+	if n := counts[brokenLink]; n > 0 {
+		found = append(found, plural(n, "link")+" to missing files")
+	}
+	if n := counts[brokenAnchor]; n > 0 {
+		found = append(found, plural(n, "link")+" to missing headings")
+	}
+	if n := counts[outsideRef]; n > 0 {
+		found = append(found, plural(n, "reference")+" to files outside the tree")
+	}
+	return errors.New(strings.Join(found, ", "))
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"./parser"
+)
+
+// AST dump formats, for -ast
+const (
+	astText = "text"
+	astJSON = "json"
+)
+
+// astPosition is a place in a document, as -ast json writes it
+type astPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Offset int `json:"offset"`
+}
+
+// astNode is a node as -ast json writes it, leaving out fields that are
+// unset
+type astNode struct {
+	Kind     string       `json:"kind"`
+	Start    *astPosition `json:"start,omitempty"` // Unset for nodes added after parsing
+	End      *astPosition `json:"end,omitempty"`
+	Level    int          `json:"level,omitempty"`
+	Ordered  bool         `json:"ordered,omitempty"`
+	Info     string       `json:"info,omitempty"`
+	Literal  string       `json:"literal,omitempty"`
+	Dest     string       `json:"dest,omitempty"`
+	Title    string       `json:"title,omitempty"`
+	ID       string       `json:"id,omitempty"`
+	Children []*astNode   `json:"children,omitempty"`
+}
+
+// dumpAST writes the syntax tree of doc, which must have been parsed
+// WithSourceRanges, to w in format
+func dumpAST(w io.Writer, doc *parser.Document, format string) error {
+	if format == astJSON {
+		b, err := json.MarshalIndent(struct {
+			Name string            `json:"name"`
+			Meta map[string]string `json:"meta,omitempty"`
+			Root *astNode          `json:"root"`
+		}{doc.Name, doc.Meta, astJSONNode(doc, doc.Root)}, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	}
+	var b strings.Builder
+	writeASTNode(&b, doc, doc.Root, 0)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// positioned reports whether n has a place in doc's source
+func positioned(doc *parser.Document, n *parser.Node) bool {
+	return n == doc.Root || n.Range != (parser.Range{})
+}
+
+// astJSONNode converts the tree under n, in doc, to its -ast json form
+func astJSONNode(doc *parser.Document, n *parser.Node) *astNode {
+	a := &astNode{
+		Kind:    n.Kind.String(),
+		Level:   n.Level,
+		Ordered: n.Ordered,
+		Info:    n.Info,
+		Literal: n.Literal,
+		Dest:    n.Dest,
+		Title:   n.Title,
+		ID:      n.ID,
+	}
+	if positioned(doc, n) {
+		start, end := doc.Position(n.Range.Start), doc.Position(n.Range.End)
+		a.Start = &astPosition{start.Line, start.Column, start.Offset}
+		a.End = &astPosition{end.Line, end.Column, end.Offset}
+	}
+	for _, c := range n.Children {
+		a.Children = append(a.Children, astJSONNode(doc, c))
+	}
+	return a
+}
+
+// writeASTNode writes the tree under n, in doc, to b as text, a node a
+// line indented by its depth: its kind, the fields set, & where it starts
+// and ends
+func writeASTNode(b *strings.Builder, doc *parser.Document, n *parser.Node, depth int) {
+	b.WriteString(strings.Repeat("  ", depth) + n.Kind.String())
+	if n.Level != 0 {
+		fmt.Fprintf(b, " level=%d", n.Level)
+	}
+	if n.Ordered {
+		b.WriteString(" ordered")
+	}
+	for _, f := range []struct{ name, value string }{
+		{"info", n.Info}, {"dest", n.Dest}, {"title", n.Title}, {"id", n.ID}, {"literal", n.Literal},
+	} {
+		if f.value != "" {
+			fmt.Fprintf(b, " %s=%q", f.name, f.value)
+		}
+	}
+	if positioned(doc, n) {
+		fmt.Fprintf(b, " %v-%v", doc.Position(n.Range.Start), doc.Position(n.Range.End))
+	}
+	b.WriteByte('\n')
+	for _, c := range n.Children {
+		writeASTNode(b, doc, c, depth+1)
+	}
+}
+
+// dumpTokens writes each of tokens to w on a line of its own: where it
+// starts, its kind, and its value
+func dumpTokens(w io.Writer, tokens []parser.Token) error {
+	var b strings.Builder
+	for _, t := range tokens {
+		fmt.Fprintf(&b, "%v\t%v\t%q\n", t.Pos, t.Kind, t.Val)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
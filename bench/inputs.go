@@ -0,0 +1,99 @@
+// Package bench generates the markdown documents the parser & renderers
+// are benchmarked against: typical, very large & pathological ones. The
+// benchmarks themselves are in the packages they measure:
+//
+//	go test -bench . -count 5 ./parser ./render > old.txt
+//	(make changes)
+//	go test -bench . -count 5 ./parser ./render > new.txt
+//	benchstat old.txt new.txt
+package bench
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Input is a named markdown document to benchmark against
+type Input struct {
+	Name string
+	Src  string
+}
+
+// readme builds a README sized document exercising the common syntax
+func readme() string {
+	var b strings.Builder
+	b.WriteString("# gomd\r\n\r\nA **markdown** parser & renderer written in *Go*.\r\n\r\n")
+	for i := 1; i <= 8; i++ {
+		fmt.Fprintf(&b, "## Section %d\r\n\r\n", i)
+		b.WriteString("Some prose with `inline code`, a [link](https://example.com \"title\") and an\r\n")
+		b.WriteString("image ![alt text](img.png). Lines are joined by soft breaks  \r\nor hard ones.\r\n\r\n")
+		b.WriteString("- first item\r\n- second item with *emphasis*\r\n- third item\r\n\r\n")
+		b.WriteString("> Quoted text that runs on\r\n> over two lines.\r\n\r\n")
+		b.WriteString("```go\r\nfunc main() {\r\n\tfmt.Println(\"hello\")\r\n}\r\n```\r\n\r\n")
+	}
+	return b.String()
+}
+
+// Inputs returns every benchmark input: typical documents, very large ones,
+// and the pathological cases that make naive parsers go quadratic
+func Inputs() []Input {
+	doc := readme()
+	ins := []Input{
+		{"readme", doc},
+		{"large-10MB", strings.Repeat(doc, 10<<20/len(doc)+1)},
+		{"prose-5MB", prose(5 << 20)},
+		{"emphasis-heavy", strings.Repeat("*a **b** c* _d __e__ f_ ", 2000) + "\r\n"},
+		{"nested-lists", nested("- ", 1000)},
+		{"nested-quotes", nested("> ", 1000)},
+	}
+	for _, n := range []int{1000, 10000} {
+		for _, pc := range Pathological {
+			ins = append(ins, Input{fmt.Sprintf("%s-%d", pc.Name, n), pc.Gen(n)})
+		}
+	}
+	return ins
+}
+
+// Pathological are the classic inputs that take naive parsers quadratic
+// time, each generated with n repetitions of its troublesome construct
+var Pathological = []struct {
+	Name string
+	Gen  func(n int) string
+}{
+	{"nested-brackets", func(n int) string { return strings.Repeat("[", n) + "a" + strings.Repeat("]", n) + "\r\n" }},
+	{"unclosed-brackets", func(n int) string { return strings.Repeat("[", n) + "a\r\n" }},
+	{"unclosed-images", func(n int) string { return strings.Repeat("![", n) + "a\r\n" }},
+	{"alternating-emphasis", func(n int) string { return strings.Repeat("*a **a ", n) + "\r\n" }},
+	{"unclosed-emphasis", func(n int) string { return strings.Repeat("a **", n) + "\r\n" }},
+	{"backtick-runs", func(n int) string { return backtickRuns(n) + "\r\n" }},
+	{"unclosed-autolinks", func(n int) string { return strings.Repeat("<a:", n) + "\r\n" }},
+	{"unclosed-destinations", func(n int) string { return strings.Repeat("[a](<", n) + "\r\n" }},
+}
+
+// prose returns n bytes or so of long paragraphs of plain text, where the
+// time goes on finding where each line ends
+func prose(n int) string {
+	line := strings.Repeat("Lorem ipsum dolor sit amet, consectetur adipiscing elit. ", 8) + "\r\n"
+	para := strings.Repeat(line, 6) + "\r\n"
+	return strings.Repeat(para, n/len(para)+1)
+}
+
+// nested returns lines each indented one level deeper with marker
+func nested(marker string, depth int) string {
+	var b strings.Builder
+	for i := 0; i < depth; i++ {
+		b.WriteString(strings.Repeat("  ", i) + marker + "item\r\n")
+	}
+	return b.String()
+}
+
+// backtickRuns returns "a`a“a```..." up to around n bytes, where no run
+// of backticks has a matching closing run, the case a backtick cache
+// exists to defuse
+func backtickRuns(n int) string {
+	var b strings.Builder
+	for i := 1; b.Len() < n; i++ {
+		b.WriteString("a" + strings.Repeat("`", i))
+	}
+	return b.String()
+}
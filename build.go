@@ -0,0 +1,327 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"./lint"
+	"./parser"
+	"./render"
+)
+
+// build converts every markdown file in a source tree into the same place
+// in an output tree, copying everything else across as it is
+func build(args []string) error {
+	fs := flag.NewFlagSet("build", flag.ExitOnError)
+	out := fs.String("o", "public", "directory to write the converted tree to")
+	to := fs.String("to", render.FormatHTML, "output format: "+formatNames())
+	jobs := jobsFlag(fs)
+	configFile := fs.String("config", "", "read the front matter schema from this file rather than the nearest "+configName)
+	base := fs.String("base", "", "URL the output is served from, such as https://example.com/docs/, to write a "+sitemapName+" of the converted files")
+	footnotes := fs.Bool("footnotes", false, "read ^[inline notes], numbering them & gathering them at the end")
+	critic := fs.String("critic", "", "read CriticMarkup edits, and "+render.CriticShow+", "+render.CriticAccept+" or "+render.CriticReject+" them")
+	external := fs.Bool("external-assets", false, "copy images referred to from outside dir into the output")
+	incremental := fs.Bool("incremental", false, "only convert & copy the files git says changed since the last build into the output")
+	var filterPaths, pluginPaths listFlag
+	fs.Var(&pluginPaths, "plugin", "rewrite each document with this plugin `program` as it's parsed; may be repeated")
+	scripts := fs.Bool("scripts", false, "run the scripts in the \"scripts\" list of the config as plugins, which it never does otherwise")
+	fs.Var(&filterPaths, "filter", "run each document through this Pandoc `filter` before writing it; may be repeated")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s build [-o dir] [-to format] [-base url] [-config file] [-filter file ...] [-plugin program ...] [-scripts]\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "           [-footnotes] [-critic mode] [-external-assets] [-incremental] [-j n] dir or zip")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Converts each markdown file under dir, copying other files as they are")
+		fmt.Fprintln(os.Stderr, "and rewriting links between markdown files to point at the output.")
+		fmt.Fprintln(os.Stderr, "With -external-assets, images referred to from outside dir are copied into")
+		fmt.Fprintf(os.Stderr, "%s in the output. Other references to files outside dir, and hidden\n", assetsDir)
+		fmt.Fprintln(os.Stderr, "files such as .env, are never copied but reported, as are images & links")
+		fmt.Fprintln(os.Stderr, "to files that don't exist, and links to #fragments of other markdown files")
+		fmt.Fprintln(os.Stderr, "that no heading has the anchor of, each kind apart.")
+		fmt.Fprintln(os.Stderr, "A zip archive is read as the directory it holds. A line of {{path}} includes")
+		fmt.Fprintln(os.Stderr, "the file at path, from the directory of the one including it, in its place.")
+		fmt.Fprintf(os.Stderr, "A table of contents replaces the %s and %s markers of each file.\n", tocStart, tocStop)
+		fmt.Fprintln(os.Stderr, "Nothing is converted if the front matter of a file doesn't match the schema")
+		fmt.Fprintf(os.Stderr, "in %s; see %s lint -h.\n", configName, os.Args[0])
+		fmt.Fprintf(os.Stderr, "With -base a %s of the converted files is written to the output too,\n", sitemapName)
+		fmt.Fprintln(os.Stderr, "dated when each was last modified.")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Pandoc filters given with -filter rewrite each document, in turn, as")
+		fmt.Fprintln(os.Stderr, "Pandoc's JSON syntax tree, before it's written. Lua filters are run with")
+		fmt.Fprintln(os.Stderr, "pandoc, Python ones with python3 and any others as programs themselves.")
+		fmt.Fprintln(os.Stderr, "Plugins given with -plugin rewrite each document as it's parsed, before")
+		fmt.Fprintf(os.Stderr, "links are checked; see %s -h. With -scripts, so do the scripts in the\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\"scripts\" list of %s, relative to it, first.\n", configName)
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "When dir is in a git repository, %s in the output records the commit\n", manifestName)
+		fmt.Fprintln(os.Stderr, "it was built from. With -incremental, only the files changed since that")
+		fmt.Fprintln(os.Stderr, "commit, committed or not, are converted or copied again, and the output of")
+		fmt.Fprintln(os.Stderr, "those deleted is removed; everything is if there's no earlier build, or it")
+		fmt.Fprintln(os.Stderr, "was built to another format or with other filters. Links in the files")
+		fmt.Fprintln(os.Stderr, "left alone aren't checked again.")
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+	}
+	args = parseInterspersed(fs, args)
+	if len(args) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	criticExts, ropts, err := criticOptions(*critic)
+	if err != nil {
+		return err
+	}
+	r, err := render.NewRenderer(*to, ropts...)
+	if err != nil {
+		return err
+	}
+	ext := formatExts[*to]
+	dest := filepath.Clean(*out)
+	if filepath.Clean(args[0]) == dest {
+		return usagef("output would overwrite the input")
+	}
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+	t, err := openTree(args[0])
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+	src := t.dir
+
+	manifest := &buildManifest{Format: *to, Filters: filterPaths}
+	if t.fsys == nil {
+		manifest.Commit = gitHead(src)
+	}
+	var changed map[string]bool // Files to build, or nil for all of them
+	if *incremental && t.fsys != nil {
+		return usagef("-incremental needs a directory, not an archive")
+	}
+	if *incremental {
+		if changed, err = changedSince(src, dest, manifest); err != nil {
+			return err
+		}
+	}
+	stale := func(path, target string) bool {
+		if changed == nil || changed[path] {
+			return true
+		}
+		_, err := os.Stat(target)
+		return err != nil
+	}
+
+	var docs, convert []string
+	copied := 0
+	destOf := make(map[string]string)
+	modified := make(map[string]string) // Dates for the sitemap
+	err = t.walk(func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dest && t.fsys == nil || path != src && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() { // Don't convert our own output, or .git & co
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, _ := filepath.Rel(src, path)
+		target := filepath.Join(dest, rel)
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, 0755)
+		case isMarkdown(path):
+			docs = append(docs, path)
+			destOf[path] = render.ReplaceExt(ext)(target)
+			modified[path] = info.ModTime().Format("2006-01-02")
+			if stale(path, destOf[path]) {
+				convert = append(convert, path)
+			}
+			return nil
+		case info.Mode().IsRegular() && stale(path, target):
+			copied++
+			return t.copyFile(path, target)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if changed != nil {
+		err := removeDeleted(src, changed, func(path string) string {
+			rel, _ := filepath.Rel(src, path)
+			if isMarkdown(path) {
+				return render.ReplaceExt(ext)(filepath.Join(dest, rel))
+			}
+			return filepath.Join(dest, rel)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if err := checkFrontMatter(t, cfg.FrontMatter, convert); err != nil {
+		return err
+	}
+	scriptPaths, err := configScripts(cfg, *scripts)
+	if err != nil {
+		return err
+	}
+	plugins, err := startPlugins(append(scriptPaths, pluginPaths...), 0)
+	if err != nil {
+		return err
+	}
+	defer closePlugins(plugins)
+	dests := func(path string) string { return destOf[path] }
+	a := newAssets(t, dest, dests, *external)
+	f := newFilters(filterPaths, *to)
+	exts := append(pluginExtensions(plugins), tocMarkers, a, linkRewriter(render.ReplaceExt(ext)), f)
+	if *footnotes {
+		exts = append(exts, parser.InlineFootnotes)
+	}
+	exts = append(exts, criticExts...)
+	b := &render.Batch{
+		Parser:   parser.New(parser.WithSourceRanges(), parser.WithHeadingIDs(), parser.WithExtensions(withDefaults(exts...)...)),
+		Renderer: r,
+		Dest:     dests,
+		FS:       t.fsys,
+		Workers:  *jobs,
+	}
+	if err := b.Convert(convert); err != nil {
+		return err
+	}
+	if err := closePlugins(plugins); err != nil {
+		return err
+	}
+	if err := a.check(); err != nil {
+		return err
+	}
+	if err := f.check(); err != nil {
+		return err
+	}
+	if *base != "" {
+		var urls []*sitemapURL
+		for _, doc := range docs {
+			rel, _ := filepath.Rel(dest, destOf[doc])
+			urls = append(urls, newSitemapURL(*base, filepath.ToSlash(rel), modified[doc]))
+		}
+		if err := writeSitemap(dest, urls); err != nil {
+			return err
+		}
+	}
+	if manifest.Commit != "" {
+		if err := writeManifest(dest, manifest); err != nil {
+			return err
+		}
+	}
+	logger.Info(fmt.Sprintf("converted %s and copied %s to %s", plural(len(convert), "file"), plural(copied+len(a.copied), "other file"), dest),
+		"converted", len(convert), "copied", copied+len(a.copied), "dest", dest)
+	return nil
+}
+
+// checkFrontMatter reports the files of t whose front matter doesn't match
+// schema, if there is one
+func checkFrontMatter(t *tree, schema *lint.Schema, files []string) error {
+	if schema == nil {
+		return nil
+	}
+	l := lint.New(lint.FrontMatterSchema{Schema: schema})
+	problems := 0
+	for _, path := range files {
+		src, err := t.readFile(path)
+		if err != nil {
+			return err
+		}
+		diags, err := l.Lint(path, string(src))
+		if err != nil {
+			return err
+		}
+		for _, d := range diags {
+			logger.Error(d.String(), "file", d.File, "line", d.Pos.Line, "rule", d.Rule)
+		}
+		problems += len(diags)
+	}
+	if problems > 0 {
+		return fmt.Errorf("%s with front matter", plural(problems, "problem"))
+	}
+	return nil
+}
+
+// withDefaults returns the default extensions followed by exts
+func withDefaults(exts ...parser.Extension) []parser.Extension {
+	return append(append([]parser.Extension{}, parser.DefaultExtensions...), exts...)
+}
+
+// parseInterspersed parses args with fs, allowing flags to come after the
+// other arguments, and returns the other arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var rest []string
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return rest
+		}
+		if args[0] == "--" {
+			return append(rest, args[1:]...)
+		}
+		rest = append(rest, args[0])
+		args = args[1:]
+	}
+}
+
+// isMarkdown reports whether path has an extension markdown files use
+func isMarkdown(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown", ".mdown", ".mkd":
+		return true
+	}
+	return false
+}
+
+// linkRewriter returns an extension pointing links to markdown files on
+// the same site at the converted files, the paths of which converted
+// returns given the paths of the markdown files
+func linkRewriter(converted func(string) string) parser.Extension {
+	return parser.ExtensionFunc(func(p *parser.Parser) {
+		p.AddTransformer(parser.TransformerFunc(func(doc *parser.Document) {
+			rewriteLinks(doc.Root, converted)
+		}))
+	})
+}
+
+// rewriteLinks points the links to markdown files in n and its children at
+// the converted files instead
+func rewriteLinks(n *parser.Node, converted func(string) string) {
+	if n.Kind == parser.NodeLink {
+		n.Dest = localLink(n.Dest, converted)
+	}
+	for _, c := range n.Children {
+		rewriteLinks(c, converted)
+	}
+}
+
+// localLink points dest at the converted file if it links to a markdown
+// file on the same site, keeping any query & fragment
+func localLink(dest string, converted func(string) string) string {
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return dest
+	}
+	path := dest
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	if !isMarkdown(path) {
+		return dest
+	}
+	return converted(path) + dest[len(path):]
+}
+
+// copyFile copies the file src to dest
+func copyFile(src, dest string) error {
+	return osFiles.copyFile(src, dest)
+}
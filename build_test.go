@@ -0,0 +1,93 @@
+package main
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"docs/index.md":     "# Index\n\n![logo](img/logo.png) [page](sub/page.md)\n",
+		"docs/sub/page.md":  "# Page\n",
+		"docs/img/logo.png": "png",
+		"docs/.git/config":  "[core]",
+	})
+	r := gomd(t, dir, "", "build", "-o", "out", "-base", "https://example.com/docs/", "docs")
+	if r.code != 0 {
+		t.Fatalf("exit %d: %s", r.code, r.stderr)
+	}
+	var built []string
+	filepath.Walk(filepath.Join(dir, "out"), func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			rel, _ := filepath.Rel(filepath.Join(dir, "out"), path)
+			built = append(built, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if got, want := strings.Join(built, " "), "img/logo.png index.html sitemap.xml sub/page.html"; got != want {
+		t.Errorf("built %s, want %s", got, want)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "out/index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `<a href="sub/page.html">`) || !strings.Contains(string(b), `src="img/logo.png"`) {
+		t.Errorf("index.html doesn't link the converted page & copied image:\n%s", b)
+	}
+	b, err = ioutil.ReadFile(filepath.Join(dir, "out/sitemap.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "<loc>https://example.com/docs/sub/page.html</loc>") {
+		t.Errorf("sitemap without the page:\n%s", b)
+	}
+
+	if r := gomd(t, dir, "", "build", "-o", "out", "missing"); r.code != exitIO {
+		t.Errorf("exit %d building a missing directory, want %d", r.code, exitIO)
+	}
+}
+
+// Files a document includes are read from the tree it's in, whether a
+// directory or a zip archive
+func TestBuildIncludes(t *testing.T) {
+	files := map[string]string{
+		"docs/index.md":       "# Index\n\n{{parts/intro.md}}\n",
+		"docs/parts/intro.md": "Included text.\n",
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, files)
+	f, err := os.Create(filepath.Join(dir, "docs.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	z := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := z.Create(strings.TrimPrefix(name, "docs/"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	for _, src := range []string{"docs", "docs.zip"} {
+		out := "out-" + strings.TrimSuffix(src, ".zip")
+		if r := gomd(t, dir, "", "build", "-o", out, src); r.code != 0 {
+			t.Fatalf("build %s: exit %d: %s", src, r.code, r.stderr)
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, out, "index.html"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), "<p>Included text.</p>") {
+			t.Errorf("%s built\n%s\nwithout what it includes", src, b)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+/*
+ * gomd.h - C interface to the gomd markdown converter
+ *
+ * Build the shared library with
+ *
+ *     go build -buildmode=c-shared -o libgomd.so ./capi
+ *
+ * and include this header, rather than the libgomd.h go build writes
+ * alongside it, which lacks the const qualifiers & documentation.
+ *
+ * The ABI is stable: functions are never removed or changed, and fields are
+ * only ever added to the end of gomd_options. Callers set its size field to
+ * sizeof(gomd_options) as they compiled it, and fields beyond that size are
+ * taken to be zero, so programs built against an older header keep working
+ * with a newer library.
+ */
+#ifndef GOMD_H
+#define GOMD_H
+
+#include <stddef.h>
+#include <stdint.h>
+
+/* Flags for gomd_options.flags */
+#define GOMD_PLAIN  (1u << 0) /* Turn off the default extensions */
+#define GOMD_MINIFY (1u << 1) /* Drop whitespace between HTML tags */
+
+/* gomd_options configures a conversion. A zeroed struct, with size set,
+ * converts to HTML with the default settings */
+typedef struct gomd_options {
+	size_t size;              /* sizeof(gomd_options) */
+	const char *format;       /* One of those gomd_formats lists; html if NULL */
+	uint32_t flags;           /* GOMD_ flags, or'd together */
+	int32_t max_nesting;      /* How deeply inline elements may nest, the default if 0 */
+	const char *class_prefix; /* Prepended to every class, none if NULL */
+	const char *line_ending;  /* "\n" or "\r\n" for every line of output, the renderer's own if NULL */
+} gomd_options;
+
+#ifndef GOMD_INTERNAL
+
+/* gomd_convert renders the len bytes of markdown as opts ask, which may be
+ * NULL for the defaults. It returns the NUL-terminated output, to be
+ * released with gomd_free. On failure it returns NULL and, if err isn't
+ * NULL, points *err at a message that must also be freed with gomd_free.
+ * It's safe to call from several threads at once */
+char *gomd_convert(const char *markdown, size_t len, const gomd_options *opts, char **err);
+
+/* gomd_formats returns the formats gomd_options.format can name, such as
+ * html, separated by commas, as a NUL-terminated string to be released
+ * with gomd_free */
+char *gomd_formats(void);
+
+/* gomd_free releases a string returned by gomd_convert or gomd_formats.
+ * NULL is ignored */
+void gomd_free(char *p);
+
+#endif
+
+#endif
@@ -0,0 +1,106 @@
+// Command capi exports the converter as a C library, for applications
+// written in other languages to embed. Build it with
+//
+//	go build -buildmode=c-shared -o libgomd.so ./capi
+//
+// gomd.h documents the functions & their stable ABI
+package main
+
+/*
+#include <stdlib.h>
+#include <string.h>
+#define GOMD_INTERNAL
+#include "gomd.h"
+*/
+import "C"
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unsafe"
+
+	"../parser"
+	"../render"
+)
+
+func main() {} // Required by -buildmode=c-shared, never run
+
+//export gomd_convert
+func gomd_convert(markdown *C.char, length C.size_t, opts *C.gomd_options, errOut **C.char) *C.char {
+	out, err := convert(C.GoStringN(markdown, C.int(length)), readOptions(opts))
+	if err != nil {
+		if errOut != nil {
+			*errOut = C.CString(err.Error())
+		}
+		return nil
+	}
+	return C.CString(out)
+}
+
+//export gomd_formats
+func gomd_formats() *C.char {
+	return C.CString(strings.Join(render.Formats(), ","))
+}
+
+//export gomd_free
+func gomd_free(p *C.char) {
+	C.free(unsafe.Pointer(p))
+}
+
+// readOptions copies the caller's options, leaving the fields past the
+// size they were compiled with zero. nil gives all zeroes
+func readOptions(opts *C.gomd_options) C.gomd_options {
+	var o C.gomd_options
+	if opts == nil {
+		return o
+	}
+	n := opts.size
+	if n > C.sizeof_gomd_options {
+		n = C.sizeof_gomd_options
+	}
+	C.memcpy(unsafe.Pointer(&o), unsafe.Pointer(opts), n)
+	return o
+}
+
+// convert renders markdown as opts ask
+func convert(markdown string, opts C.gomd_options) (string, error) {
+	format := render.FormatHTML
+	if opts.format != nil {
+		format = C.GoString(opts.format)
+	}
+	var popts []parser.Option
+	if opts.flags&C.GOMD_PLAIN != 0 {
+		popts = append(popts, parser.WithExtensions())
+	}
+	if opts.max_nesting > 0 {
+		popts = append(popts, parser.WithMaxNesting(int(opts.max_nesting)))
+	}
+	var ropts []render.Option
+	if opts.flags&C.GOMD_MINIFY != 0 {
+		ropts = append(ropts, render.WithMinify())
+	}
+	if opts.class_prefix != nil {
+		ropts = append(ropts, render.WithClassPrefix(C.GoString(opts.class_prefix)))
+	}
+	if opts.line_ending != nil {
+		switch le := parser.LineEnding(C.GoString(opts.line_ending)); le {
+		case parser.LF, parser.CRLF:
+			ropts = append(ropts, render.WithLineEnding(le))
+		default:
+			return "", fmt.Errorf("unknown line ending %q", le)
+		}
+	}
+
+	r, err := render.NewRenderer(format, ropts...)
+	if err != nil {
+		return "", err
+	}
+	doc, err := parser.New(popts...).Parse("input", markdown)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	err = render.RenderDocument(r, &out, doc)
+	return out.String(), err
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"./parser"
+	"./render"
+)
+
+// changelogName is the changelog release notes are taken from by default
+const changelogName = "CHANGELOG.md"
+
+// release is the section of a changelog about one version
+type release struct {
+	version string
+	nodes   []*parser.Node // The heading & what's under it
+}
+
+// changelog writes the sections of a changelog about a range of versions as
+// a page of release notes
+func changelog(args []string) error {
+	fs := flag.NewFlagSet("changelog", flag.ExitOnError)
+	out := fs.String("o", stdinName, "write the page to this file, or - for standard output")
+	title := fs.String("title", "", "title the page this rather than after the versions")
+	theme := fs.String("theme", render.ThemeGitHub, "theme to style the page with: "+themeNames())
+	css := fs.String("css", "", "link to this stylesheet rather than using a theme")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s changelog [-o file] [-title text] [-theme name] [-css url] range [file]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Writes the sections of file, %s by default, about the versions in\n", changelogName)
+		fmt.Fprintln(os.Stderr, "range as a page of release notes. Each version's section starts at a")
+		fmt.Fprintln(os.Stderr, "second level heading naming it first, such as \"## [1.2.0] - 2024-05-01\",")
+		fmt.Fprintln(os.Stderr, "newest first, as Keep a Changelog has them. The range is a version, or")
+		fmt.Fprintln(os.Stderr, "old..new for the versions after old up to new, either of which may be")
+		fmt.Fprintln(os.Stderr, "left out for the oldest or newest. A leading v is ignored.")
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+	}
+	args = parseInterspersed(fs, args)
+	if len(args) < 1 || len(args) > 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	path := changelogName
+	if len(args) == 2 {
+		path = args[1]
+	}
+	r, err := newPageRenderer(*theme, *css)
+	if err != nil {
+		return err
+	}
+	name, src, err := readSource(path)
+	if err != nil {
+		return err
+	}
+	doc, err := parser.New(parser.WithHeadingIDs()).Parse(name, src)
+	if err != nil {
+		return err
+	}
+	releases, err := releasesIn(releaseSections(doc), args[0])
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	notes := &parser.Document{Name: name, Meta: map[string]string{"title": *title}, Root: parser.NewNode(parser.NodeDocument)}
+	if *title == "" {
+		notes.Meta["title"] = "Release notes for " + releases[len(releases)-1].version
+		if len(releases) > 1 {
+			notes.Meta["title"] += " to " + releases[0].version
+		}
+	}
+	for k, v := range doc.Meta {
+		if k != "title" {
+			notes.Meta[k] = v
+		}
+	}
+	for _, rel := range releases {
+		for _, n := range rel.nodes {
+			notes.Root.AppendChild(n)
+		}
+	}
+	var b bytes.Buffer
+	if err := r.RenderDocument(&b, notes); err != nil {
+		return err
+	}
+	if *out == stdinName {
+		_, err = os.Stdout.Write(b.Bytes())
+		return err
+	}
+	return ioutil.WriteFile(*out, b.Bytes(), 0644)
+}
+
+// releaseSections returns the section of doc about each version, in order
+func releaseSections(doc *parser.Document) []*release {
+	var releases []*release
+	var cur *release
+	for _, n := range doc.Root.Children {
+		if n.Kind == parser.NodeHeading && n.Level <= 2 {
+			cur = nil
+			if n.Level == 2 {
+				cur = &release{version: releaseVersion(n.PlainText())}
+				releases = append(releases, cur)
+			}
+		}
+		if cur != nil {
+			cur.nodes = append(cur.nodes, n)
+		}
+	}
+	return releases
+}
+
+// releaseVersion returns the version a changelog heading names, the first
+// word of it, without brackets or a leading v
+func releaseVersion(heading string) string {
+	f := strings.Fields(strings.NewReplacer("[", " ", "]", " ").Replace(heading))
+	if len(f) == 0 {
+		return ""
+	}
+	return trimV(f[0])
+}
+
+// trimV returns version without a leading v
+func trimV(version string) string {
+	if len(version) > 1 && (version[0] == 'v' || version[0] == 'V') {
+		return version[1:]
+	}
+	return version
+}
+
+// releasesIn returns the releases, newest first, in the range spec: a
+// version, or old..new for those after old up to & including new
+func releasesIn(releases []*release, spec string) ([]*release, error) {
+	find := func(version string) (int, error) {
+		for i, r := range releases {
+			if strings.EqualFold(r.version, trimV(version)) {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("no section for version %s", version)
+	}
+	older, newer, isRange := strings.Cut(spec, "..")
+	if !isRange {
+		i, err := find(spec)
+		if err != nil {
+			return nil, err
+		}
+		return releases[i : i+1], nil
+	}
+	from, to := 0, len(releases)
+	var err error
+	if newer != "" {
+		if from, err = find(newer); err != nil {
+			return nil, err
+		}
+	}
+	if older != "" {
+		if to, err = find(older); err != nil {
+			return nil, err
+		}
+	}
+	if from >= to {
+		return nil, fmt.Errorf("no versions after %s up to %s", older, newer)
+	}
+	return releases[from:to], nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"./lint"
+)
+
+// configName is the file settings are read from, looked for in the
+// current directory and then each one above it
+const configName = ".gomd.json"
+
+// config is the settings in a configName file
+type config struct {
+	Lint  lint.Config `json:"lint"` // Rules to check & their parameters
+	Links struct {
+		Ignore []string `json:"ignore"` // Patterns of URLs links -http doesn't request
+	} `json:"links"`
+	FrontMatter *lint.Schema `json:"frontMatter"` // What each document's front matter must have
+	Scripts     []string     `json:"scripts"`     // Plugins rewriting each document, relative to the file, run only with -scripts
+
+	path string // Where it was read from, or "" if there was no file
+}
+
+// loadConfig reads the config file at path, or if path is "" the nearest
+// configName, returning empty settings if there is none
+func loadConfig(path string) (*config, error) {
+	if path == "" {
+		path = findConfig()
+		if path == "" {
+			return &config{}, nil
+		}
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c config
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if c.FrontMatter != nil {
+		if err := c.FrontMatter.Check(); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	c.path = path
+	for i, script := range c.Scripts {
+		if !filepath.IsAbs(script) {
+			c.Scripts[i] = filepath.Join(filepath.Dir(path), script)
+		}
+	}
+	return &c, nil
+}
+
+// findConfig returns the path of the nearest configName, or "" if there is
+// none
+func findConfig() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, configName)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// configScripts returns the scripts of cfg to run as plugins: none unless
+// trusted, as -scripts says, since the nearest config may be in a tree
+// someone else wrote, such as a cloned repository
+func configScripts(cfg *config, trusted bool) ([]string, error) {
+	if !trusted {
+		if len(cfg.Scripts) > 0 {
+			logger.Warn(fmt.Sprintf("%s: not running its %s without -scripts", cfg.path, plural(len(cfg.Scripts), "script")), "config", cfg.path)
+		}
+		return nil, nil
+	}
+	for _, script := range cfg.Scripts {
+		if _, ok := interpreters[strings.ToLower(filepath.Ext(script))]; !ok {
+			return nil, fmt.Errorf("%s: script %s isn't %s", cfg.path, script, scriptExts())
+		}
+	}
+	return cfg.Scripts, nil
+}
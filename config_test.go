@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigScriptsNeedTrust(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "ran")
+	writeFiles(t, dir, map[string]string{
+		configName:       `{"scripts": ["plugin.py"]}`,
+		"plugin.py":      "open(" + pyString(marker) + ", 'w').close()\n",
+		"sub/sub/doc.md": "# hi\n",
+	})
+	r := gomd(t, filepath.Join(dir, "sub", "sub"), "# hi\n", "-to", "html")
+	if r.code != 0 {
+		t.Fatalf("exit %d: %s", r.code, r.stderr)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("the config's script ran without -scripts")
+	}
+	if !strings.Contains(r.stderr, "-scripts") {
+		t.Errorf("no warning that the script wasn't run, got %q", r.stderr)
+	}
+}
+
+func TestConfigScriptsOnlyScripts(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{configName: `{"scripts": ["program"]}`})
+	cfg, err := loadConfig(filepath.Join(dir, configName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := configScripts(cfg, true); err == nil {
+		t.Error("a program that isn't a script was accepted")
+	}
+	cfg.Scripts = []string{filepath.Join(dir, "plugin.lua")}
+	if scripts, err := configScripts(cfg, true); err != nil || len(scripts) != 1 {
+		t.Errorf("got %q, %v, want the script", scripts, err)
+	}
+	if scripts, _ := configScripts(cfg, false); scripts != nil {
+		t.Errorf("got %q without trust, want none", scripts)
+	}
+}
+
+// pyString quotes s as a Python string
+func pyString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"./parser"
+	"./render"
+)
+
+// convertPath is where the daemon converts markdown
+const convertPath = "/convert"
+
+// convertRequest is a conversion asked for as JSON
+type convertRequest struct {
+	Markdown   string `json:"markdown"`
+	Format     string `json:"format"`     // html by default
+	Standalone bool   `json:"standalone"` // A complete HTML page rather than a fragment
+	Theme      string `json:"theme"`      // Of the page, github by default
+	HeadingIDs bool   `json:"headingIDs"` // Give headings anchors
+}
+
+// convertResponse answers a convertRequest
+type convertResponse struct {
+	Output   string          `json:"output,omitempty"`
+	Document json.RawMessage `json:"document,omitempty"` // The syntax tree, for the json format
+	Error    string          `json:"error,omitempty"`
+}
+
+// daemonFormats are the formats the daemon converts to, and the types of
+// their output. Neither PDF, needing an engine to run, nor ANSI, for
+// terminals, are
+var daemonFormats = map[string]string{
+	render.FormatHTML:     "text/html; charset=utf-8",
+	render.FormatMarkdown: "text/markdown; charset=utf-8",
+	render.FormatText:     "text/plain; charset=utf-8",
+	render.FormatLaTeX:    "application/x-latex; charset=utf-8",
+	render.FormatMan:      "text/troff; charset=utf-8",
+	render.FormatJSON:     "application/json",
+	render.FormatSlides:   "text/html; charset=utf-8",
+	render.FormatDocBook:  "application/docbook+xml; charset=utf-8",
+	render.FormatJira:     "text/plain; charset=utf-8",
+	render.FormatAsciiDoc: "text/asciidoc; charset=utf-8",
+	render.FormatBBCode:   "text/plain; charset=utf-8",
+	render.FormatPandoc:   "application/json",
+}
+
+// converter converts the markdown posted to it. It lives as long as the
+// daemon, keeping its parsers, the plugins they run & what it converted
+// warm between requests
+type converter struct {
+	maxSize  int64
+	parser   *parser.Parser
+	anchored *parser.Parser // With heading IDs
+	cache    *outputCache
+}
+
+// outputCache holds the output of the last conversions, so markdown
+// converted again, as editors & build farms do, isn't parsed again
+type outputCache struct {
+	size int
+	mu   sync.Mutex
+	out  map[[sha256.Size]byte][]byte
+	keys [][sha256.Size]byte // Oldest first
+}
+
+// key identifies the output req asks for
+func (c *outputCache) key(req *convertRequest) [sha256.Size]byte {
+	b, _ := json.Marshal(req)
+	return sha256.Sum256(b)
+}
+
+// get returns the output cached under key, if any
+func (c *outputCache) get(key [sha256.Size]byte) ([]byte, bool) {
+	if c.size == 0 {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out, ok := c.out[key]
+	if ok {
+		serverMetrics.cacheHits.Add(1)
+	} else {
+		serverMetrics.cacheMisses.Add(1)
+	}
+	return out, ok
+}
+
+// put caches out under key, forgetting the oldest output if it's full
+func (c *outputCache) put(key [sha256.Size]byte, out []byte) {
+	if c.size == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.out[key]; ok {
+		return
+	}
+	if len(c.keys) == c.size {
+		delete(c.out, c.keys[0])
+		c.keys = c.keys[1:]
+	}
+	c.out[key] = out
+	c.keys = append(c.keys, key)
+}
+
+// daemon converts markdown over HTTP & gRPC, for services in other
+// languages
+func daemon(args []string) error {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8088", "address to listen on")
+	maxSize := fs.Int64("max-size", 1<<20, "largest request body to accept, in bytes")
+	timeout := fs.Duration("timeout", 10*time.Second, "longest to take reading a request or converting it")
+	socket := fs.String("socket", "", "listen on the unix socket at this `path` rather than -addr")
+	cacheSize := fs.Int("cache", 256, "conversions to keep the output of, to answer again without converting")
+	withMetrics := fs.Bool("metrics", false, "serve Prometheus metrics at "+metricsPath)
+	var pluginPaths listFlag
+	fs.Var(&pluginPaths, "plugin", "rewrite each document with this plugin `program` as it's parsed; may be repeated")
+	scripts := fs.Bool("scripts", false, "run the scripts in the \"scripts\" list of the nearest "+configName+" as plugins, which it never does otherwise")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s daemon [-addr host:port | -socket path] [-max-size bytes] [-timeout d] [-cache n] [-plugin program] [-scripts] [-metrics]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Converts the markdown POSTed to %s. A JSON body, such as\n", convertPath)
+		fmt.Fprintln(os.Stderr, `{"markdown": "# Hi", "format": "html", "standalone": false, "theme": "github",`)
+		fmt.Fprintln(os.Stderr, `"headingIDs": false}, is answered with {"output": "..."}, or for the json`)
+		fmt.Fprintln(os.Stderr, `format {"document": {...}}, and {"error": "..."} if it fails. Any other`)
+		fmt.Fprintln(os.Stderr, "body is the markdown itself, answered with the output, with the options")
+		fmt.Fprintln(os.Stderr, "given as query parameters such as ?format=html&standalone=true.")
+		fmt.Fprintf(os.Stderr, "Formats are those of -to but %s and %s. %s answers ok while the\n", render.FormatPDF, render.FormatANSI, healthPath)
+		fmt.Fprintln(os.Stderr, "daemon is up. Larger bodies are refused with 413, and slower requests with 503.")
+		fmt.Fprintln(os.Stderr, "-plugin programs, & with -scripts those in .gomd.json, are started once and run")
+		fmt.Fprintln(os.Stderr, "on each document. On a socket, for editors & build farms, ask with")
+		fmt.Fprintln(os.Stderr, "curl --unix-socket path http://gomd/convert.")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "The gomd.Converter gRPC service of gomd.proto converts the same way, over")
+		fmt.Fprintln(os.Stderr, "HTTP/2 without TLS on the same address or socket. A plugin taking longer")
+		fmt.Fprintln(os.Stderr, "than -timeout over a document is killed and started again.")
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+	}
+	args = parseInterspersed(fs, args)
+	if len(args) != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *cacheSize < 0 {
+		return usagef("-cache must be at least 0")
+	}
+	cfg, err := loadConfig("")
+	if err != nil {
+		return err
+	}
+	scriptPaths, err := configScripts(cfg, *scripts)
+	if err != nil {
+		return err
+	}
+	plugins, err := startPlugins(append(scriptPaths, pluginPaths...), *timeout)
+	if err != nil {
+		return err
+	}
+	defer closePlugins(plugins)
+	exts := withDefaults(pluginExtensions(plugins)...)
+	c := &converter{
+		maxSize:  *maxSize,
+		parser:   parser.New(parser.WithMaxInputSize(int(*maxSize)), parser.WithExtensions(exts...)),
+		anchored: parser.New(parser.WithMaxInputSize(int(*maxSize)), parser.WithHeadingIDs(), parser.WithExtensions(exts...)),
+		cache:    &outputCache{size: *cacheSize, out: map[[sha256.Size]byte][]byte{}},
+	}
+	http.Handle(convertPath, c)
+	http.Handle(grpcConvertPath, grpcConverter{c})
+	handleService(*withMetrics)
+	srv := &http.Server{
+		Handler:           instrument(http.TimeoutHandler(http.DefaultServeMux, *timeout, "conversion timed out\n")),
+		ReadHeaderTimeout: *timeout,
+		ReadTimeout:       *timeout,
+		WriteTimeout:      2 * *timeout,
+		Protocols:         new(http.Protocols),
+	}
+	srv.Protocols.SetHTTP1(true)
+	srv.Protocols.SetUnencryptedHTTP2(true) // For gRPC
+	l, err := daemonListener(*addr, *socket)
+	if err != nil {
+		return err
+	}
+	// Stopped, close the plugins & remove the socket rather than leave them
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-stop
+		srv.Close()
+	}()
+	if *socket != "" {
+		logger.Info(fmt.Sprintf("converting at %s on %s", convertPath, *socket), "socket", *socket)
+	} else {
+		logger.Info(fmt.Sprintf("converting at http://%s%s", *addr, convertPath), "addr", *addr)
+	}
+	if err := srv.Serve(l); err != http.ErrServerClosed {
+		return err
+	}
+	return closePlugins(plugins)
+}
+
+// daemonListener listens on the unix socket at socket, replacing one a
+// daemon that's gone left behind, or if it's "" at addr
+func daemonListener(addr, socket string) (net.Listener, error) {
+	if socket == "" {
+		return net.Listen("tcp", addr)
+	}
+	if fi, err := os.Lstat(socket); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s: path exists and is not a socket", socket)
+		}
+		if conn, err := net.Dial("unix", socket); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s: a daemon is already listening", socket)
+		}
+		if err := os.Remove(socket); err != nil {
+			return nil, err
+		}
+	}
+	// Only for whoever started it, as anyone connecting runs its plugins
+	return listenUnix(socket)
+}
+
+func (c *converter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	asJSON := false
+	if t, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil && t == "application/json" {
+		asJSON = true
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		c.fail(w, asJSON, http.StatusMethodNotAllowed, errors.New("convert with POST"))
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, c.maxSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.fail(w, asJSON, http.StatusRequestEntityTooLarge, fmt.Errorf("body is over %d bytes", c.maxSize))
+			return
+		}
+		c.fail(w, asJSON, http.StatusBadRequest, err)
+		return
+	}
+	var req convertRequest
+	if asJSON {
+		if err := json.Unmarshal(body, &req); err != nil {
+			c.fail(w, asJSON, http.StatusBadRequest, err)
+			return
+		}
+	} else {
+		q := r.URL.Query()
+		req = convertRequest{Markdown: string(body), Format: q.Get("format"), Theme: q.Get("theme")}
+		req.Standalone, _ = strconv.ParseBool(q.Get("standalone"))
+		req.HeadingIDs, _ = strconv.ParseBool(q.Get("headingIDs"))
+	}
+	out, contentType, status, err := c.convert(&req)
+	if err != nil {
+		c.fail(w, asJSON, status, err)
+		return
+	}
+	if !asJSON {
+		w.Header().Set("Content-Type", contentType)
+		w.Write(out)
+		return
+	}
+	resp := &convertResponse{Output: string(out)}
+	if req.Format == render.FormatJSON || req.Format == render.FormatPandoc {
+		resp = &convertResponse{Document: bytes.TrimSpace(out)}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// convert does what req asks, returning the output & its type, or the
+// status to fail with
+func (c *converter) convert(req *convertRequest) ([]byte, string, int, error) {
+	if req.Format == "" {
+		req.Format = render.FormatHTML
+	}
+	contentType, ok := daemonFormats[req.Format]
+	if !ok {
+		return nil, "", http.StatusBadRequest, fmt.Errorf("unknown format %q", req.Format)
+	}
+	key := c.cache.key(req)
+	if out, ok := c.cache.get(key); ok {
+		return out, contentType, http.StatusOK, nil
+	}
+	r, err := render.NewRenderer(req.Format)
+	if err != nil {
+		return nil, "", http.StatusBadRequest, err
+	}
+	if req.Standalone || req.Theme != "" {
+		if req.Format != render.FormatHTML {
+			return nil, "", http.StatusBadRequest, fmt.Errorf("standalone & theme only apply to %s", render.FormatHTML)
+		}
+		if req.Theme == "" {
+			req.Theme = render.ThemeGitHub
+		}
+		if r, err = render.NewPageRenderer(req.Theme); err != nil {
+			return nil, "", http.StatusBadRequest, err
+		}
+	}
+	p := c.parser
+	if req.HeadingIDs {
+		p = c.anchored
+	}
+	start := time.Now()
+	doc, err := p.Parse("", req.Markdown)
+	if err != nil {
+		var tooLarge *parser.InputTooLargeError
+		if errors.As(err, &tooLarge) {
+			return nil, "", http.StatusRequestEntityTooLarge, err
+		}
+		return nil, "", http.StatusUnprocessableEntity, err
+	}
+	var b bytes.Buffer
+	if err := render.RenderDocument(r, &b, doc); err != nil {
+		return nil, "", http.StatusInternalServerError, err
+	}
+	serverMetrics.rendered(time.Since(start))
+	c.cache.put(key, b.Bytes())
+	return b.Bytes(), contentType, http.StatusOK, nil
+}
+
+// fail answers with err & status, as JSON if the request was
+func (c *converter) fail(w http.ResponseWriter, asJSON bool, status int, err error) {
+	if asJSON {
+		writeJSON(w, status, &convertResponse{Error: err.Error()})
+		return
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// writeJSON answers with v as JSON
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(b, '\n'))
+}
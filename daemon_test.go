@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"./parser"
+)
+
+func TestDaemonKeepsOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notasocket")
+	writeFiles(t, dir, map[string]string{"notasocket": "keep me\n"})
+	r := gomd(t, dir, "", "daemon", "-socket", path)
+	if r.code == 0 || !strings.Contains(r.stderr, "not a socket") {
+		t.Errorf("exit %d, %q, want it to refuse the path", r.code, r.stderr)
+	}
+	if b, err := ioutil.ReadFile(path); err != nil || string(b) != "keep me\n" {
+		t.Errorf("the file was changed: %q, %v", b, err)
+	}
+}
+
+func TestDaemonGRPC(t *testing.T) {
+	c := &converter{maxSize: 1 << 20, parser: parser.New(parser.WithExtensions(withDefaults()...)), cache: &outputCache{}}
+	mux := http.NewServeMux()
+	mux.Handle(grpcConvertPath, grpcConverter{c})
+	srv := httptest.NewUnstartedServer(instrument(http.TimeoutHandler(mux, 10*time.Second, "")))
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	defer srv.Close()
+	tr := &http.Transport{Protocols: new(http.Protocols)}
+	tr.Protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: tr}
+
+	call := func(req []byte) (*http.Response, []byte) {
+		t.Helper()
+		body := append([]byte{0, 0, 0, 0, 0}, req...)
+		binary.BigEndian.PutUint32(body[1:5], uint32(len(req)))
+		resp, err := client.Post(srv.URL+grpcConvertPath, "application/grpc", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, b
+	}
+	resp, b := call(appendProtoString(appendProtoString(nil, 1, "# Hi"), 2, "md"))
+	if status := resp.Trailer.Get("Grpc-Status"); status != "0" {
+		t.Fatalf("status %q: %s", status, resp.Header.Get("Grpc-Message"))
+	}
+	msg := appendProtoString(nil, 1, "# Hi\n")
+	if want := append([]byte{0, 0, 0, 0, byte(len(msg))}, msg...); !bytes.Equal(b, want) {
+		t.Errorf("got %q, want %q", b, want)
+	}
+	resp, _ = call(appendProtoString(nil, 2, "nonsense"))
+	if status := resp.Header.Get("Grpc-Status"); status != "3" {
+		t.Errorf("an unknown format has status %q, want 3", status)
+	}
+}
+
+// A plugin stuck on a document is restarted for the next
+func TestPluginTimeout(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "plugin.py")
+	writeFiles(t, dir, map[string]string{"plugin.py": `import json, os, sys, time
+marker = ` + pyString(filepath.Join(dir, "hung")) + `
+for line in sys.stdin:
+    if not os.path.exists(marker):
+        open(marker, "w").close()
+        time.sleep(60)
+    print(json.dumps({"document": json.loads(line)["document"]}), flush=True)
+`})
+	plugins, err := startPlugins([]string{script}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closePlugins(plugins)
+	p := parser.New(parser.WithExtensions(pluginExtensions(plugins)...))
+	start := time.Now()
+	for _, name := range []string{"hangs.md", "rewritten.md"} {
+		if _, err := p.Parse(name, "# Hi\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d > 30*time.Second {
+		t.Errorf("took %v, waiting on the stuck plugin", d)
+	}
+	if plugins[0].failed != 1 {
+		t.Errorf("failed on %d documents, want the one it was stuck on", plugins[0].failed)
+	}
+}
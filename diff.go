@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is how many unchanged lines unified diffs show around each
+// change
+const diffContext = 3
+
+// edit is a step in turning one list of lines into another: keeping a
+// line of both, deleting one of the old or inserting one of the new
+type edit struct {
+	op   byte // ' ', '-' or '+'
+	line string
+}
+
+// diffLines returns the shortest edit script from a to b, using Myers'
+// algorithm
+func diffLines(a, b []string) []edit {
+	n, m := len(a), len(b)
+	max := n + m
+	v := make([]int, 2*max+2)
+	var trace [][]int
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[max+k-1] < v[max+k+1] {
+				x = v[max+k+1] // Down, an insertion
+			} else {
+				x = v[max+k-1] + 1 // Right, a deletion
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[max+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b, d)
+			}
+		}
+	}
+	return nil
+}
+
+// backtrack walks the furthest reaching paths Myers' algorithm recorded
+// at each number of edits d back from the end, building the edit script
+func backtrack(trace [][]int, a, b []string, d int) []edit {
+	max := len(a) + len(b)
+	x, y := len(a), len(b)
+	var script []edit
+	for ; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || k != d && v[max+k-1] < v[max+k+1] {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[max+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x, y = x-1, y-1
+			script = append(script, edit{' ', a[x]})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			y--
+			script = append(script, edit{'+', b[y]})
+		} else {
+			x--
+			script = append(script, edit{'-', a[x]})
+		}
+	}
+	for i, j := 0, len(script)-1; i < j; i, j = i+1, j-1 {
+		script[i], script[j] = script[j], script[i]
+	}
+	return script
+}
+
+// unifiedDiff returns the differences between the texts old and new as a
+// unified diff with the given file names, or "" if they're the same
+func unifiedDiff(oldName, newName, old, new string) string {
+	if old == new {
+		return ""
+	}
+	script := diffLines(splitLines(old), splitLines(new))
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+	// Line numbers at the start of each edit, counting from 0
+	oldAt := make([]int, len(script)+1)
+	newAt := make([]int, len(script)+1)
+	for i, e := range script {
+		oldAt[i+1], newAt[i+1] = oldAt[i], newAt[i]
+		if e.op != '+' {
+			oldAt[i+1]++
+		}
+		if e.op != '-' {
+			newAt[i+1]++
+		}
+	}
+	for i := 0; i < len(script); {
+		if script[i].op == ' ' {
+			i++
+			continue
+		}
+		// Grow the hunk until the changes are more than twice the context
+		// apart
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for j := i; j < len(script) && j <= end+2*diffContext; j++ {
+			if script[j].op != ' ' {
+				end = j
+			}
+		}
+		end += diffContext + 1
+		if end > len(script) {
+			end = len(script)
+		}
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(oldAt[start], oldAt[end]), hunkRange(newAt[start], newAt[end]))
+		for _, e := range script[start:end] {
+			b.WriteByte(e.op)
+			b.WriteString(e.line)
+			if !strings.HasSuffix(e.line, "\n") {
+				b.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+	return b.String()
+}
+
+// hunkRange formats lines from up to to, counting from 0, as a unified
+// diff hunk range
+func hunkRange(from, to int) string {
+	switch to - from {
+	case 0:
+		return fmt.Sprintf("%d,0", from)
+	case 1:
+		return fmt.Sprint(from + 1)
+	}
+	return fmt.Sprintf("%d,%d", from+1, to-from)
+}
+
+// splitLines splits s into lines, each keeping its line ending
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
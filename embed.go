@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"./parser"
+)
+
+// embedImages replaces the destination of each image in a local file with
+// a data URI holding the file, so the output needs nothing beside it. As
+// build publishes files, only images are embedded, never hidden files,
+// and nothing from outside the document's directory unless external says
+// to. Images that can't be or aren't embedded are left as they are, with
+// a warning
+func embedImages(external bool) parser.Extension {
+	return parser.ExtensionFunc(func(p *parser.Parser) {
+		p.AddTransformer(parser.TransformerFunc(func(doc *parser.Document) {
+			dir := "."
+			if doc.Name != "stdin" {
+				dir = filepath.Dir(doc.Name)
+			}
+			var walk func(n *parser.Node)
+			walk = func(n *parser.Node) {
+				if n.Kind == parser.NodeImage {
+					if uri, err := dataURI(dir, n.Dest, external); err != nil {
+						logger.Warn(fmt.Sprintf("%s: %v", doc.Name, err), "file", doc.Name, "image", n.Dest)
+					} else if uri != "" {
+						n.Dest = uri
+					}
+				}
+				for _, c := range n.Children {
+					walk(c)
+				}
+			}
+			walk(doc.Root)
+		}))
+	})
+}
+
+// dataURI returns a data URI holding the image dest refers to, relative to
+// dir, or "" if dest isn't a local file. It fails for files that aren't
+// images, hidden ones, and unless external those outside dir
+func dataURI(dir, dest string, external bool) (string, error) {
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return "", nil
+	}
+	path := filepath.FromSlash(u.Path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	switch {
+	case isHidden(u.Path):
+		return "", fmt.Errorf("%s is a hidden file, so isn't embedded", u.Path)
+	case !imageExts[strings.ToLower(filepath.Ext(path))]:
+		return "", fmt.Errorf("%s isn't an image, so isn't embedded", u.Path)
+	case !external && !within(dir, path):
+		return "", fmt.Errorf("%s is outside %s; embed it with -external-assets", u.Path, dir)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	typ := mime.TypeByExtension(strings.ToLower(filepath.Ext(path)))
+	if typ == "" {
+		typ = http.DetectContentType(b)
+	}
+	if i := strings.IndexByte(typ, ';'); i >= 0 {
+		typ = typ[:i] // Without parameters, such as a charset
+	}
+	return "data:" + typ + ";base64," + base64.StdEncoding.EncodeToString(b), nil
+}
+
+// within reports whether path is in dir, once symbolic links are followed
+func within(dir, path string) bool {
+	for _, p := range []*string{&dir, &path} {
+		abs, err := filepath.Abs(*p)
+		if err != nil {
+			return false
+		}
+		if real, err := filepath.EvalSymlinks(abs); err == nil {
+			abs = real
+		}
+		*p = abs
+	}
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// inlineStylesheet returns a style element holding the stylesheet at css,
+// or "" if it's a URL rather than a local file
+func inlineStylesheet(css string) (string, error) {
+	if u, err := url.Parse(css); err != nil || u.Scheme != "" || u.Host != "" {
+		return "", nil
+	}
+	b, err := ioutil.ReadFile(filepath.FromSlash(css))
+	if err != nil {
+		return "", err
+	}
+	return "<style>\n" + strings.TrimRight(string(b), "\n") + "\n</style>\n", nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSelfContainedEmbedsOnlyImages(t *testing.T) {
+	dir := t.TempDir()
+	png := "\x89PNG\r\n\x1a\n"
+	writeFiles(t, dir, map[string]string{
+		"outside.png":      png,
+		"docs/.hidden.png": png,
+		"docs/notes.txt":   "secret\n",
+		"docs/ok.png":      png,
+		"docs/doc.md": "![ok](ok.png) ![abs](/etc/passwd) ![up](../outside.png)\n" +
+			"![hidden](.hidden.png) ![text](notes.txt)\n",
+	})
+	r := gomd(t, dir, "", "-self-contained", "-fragment", "-to", "html", "-o", "-", filepath.Join("docs", "doc.md"))
+	if r.code != 0 {
+		t.Fatalf("exit %d: %s", r.code, r.stderr)
+	}
+	if want := `src="data:image/png;base64,`; strings.Count(r.stdout, want) != 1 || !strings.Contains(r.stdout, `alt="ok"`) {
+		t.Errorf("want only ok.png embedded, got %s", r.stdout)
+	}
+	for _, src := range []string{"/etc/passwd", "../outside.png", ".hidden.png", "notes.txt"} {
+		if !strings.Contains(r.stdout, `src="`+src+`"`) {
+			t.Errorf("%s was embedded: %s", src, r.stdout)
+		}
+	}
+	r = gomd(t, dir, "", "-self-contained", "-external-assets", "-fragment", "-to", "html", "-o", "-", filepath.Join("docs", "doc.md"))
+	if strings.Count(r.stdout, "data:image/png;base64,") != 2 || !strings.Contains(r.stdout, `src="/etc/passwd"`) {
+		t.Errorf("want ok.png & ../outside.png embedded with -external-assets, got %s", r.stdout)
+	}
+}
+
+func TestDataURIType(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"a.svg": "<svg/>"})
+	uri, err := dataURI(dir, "a.svg", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "data:image/svg+xml;base64,"; !strings.HasPrefix(uri, want) {
+		t.Errorf("got %q, want it to start %q", uri, want)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"./pandoc"
+	"./parser"
+	"./render"
+)
+
+// pandocFormats are the names Pandoc gives the formats gomd writes, which
+// filters are told the document is on its way to
+var pandocFormats = map[string]string{
+	render.FormatMarkdown: "markdown",
+	render.FormatText:     "plain",
+	render.FormatSlides:   "revealjs",
+	render.FormatANSI:     "ansi",
+	render.FormatPandoc:   "json",
+}
+
+// filters is an Extension of a build's parser running each document
+// through Pandoc filters in turn, noting those that fail
+type filters struct {
+	paths  []string
+	format string // Pandoc's name for the format being written
+
+	mu   sync.Mutex
+	errs []string
+}
+
+// newFilters returns the filters at paths, for documents written in format
+func newFilters(paths []string, format string) *filters {
+	if f, ok := pandocFormats[format]; ok {
+		format = f
+	}
+	return &filters{paths: paths, format: format}
+}
+
+func (f *filters) Extend(p *parser.Parser) {
+	p.AddTransformer(parser.TransformerFunc(func(doc *parser.Document) {
+		for _, path := range f.paths {
+			if err := pandoc.Filter(doc, f.format, path); err != nil {
+				f.mu.Lock()
+				f.errs = append(f.errs, fmt.Sprintf("%s: %v", doc.Name, err))
+				f.mu.Unlock()
+				return
+			}
+		}
+	}))
+}
+
+// check prints the failures, in order, returning an error if there were
+// any
+func (f *filters) check() error {
+	if len(f.errs) == 0 {
+		return nil
+	}
+	sort.Strings(f.errs)
+	for _, msg := range f.errs {
+		logger.Error(msg)
+	}
+	return fmt.Errorf("filters failed on %s", plural(len(f.errs), "file"))
+}
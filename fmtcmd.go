@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"./parser"
+	"./render"
+)
+
+// formatFiles rewrites markdown files in the canonical style the markdown
+// renderer writes, as gofmt does for Go
+func formatFiles(args []string) error {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	check := fs.Bool("check", false, "list the files that aren't formatted instead of writing them, failing if there are any")
+	diff := fs.Bool("diff", false, "print the changes formatting would make instead of writing them")
+	tableWidth := fs.Int("table-width", 120, "write tables that would be wider than this once aligned compactly, or 0 to align them all")
+	inferLang := fs.Bool("infer-lang", false, "label code blocks that have no language with the one their code looks to be in")
+	links := fs.String("links", linksInline, "how to write links & images: "+linksInline+", [text](url), or "+linksReference+", [text][1] with the references at the end")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s fmt [-check] [-diff] [-links style] [-table-width n] [-infer-lang]\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "           [file or pattern ...]")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Formats each markdown file in place, or standard input to standard output")
+		fmt.Fprintln(os.Stderr, "if none are given: ATX headings, - bullets, ``` fences and * emphasis,")
+		fmt.Fprintln(os.Stderr, "with links written inline, the pipes of tables lined up, and a fresh")
+		fmt.Fprintf(os.Stderr, "table of contents between any %s and %s markers.\n", tocStart, tocStop)
+		fmt.Fprintln(os.Stderr, "Blocks the parser doesn't read as written, such as raw HTML, nested lists")
+		fmt.Fprintln(os.Stderr, "and reference links, are left as they are. A file is left alone, and fmt")
+		fmt.Fprintln(os.Stderr, "fails, if formatting would change what it says.")
+		fmt.Fprintln(os.Stderr, "With -check or -diff nothing is written; use both in CI to fail with the")
+		fmt.Fprintln(os.Stderr, "changes needed.")
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+	}
+	args, err := expandArgs(parseInterspersed(fs, args))
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		args = []string{stdinName}
+	}
+	md := render.NewMarkdownRenderer(render.WithNodeRenderer(fmtVerbatim, writeVerbatim))
+	var exts []parser.Extension
+	if *inferLang {
+		exts = append(exts, parser.InferLanguages(nil, true))
+	}
+	f := newFormatter(md, *tableWidth, exts...)
+	switch *links {
+	case linksInline:
+	case linksReference:
+		md.ReferenceLinks = true
+	default:
+		return usagef("unknown -links style %q, want %s or %s", *links, linksInline, linksReference)
+	}
+	unformatted := 0
+	for _, path := range args {
+		name, src, err := readSource(path)
+		if err != nil {
+			return err
+		}
+		out, err := f.format(name, src)
+		if err != nil {
+			return err
+		}
+		switch {
+		case *check || *diff:
+			if out == src {
+				continue
+			}
+			unformatted++
+			if *diff {
+				fmt.Print(unifiedDiff(name, name+" (formatted)", src, out))
+			} else {
+				fmt.Println(name)
+			}
+		case path == stdinName:
+			fmt.Print(out)
+		case out != src:
+			if err := ioutil.WriteFile(path, []byte(out), 0644); err != nil {
+				return err
+			}
+		}
+	}
+	if *check && unformatted > 0 {
+		return fmt.Errorf("%s not formatted", plural(unformatted, "file"))
+	}
+	return nil
+}
+
+// Styles of link fmt -links writes
+const (
+	linksInline    = "inline"
+	linksReference = "reference"
+)
+
+// formatter writes markdown in the canonical style
+type formatter struct {
+	parser     *parser.Parser
+	md         *render.MarkdownRenderer
+	tableWidth int // Widest a table may be aligned, or 0 for any width
+}
+
+// newFormatter returns a formatter writing with md, reading with the
+// default extensions & exts
+func newFormatter(md *render.MarkdownRenderer, tableWidth int, exts ...parser.Extension) *formatter {
+	return &formatter{
+		parser:     parser.New(parser.WithSourceRanges(), parser.WithExtensions(withDefaults(exts...)...)),
+		md:         md,
+		tableWidth: tableWidth,
+	}
+}
+
+// fmtVerbatim is a block a formatter writes exactly as it was, its
+// Literal: one holding what the parser doesn't read as written, or text
+// between blocks the parser drops, such as link reference definitions
+var fmtVerbatim = parser.NewNodeKind("Verbatim", true)
+
+// writeVerbatim writes a fmtVerbatim block
+func writeVerbatim(w io.Writer, n *parser.Node, entering bool) {
+	if entering {
+		io.WriteString(w, n.Literal+"\n\n")
+	}
+}
+
+// format returns src, from the file name, in the canonical style, with
+// tables aligned and a fresh table of contents between any toc markers.
+// Blocks the parser doesn't read as written are left as they are, and
+// it fails rather than return markdown that says something else
+func (f *formatter) format(name, src string) (string, error) {
+	src, err := refreshTOC(name, src)
+	if err != nil {
+		return "", err
+	}
+	src, tables := extractTables(src)
+	doc, err := f.parser.Parse(name, src)
+	if err != nil {
+		return "", err
+	}
+	want := treeEvents(doc.Root)
+	doc.Root = f.canonical(doc)
+	var b bytes.Buffer
+	if err := render.RenderDocument(f.md, &b, doc); err != nil {
+		return "", err
+	}
+	formatted, err := f.parser.Parse(name, b.String())
+	if err != nil {
+		return "", err
+	}
+	if !reflect.DeepEqual(treeEvents(formatted.Root), want) {
+		return "", fmt.Errorf("%s: formatting would change what it says, so it's left as it is", name)
+	}
+	return restoreTables(b.String(), tables, f.tableWidth), nil
+}
+
+// canonical returns the blocks of doc to write, each as it is to be
+// formatted or a fmtVerbatim block of its source
+func (f *formatter) canonical(doc *parser.Document) *parser.Node {
+	root := parser.NewNode(parser.NodeDocument)
+	verbatim := func(src string) {
+		if src = strings.Trim(strings.Replace(src, "\r\n", "\n", -1), "\n"); strings.TrimSpace(src) != "" {
+			root.AppendChild(&parser.Node{Kind: fmtVerbatim, Literal: src})
+		}
+	}
+	pos := len(doc.FrontMatter)
+	for _, n := range doc.Root.Children {
+		start, end := lineStart(doc.Source, n.Range.Start), lineEnd(doc.Source, n.Range.End)
+		if start < pos { // Sharing a line with the block before, which was kept
+			start = pos
+		}
+		verbatim(doc.Source[pos:start])
+		if f.modeled(doc, n, doc.Source[start:end]) {
+			root.AppendChild(n)
+		} else {
+			verbatim(doc.Source[start:end])
+		}
+		pos = end
+	}
+	verbatim(doc.Source[pos:])
+	return root
+}
+
+// lineStart returns the offset in s of the start of the line at i
+func lineStart(s string, i int) int {
+	return strings.LastIndexByte(s[:i], '\n') + 1
+}
+
+// lineEnd returns the offset in s of the end of the line at i
+func lineEnd(s string, i int) int {
+	if j := strings.IndexByte(s[i:], '\n'); j >= 0 {
+		return i + j
+	}
+	return len(s)
+}
+
+// Source the parser doesn't read as it's written, so that formatting it
+// would change what it says to other markdown tools
+var (
+	// Raw HTML, comments & autolinks, read as text
+	rawHTML = regexp.MustCompile(`<[A-Za-z/!?]`)
+	// Underscores within words, read as emphasis
+	intraword = regexp.MustCompile(`[\p{L}\p{N}]_+[\p{L}\p{N}]`)
+	// A line indented as code, which the parser reads as a paragraph
+	indentedCode = regexp.MustCompile(`(?m)^(?: {0,3}\t| {4})`)
+	// A line starting a block, which within another is read as its text
+	// or flattened into it: a list item, quote, heading or fence
+	blockMarker = regexp.MustCompile(`^[ \t]*(?:[-*+](?:[ \t]|$)|\d{1,9}[.)](?:[ \t]|$)|>|#{1,6}(?:[ \t]|$)|` + "```" + `|~~~)`)
+	// A list item's marker, not indented
+	itemMarker = regexp.MustCompile(`^(?:[-*+]|(\d{1,9})[.)])(?:[ \t]|$)`)
+	// The markers of a block quote
+	quoteMarkers = regexp.MustCompile(`^(?: {0,3}> ?)+`)
+)
+
+// modeled reports whether the block n of doc, written as src, says the
+// same once formatted: it holds nothing the parser reads otherwise than
+// written, and what the formatter writes for it is read back the same
+func (f *formatter) modeled(doc *parser.Document, n *parser.Node, src string) bool {
+	if n.Kind == parser.NodeCodeBlock {
+		return f.roundTrips(n)
+	}
+	if !n.IsBlock() || n.Kind > parser.NodeBlockQuote || rawHTML.MatchString(src) || intraword.MatchString(src) || indentedCode.MatchString(src) {
+		return false
+	}
+	lines := strings.Split(strings.Replace(src, "\r\n", "\n", -1), "\n")
+	for i, line := range lines {
+		switch n.Kind {
+		case parser.NodeList:
+			// Each item on a line of its own, not indented, and never
+			// renumbered; anything else in a list is text the parser
+			// has run on into an item, or a nested list it's flattened
+			if strings.TrimSpace(line) == "" {
+				return false // A loose list, written tight
+			}
+			if m := itemMarker.FindStringSubmatch(line); m != nil {
+				if i == 0 && m[1] != "" && strings.TrimLeft(m[1], "0") != "1" {
+					return false
+				}
+				continue
+			}
+		case parser.NodeBlockQuote:
+			line = quoteMarkers.ReplaceAllString(line, "")
+		default:
+			if i == 0 {
+				continue
+			}
+		}
+		if blockMarker.MatchString(line) {
+			return false
+		}
+	}
+	if n.Kind == parser.NodeList && countItems(lines) != len(n.Children) {
+		return false
+	}
+	return !hasReferenceLinks(doc, n) && f.roundTrips(n)
+}
+
+// countItems returns how many of lines start list items
+func countItems(lines []string) int {
+	items := 0
+	for _, line := range lines {
+		if itemMarker.MatchString(line) {
+			items++
+		}
+	}
+	return items
+}
+
+// hasReferenceLinks reports whether n holds a link or image written as a
+// reference, which formatting would write inline
+func hasReferenceLinks(doc *parser.Document, n *parser.Node) bool {
+	if (n.Kind == parser.NodeLink || n.Kind == parser.NodeImage) && !strings.HasSuffix(doc.Raw(n), ")") {
+		return true
+	}
+	for _, c := range n.Children {
+		if hasReferenceLinks(doc, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// roundTrips reports whether the block n, formatted on its own, is read
+// back the same
+func (f *formatter) roundTrips(n *parser.Node) bool {
+	var b bytes.Buffer
+	if err := render.NewMarkdownRenderer().Render(&b, n); err != nil {
+		return false
+	}
+	doc, err := f.parser.Parse("", b.String())
+	if err != nil || len(doc.Root.Children) != 1 {
+		return false
+	}
+	return reflect.DeepEqual(treeEvents(doc.Root.Children[0]), treeEvents(n))
+}
+
+// treeEvents returns n & everything in it as a line each, without where
+// they were in the source, adjacent text run together as the parser
+// splits it wherever escapes and markup were
+func treeEvents(n *parser.Node) []string {
+	var events []string
+	text := false
+	var walk func(n *parser.Node)
+	walk = func(n *parser.Node) {
+		if n.Kind == parser.NodeText {
+			if text {
+				events[len(events)-1] += n.Literal
+			} else {
+				events = append(events, "text "+n.Literal)
+			}
+			text = true
+			return
+		}
+		text = false
+		literal := n.Literal
+		if n.Kind == parser.NodeThematicBreak {
+			literal = "" // However it's written
+		}
+		events = append(events, fmt.Sprintf("%v level=%d ordered=%t literal=%q info=%q dest=%q title=%q {", n.Kind, n.Level, n.Ordered, literal, n.Info, n.Dest, n.Title))
+		for _, c := range n.Children {
+			walk(c)
+		}
+		events = append(events, "}")
+		text = false
+	}
+	walk(n)
+	return events
+}
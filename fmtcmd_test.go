@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"./render"
+)
+
+// testFormatter returns the formatter fmt uses, with its defaults
+func testFormatter() *formatter {
+	return newFormatter(render.NewMarkdownRenderer(render.WithNodeRenderer(fmtVerbatim, writeVerbatim)), 120)
+}
+
+func TestFormat(t *testing.T) {
+	in := "Title\n=====\n\n* a\n* b\n\nSome __strong__ and _em_ text\nwith a [link](http://x \"t\").\n\n***\n\n```go\nx := 1\n```\n"
+	want := "# Title\n\n- a\n- b\n\nSome **strong** and *em* text\nwith a [link](http://x \"t\").\n\n---\n\n```go\nx := 1\n```\n"
+	got, err := testFormatter().format("test.md", in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+// Blocks the parser doesn't read as written are left alone
+func TestFormatKeepsUnmodeled(t *testing.T) {
+	for name, block := range map[string]string{
+		"nested list":        "- a\n  - nested\n- b",
+		"numbered items":     "1. one\n2. two\n3. three",
+		"numbered from 3":    "3. three\n4. four",
+		"loose list":         "- a\n\n- b",
+		"raw html":           "<div>\nraw\n</div>",
+		"inline comment":     "text <!-- gomd-disable --> more",
+		"autolink":           "see <http://example.com>",
+		"intraword":          "x_y_z",
+		"reference link":     "a [ref][r] link\n\n[r]: http://example.com",
+		"indented code":      "    code",
+		"nested quote list":  "> - a\n>   - b",
+		"reference def only": "[unused]: http://example.com",
+	} {
+		in := "# Before\n\n" + block + "\n\n# After\n"
+		got, err := testFormatter().format("test.md", in)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if got != in {
+			t.Errorf("%s: got\n%s\nwant it as it was", name, got)
+		}
+	}
+}
+
+func TestFormatKeepsNestedTOC(t *testing.T) {
+	in := "<!-- toc -->\n<!-- tocstop -->\n\n# A\n\n## B\n"
+	got, err := testFormatter().format("test.md", in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "- [A](#a)\n  - [B](#b)\n") {
+		t.Errorf("the table of contents isn't nested:\n%s", got)
+	}
+}
+
+// fmt fails without writing a file formatting would change the meaning of
+func TestFormatRefusesChanges(t *testing.T) {
+	dir := t.TempDir()
+	in := "see [x][1]\n\n[1]: http://a\n\nand [y](http://b)\n"
+	writeFiles(t, dir, map[string]string{"doc.md": in})
+	r := gomd(t, dir, "", "fmt", "-links", "reference", "doc.md")
+	if r.code == 0 {
+		t.Errorf("exit 0, want it to fail: %s", r.stderr)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dir, "doc.md")); err != nil || string(b) != in {
+		t.Errorf("the file was changed to %q, %v", b, err)
+	}
+}
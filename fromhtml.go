@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"./htmltomd"
+	"./render"
+)
+
+// fromHTML converts HTML files into markdown
+func fromHTML(args []string) error {
+	fs := flag.NewFlagSet("from-html", flag.ExitOnError)
+	out := fs.String("o", "", "write the markdown to this file, or - for standard output; only for a single input")
+	links := fs.String("links", linksInline, "how to write links & images: "+linksInline+" or "+linksReference)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s from-html [-o out] [-links style] [file or pattern ...]\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Converts each HTML file to a markdown file beside it, or standard input")
+		fmt.Fprintln(os.Stderr, "to standard output if no files are given. The page's <title> becomes the")
+		fmt.Fprintln(os.Stderr, "title in the front matter; scripts, styles & navigation are left out, as")
+		fmt.Fprintln(os.Stderr, "is markup markdown can't write, keeping the text within it.")
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+	}
+	args, err := expandArgs(parseInterspersed(fs, args))
+	if err != nil {
+		return err
+	}
+	r := render.NewMarkdownRenderer(render.WithNodeRenderer(fmtVerbatim, writeVerbatim))
+	switch *links {
+	case linksInline:
+	case linksReference:
+		r.ReferenceLinks = true
+	default:
+		return usagef("unknown -links style %q, want %s or %s", *links, linksInline, linksReference)
+	}
+	f := newFormatter(r, 120)
+	if len(args) == 0 {
+		args = []string{stdinName}
+	}
+	if *out != "" && len(args) > 1 {
+		return usagef("-o can only be used with a single input")
+	}
+	for _, path := range args {
+		name, src, err := readSource(path)
+		if err != nil {
+			return err
+		}
+		doc, err := htmltomd.Convert(name, strings.NewReader(src))
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		var b bytes.Buffer
+		if err := r.RenderDocument(&b, doc); err != nil {
+			return err
+		}
+		// Formatted like fmt would, so the tables made line up
+		md, err := f.format(name, b.String())
+		if err != nil {
+			return err
+		}
+		dest := *out
+		if dest == "" {
+			dest = stdinName
+			if path != stdinName {
+				dest = render.ReplaceExt(formatExts[render.FormatMarkdown])(path)
+			}
+		}
+		if dest == stdinName {
+			fmt.Print(md)
+		} else if err := ioutil.WriteFile(dest, []byte(md), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
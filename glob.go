@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// expandArgs replaces each pattern in args with the files it matches, in
+// order and without repeats. Besides the patterns filepath.Match takes, a
+// ** path element matches any number of directories. Arguments that
+// aren't patterns are kept as they are, so a missing file is reported
+// when it's read
+func expandArgs(args []string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	for _, arg := range args {
+		matches := []string{arg}
+		if isPattern(arg) {
+			var err error
+			if matches, err = glob(arg); err != nil {
+				return nil, err
+			}
+			if len(matches) == 0 {
+				return nil, &os.PathError{Op: "match", Path: arg, Err: errNoMatches}
+			}
+		}
+		for _, m := range matches {
+			if !seen[m] || m == stdinName {
+				seen[m] = true
+				files = append(files, m)
+			}
+		}
+	}
+	return files, nil
+}
+
+// isPattern reports whether arg has any of the characters filepath.Match
+// treats specially
+func isPattern(arg string) bool {
+	return strings.ContainsAny(arg, `*?[`)
+}
+
+// glob returns the regular files matching pattern, sorted
+func glob(pattern string) ([]string, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, usagef("%s: %v", pattern, err)
+	}
+	elems := strings.Split(filepath.ToSlash(filepath.Clean(pattern)), "/")
+	// Walk from the deepest directory without a pattern in it
+	fixed := 0
+	for fixed < len(elems)-1 && !isPattern(elems[fixed]) {
+		fixed++
+	}
+	root := strings.Join(elems[:fixed], "/")
+	if root == "" && fixed > 0 { // An absolute pattern
+		root = "/"
+	}
+	var matches []string
+	err := filepath.Walk(filepath.FromSlash(orDot(root)), func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path == filepath.FromSlash(orDot(root)) {
+			return nil // Nothing can match
+		}
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(orDot(root), path)
+		if info.IsDir() {
+			if rel != "." && !canMatchBelow(elems[fixed:], strings.Split(filepath.ToSlash(rel), "/")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() && matchElems(elems[fixed:], strings.Split(filepath.ToSlash(rel), "/")) {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	sort.Strings(matches)
+	return matches, err
+}
+
+// orDot returns dir, or the current directory if it's empty
+func orDot(dir string) string {
+	if dir == "" {
+		return "."
+	}
+	return dir
+}
+
+// matchElems reports whether the path elements name match the pattern
+// elements pat, where ** matches any number of elements
+func matchElems(pat, name []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchElems(pat[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := filepath.Match(pat[0], name[0]); !ok {
+			return false
+		}
+		pat, name = pat[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// canMatchBelow reports whether files inside the directory dir might
+// match the pattern elements pat, so whether it's worth walking into
+func canMatchBelow(pat, dir []string) bool {
+	for i, d := range dir {
+		if i >= len(pat) {
+			return false
+		}
+		if pat[i] == "**" {
+			return true
+		}
+		if ok, _ := filepath.Match(pat[i], d); !ok {
+			return false
+		}
+	}
+	return len(pat) > len(dir)
+}
@@ -0,0 +1,172 @@
+// Package gomdhttp serves a tree of markdown files over HTTP, rendering
+// each to an HTML page when it's requested. Pages are cached until their
+// file changes, and carry an ETag so browsers can check theirs is current:
+//
+//	docs := os.DirFS("docs")
+//	http.Handle("/docs/", http.StripPrefix("/docs", gomdhttp.Handler(docs)))
+package gomdhttp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"../parser"
+	"../render"
+)
+
+// Indexes are the files served for a directory, the first that exists
+var Indexes = []string{"index.md", "README.md"}
+
+// Option configures a Handler
+type Option func(*handler)
+
+// WithParser parses files with p rather than with the default options
+func WithParser(p *parser.Parser) Option {
+	return func(h *handler) { h.parser = p }
+}
+
+// WithRenderer renders files with r rather than as pages in the GitHub
+// theme. Its output is served as HTML
+func WithRenderer(r render.Renderer) Option {
+	return func(h *handler) { h.renderer = r }
+}
+
+// WithLogger logs each request to l at info level, with its status, and
+// files that can't be rendered as errors
+func WithLogger(l *slog.Logger) Option {
+	return func(h *handler) { h.logger = l }
+}
+
+// handler serves the files in fsys, rendering markdown
+type handler struct {
+	fsys     fs.FS
+	files    http.Handler // Serves everything else
+	parser   *parser.Parser
+	renderer render.Renderer
+	logger   *slog.Logger // nil if not logging
+
+	mu    sync.Mutex
+	cache map[string]*page
+}
+
+// page is a rendered file, as it was when modified at mod & size bytes long
+type page struct {
+	mod  time.Time
+	size int64
+	body []byte
+	etag string
+}
+
+// Handler returns a handler serving the files in fsys, with markdown files
+// rendered to HTML and other files served as they are. A directory with one
+// of the Indexes in it is served as that
+func Handler(fsys fs.FS, opts ...Option) http.Handler {
+	h := &handler{fsys: fsys, files: http.FileServer(http.FS(fsys)), parser: parser.New(), cache: make(map[string]*page)}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.renderer == nil {
+		h.renderer, _ = render.NewPageRenderer(render.ThemeGitHub)
+	}
+	return h
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.logger == nil {
+		h.serve(w, r)
+		return
+	}
+	start := time.Now()
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	h.serve(sw, r)
+	h.logger.Info("request", "method", r.Method, "path", r.URL.Path, "status", sw.status, "duration", time.Since(start))
+}
+
+// serve responds to r
+func (h *handler) serve(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" {
+		name = "."
+	}
+	info, err := fs.Stat(h.fsys, name)
+	if err == nil && info.IsDir() && strings.HasSuffix(r.URL.Path, "/") {
+		for _, index := range Indexes {
+			if i, err := fs.Stat(h.fsys, path.Join(name, index)); err == nil && !i.IsDir() {
+				name, info = path.Join(name, index), i
+				break
+			}
+		}
+	}
+	if err != nil || info.IsDir() || !isMarkdown(name) {
+		h.files.ServeHTTP(w, r)
+		return
+	}
+	p, err := h.render(name, info)
+	if err != nil {
+		if h.logger != nil {
+			h.logger.Error("rendering failed", "file", name, "err", err)
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("ETag", p.etag)
+	http.ServeContent(w, r, name, p.mod, bytes.NewReader(p.body))
+}
+
+// render returns the page of the markdown file name, whose info is info,
+// from the cache if it hasn't changed since it was rendered
+func (h *handler) render(name string, info fs.FileInfo) (*page, error) {
+	h.mu.Lock()
+	p := h.cache[name]
+	h.mu.Unlock()
+	if p != nil && p.mod.Equal(info.ModTime()) && p.size == info.Size() {
+		return p, nil
+	}
+	src, err := fs.ReadFile(h.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := h.parser.Parse(name, string(src))
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := render.RenderDocument(h.renderer, &b, doc); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(b.Bytes())
+	p = &page{mod: info.ModTime(), size: info.Size(), body: b.Bytes(), etag: `"` + hex.EncodeToString(sum[:8]) + `"`}
+	h.mu.Lock()
+	h.cache[name] = p
+	h.mu.Unlock()
+	return p, nil
+}
+
+// statusWriter notes the status of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// isMarkdown reports whether name is of a markdown file by its extension
+func isMarkdown(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".md", ".markdown", ".mdown", ".mkd":
+		return true
+	}
+	return false
+}
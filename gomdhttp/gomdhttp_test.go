@@ -0,0 +1,32 @@
+package gomdhttp_test
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"../gomdhttp"
+)
+
+func TestHandlerLeavesOutScripts(t *testing.T) {
+	fsys := fstest.MapFS{"doc.md": {Data: []byte("# Doc\n\n[x](javascript:alert(document.cookie))\n")}}
+	srv := httptest.NewServer(gomdhttp.Handler(fsys))
+	defer srv.Close()
+	resp, err := srv.Client().Get(srv.URL + "/doc.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 || !strings.Contains(string(body), "<h1") {
+		t.Fatalf("status %d: %s", resp.StatusCode, body)
+	}
+	if strings.Contains(string(body), "javascript:") {
+		t.Errorf("the script's link was served: %s", body)
+	}
+}
@@ -0,0 +1,154 @@
+// Package gomdtmpl provides template functions rendering markdown, for Go
+// programs whose templates show markdown content:
+//
+//	t := template.New("page").Funcs(gomdtmpl.HTMLFuncs(nil))
+//
+// then {{markdown .Body}} in the template writes .Body as HTML. The
+// functions are:
+//
+//	markdown        the HTML of a document
+//	markdownInline  the HTML of a line of markdown, without a paragraph around it
+//	toc             a nested list of links to the headings of a document
+//	plainText       the text of a document without markup, on one line
+package gomdtmpl
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+
+	"../parser"
+	"../render"
+)
+
+// funcs renders markdown with a parser & HTML renderer
+type funcs struct {
+	p    *parser.Parser
+	html *render.HTMLRenderer
+}
+
+func newFuncs(p *parser.Parser, opts []render.Option) *funcs {
+	if p == nil {
+		p = parser.New(parser.WithHeadingIDs())
+	}
+	return &funcs{p: p, html: render.NewHTMLRenderer(opts...)}
+}
+
+// HTMLFuncs returns the functions for an html/template, parsing with p, or
+// with heading IDs if p is nil, and rendering with opts. Their HTML isn't
+// escaped again, so links & images that could run scripts are left out
+// of it unless opts has render.WithUnsafe
+func HTMLFuncs(p *parser.Parser, opts ...render.Option) htmltemplate.FuncMap {
+	f := newFuncs(p, opts)
+	html := func(fn func(string) (string, error)) func(string) (htmltemplate.HTML, error) {
+		return func(src string) (htmltemplate.HTML, error) {
+			s, err := fn(src)
+			return htmltemplate.HTML(s), err
+		}
+	}
+	return htmltemplate.FuncMap{
+		"markdown":       html(f.markdown),
+		"markdownInline": html(f.markdownInline),
+		"toc":            html(f.toc),
+		"plainText":      f.plainText,
+	}
+}
+
+// TextFuncs returns the functions for a text/template, parsing with p, or
+// with heading IDs if p is nil, and rendering with opts
+func TextFuncs(p *parser.Parser, opts ...render.Option) texttemplate.FuncMap {
+	f := newFuncs(p, opts)
+	return texttemplate.FuncMap{
+		"markdown":       f.markdown,
+		"markdownInline": f.markdownInline,
+		"toc":            f.toc,
+		"plainText":      f.plainText,
+	}
+}
+
+// markdown returns the HTML of src
+func (f *funcs) markdown(src string) (string, error) {
+	doc, err := f.p.Parse("", src)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := f.html.RenderDocument(&b, doc); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// markdownInline returns the HTML of src, without the paragraph it would
+// be in if it's a single one
+func (f *funcs) markdownInline(src string) (string, error) {
+	doc, err := f.p.Parse("", src)
+	if err != nil {
+		return "", err
+	}
+	nodes := doc.Root.Children
+	if len(nodes) == 1 && nodes[0].Kind == parser.NodeParagraph {
+		nodes = nodes[0].Children
+	}
+	var b bytes.Buffer
+	for _, n := range nodes {
+		if err := f.html.Render(&b, n); err != nil {
+			return "", err
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// toc returns a nested list of links to the headings of src, or "" if it
+// has none
+func (f *funcs) toc(src string) (string, error) {
+	doc, err := f.p.Parse("", src)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	var list func(headings []*parser.Heading)
+	list = func(headings []*parser.Heading) {
+		if len(headings) == 0 {
+			return
+		}
+		b.WriteString("<ul>\n")
+		for _, h := range headings {
+			b.WriteString(`<li><a href="#` + htmltemplate.HTMLEscapeString(h.ID) + `">` + htmltemplate.HTMLEscapeString(h.Text) + "</a>")
+			if len(h.Children) > 0 {
+				b.WriteString("\n")
+				list(h.Children)
+			}
+			b.WriteString("</li>\n")
+		}
+		b.WriteString("</ul>\n")
+	}
+	list(doc.Outline())
+	return b.String(), nil
+}
+
+// plainText returns the text of src's blocks, separated by spaces, with
+// runs of spaces & line breaks collapsed
+func (f *funcs) plainText(src string) (string, error) {
+	doc, err := f.p.Parse("", src)
+	if err != nil {
+		return "", err
+	}
+	var text []string
+	var walk func(n *parser.Node)
+	walk = func(n *parser.Node) {
+		switch {
+		case n.Kind == parser.NodeCodeBlock:
+			text = append(text, n.Literal)
+		case n.IsBlock() && (len(n.Children) == 0 || !n.Children[0].IsBlock()):
+			text = append(text, n.PlainText())
+		default:
+			for _, c := range n.Children {
+				walk(c)
+			}
+		}
+	}
+	walk(doc.Root)
+	return strings.Join(strings.Fields(strings.Join(text, " ")), " "), nil
+}
@@ -0,0 +1,41 @@
+package gomdtmpl_test
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+	"testing"
+
+	"../gomdtmpl"
+	"../render"
+)
+
+func TestHTMLFuncsLeaveOutScripts(t *testing.T) {
+	for _, md := range []string{
+		"[x](javascript:alert(document.cookie))",
+		"![x](javascript:alert(document.cookie))",
+		"[x](data:text/html;base64,PHNjcmlwdD4=)",
+	} {
+		for _, fn := range []string{"markdown", "markdownInline"} {
+			tmpl := template.Must(template.New("page").Funcs(gomdtmpl.HTMLFuncs(nil)).Parse(`<div>{{` + fn + ` .}}</div>`))
+			var b bytes.Buffer
+			if err := tmpl.Execute(&b, md); err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(b.String(), "javascript:") || strings.Contains(b.String(), "data:") {
+				t.Errorf("%s %q: got %q", fn, md, b.String())
+			}
+		}
+	}
+}
+
+func TestHTMLFuncsUnsafe(t *testing.T) {
+	tmpl := template.Must(template.New("page").Funcs(gomdtmpl.HTMLFuncs(nil, render.WithUnsafe())).Parse(`{{markdownInline .}}`))
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, "[x](javascript:go())"); err != nil {
+		t.Fatal(err)
+	}
+	if want := `<a href="javascript:go()">x</a>`; b.String() != want {
+		t.Errorf("got %q, want %q", b.String(), want)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"./parser"
+)
+
+// grepKinds are the kinds of node grep -kind accepts
+var grepKinds = map[string]parser.NodeKind{
+	"heading":   parser.NodeHeading,
+	"paragraph": parser.NodeParagraph,
+	"list":      parser.NodeList,
+	"item":      parser.NodeListItem,
+	"task":      parser.NodeListItem, // Only items starting [ ] or [x]
+	"code":      parser.NodeCodeBlock,
+	"codespan":  parser.NodeCodeSpan,
+	"quote":     parser.NodeBlockQuote,
+	"link":      parser.NodeLink,
+	"image":     parser.NodeImage,
+}
+
+// taskMarker matches the checkbox at the start of a task list item
+var taskMarker = regexp.MustCompile(`^\[[ xX]\]\s`)
+
+// grep searches the syntax trees of markdown files for nodes of a kind
+// whose text matches a pattern
+func grep(args []string) error {
+	fs := flag.NewFlagSet("grep", flag.ExitOnError)
+	kind := fs.String("kind", "", "kind of node to search, rather than headings, paragraphs & code: "+grepKindNames())
+	match := fs.String("match", "", "regular expression the node's text must match; link & image destinations count too")
+	lang := fs.String("lang", "", "only search code blocks in this language")
+	level := fs.Int("level", 0, "only search headings of this level")
+	section := fs.Bool("section", false, "print the section each match is in")
+	ignoreCase := fs.Bool("i", false, "match without regard to case")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s grep [-kind kind] [-match regexp] [-i] [-lang lang] [-level n] [-section] [file or pattern ...]\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Prints where each node of the given kind whose text matches is, in each")
+		fmt.Fprintln(os.Stderr, "markdown file or standard input if none are given. Matching is against")
+		fmt.Fprintln(os.Stderr, "the text as it reads, so markup doesn't get in the way.")
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+	}
+	args, err := expandArgs(parseInterspersed(fs, args))
+	if err != nil {
+		return err
+	}
+	want, ok := grepKinds[*kind]
+	if !ok && *kind != "" {
+		return usagef("unknown kind %q, want one of %s", *kind, grepKindNames())
+	}
+	if *lang != "" {
+		if *kind != "" && *kind != "code" {
+			return usagef("-lang only applies to code")
+		}
+		*kind, want = "code", parser.NodeCodeBlock
+	}
+	if *level != 0 {
+		if *kind != "" && *kind != "heading" {
+			return usagef("-level only applies to headings")
+		}
+		*kind, want = "heading", parser.NodeHeading
+	}
+	if *ignoreCase {
+		*match = "(?i)" + *match
+	}
+	re, err := regexp.Compile(*match)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		args = []string{stdinName}
+	}
+	p := parser.New(parser.WithSourceRanges())
+	found := 0
+	for _, path := range args {
+		name, src, err := readSource(path)
+		if err != nil {
+			return err
+		}
+		doc, err := p.Parse(name, src)
+		if err != nil {
+			return err
+		}
+		matches := func(n *parser.Node) bool {
+			if *kind == "" {
+				switch n.Kind {
+				case parser.NodeHeading, parser.NodeParagraph, parser.NodeCodeBlock:
+				default:
+					return false
+				}
+			} else if n.Kind != want {
+				return false
+			}
+			text := nodeText(n)
+			switch {
+			case *kind == "task" && !taskMarker.MatchString(text),
+				*lang != "" && !strings.EqualFold(firstField(n.Info), *lang),
+				*level != 0 && n.Level != *level:
+				return false
+			}
+			return re.MatchString(text) || n.Dest != "" && re.MatchString(n.Dest)
+		}
+		var walk func(n *parser.Node)
+		walk = func(n *parser.Node) {
+			if matches(n) {
+				found++
+				fmt.Printf("%s:%v: %s: %s\n", name, doc.Position(n.Range.Start), kindName(n), summary(nodeText(n)))
+				if *section {
+					fmt.Println(indent(strings.TrimRight(doc.Raw(sectionOf(doc, n)), "\r\n")))
+				}
+			}
+			for _, c := range n.Children {
+				walk(c)
+			}
+		}
+		walk(doc.Root)
+	}
+	if found == 0 {
+		return fmt.Errorf("no matches")
+	}
+	return nil
+}
+
+// grepKindNames lists the kinds grep -kind accepts
+func grepKindNames() string {
+	var names []string
+	for name := range grepKinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// nodeText returns the text of n as it reads
+func nodeText(n *parser.Node) string {
+	switch n.Kind {
+	case parser.NodeCodeBlock, parser.NodeCodeSpan:
+		return n.Literal
+	}
+	return n.PlainText()
+}
+
+// firstField returns the first word of s
+func firstField(s string) string {
+	if f := strings.Fields(s); len(f) > 0 {
+		return f[0]
+	}
+	return ""
+}
+
+// summary returns the first line of text, shortened if it's long
+func summary(text string) string {
+	line := strings.TrimSpace(text)
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i] + " …"
+	}
+	if r := []rune(line); len(r) > 72 {
+		line = string(r[:71]) + "…"
+	}
+	return line
+}
+
+// indent indents each line of s
+func indent(s string) string {
+	return "\t" + strings.Replace(s, "\n", "\n\t", -1)
+}
+
+// sectionOf returns a node spanning the section of doc that n is in: from
+// the heading before it up to the next heading of the same or a higher
+// level, or the whole document if no heading comes before it
+func sectionOf(doc *parser.Document, n *parser.Node) *parser.Node {
+	for n.Parent != nil && n.Parent != doc.Root {
+		n = n.Parent
+	}
+	var heading *parser.Node
+	end := len(doc.Source)
+	for _, c := range doc.Root.Children {
+		switch {
+		case c.Range.Start <= n.Range.Start:
+			if c.Kind == parser.NodeHeading {
+				heading = c
+			}
+		case c.Kind == parser.NodeHeading && heading != nil && c.Level <= heading.Level:
+			end = c.Range.Start
+		}
+		if end != len(doc.Source) {
+			break
+		}
+	}
+	section := &parser.Node{Range: parser.Range{End: end}}
+	if heading != nil {
+		section.Range.Start = heading.Range.Start
+	}
+	return section
+}
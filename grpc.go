@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"./render"
+)
+
+// grpcConvertPath is where the daemon's gRPC service, gomd.Converter in
+// gomd.proto, converts markdown
+const grpcConvertPath = "/gomd.Converter/Convert"
+
+// gRPC status codes the daemon answers with
+const (
+	grpcOK                = 0
+	grpcInvalidArgument   = 3
+	grpcResourceExhausted = 8
+	grpcUnimplemented     = 12
+	grpcInternal          = 13
+)
+
+// grpcConverter answers the Convert calls of gRPC clients, over HTTP/2
+// without TLS, as the converter answers HTTP ones. Messages are encoded
+// here, there being two & so small, rather than generated from gomd.proto
+type grpcConverter struct {
+	*converter
+}
+
+func (g grpcConverter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ProtoMajor != 2 {
+		http.Error(w, "gRPC is over HTTP/2, with POST", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	msg, code, err := g.readMessage(r)
+	if err != nil {
+		grpcFail(w, code, err)
+		return
+	}
+	req, err := decodeConvertRequest(msg)
+	if err != nil {
+		grpcFail(w, grpcInvalidArgument, err)
+		return
+	}
+	out, _, status, err := g.convert(req)
+	if err != nil {
+		grpcFail(w, grpcCode(status), err)
+		return
+	}
+	var resp []byte
+	if req.Format == render.FormatJSON || req.Format == render.FormatPandoc {
+		resp = appendProtoString(resp, 2, string(out))
+	} else {
+		resp = appendProtoString(resp, 1, string(out))
+	}
+	w.WriteHeader(http.StatusOK)
+	var prefix [5]byte
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(resp)))
+	w.Write(prefix[:])
+	w.Write(resp)
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(grpcOK))
+}
+
+// readMessage reads the one message of a unary call, returning the
+// status to fail with if it can't
+func (g grpcConverter) readMessage(r *http.Request) ([]byte, int, error) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, g.maxSize+6))
+	if err != nil {
+		return nil, grpcInternal, err
+	}
+	if len(body) < 5 {
+		return nil, grpcInvalidArgument, errors.New("no message")
+	}
+	if body[0] != 0 {
+		return nil, grpcUnimplemented, errors.New("messages can't be compressed")
+	}
+	n := binary.BigEndian.Uint32(body[1:5])
+	if int64(n) > g.maxSize {
+		return nil, grpcResourceExhausted, fmt.Errorf("message is over %d bytes", g.maxSize)
+	}
+	if int(n) != len(body)-5 {
+		return nil, grpcInvalidArgument, errors.New("expected a single message")
+	}
+	return body[5:], grpcOK, nil
+}
+
+// grpcFail ends the call with code & err, in the headers as a call
+// without a message does
+func grpcFail(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	w.Header().Set("Grpc-Message", url.PathEscape(err.Error()))
+	w.WriteHeader(http.StatusOK)
+}
+
+// grpcCode returns the gRPC status code for the HTTP status the
+// converter fails with
+func grpcCode(status int) int {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return grpcInvalidArgument
+	case http.StatusRequestEntityTooLarge:
+		return grpcResourceExhausted
+	}
+	return grpcInternal
+}
+
+// decodeConvertRequest decodes the protobuf ConvertRequest of gomd.proto,
+// skipping fields it doesn't know
+func decodeConvertRequest(b []byte) (*convertRequest, error) {
+	req := new(convertRequest)
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errors.New("bad field key")
+		}
+		b = b[n:]
+		field, wire := key>>3, key&7
+		var v uint64
+		var s []byte
+		switch wire {
+		case 0: // Varint
+			if v, n = binary.Uvarint(b); n <= 0 {
+				return nil, errors.New("bad varint")
+			}
+			b = b[n:]
+		case 1: // 64 bits
+			if len(b) < 8 {
+				return nil, errors.New("short fixed64")
+			}
+			b = b[8:]
+		case 2: // Length delimited
+			l, n := binary.Uvarint(b)
+			if n <= 0 || l > uint64(len(b)-n) {
+				return nil, errors.New("bad length")
+			}
+			s, b = b[n:n+int(l)], b[n+int(l):]
+		case 5: // 32 bits
+			if len(b) < 4 {
+				return nil, errors.New("short fixed32")
+			}
+			b = b[4:]
+		default:
+			return nil, fmt.Errorf("field %d has unknown wire type %d", field, wire)
+		}
+		switch {
+		case field == 1 && wire == 2:
+			req.Markdown = string(s)
+		case field == 2 && wire == 2:
+			req.Format = string(s)
+		case field == 3 && wire == 0:
+			req.Standalone = v != 0
+		case field == 4 && wire == 2:
+			req.Theme = string(s)
+		case field == 5 && wire == 0:
+			req.HeadingIDs = v != 0
+		}
+	}
+	return req, nil
+}
+
+// appendProtoString appends the protobuf string field numbered field to b
+func appendProtoString(b []byte, field int, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
@@ -0,0 +1,492 @@
+// Package htmltomd converts HTML into a markdown syntax tree, which the
+// markdown renderer writes out, so content written in a CMS or another
+// tool can be moved into markdown
+package htmltomd
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"../parser"
+)
+
+// element is an HTML element, its children either *element or text
+type element struct {
+	name     string
+	attrs    map[string]string
+	children []interface{}
+}
+
+// skipped are the elements whose contents aren't part of the document
+var skipped = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true,
+	"head": true, "nav": true, "iframe": true, "object": true, "svg": true,
+}
+
+// Convert reads the HTML from r into a Document named name. The <title>,
+// if there is one, becomes the title in its front matter. Markup markdown
+// has no way to write is dropped, keeping the text within it; lists within
+// list items are flattened into the list they're in, as the parser reads
+// no deeper, and tables become pipe tables in paragraphs
+func Convert(name string, r io.Reader) (*parser.Document, error) {
+	root, title, err := parse(r)
+	if err != nil {
+		return nil, err
+	}
+	doc := &parser.Document{Name: name, Meta: make(map[string]string), Root: parser.NewNode(parser.NodeDocument)}
+	if title = collapse(title); strings.TrimSpace(title) != "" {
+		title = strings.TrimSpace(title)
+		doc.Meta["title"] = title
+		doc.FrontMatter = "---\ntitle: " + title + "\n---\n"
+	}
+	blocks(doc.Root, root.children)
+	return doc, nil
+}
+
+// Patterns of what the XML decoder can't read, even leniently: scripts &
+// styles, whose contents aren't markup, and < signs that don't start tags
+var (
+	rawText = regexp.MustCompile(`(?is)<script\b.*?</script\s*>|<style\b.*?</style\s*>`)
+	bareLT  = regexp.MustCompile(`<([^a-zA-Z/!?]|$)`)
+)
+
+// closes are the elements left open that each element closes, as HTML
+// lets their end tags be left out
+var closes = map[string]map[string]bool{
+	"li": {"li": true, "p": true},
+	"p":  {"p": true},
+	"dt": {"dt": true, "dd": true},
+	"dd": {"dt": true, "dd": true},
+	"tr": {"tr": true, "td": true, "th": true},
+	"td": {"td": true, "th": true},
+	"th": {"td": true, "th": true},
+}
+
+// parse reads the HTML from r into a tree of elements, returning the text
+// of its title too. The XML decoder is told to read HTML leniently, closing
+// void elements & those left open
+func parse(r io.Reader) (*element, string, error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, "", err
+	}
+	src = rawText.ReplaceAll(src, nil)
+	src = bareLT.ReplaceAll(src, []byte("&lt;$1"))
+	d := xml.NewDecoder(bytes.NewReader(src))
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+	root := &element{}
+	stack := []*element{root}
+	var title strings.Builder
+	inTitle := false
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		top := stack[len(stack)-1]
+		switch t := tok.(type) {
+		case xml.StartElement:
+			e := &element{name: strings.ToLower(t.Name.Local), attrs: make(map[string]string)}
+			for _, a := range t.Attr {
+				e.attrs[strings.ToLower(a.Name.Local)] = a.Value
+			}
+			for len(stack) > 1 && closes[e.name][stack[len(stack)-1].name] {
+				stack = stack[:len(stack)-1]
+			}
+			inTitle = e.name == "title"
+			top = stack[len(stack)-1]
+			top.children = append(top.children, e)
+			stack = append(stack, e)
+		case xml.EndElement:
+			inTitle = false
+			// Close the nearest element of the name, and any left open
+			// within it; the decoder reports each end more than once when
+			// they're left open
+			name := strings.ToLower(t.Name.Local)
+			for i := len(stack) - 1; i > 0; i-- {
+				if stack[i].name == name {
+					stack = stack[:i]
+					break
+				}
+			}
+		case xml.CharData:
+			if inTitle {
+				title.Write(t)
+			}
+			top.children = append(top.children, string(t))
+		}
+	}
+	return root, title.String(), nil
+}
+
+// isBlock reports whether the element name starts a block of its own
+func isBlock(name string) bool {
+	switch name {
+	case "p", "h1", "h2", "h3", "h4", "h5", "h6", "ul", "ol", "li", "pre", "blockquote", "hr", "table",
+		"div", "section", "article", "main", "header", "footer", "aside", "figure", "figcaption",
+		"body", "html", "dl", "dt", "dd", "address", "details", "summary", "form", "fieldset":
+		return true
+	}
+	return false
+}
+
+// blocks appends the blocks made of children to parent, gathering runs of
+// inline content between block elements into paragraphs
+func blocks(parent *parser.Node, children []interface{}) {
+	var inline []interface{}
+	flush := func() {
+		if p := paragraph(parser.NodeParagraph, inline); p != nil {
+			parent.AppendChild(p)
+		}
+		inline = nil
+	}
+	for _, child := range children {
+		e, ok := child.(*element)
+		if !ok || !isBlock(e.name) {
+			inline = append(inline, child)
+			continue
+		}
+		flush()
+		block(parent, e)
+	}
+	flush()
+}
+
+// block appends the block element e to parent
+func block(parent *parser.Node, e *element) {
+	switch e.name {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		if h := paragraph(parser.NodeHeading, e.children); h != nil {
+			h.Level = int(e.name[1] - '0')
+			parent.AppendChild(h)
+		}
+	case "p", "dt", "figcaption", "summary", "address":
+		if p := paragraph(parser.NodeParagraph, e.children); p != nil {
+			parent.AppendChild(p)
+		}
+	case "hr":
+		hr := parser.NewNode(parser.NodeThematicBreak)
+		hr.Literal = "---"
+		parent.AppendChild(hr)
+	case "pre":
+		parent.AppendChild(codeBlock(e))
+	case "blockquote":
+		q := parser.NewNode(parser.NodeBlockQuote)
+		blocks(q, e.children)
+		if len(q.Children) > 0 {
+			parent.AppendChild(q)
+		}
+	case "ul", "ol":
+		list := parser.NewNode(parser.NodeList)
+		list.Ordered = e.name == "ol"
+		listItems(list, e)
+		if len(list.Children) > 0 {
+			parent.AppendChild(list)
+		}
+	case "table":
+		if p := table(e); p != nil {
+			parent.AppendChild(p)
+		}
+	default:
+		if !skipped[e.name] {
+			blocks(parent, e.children)
+		}
+	}
+}
+
+// listItems appends the items of the list e to list, with those of lists
+// within them after them
+func listItems(list *parser.Node, e *element) {
+	for _, child := range e.children {
+		li, ok := child.(*element)
+		if !ok {
+			continue
+		}
+		if li.name == "ul" || li.name == "ol" {
+			listItems(list, li)
+			continue
+		}
+		if li.name != "li" {
+			continue
+		}
+		var inline []interface{}
+		var nested []*element
+		for _, gc := range li.children {
+			if sub, ok := gc.(*element); ok && (sub.name == "ul" || sub.name == "ol") {
+				nested = append(nested, sub)
+				continue
+			}
+			if sub, ok := gc.(*element); ok && isBlock(sub.name) {
+				inline = append(inline, " ")
+			}
+			inline = append(inline, gc)
+		}
+		if item := paragraph(parser.NodeListItem, inline); item != nil {
+			list.AppendChild(item)
+		}
+		for _, sub := range nested {
+			listItems(list, sub)
+		}
+	}
+}
+
+// codeBlock returns the <pre> element e as a code block, its language
+// taken from the class of the <code> element within it
+func codeBlock(e *element) *parser.Node {
+	n := parser.NewNode(parser.NodeCodeBlock)
+	for _, child := range e.children {
+		if code, ok := child.(*element); ok && code.name == "code" {
+			for _, class := range strings.Fields(code.attrs["class"]) {
+				for _, prefix := range []string{"language-", "lang-"} {
+					if strings.HasPrefix(class, prefix) {
+						n.Info = strings.TrimPrefix(class, prefix)
+					}
+				}
+			}
+		}
+	}
+	lit := strings.TrimPrefix(text(e), "\n") // Dropped by HTML too
+	if lit != "" && !strings.HasSuffix(lit, "\n") {
+		lit += "\n"
+	}
+	n.Literal = lit
+	return n
+}
+
+// table returns the table e as a paragraph with a line for each row, the
+// first its header, or nil if it has no rows
+func table(e *element) *parser.Node {
+	var rows [][]*element
+	var walk func(e *element)
+	walk = func(e *element) {
+		for _, child := range e.children {
+			switch ce, _ := child.(*element); {
+			case ce == nil:
+			case ce.name == "tr":
+				var cells []*element
+				for _, cell := range ce.children {
+					if td, ok := cell.(*element); ok && (td.name == "td" || td.name == "th") {
+						cells = append(cells, td)
+					}
+				}
+				rows = append(rows, cells)
+			case ce.name != "table":
+				walk(ce) // thead, tbody & tfoot
+			}
+		}
+	}
+	walk(e)
+	cols := 0
+	for _, row := range rows {
+		if len(row) > cols {
+			cols = len(row)
+		}
+	}
+	if cols == 0 {
+		return nil
+	}
+	p := parser.NewNode(parser.NodeParagraph)
+	for r, row := range rows {
+		if r > 0 {
+			p.AppendChild(parser.NewNode(parser.NodeSoftBreak))
+		}
+		for i := 0; i < cols; i++ {
+			p.AppendChild(textNode("| "))
+			if i < len(row) {
+				if cell := paragraph(parser.NodeParagraph, row[i].children); cell != nil {
+					for _, n := range cell.Children {
+						if n.Kind == parser.NodeSoftBreak || n.Kind == parser.NodeHardBreak {
+							n = textNode(" ")
+						}
+						p.AppendChild(n)
+					}
+				}
+			}
+			p.AppendChild(textNode(" "))
+		}
+		p.AppendChild(textNode("|"))
+		if r == 0 {
+			p.AppendChild(parser.NewNode(parser.NodeSoftBreak))
+			p.AppendChild(textNode(strings.Repeat("| --- ", cols) + "|"))
+		}
+	}
+	return p
+}
+
+// paragraph returns a node of kind holding the inline content made of
+// children, with whitespace collapsed as a browser would, or nil if there's
+// nothing but whitespace
+func paragraph(kind parser.NodeKind, children []interface{}) *parser.Node {
+	n := parser.NewNode(kind)
+	inlines(n, children)
+	trimSpace(n)
+	if len(n.Children) == 0 {
+		return nil
+	}
+	return n
+}
+
+// inlines appends the inline nodes made of children to parent
+func inlines(parent *parser.Node, children []interface{}) {
+	for _, child := range children {
+		e, ok := child.(*element)
+		if !ok {
+			parent.AppendChild(textNode(collapse(child.(string))))
+			continue
+		}
+		if skipped[e.name] {
+			continue
+		}
+		switch e.name {
+		case "br":
+			parent.AppendChild(parser.NewNode(parser.NodeHardBreak))
+		case "em", "i", "cite", "dfn", "var":
+			wrap(parent, parser.NodeEmphasis, e)
+		case "strong", "b":
+			wrap(parent, parser.NodeStrong, e)
+		case "code", "kbd", "samp", "tt":
+			code := parser.NewNode(parser.NodeCodeSpan)
+			code.Literal = collapse(text(e))
+			if code.Literal != "" {
+				parent.AppendChild(code)
+			}
+		case "a":
+			href, ok := e.attrs["href"]
+			if !ok || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+				inlines(parent, e.children)
+				break
+			}
+			link := parser.NewNode(parser.NodeLink)
+			link.Dest, link.Title = href, e.attrs["title"]
+			inlines(link, e.children)
+			parent.AppendChild(link)
+		case "img":
+			img := parser.NewNode(parser.NodeImage)
+			img.Dest, img.Title = e.attrs["src"], e.attrs["title"]
+			if alt := e.attrs["alt"]; alt != "" {
+				img.AppendChild(textNode(collapse(alt)))
+			}
+			parent.AppendChild(img)
+		default:
+			if isBlock(e.name) {
+				parent.AppendChild(textNode(" "))
+			}
+			inlines(parent, e.children)
+		}
+	}
+}
+
+// wrap appends a node of kind holding the inline content of e to parent,
+// if there is any
+func wrap(parent *parser.Node, kind parser.NodeKind, e *element) {
+	n := parser.NewNode(kind)
+	inlines(n, e.children)
+	if strings.TrimSpace(n.PlainText()) != "" {
+		parent.AppendChild(n)
+	}
+}
+
+// trimSpace drops the spaces at the start & end of the inline content of
+// n, those after line breaks and all but the first of each run of them,
+// which collapse has left as one space per text node
+func trimSpace(n *parser.Node) {
+	space := true // Drop spaces at the start
+	var last *parser.Node
+	var walk func(n *parser.Node)
+	walk = func(n *parser.Node) {
+		var kept []*parser.Node
+		for _, c := range n.Children {
+			switch c.Kind {
+			case parser.NodeText:
+				if space {
+					c.Literal = strings.TrimLeft(c.Literal, " ")
+				}
+				if c.Literal == "" {
+					continue
+				}
+				space = strings.HasSuffix(c.Literal, " ")
+				last = c
+			case parser.NodeHardBreak, parser.NodeSoftBreak:
+				if last != nil && last.Kind == parser.NodeText {
+					last.Literal = strings.TrimRight(last.Literal, " ")
+				}
+				space = true
+				last = c
+			case parser.NodeCodeSpan, parser.NodeImage:
+				space = false
+				last = c
+			default:
+				walk(c)
+			}
+			kept = append(kept, c)
+		}
+		n.Children = kept
+	}
+	walk(n)
+	for last != nil && (last.Kind == parser.NodeText || last.Kind == parser.NodeHardBreak) {
+		if last.Kind == parser.NodeText {
+			last.Literal = strings.TrimRight(last.Literal, " ")
+			if last.Literal != "" {
+				break
+			}
+		}
+		p := last.Parent
+		p.Children = p.Children[:len(p.Children)-1]
+		last = p.LastChild()
+	}
+}
+
+// text returns all the text within e, as it's written
+func text(e *element) string {
+	var b strings.Builder
+	for _, child := range e.children {
+		switch c := child.(type) {
+		case string:
+			b.WriteString(c)
+		case *element:
+			if c.name == "br" {
+				b.WriteString("\n")
+			}
+			b.WriteString(text(c))
+		}
+	}
+	return b.String()
+}
+
+// collapse turns each run of whitespace in s into a single space, as
+// browsers do. Non-breaking spaces are kept
+func collapse(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if strings.ContainsRune(" \t\n\r\f", r) {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+// textNode returns a Text node of s
+func textNode(s string) *parser.Node {
+	n := parser.NewNode(parser.NodeText)
+	n.Literal = s
+	return n
+}
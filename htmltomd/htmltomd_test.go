@@ -0,0 +1,40 @@
+package htmltomd_test
+
+import (
+	"strings"
+	"testing"
+
+	"../htmltomd"
+	"../render"
+)
+
+func TestConvert(t *testing.T) {
+	doc, err := htmltomd.Convert("page.html", strings.NewReader(`<html><head><title>The Page</title><style>p{}</style></head>
+<body><nav>Menu</nav><h1>Hello</h1>
+<p>Some <b>bold</b> &amp; <em>em</em> with <a href="/x">a link</a> and <code>code</code>.<script>alert(1)</script></p>
+<ul><li>one<li>two</ul>
+<pre><code class="language-go">x := 1
+</code></pre>
+<img src="a.png" alt="A"><p>1 < 2</p>
+<table><tr><th>a</th><th>b</th></tr><tr><td>1</td><td>2</td></tr></table></body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Meta["title"] != "The Page" {
+		t.Errorf("title %q, want the <title>", doc.Meta["title"])
+	}
+	var b strings.Builder
+	if err := render.RenderDocument(render.NewMarkdownRenderer(), &b, doc); err != nil {
+		t.Fatal(err)
+	}
+	want := "---\ntitle: The Page\n---\n# Hello\n\n" +
+		"Some **bold** & *em* with [a link](/x) and `code`.\n\n" +
+		"- one\n- two\n\n" +
+		"```go\nx := 1\n```\n\n" +
+		"![A](a.png)\n\n" +
+		"1 \\< 2\n\n" +
+		"| a | b |\n| --- | --- |\n| 1 | 2 |\n"
+	if b.String() != want {
+		t.Errorf("converted to\n%s\nwant\n%s", b.String(), want)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// manifestName is the file in build's output recording what it was built
+// from, for the next -incremental build to compare against
+const manifestName = ".gomd-build.json"
+
+// buildManifest is what a build was built from: the commit checked out, and
+// the options that change every file's output
+type buildManifest struct {
+	Commit  string   `json:"commit"`
+	Format  string   `json:"format"`
+	Filters []string `json:"filters,omitempty"`
+}
+
+// same reports whether m was built with the same options as o
+func (m *buildManifest) same(o *buildManifest) bool {
+	return m.Format == o.Format && strings.Join(m.Filters, "\x00") == strings.Join(o.Filters, "\x00")
+}
+
+// readManifest returns the manifest of the build in dest, or nil if there
+// isn't one
+func readManifest(dest string) (*buildManifest, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dest, manifestName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m buildManifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("%s: %v", filepath.Join(dest, manifestName), err)
+	}
+	return &m, nil
+}
+
+// writeManifest records the build in dest as built from m
+func writeManifest(dest string, m *buildManifest) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dest, manifestName), append(b, '\n'), 0644)
+}
+
+// git runs git in dir with args, returning what it prints
+func git(dir string, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %v", args[0], err)
+	}
+	return string(out), nil
+}
+
+// gitHead returns the commit checked out in the repository dir is in, or
+// "" if it isn't in one
+func gitHead(dir string) string {
+	out, err := git(dir, "rev-parse", "--verify", "-q", "HEAD")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+// gitChanged returns the files under dir that differ from those of commit,
+// committed or not, along with those git doesn't track but doesn't ignore.
+// Files deleted since are among them
+func gitChanged(dir, commit string) (map[string]bool, error) {
+	diff, err := git(dir, "diff", "--name-only", "--relative", "-z", commit, "--")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := git(dir, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, err
+	}
+	changed := make(map[string]bool)
+	for _, name := range strings.Split(diff+untracked, "\x00") {
+		if name != "" {
+			changed[filepath.Join(dir, filepath.FromSlash(name))] = true
+		}
+	}
+	return changed, nil
+}
+
+// changedSince returns the files under src changed since the build in dest
+// whose manifest m would replace, or nil if everything needs building, as
+// it does if there's no build there, it was built with other options or
+// either isn't of a commit
+func changedSince(src, dest string, m *buildManifest) (map[string]bool, error) {
+	prev, err := readManifest(dest)
+	if err != nil || prev == nil || prev.Commit == "" || m.Commit == "" || !prev.same(m) {
+		return nil, err
+	}
+	changed, err := gitChanged(src, prev.Commit)
+	if err != nil { // Such as the commit having been rebased away
+		logger.Warn(fmt.Sprintf("%s build: building everything: %v", os.Args[0], err), "err", err)
+		return nil, nil
+	}
+	return changed, nil
+}
+
+// removeDeleted removes the output of each of changed no longer in src,
+// where target returns the output of a file
+func removeDeleted(src string, changed map[string]bool, target func(path string) string) error {
+	for path := range changed {
+		rel, err := filepath.Rel(src, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			continue
+		}
+		if err := os.Remove(target(path)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"./parser"
+	"./render"
+)
+
+// indexRecord is a section of a document in a search index: the text
+// under a heading, up to the next one, or before the first. Its fields are
+// what lunr & elasticlunr index, with ID the ref to give them
+type indexRecord struct {
+	ID     string `json:"id"`     // URL & anchor, unique across the index
+	Title  string `json:"title"`  // Heading of the section, or title of the page before the first
+	Page   string `json:"page"`   // Title of the page it's in
+	Anchor string `json:"anchor"` // ID of the heading, "" before the first
+	Text   string `json:"text"`
+	URL    string `json:"url"` // Of the page, with the anchor
+}
+
+// index writes a JSON search index of the markdown files under a directory
+func index(args []string) error {
+	fs := flag.NewFlagSet("index", flag.ExitOnError)
+	out := fs.String("o", stdinName, "write the index to this file, or - for standard output")
+	base := fs.String("base", "", "prefix the URL of every page with this, such as /docs/")
+	to := fs.String("to", render.FormatHTML, "format the pages are built in, whose extension their URLs end in")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s index [-o file] [-base url] [-to format] dir\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Writes a search index of each markdown file under dir as a JSON array of")
+		fmt.Fprintln(os.Stderr, "records, one for each section of a page, with the fields id, title, page,")
+		fmt.Fprintln(os.Stderr, "anchor, text & url. Load it into lunr or elasticlunr with id as the ref")
+		fmt.Fprintln(os.Stderr, "and title & text as fields, for a site built from dir to search itself.")
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+	}
+	args = parseInterspersed(fs, args)
+	if len(args) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	ext, ok := formatExts[*to]
+	if !ok {
+		return usagef("unknown format %q, want one of %s", *to, formatNames())
+	}
+	src := filepath.Clean(args[0])
+	p := parser.New(parser.WithHeadingIDs())
+	records := []*indexRecord{}
+	err := filepath.Walk(src, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if file != src && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || !isMarkdown(file) {
+			return nil
+		}
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		doc, err := p.Parse(file, string(b))
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, file)
+		url := *base + render.ReplaceExt(ext)(filepath.ToSlash(rel))
+		records = append(records, indexSections(doc, url)...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if *out == stdinName {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	return ioutil.WriteFile(*out, b, 0644)
+}
+
+// indexSections returns a record of each section of doc, found at url,
+// leaving out those with no text & no heading
+func indexSections(doc *parser.Document, url string) []*indexRecord {
+	page := doc.Title()
+	var records []*indexRecord
+	cur := &indexRecord{ID: url, Title: page, Page: page, URL: url}
+	var text []string
+	flush := func() {
+		cur.Text = strings.Join(text, " ")
+		if cur.Text != "" || cur.Anchor != "" {
+			records = append(records, cur)
+		}
+		text = nil
+	}
+	for _, n := range doc.Root.Children {
+		if n.Kind != parser.NodeHeading {
+			text = append(text, blockText(n)...)
+			continue
+		}
+		flush()
+		u := url + "#" + n.ID
+		cur = &indexRecord{ID: u, Title: n.PlainText(), Page: page, Anchor: n.ID, URL: u}
+	}
+	flush()
+	return records
+}
+
+// blockText returns the text of each paragraph, list item & code block
+// within the block n, in order
+func blockText(n *parser.Node) []string {
+	switch n.Kind {
+	case parser.NodeParagraph, parser.NodeListItem, parser.NodeHeading:
+		if s := strings.TrimSpace(n.PlainText()); s != "" {
+			return []string{s}
+		}
+		return nil
+	case parser.NodeCodeBlock:
+		if s := strings.TrimSpace(n.Literal); s != "" {
+			return []string{s}
+		}
+		return nil
+	}
+	var text []string
+	for _, c := range n.Children {
+		text = append(text, blockText(c)...)
+	}
+	return text
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// jobsFlag adds the -j flag setting how many files a command works on at
+// once to fs
+func jobsFlag(fs *flag.FlagSet) *int {
+	return fs.Int("j", 0, "how many files to work on at once, or 0 for one per CPU")
+}
+
+// runOrdered calls fn for each of paths on up to jobs goroutines at once,
+// or one per CPU if jobs is 0. Each call writes its output to the buffer it
+// is given, which is copied to w in the order of paths as soon as the
+// calls before it are done, so output is never interleaved. All the calls
+// are made even if some fail; the first error in order is returned
+func runOrdered(w io.Writer, paths []string, jobs int, fn func(i int, path string, out *bytes.Buffer) error) error {
+	if jobs <= 0 {
+		jobs = runtime.GOMAXPROCS(0)
+	}
+	outs := make([]bytes.Buffer, len(paths))
+	errs := make([]error, len(paths))
+	done := make([]chan struct{}, len(paths))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for j := 0; j < jobs; j++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				errs[i] = fn(i, paths[i], &outs[i])
+				close(done[i])
+			}
+		}()
+	}
+	go func() {
+		for i := range paths {
+			next <- i
+		}
+		close(next)
+	}()
+	var first error
+	for i := range paths {
+		<-done[i]
+		if _, err := w.Write(outs[i].Bytes()); err != nil && first == nil {
+			first = err
+		}
+		outs[i] = bytes.Buffer{} // Written, so no need to hold on to it
+		if errs[i] != nil && first == nil {
+			first = errs[i]
+		}
+	}
+	wg.Wait()
+	return first
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// linkChecker requests http & https URLs to see whether they can be
+// fetched: no more than workers at once, waiting at least interval between
+// requests to the same host, and requesting each URL only once however
+// many links point at it. Redirects are followed, up to the client's limit
+type linkChecker struct {
+	client   *http.Client
+	workers  int
+	interval time.Duration
+	ignore   []*regexp.Regexp // URLs not to request
+
+	mu    sync.Mutex
+	cache map[string]*linkResult // By URL, without any fragment
+	hosts map[string]*hostLimit
+}
+
+// linkResult is the outcome of requesting a URL, ready once done is closed
+type linkResult struct {
+	done   chan struct{}
+	status int    // HTTP status code of the final response, or 0 if there was none
+	final  string // URL redirects ended at, or "" if there were none
+	broken string // Why the URL couldn't be fetched, or ""
+}
+
+// hostLimit spaces out the requests to a host
+type hostLimit struct {
+	mu   sync.Mutex
+	next time.Time // When the next request may be made
+}
+
+// newLinkChecker returns a checker making requests workers at once, each
+// giving up after timeout, at most one every interval to any host, and
+// skipping URLs that match any of the ignore patterns
+func newLinkChecker(workers int, timeout, interval time.Duration, ignore []string) (*linkChecker, error) {
+	c := &linkChecker{
+		client:   &http.Client{Timeout: timeout},
+		workers:  workers,
+		interval: interval,
+		cache:    make(map[string]*linkResult),
+		hosts:    make(map[string]*hostLimit),
+	}
+	for _, pattern := range ignore {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, usagef("bad ignore pattern %q: %v", pattern, err)
+		}
+		c.ignore = append(c.ignore, re)
+	}
+	return c, nil
+}
+
+// ignored reports whether dest matches one of the ignore patterns
+func (c *linkChecker) ignored(dest string) bool {
+	for _, re := range c.ignore {
+		if re.MatchString(dest) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAll requests the destination of each of refs, setting their Status,
+// Final & Broken from the result
+func (c *linkChecker) checkAll(refs []*linkRef) {
+	next := make(chan *linkRef)
+	var wg sync.WaitGroup
+	for w := 0; w < c.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for l := range next {
+				r := c.check(l.Dest)
+				l.Status, l.Final, l.Broken = r.status, r.final, r.broken
+			}
+		}()
+	}
+	for _, l := range refs {
+		if !c.ignored(l.Dest) {
+			next <- l
+		}
+	}
+	close(next)
+	wg.Wait()
+}
+
+// check returns the result of requesting dest, requesting it only if it
+// hasn't been already
+func (c *linkChecker) check(dest string) *linkResult {
+	key := dest
+	if i := strings.IndexByte(key, '#'); i >= 0 {
+		key = key[:i] // The server never sees the fragment
+	}
+	c.mu.Lock()
+	r, ok := c.cache[key]
+	if !ok {
+		r = &linkResult{done: make(chan struct{})}
+		c.cache[key] = r
+	}
+	c.mu.Unlock()
+	if ok {
+		<-r.done
+		return r
+	}
+	defer close(r.done)
+	u, err := url.Parse(key)
+	if err != nil {
+		r.broken = err.Error()
+		return r
+	}
+	c.wait(u.Host)
+	resp, err := c.client.Head(key)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp.Body.Close()
+		c.wait(u.Host)
+		resp, err = c.client.Get(key) // Not every server handles HEAD
+	}
+	if ue, ok := err.(*url.Error); ok {
+		r.broken = ue.Err.Error() // Without the method & URL
+		return r
+	} else if err != nil {
+		r.broken = err.Error()
+		return r
+	}
+	resp.Body.Close()
+	r.status = resp.StatusCode
+	if final := resp.Request.URL.String(); final != key {
+		r.final = final
+	}
+	if resp.StatusCode >= 400 {
+		r.broken = resp.Status
+	}
+	return r
+}
+
+// wait blocks until a request may be made to host
+func (c *linkChecker) wait(host string) {
+	c.mu.Lock()
+	h, ok := c.hosts[host]
+	if !ok {
+		h = &hostLimit{}
+		c.hosts[host] = h
+	}
+	c.mu.Unlock()
+	h.mu.Lock()
+	now := time.Now()
+	at := h.next
+	if at.Before(now) {
+		at = now
+	}
+	h.next = at.Add(c.interval)
+	h.mu.Unlock()
+	time.Sleep(time.Until(at))
+}
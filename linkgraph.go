@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// graphDot is the -graph format of Graphviz's dot language
+const graphDot = "dot"
+
+// linkGraph is which markdown files link to which others
+type linkGraph struct {
+	files []string                   // In the order given
+	out   map[string]map[string]bool // Files each file links to
+	in    map[string]int             // How many files link to each
+}
+
+// newLinkGraph returns the graph of the links refs between files. Only
+// local links from one of files to another count, once for each pair
+func newLinkGraph(files []string, refs []*linkRef) *linkGraph {
+	g := &linkGraph{out: make(map[string]map[string]bool), in: make(map[string]int)}
+	for _, f := range files {
+		f = filepath.Clean(f)
+		if _, ok := g.out[f]; !ok {
+			g.files = append(g.files, f)
+			g.out[f] = make(map[string]bool)
+		}
+	}
+	for _, l := range refs {
+		if l.Kind != "link" {
+			continue
+		}
+		u, err := url.Parse(l.Dest)
+		if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" || filepath.IsAbs(u.Path) {
+			continue
+		}
+		from := filepath.Clean(l.File)
+		to := filepath.Join(filepath.Dir(from), filepath.FromSlash(u.Path))
+		if _, ok := g.out[to]; !ok || to == from || g.out[from][to] {
+			continue
+		}
+		g.out[from][to] = true
+		g.in[to]++
+	}
+	return g
+}
+
+// orphans returns the files no other links to, in order
+func (g *linkGraph) orphans() []string {
+	var files []string
+	for _, f := range g.files {
+		if g.in[f] == 0 {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// writeDot writes g to w in the dot language, each file labelled with how
+// many link to it & how many it links to, and orphans dashed
+func (g *linkGraph) writeDot(w io.Writer) error {
+	fmt.Fprintln(w, "digraph links {")
+	fmt.Fprintln(w, "\tnode [shape=box];")
+	for _, f := range g.files {
+		label := fmt.Sprintf("%s\nin %d, out %d", filepath.ToSlash(f), g.in[f], len(g.out[f]))
+		style := ""
+		if g.in[f] == 0 {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(w, "\t%s [label=%s%s];\n", strconv.Quote(f), strconv.Quote(label), style)
+	}
+	for _, f := range g.files {
+		var to []string
+		for t := range g.out[f] {
+			to = append(to, t)
+		}
+		sort.Strings(to)
+		for _, t := range to {
+			fmt.Fprintf(w, "\t%s -> %s;\n", strconv.Quote(f), strconv.Quote(t))
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
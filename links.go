@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"./parser"
+)
+
+// linkRef is a link or image found in a document
+type linkRef struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Kind   string `json:"kind"` // "link" or "image"
+	Text   string `json:"text"`
+	Dest   string `json:"dest"`
+	Title  string `json:"title,omitempty"`
+	Status int    `json:"status,omitempty"` // HTTP status code of the target, with -http
+	Final  string `json:"final,omitempty"`  // URL the target redirected to, with -http
+	Broken string `json:"broken,omitempty"` // Why the target couldn't be reached, with -check or -http
+}
+
+// listFlag collects the values given by a repeatable flag
+type listFlag []string
+
+func (p *listFlag) String() string { return strings.Join(*p, ",") }
+
+func (p *listFlag) Set(s string) error {
+	*p = append(*p, s)
+	return nil
+}
+
+// links lists the links & images in markdown files, optionally checking
+// that their targets exist
+func links(args []string) error {
+	fs := flag.NewFlagSet("links", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the links as JSON")
+	check := fs.Bool("check", false, "only list links whose targets don't exist, failing if there are any; only local targets are checked without -http")
+	probe := fs.Bool("http", false, "also request http and https links, reporting their status and where they redirect")
+	timeout := fs.Duration("timeout", 10*time.Second, "how long to wait for each http request")
+	rate := fs.Duration("rate", 250*time.Millisecond, "least time to leave between http requests to the same host")
+	graph := fs.String("graph", "", "print the graph of links between the files in this format instead: "+graphDot)
+	var ignore listFlag
+	fs.Var(&ignore, "ignore", "don't request http links matching this `regexp`; may be repeated")
+	jobs := jobsFlag(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s links [-json | -graph dot] [-check] [-http [-timeout d] [-rate d] [-ignore regexp ...]]\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "           [-j n] [file or pattern ...]")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Lists every link and image in each markdown file, or standard input if")
+		fmt.Fprintln(os.Stderr, "none are given, with where it is and where it points.")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "With -http, each http or https URL is requested once however many links")
+		fmt.Fprintln(os.Stderr, "point at it, -j at a time, following redirects. URLs matching an -ignore")
+		fmt.Fprintf(os.Stderr, "pattern, or one of the \"ignore\" list of the \"links\" object of %s,\n", configName)
+		fmt.Fprintln(os.Stderr, "are left alone.")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "With -graph dot, the links between the files given are printed as a")
+		fmt.Fprintln(os.Stderr, "Graphviz digraph instead, each file labelled with how many files link to")
+		fmt.Fprintln(os.Stderr, "it and how many it links to. Files none link to are dashed, and listed on")
+		fmt.Fprintln(os.Stderr, "standard error as orphans.")
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+	}
+	args, err := expandArgs(parseInterspersed(fs, args))
+	if err != nil {
+		return err
+	}
+	switch {
+	case *graph != "" && *graph != graphDot:
+		return usagef("unknown -graph format %q, want %s", *graph, graphDot)
+	case *graph != "" && *asJSON:
+		return usagef("-graph and -json can't be used together")
+	}
+	if len(args) == 0 {
+		args = []string{stdinName}
+	}
+	p := parser.New(parser.WithSourceRanges())
+	found := make([][]*linkRef, len(args))
+	err = runOrdered(ioutil.Discard, args, *jobs, func(i int, path string, _ *bytes.Buffer) error {
+		name, src, err := readSource(path)
+		if err != nil {
+			return err
+		}
+		doc, err := p.Parse(name, src)
+		if err != nil {
+			return err
+		}
+		found[i] = findLinks(doc)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var refs []*linkRef
+	for _, f := range found {
+		refs = append(refs, f...)
+	}
+	total, all := len(refs), refs
+	var checker *linkChecker
+	if *probe {
+		cfg, err := loadConfig("")
+		if err != nil {
+			return err
+		}
+		workers := *jobs
+		if workers <= 0 {
+			workers = probeWorkers
+		}
+		checker, err = newLinkChecker(workers, *timeout, *rate, append(cfg.Links.Ignore, ignore...))
+		if err != nil {
+			return err
+		}
+	}
+	if *check || *probe {
+		checkLinks(refs, *check, checker)
+	}
+	if *check {
+		var broken []*linkRef
+		for _, l := range refs {
+			if l.Broken != "" {
+				broken = append(broken, l)
+			}
+		}
+		refs = broken
+	}
+	if *graph != "" {
+		g := newLinkGraph(args, all)
+		if err := g.writeDot(os.Stdout); err != nil {
+			return err
+		}
+		for _, f := range g.orphans() {
+			logger.Warn(f+": orphan, no other file links to it", "file", f)
+		}
+	} else if *asJSON {
+		if refs == nil {
+			refs = []*linkRef{}
+		}
+		b, err := json.MarshalIndent(refs, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\n", b)
+	} else {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		for _, l := range refs {
+			fmt.Fprintf(tw, "%s:%d:%d\t%s\t%s\t%s", l.File, l.Line, l.Column, l.Kind, l.Dest, l.Text)
+			if l.Status != 0 && l.Broken == "" {
+				fmt.Fprintf(tw, "\t%d", l.Status)
+			}
+			if l.Final != "" {
+				fmt.Fprintf(tw, "\t-> %s", l.Final)
+			}
+			if l.Broken != "" {
+				fmt.Fprintf(tw, "\t%s", l.Broken)
+			}
+			fmt.Fprintln(tw)
+		}
+		tw.Flush()
+	}
+	if *check && len(refs) > 0 {
+		return fmt.Errorf("%s found among %d in %s", plural(len(refs), "broken link"), total, plural(len(args), "file"))
+	}
+	if *check {
+		logger.Info(fmt.Sprintf("%s in %s checked, none broken", plural(total, "link"), plural(len(args), "file")), "links", total, "files", len(args))
+	}
+	return nil
+}
+
+// findLinks returns the links & images in doc, in order
+func findLinks(doc *parser.Document) []*linkRef {
+	var refs []*linkRef
+	var walk func(n *parser.Node)
+	walk = func(n *parser.Node) {
+		if n.Kind == parser.NodeLink || n.Kind == parser.NodeImage {
+			pos := doc.Position(n.Range.Start)
+			kind := "link"
+			if n.Kind == parser.NodeImage {
+				kind = "image"
+			}
+			refs = append(refs, &linkRef{
+				File:   doc.Name,
+				Line:   pos.Line,
+				Column: pos.Column,
+				Kind:   kind,
+				Text:   n.PlainText(),
+				Dest:   n.Dest,
+				Title:  n.Title,
+			})
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(doc.Root)
+	return refs
+}
+
+// probeWorkers is how many http requests links -http makes at once,
+// unless told otherwise with -j
+const probeWorkers = 8
+
+// checkLinks sets Broken on each of refs whose target can't be found. With
+// local set, local targets are looked for relative to the file they're in,
+// along with the headings #fragments of markdown files refer to, and with a
+// checker http targets are requested. Other kinds of link aren't checked
+func checkLinks(refs []*linkRef, local bool, checker *linkChecker) {
+	var remote []*linkRef
+	anchors := newAnchorIndex(osFiles)
+	for _, l := range refs {
+		u, err := url.Parse(l.Dest)
+		switch {
+		case err != nil:
+			l.Broken = err.Error()
+		case u.Scheme == "http" || u.Scheme == "https":
+			remote = append(remote, l)
+		case local && u.Scheme == "" && u.Host == "" && (u.Path != "" || u.Fragment != ""):
+			l.Broken = checkLocal(anchors, l.File, u)
+		}
+	}
+	if checker != nil {
+		checker.checkAll(remote)
+	}
+}
+
+// checkLocal returns why the target of u, linked to from the file from,
+// doesn't exist, or "" if it does. The target of a link to a #fragment of
+// a markdown file is the heading with that anchor
+func checkLocal(anchors *anchorIndex, from string, u *url.URL) string {
+	path := filepath.FromSlash(u.Path)
+	switch {
+	case path == "" && from == "stdin":
+		return "" // Nothing to look at
+	case path == "":
+		path = from
+	case !filepath.IsAbs(path) && from != "stdin":
+		path = filepath.Join(filepath.Dir(from), path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return "no such file"
+		}
+		return err.Error()
+	}
+	if u.Fragment != "" && isMarkdown(path) {
+		return anchors.check(path, u.Fragment)
+	}
+	return ""
+}
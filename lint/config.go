@@ -0,0 +1,99 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Config turns rules on & off and sets their parameters. It's read from
+// JSON like {"default": true, "no-bare-urls": false, "line-length": {"max": 100}},
+// where each rule is named by its ID and set to true or false to turn it
+// on or off, or to an object of its fields to turn it on with those set.
+// Rules not named are on unless "default" is false
+type Config map[string]json.RawMessage
+
+// Rules returns those of rules c leaves on, with their parameters set
+func (c Config) Rules(rules []Rule) ([]Rule, error) {
+	known := make(map[string]bool)
+	for _, r := range append(DefaultRules, rules...) {
+		known[r.ID()] = true
+	}
+	on := true
+	for id, raw := range c {
+		if id == "default" {
+			if err := json.Unmarshal(raw, &on); err != nil {
+				return nil, fmt.Errorf("lint config: default must be true or false")
+			}
+		} else if !known[id] {
+			return nil, fmt.Errorf("lint config: unknown rule %q", id)
+		}
+	}
+	var out []Rule
+	for _, r := range rules {
+		raw, ok := c[r.ID()]
+		if !ok {
+			if on {
+				out = append(out, r)
+			}
+			continue
+		}
+		var enabled bool
+		if json.Unmarshal(raw, &enabled) == nil {
+			if enabled {
+				out = append(out, r)
+			}
+			continue
+		}
+		// Unmarshal the parameters into a copy of the rule
+		v := reflect.New(reflect.TypeOf(r))
+		v.Elem().Set(reflect.ValueOf(r))
+		if err := json.Unmarshal(raw, v.Interface()); err != nil {
+			return nil, fmt.Errorf("lint config: %s: want true, false or an object of its parameters: %v", r.ID(), err)
+		}
+		out = append(out, v.Elem().Interface().(Rule))
+	}
+	return out, nil
+}
+
+// directive matches the comments turning rules off & on again within a
+// document: <!-- gomd-disable rule-id ... --> and <!-- gomd-enable rule-id ... -->,
+// either of which applies to every rule if none are named
+var directive = regexp.MustCompile(`<!--\s*gomd-(disable|enable)((?:\s+[\w-]+)*)\s*-->`)
+
+// suppress drops the diagnostics, in order of position, that f's comments
+// turn their rules off for
+func suppress(f *File, diags []Diagnostic) []Diagnostic {
+	var (
+		all bool                // Whether every rule is off
+		off = map[string]bool{} // Rules turned off by name
+		on  = map[string]bool{} // Rules turned back on by name while all are off
+	)
+	matches := directive.FindAllStringSubmatchIndex(f.Doc.Source, -1)
+	out := diags[:0]
+	for _, d := range diags {
+		for len(matches) > 0 && matches[0][0] <= d.Pos.Offset {
+			m := matches[0]
+			matches = matches[1:]
+			if f.InCode(m[0]) {
+				continue
+			}
+			disable, ids := f.Doc.Source[m[2]:m[3]] == "disable", strings.Fields(f.Doc.Source[m[4]:m[5]])
+			switch {
+			case disable && len(ids) == 0:
+				all, on = true, map[string]bool{}
+			case !disable && len(ids) == 0:
+				all, off, on = false, map[string]bool{}, map[string]bool{}
+			}
+			for _, id := range ids {
+				off[id], on[id] = disable, !disable && all
+			}
+		}
+		if !off[d.Rule] && !(all && !on[d.Rule]) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
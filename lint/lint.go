@@ -0,0 +1,187 @@
+// Package lint checks markdown documents for common mistakes & style
+// problems, reporting each as a Diagnostic at its place in the source
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"../parser"
+)
+
+// Diagnostic is a problem a Rule found in a document
+type Diagnostic struct {
+	File    string
+	Pos     parser.Position
+	Rule    string // ID of the Rule that found it
+	Message string
+	Fix     *Fix // How to resolve it, if the rule knows a safe way
+}
+
+// Fix is an edit resolving a Diagnostic: the source from byte offset Start
+// up to End is replaced by Text
+type Fix struct {
+	Start, End int
+	Text       string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%v: %s: %s", d.File, d.Pos, d.Rule, d.Message)
+}
+
+// Rule checks a document for one kind of problem
+type Rule interface {
+	ID() string // Short kebab-case name diagnostics are reported under
+	Check(f *File)
+}
+
+// File is the document a Rule checks, parsed with source ranges so
+// diagnostics can be placed
+type File struct {
+	Name  string
+	Doc   *parser.Document
+	Lines []string // Source split into lines, without line endings
+
+	starts []int // Offset of the start of each line
+	rule   string
+	diags  []Diagnostic
+}
+
+// Report records a problem starting at the byte offset in the source
+func (f *File) Report(offset int, format string, args ...interface{}) {
+	f.diags = append(f.diags, Diagnostic{
+		File:    f.Name,
+		Pos:     f.Position(offset),
+		Rule:    f.rule,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// ReportFix records a problem that fix resolves, starting where fix does
+func (f *File) ReportFix(fix Fix, format string, args ...interface{}) {
+	f.Report(fix.Start, format, args...)
+	f.diags[len(f.diags)-1].Fix = &fix
+}
+
+// Position returns the line & column of the byte offset in the source
+func (f *File) Position(offset int) parser.Position {
+	line := sort.SearchInts(f.starts, offset+1) - 1
+	return parser.Position{Offset: offset, Line: line + 1, Column: offset - f.starts[line] + 1}
+}
+
+// LineStart returns the offset of the start of line i, counting from 0
+func (f *File) LineStart(i int) int {
+	return f.starts[i]
+}
+
+// InCode reports whether the byte offset is inside a code block, where
+// text is taken literally
+func (f *File) InCode(offset int) bool {
+	for _, n := range f.Doc.Root.Children {
+		if n.Kind == parser.NodeCodeBlock && n.Range.Start <= offset && offset < n.Range.End {
+			return true
+		}
+	}
+	return false
+}
+
+// Walk calls fn for n and everything beneath it, parents first
+func Walk(n *parser.Node, fn func(*parser.Node)) {
+	fn(n)
+	for _, c := range n.Children {
+		Walk(c, fn)
+	}
+}
+
+// Linter checks documents against a set of rules. The zero value checks
+// nothing; New returns one with the DefaultRules
+type Linter struct {
+	Rules []Rule
+}
+
+// New returns a Linter checking rules, or DefaultRules if none are given
+func New(rules ...Rule) *Linter {
+	if len(rules) == 0 {
+		rules = DefaultRules
+	}
+	return &Linter{Rules: rules}
+}
+
+// rangeParser parses documents with the source ranges rules need
+var rangeParser = parser.New(parser.WithSourceRanges())
+
+// Lint checks the markdown src, from the file name, returning what the
+// rules found in order of position, except where the document's comments
+// turn them off
+func (l *Linter) Lint(name, src string) ([]Diagnostic, error) {
+	doc, err := rangeParser.Parse(name, src)
+	if err != nil {
+		return nil, err
+	}
+	f := &File{Name: name, Doc: doc}
+	for start := 0; ; {
+		end := strings.IndexByte(doc.Source[start:], '\n')
+		f.starts = append(f.starts, start)
+		if end < 0 {
+			f.Lines = append(f.Lines, doc.Source[start:])
+			break
+		}
+		f.Lines = append(f.Lines, strings.TrimSuffix(doc.Source[start:start+end], "\r"))
+		start += end + 1
+	}
+	for _, r := range l.Rules {
+		f.rule = r.ID()
+		r.Check(f)
+	}
+	sort.SliceStable(f.diags, func(i, j int) bool {
+		return f.diags[i].Pos.Offset < f.diags[j].Pos.Offset
+	})
+	return suppress(f, f.diags), nil
+}
+
+// ApplyFixes returns src, which diags were found in, with their fixes
+// applied, and how many were. Fixes overlapping one before them are left
+// out, to be found and applied again in the fixed source
+func ApplyFixes(src string, diags []Diagnostic) (string, int) {
+	var fixes []*Fix
+	for _, d := range diags {
+		if d.Fix != nil {
+			fixes = append(fixes, d.Fix)
+		}
+	}
+	sort.SliceStable(fixes, func(i, j int) bool { return fixes[i].Start < fixes[j].Start })
+	var b strings.Builder
+	last, applied := 0, 0
+	for _, fix := range fixes {
+		if fix.Start < last || fix.End > len(src) {
+			continue
+		}
+		b.WriteString(src[last:fix.Start])
+		b.WriteString(fix.Text)
+		last = fix.End
+		applied++
+	}
+	b.WriteString(src[last:])
+	return b.String(), applied
+}
+
+// maxFixPasses bounds how many times Fix lints & fixes a document, in case
+// fixes never settle
+const maxFixPasses = 10
+
+// Fix lints src as Lint does, applying the fixes found until there are
+// none left, and returns the fixed source with the problems that remain
+func (l *Linter) Fix(name, src string) (string, []Diagnostic, error) {
+	for pass := 1; ; pass++ {
+		diags, err := l.Lint(name, src)
+		if err != nil {
+			return "", nil, err
+		}
+		fixed, n := ApplyFixes(src, diags)
+		if n == 0 || fixed == src || pass == maxFixPasses {
+			return src, diags, nil
+		}
+		src = fixed
+	}
+}
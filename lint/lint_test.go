@@ -0,0 +1,80 @@
+package lint_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"../lint"
+)
+
+const doc = "# Title\n\n## A\t\n\n\n\nSee http://example.com and [x](#nope).\n\n# Title\n\n![](missing.png)\n" +
+	"<!-- gomd-disable no-trailing-spaces -->\ntrailing   \n<!-- gomd-enable -->\nhard break  \nno newline"
+
+func TestLint(t *testing.T) {
+	diags, err := lint.New().Lint("doc.md", doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range diags {
+		got = append(got, d.String())
+	}
+	want := []string{
+		"doc.md:3:5: no-hard-tabs: hard tab",
+		"doc.md:3:5: no-trailing-spaces: trailing whitespace",
+		"doc.md:5:1: no-multiple-blanks: multiple consecutive blank lines",
+		"doc.md:7:5: no-bare-urls: bare URL; write <http://example.com> to make it a link",
+		"doc.md:7:28: valid-fragments: no heading has the anchor #nope",
+		`doc.md:9:1: single-h1: more than one level 1 heading; the first is "Title"`,
+		`doc.md:9:1: no-duplicate-headings: heading "Title" is the same as the one on line 1`,
+		"doc.md:11:1: image-alt-text: image missing.png has no alt text",
+		"doc.md:11:1: no-missing-images: image missing.png doesn't exist",
+		"doc.md:16:11: final-newline: no line ending at the end of the file",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("found\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestApplyFixes(t *testing.T) {
+	diags, err := lint.New().Lint("doc.md", "# Title\n\nA\ttab.\n\n\n\nEnd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixed, n := lint.ApplyFixes("# Title\n\nA\ttab.\n\n\n\nEnd", diags)
+	if want := "# Title\n\nA   tab.\n\nEnd\n"; fixed != want || n != 3 {
+		t.Errorf("fixed %d, to %q, want 3, to %q", n, fixed, want)
+	}
+	if diags, _ := lint.New().Lint("doc.md", fixed); len(diags) != 0 {
+		t.Errorf("fixed document still has %v", diags)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	var c lint.Config
+	if err := json.Unmarshal([]byte(`{"default": false, "line-length": {"max": 10}, "final-newline": true}`), &c); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := c.Rules(lint.DefaultRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diags, err := lint.New(rules...).Lint("doc.md", "A line longer than ten.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, d := range diags {
+		ids = append(ids, d.Rule)
+	}
+	if got := strings.Join(ids, " "); got != "line-length final-newline" {
+		t.Errorf("rules %q reported, want line-length & final-newline", got)
+	}
+	if err := json.Unmarshal([]byte(`{"no-such-rule": true}`), &c); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Rules(lint.DefaultRules); err == nil {
+		t.Error("no error for an unknown rule")
+	}
+}
@@ -0,0 +1,416 @@
+package lint
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"../parser"
+	"../render"
+)
+
+// DefaultRules are the rules a Linter checks unless given others
+var DefaultRules = []Rule{
+	HardTabs{},
+	MultipleBlankLines{},
+	FinalNewline{},
+	TrailingSpaces{},
+	LineLength{},
+	HeadingStyle{},
+	HeadingIncrement{},
+	SingleTitle{},
+	EmptyHeadings{},
+	DuplicateHeadings{},
+	DuplicateAnchors{},
+	FragmentLinks{},
+	BareURLs{},
+	ImageAltText{},
+	MissingImages{},
+}
+
+// AnchorRules are the rules checking heading anchors & the links to them
+var AnchorRules = []Rule{
+	DuplicateHeadings{},
+	DuplicateAnchors{},
+	FragmentLinks{},
+}
+
+// AccessibilityRules are the rules checking documents can be read by
+// those using screen readers
+var AccessibilityRules = []Rule{
+	ImageAltText{},
+}
+
+// HardTabs reports tabs used for indentation or spacing outside code
+// blocks, where they render differently from editor to editor. The fix
+// expands them to spaces, with tab stops every 4 columns
+type HardTabs struct{}
+
+func (HardTabs) ID() string { return "no-hard-tabs" }
+
+func (HardTabs) Check(f *File) {
+	for i, line := range f.Lines {
+		if col := strings.IndexByte(line, '\t'); col >= 0 && !f.InCode(f.LineStart(i)+col) {
+			start := f.LineStart(i) + col
+			f.ReportFix(Fix{start, f.LineStart(i) + len(line), expandTabs(line, col)}, "hard tab")
+		}
+	}
+}
+
+// expandTabs returns line from byte col on, which starts with a tab, with
+// its tabs replaced by spaces up to the next tab stop
+func expandTabs(line string, col int) string {
+	var b strings.Builder
+	width := utf8.RuneCountInString(line[:col])
+	for _, c := range line[col:] {
+		if c == '\t' {
+			n := 4 - width%4
+			b.WriteString(strings.Repeat(" ", n))
+			width += n
+			continue
+		}
+		b.WriteRune(c)
+		width++
+	}
+	return b.String()
+}
+
+// MultipleBlankLines reports runs of more than one blank line outside
+// code blocks, which render no differently from a single one. The fix
+// collapses them into one
+type MultipleBlankLines struct{}
+
+func (MultipleBlankLines) ID() string { return "no-multiple-blanks" }
+
+func (MultipleBlankLines) Check(f *File) {
+	blank := func(i int) bool {
+		return strings.TrimSpace(f.Lines[i]) == "" && !(i == len(f.Lines)-1 && f.Lines[i] == "")
+	}
+	blanks := 0
+	for i := range f.Lines {
+		if !blank(i) {
+			blanks = 0
+			continue
+		}
+		if blanks++; blanks == 2 && !f.InCode(f.LineStart(i)) {
+			end := i + 1
+			for end < len(f.Lines) && blank(end) {
+				end++
+			}
+			fix := Fix{f.LineStart(i), len(f.Doc.Source), ""}
+			if end < len(f.Lines) {
+				fix.End = f.LineStart(end)
+			}
+			f.ReportFix(fix, "multiple consecutive blank lines")
+		}
+	}
+}
+
+// FinalNewline reports a document that doesn't end with a line ending,
+// which many tools expect of text files. The fix adds one
+type FinalNewline struct{}
+
+func (FinalNewline) ID() string { return "final-newline" }
+
+func (FinalNewline) Check(f *File) {
+	if src := f.Doc.Source; src != "" && !strings.HasSuffix(src, "\n") {
+		f.ReportFix(Fix{len(src), len(src), "\n"}, "no line ending at the end of the file")
+	}
+}
+
+// TrailingSpaces reports whitespace at the end of lines outside code
+// blocks, except the two spaces that make a hard line break
+type TrailingSpaces struct{}
+
+func (TrailingSpaces) ID() string { return "no-trailing-spaces" }
+
+func (TrailingSpaces) Check(f *File) {
+	for i, line := range f.Lines {
+		trimmed := strings.TrimRight(line, " \t")
+		if trimmed == line || trimmed != "" && line[len(trimmed):] == "  " || f.InCode(f.LineStart(i)) {
+			continue
+		}
+		f.Report(f.LineStart(i)+len(trimmed), "trailing whitespace")
+	}
+}
+
+// LineLength reports lines outside code blocks longer than Max characters,
+// or 80 if it's 0. Lines that only run over in a word with no spaces, like
+// a long URL, are let be, as they can't be wrapped
+type LineLength struct {
+	Max int
+}
+
+func (LineLength) ID() string { return "line-length" }
+
+func (r LineLength) Check(f *File) {
+	max := r.Max
+	if max <= 0 {
+		max = 80
+	}
+	for i, line := range f.Lines {
+		if utf8.RuneCountInString(line) <= max || f.InCode(f.LineStart(i)) {
+			continue
+		}
+		over, count := 0, 0 // Byte offset of the first character past max
+		for over = range line {
+			if count == max {
+				break
+			}
+			count++
+		}
+		if !strings.ContainsAny(line[over:], " \t") {
+			continue
+		}
+		f.Report(f.LineStart(i)+over, "line is %d characters long, more than %d", utf8.RuneCountInString(line), max)
+	}
+}
+
+// HeadingStyle reports headings that aren't in the ATX style, "# Title":
+// those underlined with = or -, and those closed with #s. The fix rewrites
+// them in the ATX style
+type HeadingStyle struct{}
+
+func (HeadingStyle) ID() string { return "heading-style" }
+
+func (HeadingStyle) Check(f *File) {
+	Walk(f.Doc.Root, func(n *parser.Node) {
+		if n.Kind != parser.NodeHeading {
+			return
+		}
+		src := strings.TrimRight(f.Doc.Source[n.Range.Start:n.Range.End], " \t")
+		open := strings.TrimRight(strings.TrimRight(src, "#"), " \t") // Without any closing #s
+		var problem string
+		switch {
+		case !strings.HasPrefix(strings.TrimLeft(src, " "), "#"):
+			problem = "underlined heading"
+		case open != src && len(n.Children) > 0 && n.Children[len(n.Children)-1].Range.End <= n.Range.Start+len(open):
+			problem = "heading closed with #s" // Rather than ending in text with a #
+		default:
+			return
+		}
+		var b strings.Builder
+		if err := render.NewMarkdownRenderer().Render(&b, n); err != nil {
+			f.Report(n.Range.Start, "%s; write it as %s Title", problem, strings.Repeat("#", n.Level))
+			return
+		}
+		atx := strings.TrimRight(b.String(), "\n")
+		f.ReportFix(Fix{n.Range.Start, n.Range.End, atx}, "%s; write it as %s", problem, atx)
+	})
+}
+
+// HeadingIncrement reports headings more than one level deeper than the
+// heading before them, which skips a level of the document's outline
+type HeadingIncrement struct{}
+
+func (HeadingIncrement) ID() string { return "heading-increment" }
+
+func (HeadingIncrement) Check(f *File) { reportProblems(f, parser.SkippedLevel) }
+
+// SingleTitle reports level 1 headings after the first, as a document
+// should have the one title
+type SingleTitle struct{}
+
+func (SingleTitle) ID() string { return "single-h1" }
+
+func (SingleTitle) Check(f *File) { reportProblems(f, parser.ExtraTitle) }
+
+// EmptyHeadings reports headings with no text, which leave a gap in the
+// document's outline
+type EmptyHeadings struct{}
+
+func (EmptyHeadings) ID() string { return "no-empty-headings" }
+
+func (EmptyHeadings) Check(f *File) { reportProblems(f, parser.EmptyHeading) }
+
+// reportProblems reports the problems of kind the document's Validate finds
+func reportProblems(f *File, kind parser.ProblemKind) {
+	var invalid *parser.ValidationError
+	if !errors.As(f.Doc.Validate(), &invalid) {
+		return
+	}
+	for _, p := range invalid.Problems {
+		if p.Kind == kind {
+			f.Report(p.Node.Range.Start, "%s", p.Msg)
+		}
+	}
+}
+
+// DuplicateHeadings reports headings with the same text as an earlier
+// heading, which readers can't tell apart in a table of contents
+type DuplicateHeadings struct{}
+
+func (DuplicateHeadings) ID() string { return "no-duplicate-headings" }
+
+func (DuplicateHeadings) Check(f *File) {
+	first := make(map[string]*parser.Node) // By text
+	Walk(f.Doc.Root, func(n *parser.Node) {
+		if n.Kind != parser.NodeHeading {
+			return
+		}
+		text := strings.TrimSpace(n.PlainText())
+		if prev := first[text]; prev != nil {
+			f.Report(n.Range.Start, "heading %q is the same as the one on line %d", text, f.Position(prev.Range.Start).Line)
+			return
+		}
+		first[text] = n
+	})
+}
+
+// DuplicateAnchors reports headings whose anchor is the same as that of an
+// earlier heading with different text, so links meant for them lead to the
+// first instead. Headings with the same text are left to DuplicateHeadings
+type DuplicateAnchors struct{}
+
+func (DuplicateAnchors) ID() string { return "no-duplicate-anchors" }
+
+func (DuplicateAnchors) Check(f *File) {
+	ids := parser.HeadingIDs(f.Doc.Root)
+	first := make(map[string]*parser.Node) // By slug
+	Walk(f.Doc.Root, func(n *parser.Node) {
+		if n.Kind != parser.NodeHeading || n.ID != "" {
+			return
+		}
+		slug := parser.Slug(n.PlainText())
+		if prev := first[slug]; prev != nil {
+			if strings.TrimSpace(prev.PlainText()) == strings.TrimSpace(n.PlainText()) {
+				return
+			}
+			f.Report(n.Range.Start, "anchor #%s is already taken by the heading on line %d, so this one is #%s; reword one of them",
+				slug, f.Position(prev.Range.Start).Line, ids[n])
+			return
+		}
+		first[slug] = n
+	})
+}
+
+// FragmentLinks reports links to a #fragment of the same document that no
+// heading has the anchor of, suggesting the closest anchor there is
+type FragmentLinks struct{}
+
+func (FragmentLinks) ID() string { return "valid-fragments" }
+
+func (FragmentLinks) Check(f *File) {
+	var anchors []string
+	known := make(map[string]bool)
+	for _, id := range parser.HeadingIDs(f.Doc.Root) {
+		anchors, known[id] = append(anchors, id), true
+	}
+	Walk(f.Doc.Root, func(n *parser.Node) {
+		if n.Kind != parser.NodeLink || !strings.HasPrefix(n.Dest, "#") {
+			return
+		}
+		fragment, err := url.PathUnescape(n.Dest[1:])
+		if err != nil {
+			fragment = n.Dest[1:]
+		}
+		if fragment == "" || fragment == "top" || known[fragment] { // Browsers know #top
+			return
+		}
+		if s := Suggest(fragment, anchors); s != "" {
+			f.Report(n.Range.Start, "no heading has the anchor #%s; did you mean #%s?", fragment, s)
+		} else {
+			f.Report(n.Range.Start, "no heading has the anchor #%s", fragment)
+		}
+	})
+}
+
+// bareURL matches a URL written out in text rather than as a link
+var bareURL = regexp.MustCompile(`https?://[^\s<>()]*[^\s<>().,;:!?'"]`)
+
+// BareURLs reports URLs written out in text, which plain CommonMark doesn't
+// turn into links; they should be in <angle brackets> or a [link](url)
+type BareURLs struct{}
+
+func (BareURLs) ID() string { return "no-bare-urls" }
+
+func (BareURLs) Check(f *File) {
+	var walk func(n *parser.Node)
+	walk = func(n *parser.Node) {
+		switch n.Kind {
+		case parser.NodeLink, parser.NodeImage, parser.NodeCodeSpan, parser.NodeCodeBlock:
+			return
+		case parser.NodeText:
+			for _, m := range bareURL.FindAllStringIndex(n.Literal, -1) {
+				url := n.Literal[m[0]:m[1]]
+				f.Report(n.Range.Start+m[0], "bare URL; write <%s> to make it a link", url)
+			}
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(f.Doc.Root)
+}
+
+// ImageAltText reports images without alt text, which leaves readers who
+// can't see them, and search engines, with nothing to go on
+type ImageAltText struct{}
+
+func (ImageAltText) ID() string { return "image-alt-text" }
+
+func (ImageAltText) Check(f *File) {
+	for _, p := range f.Doc.MissingAltText() {
+		f.Report(p.Node.Range.Start, "%s", p.Msg)
+	}
+}
+
+// MissingImages reports images whose destination is a relative path to a
+// file that doesn't exist, resolved from the directory of the document
+type MissingImages struct{}
+
+func (MissingImages) ID() string { return "no-missing-images" }
+
+func (MissingImages) Check(f *File) {
+	Walk(f.Doc.Root, func(n *parser.Node) {
+		if n.Kind != parser.NodeImage {
+			return
+		}
+		u, err := url.Parse(n.Dest)
+		if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" || strings.HasPrefix(u.Path, "/") {
+			return
+		}
+		if _, err := os.Stat(filepath.Join(filepath.Dir(f.Name), filepath.FromSlash(u.Path))); err != nil {
+			f.Report(n.Range.Start, "image %s doesn't exist", u.Path)
+		}
+	})
+}
+
+// Suggest returns whichever of candidates is most like s, if any is close
+// enough to be a likely misspelling of it
+func Suggest(s string, candidates []string) string {
+	best, bestDist := "", len(s)/3+2
+	for _, c := range candidates {
+		if d := editDistance(s, c); d < bestDist || d == bestDist && best != "" && c < best {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// editDistance returns how many runes must be inserted, deleted or
+// replaced to turn a into b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	row := make([]int, len(rb)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		diag := row[0]
+		row[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			next := min(row[j]+1, row[j-1]+1, diag+cost)
+			diag, row[j] = row[j], next
+		}
+	}
+	return row[len(rb)]
+}
@@ -0,0 +1,138 @@
+package lint
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema describes the front matter documents should have. It's read from
+// JSON like {"required": ["title"], "fields": {"date": {"type": "date"},
+// "status": {"enum": ["draft", "published"]}}}
+type Schema struct {
+	Required     []string         `json:"required"`     // Keys every document must have
+	Fields       map[string]Field `json:"fields"`       // What the values of keys must be
+	AllowUnknown bool             `json:"allowUnknown"` // Whether keys not in Required or Fields may be used
+}
+
+// Field describes the values a front matter key may have
+type Field struct {
+	Type string   `json:"type"` // One of the FieldTypes, or "" for any
+	Enum []string `json:"enum"` // The values allowed, if only some are
+}
+
+// FieldTypes are the types a Field may have, each with what a value of it
+// must look like
+var FieldTypes = map[string]func(v string) bool{
+	"string": func(v string) bool { return true },
+	"int": func(v string) bool {
+		_, err := strconv.Atoi(v)
+		return err == nil
+	},
+	"number": func(v string) bool {
+		_, err := strconv.ParseFloat(v, 64)
+		return err == nil
+	},
+	"bool": func(v string) bool {
+		_, err := strconv.ParseBool(v)
+		return err == nil
+	},
+	"date": func(v string) bool {
+		for _, layout := range []string{"2006-01-02", "2006-01-02 15:04", "2006-01-02 15:04:05", time.RFC3339} {
+			if _, err := time.Parse(layout, v); err == nil {
+				return true
+			}
+		}
+		return false
+	},
+	"url": func(v string) bool {
+		u, err := url.Parse(v)
+		return err == nil && u.Scheme != "" && u.Host != ""
+	},
+	"list": func(v string) bool {
+		return strings.HasPrefix(v, "[") && strings.HasSuffix(v, "]")
+	},
+}
+
+// Check returns an error if s uses a type that isn't one of FieldTypes
+func (s *Schema) Check() error {
+	for key, f := range s.Fields {
+		if _, ok := FieldTypes[f.Type]; f.Type != "" && !ok {
+			var types []string
+			for t := range FieldTypes {
+				types = append(types, t)
+			}
+			sort.Strings(types)
+			return fmt.Errorf("front matter schema: %s: unknown type %q, want one of %s", key, f.Type, strings.Join(types, ", "))
+		}
+	}
+	return nil
+}
+
+// FrontMatterSchema reports documents whose front matter doesn't match
+// Schema: that are missing required keys, have values of the wrong type or
+// not among those allowed, or have keys the schema doesn't know
+type FrontMatterSchema struct {
+	Schema *Schema
+}
+
+func (FrontMatterSchema) ID() string { return "front-matter-schema" }
+
+func (r FrontMatterSchema) Check(f *File) {
+	s := r.Schema
+	if s == nil {
+		return
+	}
+	meta := f.Doc.Meta
+	for _, key := range s.Required {
+		if _, ok := meta[key]; !ok {
+			f.Report(0, "front matter has no %s", key)
+		}
+	}
+	var keys []string
+	for key := range meta {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		v := meta[key]
+		field, known := s.Fields[key]
+		switch {
+		case !known && !s.AllowUnknown && !contains(s.Required, key):
+			f.Report(f.keyOffset(key), "unknown front matter key %s", key)
+		case field.Type != "" && !FieldTypes[field.Type](v):
+			f.Report(f.keyOffset(key), "front matter %s is %q, not a %s", key, v, field.Type)
+		case len(field.Enum) > 0 && !contains(field.Enum, v):
+			f.Report(f.keyOffset(key), "front matter %s is %q, not one of %s", key, v, strings.Join(field.Enum, ", "))
+		}
+	}
+}
+
+// keyOffset returns the offset of the line of the front matter setting key,
+// or 0 if it can't be found
+func (f *File) keyOffset(key string) int {
+	if len(f.Lines) == 0 || f.Lines[0] != "---" {
+		return 0
+	}
+	for i, line := range f.Lines[1:] {
+		if line == "---" {
+			break
+		}
+		if k, _, ok := strings.Cut(line, ":"); ok && strings.TrimSpace(k) == key {
+			return f.LineStart(i + 1)
+		}
+	}
+	return 0
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"./lint"
+)
+
+// lintFiles reports the problems the lint rules find in each file, failing
+// if there are any
+func lintFiles(args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	list := fs.Bool("rules", false, "list the rules checked and exit")
+	jobs := jobsFlag(fs)
+	anchors := fs.Bool("anchors", false, "only check for duplicate heading anchors & links to #fragments that don't exist")
+	alt := fs.Bool("alt", false, "only check for images without alt text, for accessibility audits")
+	fix := fs.Bool("fix", false, "fix the problems rules know how to, rewriting the files, and report the rest")
+	configFile := fs.String("config", "", "read the rules to check from this file rather than the nearest "+configName)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s lint [-rules] [-anchors | -alt] [-fix] [-config file] [-j n] [file or pattern ...]\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Checks each markdown file, or standard input if none are given, printing")
+		fmt.Fprintln(os.Stderr, "file:line:col: rule: message for every problem found. Exits non-zero if")
+		fmt.Fprintln(os.Stderr, "there are any. With -fix, the problems that have a safe fix, like hard tabs,")
+		fmt.Fprintln(os.Stderr, "runs of blank lines, a missing final newline and underlined headings, are")
+		fmt.Fprintln(os.Stderr, "fixed in place and only the rest are printed.")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Rules are turned on & off, and their parameters set, by the \"lint\" object")
+		fmt.Fprintf(os.Stderr, "of %s, in the current directory or the nearest one above it:\n\n", configName)
+		fmt.Fprintln(os.Stderr, `    {"lint": {"default": true, "no-bare-urls": false, "line-length": {"max": 100}}}`)
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Its \"frontMatter\" object is a schema the front matter of each document is")
+		fmt.Fprintln(os.Stderr, "checked against: the keys it must have, the type (string, int, number,")
+		fmt.Fprintln(os.Stderr, "bool, date, url or list) or values allowed of each, and whether others may")
+		fmt.Fprintln(os.Stderr, "be used:")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, `    {"frontMatter": {"required": ["title"], "fields": {"date": {"type": "date"},`)
+		fmt.Fprintln(os.Stderr, `        "status": {"enum": ["draft", "published"]}}, "allowUnknown": false}}`)
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Within a document, <!-- gomd-disable rule-id ... --> turns the rules named")
+		fmt.Fprintln(os.Stderr, "off, or every rule if none are, until <!-- gomd-enable rule-id ... --> turns")
+		fmt.Fprintln(os.Stderr, "them on again.")
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+	}
+	args, err := expandArgs(parseInterspersed(fs, args))
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		return err
+	}
+	rules := lint.DefaultRules
+	switch {
+	case *anchors && *alt:
+		return usagef("-anchors and -alt can't be used together")
+	case *anchors:
+		rules = lint.AnchorRules
+	case *alt:
+		rules = lint.AccessibilityRules
+	case cfg.FrontMatter != nil:
+		rules = append(rules[:len(rules):len(rules)], lint.FrontMatterSchema{Schema: cfg.FrontMatter})
+	}
+	if rules, err = cfg.Lint.Rules(rules); err != nil {
+		return err
+	}
+	l := &lint.Linter{Rules: rules}
+	if *list {
+		var ids []string
+		for _, r := range l.Rules {
+			ids = append(ids, r.ID())
+		}
+		sort.Strings(ids)
+		fmt.Println(strings.Join(ids, "\n"))
+		return nil
+	}
+	if len(args) == 0 {
+		args = []string{stdinName}
+	}
+	if *fix {
+		for _, arg := range args {
+			if arg == stdinName {
+				return usagef("-fix rewrites files, so can't be used on standard input")
+			}
+		}
+	}
+	counts, fixed := make([]int, len(args)), make([]int, len(args))
+	err = runOrdered(os.Stdout, args, *jobs, func(i int, path string, out *bytes.Buffer) error {
+		name, src, err := readSource(path)
+		if err != nil {
+			return err
+		}
+		var diags []lint.Diagnostic
+		if *fix {
+			var out string
+			if out, diags, err = l.Fix(name, src); err == nil && out != src {
+				fixed[i] = 1
+				err = ioutil.WriteFile(path, []byte(out), 0644)
+			}
+		} else {
+			diags, err = l.Lint(name, src)
+		}
+		if err != nil {
+			return err
+		}
+		for _, d := range diags {
+			fmt.Fprintln(out, d)
+		}
+		counts[i] = len(diags)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	problems, files, changed := 0, 0, 0
+	for i, n := range counts {
+		if n > 0 {
+			problems, files = problems+n, files+1
+		}
+		changed += fixed[i]
+	}
+	if *fix {
+		logger.Info("fixed "+plural(changed, "file"), "fixed", changed)
+	}
+	if problems > 0 {
+		return fmt.Errorf("%s found in %s of %d", plural(problems, "problem"), plural(files, "file"), len(args))
+	}
+	logger.Info(plural(len(args), "file")+" checked, no problems found", "files", len(args), "problems", 0)
+	return nil
+}
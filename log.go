@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Environment variables choosing how progress & problems are logged
+const (
+	logFormatEnv = "GOMD_LOG"       // plain, text or json
+	logLevelEnv  = "GOMD_LOG_LEVEL" // debug, info, warn or error
+)
+
+// Formats of logFormatEnv
+const (
+	logPlain = "plain" // Each message on a line of its own, as people read them
+	logText  = "text"  // slog's key=value lines
+	logJSON  = "json"  // A JSON object a line
+)
+
+// logger is where commands report progress & problems, on standard error
+var logger = slog.New(&plainHandler{w: os.Stderr, level: slog.LevelInfo})
+
+// setupLogging sets logger up as the environment says
+func setupLogging() error {
+	var level slog.Level
+	if s := os.Getenv(logLevelEnv); s != "" {
+		if err := level.UnmarshalText([]byte(s)); err != nil {
+			return usagef("%s: unknown level %q, want debug, info, warn or error", logLevelEnv, s)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	switch format := os.Getenv(logFormatEnv); format {
+	case "", logPlain:
+		logger = slog.New(&plainHandler{w: os.Stderr, level: level})
+	case logText:
+		logger = slog.New(slog.NewTextHandler(os.Stderr, opts))
+	case logJSON:
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, opts))
+	default:
+		return usagef("%s: unknown format %q, want %s, %s or %s", logFormatEnv, format, logPlain, logText, logJSON)
+	}
+	return nil
+}
+
+// plainHandler writes just the message of each record, leaving the
+// attributes to the other formats
+type plainHandler struct {
+	level slog.Leveler
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (h *plainHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *plainHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := fmt.Fprintln(h.w, r.Message)
+	return err
+}
+
+func (h *plainHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *plainHandler) WithGroup(string) slog.Handler      { return h }
+
+// instrument logs each request h serves at debug level, with its status &
+// how long it took, and counts it in serverMetrics
+func instrument(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rw, r)
+		d := time.Since(start)
+		serverMetrics.requested(rw.status)
+		logger.Debug(fmt.Sprintf("%s %s %d %v", r.Method, r.URL.RequestURI(), rw.status, d.Round(time.Microsecond)),
+			"method", r.Method, "path", r.URL.Path, "status", rw.status, "duration", d)
+	})
+}
+
+// statusWriter notes the status of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Flush lets event streams through
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -1,14 +1,570 @@
-package main
-
-import (
-	// "fmt"
-	"io/ioutil"
-
-	"./parser"
-)
-
-func main() {
-	f, _ := ioutil.ReadFile("test.md")
-	parser.Lex("test", string(f))
-	// fmt.Println(m)
-}
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"./parser"
+	"./render"
+)
+
+// stdinName is the file argument that stands for standard input, and the
+// -o argument that stands for standard output
+const stdinName = "-"
+
+// formatExts is the extension of the files written in each format
+var formatExts = map[string]string{
+	render.FormatHTML:     ".html",
+	render.FormatSlides:   ".html",
+	render.FormatDocBook:  ".xml",
+	render.FormatJira:     ".jira",
+	render.FormatAsciiDoc: ".adoc",
+	render.FormatBBCode:   ".bbcode",
+	render.FormatMarkdown: ".md",
+	render.FormatText:     ".txt",
+	render.FormatLaTeX:    ".tex",
+	render.FormatMan:      ".1",
+	render.FormatJSON:     ".json",
+	render.FormatANSI:     ".ansi",
+	render.FormatPandoc:   ".json",
+	render.FormatPDF:      ".pdf",
+}
+
+// outputFormat returns the format named by the extension of the output file
+// out, if any, leaving those sharing an extension to the plainer format
+func outputFormat(out string) string {
+	ext := strings.ToLower(filepath.Ext(out))
+	for format, e := range formatExts {
+		if e == ext && format != render.FormatSlides && format != render.FormatPandoc {
+			return format
+		}
+	}
+	return ""
+}
+
+// commands are run by naming them as the first argument
+var commands = map[string]func(args []string) error{
+	"build":   build,
+	"serve":   serve,
+	"toc":     toc,
+	"lint":    lintFiles,
+	"fmt":     formatFiles,
+	"links":   links,
+	"stats":   stats,
+	"outline": outline,
+	"diff":    diffDocs,
+	"grep":    grep,
+	"site":    site,
+	"wiki":    wiki,
+	"index":   index,
+
+	"from-html": fromHTML,
+	"changelog": changelog,
+	"daemon":    daemon,
+}
+
+func main() {
+	if err := setupLogging(); err != nil {
+		fail(err)
+	}
+	if err := loadUserFiles(); err != nil {
+		fail(err)
+	}
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			command = os.Args[1]
+			if err := cmd(os.Args[2:]); err != nil {
+				fail(err)
+			}
+			return
+		}
+	}
+
+	out := flag.String("o", "", "write the output to this file, or - for standard output")
+	to := flag.String("to", "", "output format: "+formatNames()+"; by default the one the -o file's extension names, else "+render.FormatANSI+" on a terminal and "+render.FormatHTML+" otherwise")
+	color := flag.String("color", colorAuto, "when -to isn't given, whether to write colored text: "+colorAuto+" on a terminal, "+colorAlways+" or "+colorNever)
+	stdout := flag.Bool("stdout", false, "write every file's output to standard output, one after another")
+	theme := flag.String("theme", "", "write complete HTML pages styled with this theme: "+themeNames())
+	css := flag.String("css", "", "write complete HTML pages linking to this stylesheet")
+	tmpl := flag.String("template", "", "lay out each HTML page with this html/template file")
+	engine := flag.String("pdf-engine", "", "command converting HTML to PDF for -to pdf, rather than the first of "+strings.Join(render.PDFEngines, ", ")+" found")
+	standalone := flag.Bool("standalone", false, "write complete HTML pages; the default when writing files")
+	fragment := flag.Bool("fragment", false, "write only the HTML of the document, to embed in a page; the default when writing to standard output")
+	title := flag.String("title", "", "title each page this, whatever its front matter says")
+	lang := flag.String("lang", "", "language each page is in, such as en or fr-CA, whatever its front matter says")
+	meta := metaFlag{}
+	flag.Var(meta, "meta", "set a front matter `key=value`, whatever the file's says; may be repeated")
+	var pluginPaths listFlag
+	flag.Var(&pluginPaths, "plugin", "rewrite each document with this plugin `program` as it's parsed; may be repeated")
+	scripts := flag.Bool("scripts", false, "run the scripts in the \"scripts\" list of the nearest "+configName+" as plugins, which it never does otherwise")
+	footnotes := flag.Bool("footnotes", false, "read ^[inline notes], numbering them & gathering them at the end")
+	critic := flag.String("critic", "", "read CriticMarkup edits, and "+render.CriticShow+", "+render.CriticAccept+" or "+render.CriticReject+" them")
+	inferLang := flag.Bool("infer-lang", false, "label code blocks that have no language with the one their code looks to be in, for highlighting")
+	selfContained := flag.Bool("self-contained", false, "embed local images, and the -css stylesheet if it's a local file, in the output so it stands alone")
+	external := flag.Bool("external-assets", false, "with -self-contained, embed images from outside each file's directory too")
+	ast := flag.String("ast", "", "print each file's syntax tree, with positions, as "+astText+" or "+astJSON+" rather than converting it")
+	tokens := flag.Bool("tokens", false, "print the tokens each file is lexed into, one a line, rather than converting it")
+	watching := flag.Bool("watch", false, "convert the files again whenever they change; directories are watched for markdown files")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [-o out] [-to format] [-color when] [-theme name] [-css url] [-template file]\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "           [-standalone | -fragment] [-title text] [-lang code] [-meta key=value ...] [-pdf-engine cmd]")
+		fmt.Fprintln(os.Stderr, "           [-self-contained [-external-assets]] [-infer-lang] [-footnotes] [-critic mode] [-plugin program ...] [-scripts] [-stdout] [-watch] [-ast format] [-tokens]")
+		fmt.Fprintln(os.Stderr, "           [file or pattern ...]")
+		fmt.Fprintf(os.Stderr, "       %s build [-o dir] [-to format] [-base url] [-config file] [-filter file ...] [-plugin program ...] [-scripts] [-footnotes] [-critic mode] [-external-assets] [-incremental] [-j n] dir or zip\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s serve [-addr host:port] [-theme name] [-css url] [-metrics] file or dir\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s toc [-depth n] [-json] [-write] file ...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s lint [-rules] [-anchors | -alt] [-fix] [-config file] [-j n] [file or pattern ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s fmt [-check] [-diff] [-links style] [file or pattern ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s links [-json | -graph dot] [-check] [-http [-ignore regexp ...]] [-j n] [file or pattern ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s stats [-json] [file or pattern ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s outline [-json] [file or pattern ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s diff old.md new.md\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s grep [-kind kind] [-match regexp] [-i] [-section] [file or pattern ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s site [-o dir] [-title name] [-theme name] [-base url] dir or zip\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s wiki [-addr host:port] [-theme name] [-css url] [-metrics] dir or zip\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s index [-o file] [-base url] [-to format] dir\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s from-html [-o out] [-links style] [file or pattern ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s changelog [-o file] [-title text] [-theme name] [-css url] range [file]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s daemon [-addr host:port | -socket path] [-max-size bytes] [-timeout d] [-cache n] [-plugin program] [-scripts] [-metrics]\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Converts each markdown file to a file beside it, or standard input to")
+		fmt.Fprintln(os.Stderr, "standard output if no files are given. Patterns such as docs/**/*.md")
+		fmt.Fprintln(os.Stderr, "are expanded, with ** matching any number of directories. Output to a")
+		fmt.Fprintln(os.Stderr, "terminal is colored text, paged through $PAGER, unless -to is given.")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "Themes in %s are used\n", filepath.Join(userDir(), "themes"))
+		fmt.Fprintln(os.Stderr, "as well as the bundled ones, replacing any of the same name: name.css for")
+		fmt.Fprintln(os.Stderr, "each, where a line @import \"github.css\"; brings in another. site.html and")
+		fmt.Fprintf(os.Stderr, "wiki.html in %s replace the page layouts\n", filepath.Join(userDir(), "templates"))
+		fmt.Fprintln(os.Stderr, "of site and wiki.")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "A plugin given with -plugin, here or to build, is a program extending")
+		fmt.Fprintln(os.Stderr, "gomd, started once and sent each document as it's parsed to rewrite, such")
+		fmt.Fprintln(os.Stderr, "as to add a syntax of its own. Each is a line of JSON on its standard input,")
+		fmt.Fprintln(os.Stderr, `{"protocol": 1, "document": {...}}, the document as -to json writes it,`)
+		fmt.Fprintln(os.Stderr, `which it answers with a line on its standard output: {"document": {...}}`)
+		fmt.Fprintln(os.Stderr, `holding the document rewritten, or {"error": "why"}. Scripts ending in .lua,`)
+		fmt.Fprintln(os.Stderr, ".py, .js or .rb are run with lua, python3, node or ruby. With -scripts, the")
+		fmt.Fprintf(os.Stderr, "scripts in the \"scripts\" list of %s, relative to it, are run as plugins\n", configName)
+		fmt.Fprintln(os.Stderr, "first; they must be such scripts, and are never run without it.")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "Progress & problems are logged to standard error, as plain lines unless")
+		fmt.Fprintf(os.Stderr, "$%s is %s or %s for slog's key=value or JSON records, at the level\n", logFormatEnv, logText, logJSON)
+		fmt.Fprintf(os.Stderr, "$%s gives: debug, info (the default), warn or error. Servers log\n", logLevelEnv)
+		fmt.Fprintln(os.Stderr, "each request at debug.")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "Every command exits with status %d if run the wrong way, %d if its input\n", exitUsage, exitParse)
+		fmt.Fprintf(os.Stderr, "can't be parsed, %d if a file can't be read or written, and %d for any\n", exitIO, exitFailure)
+		fmt.Fprintln(os.Stderr, "other failure.")
+		fmt.Fprintln(os.Stderr)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	toStdout := writesToStdout(*out, *stdout)
+	if *to == "" && *out != "" && *out != stdinName {
+		*to = outputFormat(*out)
+	}
+	styled := *theme != "" || *css != "" || *tmpl != ""
+	if format, err := defaultFormat(*color, toStdout && !styled && !*standalone && !*fragment); err != nil {
+		fail(err)
+	} else if *to == "" {
+		*to = format
+	}
+	criticExts, ropts, err := criticOptions(*critic)
+	if err != nil {
+		fail(err)
+	}
+	r, err := render.NewRenderer(*to, ropts...)
+	if err != nil {
+		fail(usageError{err})
+	}
+	page, err := pageOutput(*to, toStdout, *standalone, *fragment, styled)
+	if err != nil {
+		fail(err)
+	}
+	if pdf, ok := r.(*render.PDFRenderer); ok {
+		pdf.Engine = *engine
+		if *tmpl != "" {
+			fail(usagef("-template doesn't apply to %s output", render.FormatPDF))
+		}
+		if *theme != "" || *css != "" {
+			pdf.Page, err = newPageRenderer(*theme, *css, ropts...)
+		}
+	} else if *engine != "" {
+		fail(usagef("-pdf-engine only applies to %s output", render.FormatPDF))
+	} else if page {
+		if *tmpl != "" {
+			if *theme != "" || *css != "" {
+				fail(usagef("-template can't be used with -theme or -css"))
+			}
+			var t *template.Template
+			if t, err = template.ParseFiles(*tmpl); err == nil {
+				r = render.NewTemplateRenderer(t, ropts...)
+			}
+		} else {
+			r, err = newPageRenderer(*theme, *css, ropts...)
+		}
+	}
+	if err != nil {
+		fail(err)
+	}
+	if *title != "" {
+		meta["title"] = *title
+	}
+	if *lang != "" {
+		meta["lang"] = *lang
+	}
+	cfg, err := loadConfig("")
+	if err != nil {
+		fail(err)
+	}
+	scriptPaths, err := configScripts(cfg, *scripts)
+	if err != nil {
+		fail(err)
+	}
+	plugins, err := startPlugins(append(scriptPaths, pluginPaths...), 0)
+	if err != nil {
+		fail(err)
+	}
+	finish := func() {
+		if err := closePlugins(plugins); err != nil {
+			fail(err)
+		}
+	}
+	exts := pluginExtensions(plugins)
+	if len(meta) > 0 {
+		exts = append(exts, setMeta(meta))
+	}
+	if *inferLang {
+		exts = append(exts, parser.InferLanguages(nil, true))
+	}
+	if *footnotes {
+		exts = append(exts, parser.InlineFootnotes)
+	}
+	exts = append(exts, criticExts...)
+	if *external && !*selfContained {
+		fail(usagef("-external-assets only applies with -self-contained"))
+	}
+	if *selfContained {
+		page, _ := r.(*render.PageRenderer)
+		if pdf, ok := r.(*render.PDFRenderer); ok {
+			page = pdf.Page
+		}
+		if page != nil && page.Stylesheet != "" {
+			style, err := inlineStylesheet(page.Stylesheet)
+			if err != nil {
+				fail(err)
+			}
+			if style != "" {
+				page.Head, page.Stylesheet = page.Head+style, ""
+			}
+		}
+		exts = append(exts, embedImages(*external))
+	}
+	p := parser.New(parser.WithExtensions(withDefaults(exts...)...))
+	args, err := expandArgs(flag.Args())
+	if err != nil {
+		fail(err)
+	}
+	if len(args) == 0 {
+		args = []string{stdinName}
+	}
+	if *ast != "" || *tokens {
+		if *ast != "" && *ast != astText && *ast != astJSON {
+			fail(usagef("unknown -ast format %q, want %s or %s", *ast, astText, astJSON))
+		}
+		if *ast != "" && *tokens {
+			fail(usagef("-ast and -tokens can't be used together"))
+		}
+		if *out != "" || *stdout || *watching {
+			fail(usagef("-ast and -tokens always print to standard output, so can't be used with -o, -stdout or -watch"))
+		}
+		p := parser.New(parser.WithSourceRanges())
+		for _, arg := range args {
+			name, src, err := readSource(arg)
+			if err != nil {
+				fail(err)
+			}
+			if *tokens {
+				err = dumpTokens(os.Stdout, p.Tokenize(name, src))
+			} else if doc, perr := p.Parse(name, src); perr != nil {
+				err = perr
+			} else {
+				err = dumpAST(os.Stdout, doc, *ast)
+			}
+			if err != nil {
+				fail(err)
+			}
+		}
+		finish()
+		return
+	}
+	if *out != "" && *stdout {
+		fail(usagef("-o and -stdout can't be used together"))
+	}
+	if *stdout {
+		*out = stdinName
+	}
+	if *to == render.FormatANSI && toStdout && !*watching && isTerminal(os.Stdout) {
+		defer startPager()()
+	}
+	if *watching {
+		if *out == stdinName {
+			fail(usagef("-watch can't write to standard output"))
+		}
+		dest := render.ReplaceExt(formatExts[*to])
+		if *out != "" {
+			if len(args) > 1 {
+				fail(usagef("-o takes a single input, not %d", len(args)))
+			}
+			dest = func(string) string { return *out }
+		}
+		fail(watch(p, r, args, dest))
+	}
+	if *out == stdinName { // Concatenate everything to standard output
+		for _, arg := range args {
+			if err := convertFile(p, r, arg, stdinName); err != nil {
+				fail(err)
+			}
+		}
+		finish()
+		return
+	}
+	if *out != "" {
+		if len(args) > 1 {
+			fail(usagef("-o takes a single input, not %d", len(args)))
+		}
+		if err := convertFile(p, r, args[0], *out); err != nil {
+			fail(err)
+		}
+		finish()
+		return
+	}
+	var files []string
+	for _, arg := range args {
+		if arg == stdinName { // Convert standard input to standard output
+			if err := convertFile(p, r, stdinName, stdinName); err != nil {
+				fail(err)
+			}
+			continue
+		}
+		files = append(files, arg)
+	}
+	if len(files) > 0 { // Convert each file named to a file beside it
+		b := &render.Batch{
+			Parser:   p,
+			Renderer: r,
+			Dest:     render.ReplaceExt(formatExts[*to]),
+		}
+		if err := b.Convert(files); err != nil {
+			if batch, ok := err.(render.BatchError); ok && len(batch) == 1 {
+				err = batch[0] // Without the count of files
+			}
+			fail(err)
+		}
+	}
+	finish()
+}
+
+// writesToStdout reports whether, given the -o & -stdout flags, output goes
+// to standard output rather than files
+func writesToStdout(out string, stdout bool) bool {
+	return out == stdinName || stdout || out == "" && (flag.NArg() == 0 || flag.NArg() == 1 && flag.Arg(0) == stdinName)
+}
+
+// pageOutput works out whether to write complete HTML pages in format,
+// from where output goes, the -standalone & -fragment flags and whether a
+// theme, stylesheet or template was given. Unless told, pages are written
+// to files and fragments to standard output
+func pageOutput(format string, toStdout, standalone, fragment, styled bool) (bool, error) {
+	switch {
+	case standalone && fragment:
+		return false, usagef("-standalone and -fragment can't be used together")
+	case fragment && styled:
+		return false, usagef("-fragment can't be used with -theme, -css or -template")
+	case format == render.FormatPDF:
+		if fragment {
+			return false, usagef("-fragment doesn't apply to %s output", render.FormatPDF)
+		}
+		return true, nil
+	case format != render.FormatHTML && (standalone || fragment || styled):
+		return false, usagef("-standalone, -fragment, -theme, -css and -template only apply to %s and %s output", render.FormatHTML, render.FormatPDF)
+	case standalone || styled:
+		return true, nil
+	case fragment || format != render.FormatHTML:
+		return false, nil
+	}
+	return !toStdout, nil
+}
+
+// formatNames lists the formats -to accepts
+func formatNames() string {
+	return strings.Join([]string{
+		render.FormatHTML, render.FormatMarkdown, render.FormatText, render.FormatLaTeX,
+		render.FormatMan, render.FormatJSON, render.FormatSlides, render.FormatDocBook,
+		render.FormatJira, render.FormatAsciiDoc, render.FormatBBCode, render.FormatPDF,
+		render.FormatANSI, render.FormatPandoc,
+	}, ", ")
+}
+
+// themeNames lists the themes -theme accepts
+func themeNames() string {
+	var names []string
+	for name := range render.Themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// newPageRenderer returns a renderer for complete HTML pages, styled with
+// the stylesheet at the URL css if given, or else the named theme
+func newPageRenderer(theme, css string, opts ...render.Option) (*render.PageRenderer, error) {
+	if css != "" {
+		theme = ""
+	}
+	r, err := render.NewPageRenderer(theme, opts...)
+	if err != nil {
+		return nil, usageError{err}
+	}
+	r.Stylesheet = css
+	return r, nil
+}
+
+// criticOptions returns the parser extension & renderer options reading
+// CriticMarkup and treating its edits as mode says, or none if mode is ""
+func criticOptions(mode string) ([]parser.Extension, []render.Option, error) {
+	switch mode {
+	case "":
+		return nil, nil, nil
+	case render.CriticShow, render.CriticAccept, render.CriticReject:
+		return []parser.Extension{parser.CriticMarkup}, []render.Option{render.WithCriticMarkup(mode)}, nil
+	}
+	return nil, nil, usagef("unknown -critic mode %q, want %s", mode, strings.Join(render.CriticModes, ", "))
+}
+
+// plural returns n followed by noun, with an s unless n is 1
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// Exit statuses, so scripts can tell what went wrong
+const (
+	exitFailure = 1 // Anything else, such as a check finding problems
+	exitUsage   = 2 // The command was run the wrong way
+	exitParse   = 3 // Input couldn't be parsed
+	exitIO      = 4 // A file couldn't be read or written
+)
+
+// usageError is a mistake in how a command was run, rather than a problem
+// with its input
+type usageError struct {
+	error
+}
+
+// usagef returns a usageError formatted like fmt.Errorf
+func usagef(format string, args ...interface{}) error {
+	return usageError{fmt.Errorf(format, args...)}
+}
+
+// errNoMatches is reported for a pattern that matches no files
+var errNoMatches = errors.New("no matching files")
+
+// exitStatus returns the status to exit with for err. Of the errors for
+// many files, the first decides
+func exitStatus(err error) int {
+	if batch, ok := err.(render.BatchError); ok && len(batch) > 0 {
+		err = batch[0]
+	}
+	var usage usageError
+	var parse *parser.ParseError
+	var tooLarge *parser.InputTooLargeError
+	var path *os.PathError
+	var link *os.LinkError
+	switch {
+	case errors.As(err, &usage):
+		return exitUsage
+	case errors.As(err, &parse), errors.As(err, &tooLarge):
+		return exitParse
+	case errors.As(err, &path), errors.As(err, &link):
+		return exitIO
+	}
+	return exitFailure
+}
+
+// command is the name of the command being run, if not the default
+var command string
+
+// fail reports err and exits with the status for it
+func fail(err error) {
+	name := filepath.Base(os.Args[0])
+	if command != "" {
+		name += " " + command
+	}
+	fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+	status := exitStatus(err)
+	if status == exitUsage {
+		fmt.Fprintf(os.Stderr, "Run '%s -h' for usage.\n", name)
+	}
+	os.Exit(status)
+}
+
+// convertFile parses the markdown in the file in with p and renders it to
+// the file out with r. Either may be stdinName, for standard input or output
+func convertFile(p *parser.Parser, r render.Renderer, in, out string) error {
+	name, src, err := readSource(in)
+	if err != nil {
+		return err
+	}
+	doc, err := p.Parse(name, src)
+	if err != nil {
+		return err
+	}
+	if out == stdinName {
+		return write(r, os.Stdout, doc)
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := write(r, f, doc); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readSource reads the file path, or standard input if it's stdinName,
+// returning the name to report it under along with its contents
+func readSource(path string) (name, src string, err error) {
+	var b []byte
+	if path == stdinName {
+		name = "stdin"
+		b, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		name = path
+		b, err = ioutil.ReadFile(path)
+	}
+	return name, string(b), err
+}
+
+// write renders doc to w with r
+func write(r render.Renderer, w io.Writer, doc *parser.Document) error {
+	bw := bufio.NewWriter(w)
+	if err := render.RenderDocument(r, bw, doc); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Without -to, the format is the one the -o file's extension names
+func TestOutputFormatFromExt(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"doc.md": "# Title\n"})
+	for out, want := range map[string]string{
+		"doc.tex":  `\section{Title}`,
+		"doc.html": "<h1",
+		"doc.json": `"kind"`,
+	} {
+		if r := gomd(t, dir, "", "-o", out, "doc.md"); r.code != 0 {
+			t.Fatalf("-o %s: exit %d: %s", out, r.code, r.stderr)
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, out))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), want) {
+			t.Errorf("-o %s wrote\n%s\nwithout %q", out, b, want)
+		}
+	}
+	// Without an engine to make it, a PDF fails rather than being html
+	if r := gomd(t, dir, "", "-o", "doc.pdf", "doc.md"); r.code != 0 {
+		if !strings.Contains(r.stderr, "pdf") {
+			t.Errorf("-o doc.pdf: exit %d: %s", r.code, r.stderr)
+		}
+	} else if b, _ := ioutil.ReadFile(filepath.Join(dir, "doc.pdf")); !strings.HasPrefix(string(b), "%PDF-") {
+		t.Errorf("-o doc.pdf wrote %.20q, not a PDF", b)
+	}
+	if r := gomd(t, dir, "", "-o", "doc.txt", "-to", "html", "doc.md"); r.code != 0 {
+		t.Fatalf("exit %d: %s", r.code, r.stderr)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, "doc.txt")); !strings.Contains(string(b), "<h1") {
+		t.Errorf("-to html -o doc.txt wrote %q, want html", b)
+	}
+}
+
+// Notes written as markdown read back as the same notes
+func TestFootnotesRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	const in = "One^[A *note*.] and two^[See [x](second) and ^[within].].\n"
+	md := gomd(t, dir, in, "-footnotes", "-to", "md")
+	if md.code != 0 {
+		t.Fatalf("exit %d: %s", md.code, md.stderr)
+	}
+	want := gomd(t, dir, in, "-footnotes", "-to", "html")
+	got := gomd(t, dir, md.stdout, "-footnotes", "-to", "html")
+	if got.stdout != want.stdout {
+		t.Errorf("%q read back as\n%s\nnot\n%s", md.stdout, got.stdout, want.stdout)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"./parser"
+)
+
+// metaFlag collects the fields given by -meta key=value flags
+type metaFlag map[string]string
+
+func (m metaFlag) String() string {
+	var fields []string
+	for k, v := range m {
+		fields = append(fields, k+"="+v)
+	}
+	sort.Strings(fields)
+	return strings.Join(fields, ",")
+}
+
+func (m metaFlag) Set(s string) error {
+	k, v, ok := strings.Cut(s, "=")
+	if k = strings.TrimSpace(k); !ok || k == "" {
+		return errors.New("want key=value")
+	}
+	m[k] = v
+	return nil
+}
+
+// setMeta sets the given front matter fields of every document, replacing
+// any the document has
+func setMeta(meta map[string]string) parser.Extension {
+	return parser.ExtensionFunc(func(p *parser.Parser) {
+		p.AddTransformer(parser.TransformerFunc(func(doc *parser.Document) {
+			if doc.Meta == nil {
+				doc.Meta = make(map[string]string, len(meta))
+			}
+			for k, v := range meta {
+				doc.Meta[k] = v
+			}
+		}))
+	})
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Paths of the endpoints serve & wiki have for running as services
+const (
+	healthPath  = "/healthz"
+	metricsPath = "/metrics"
+)
+
+// renderBuckets are the upper bounds, in seconds, of the render latency
+// histogram's buckets
+var renderBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1}
+
+// metrics counts what a server does, for Prometheus to scrape
+type metrics struct {
+	cacheHits, cacheMisses atomic.Uint64
+	watchers               atomic.Int64 // Pages waiting for their source to change
+
+	mu       sync.Mutex
+	renders  []uint64 // In each of renderBuckets, then above them all
+	renderS  float64  // Total seconds spent rendering
+	requests map[int]uint64
+}
+
+// serverMetrics are the metrics of the server this process runs
+var serverMetrics = &metrics{renders: make([]uint64, len(renderBuckets)+1), requests: make(map[int]uint64)}
+
+// rendered notes that a page took d to parse & render
+func (m *metrics) rendered(d time.Duration) {
+	s := d.Seconds()
+	i := sort.SearchFloat64s(renderBuckets, s)
+	m.mu.Lock()
+	m.renders[i]++
+	m.renderS += s
+	m.mu.Unlock()
+}
+
+// requested notes a response with status
+func (m *metrics) requested(status int) {
+	m.mu.Lock()
+	m.requests[status]++
+	m.mu.Unlock()
+}
+
+// ServeHTTP writes the metrics in Prometheus's text format
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	renders := append([]uint64{}, m.renders...)
+	renderS := m.renderS
+	var statuses []int
+	requests := make(map[int]uint64, len(m.requests))
+	for status, n := range m.requests {
+		statuses = append(statuses, status)
+		requests[status] = n
+	}
+	m.mu.Unlock()
+	sort.Ints(statuses)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprintln(w, "# HELP gomd_render_seconds Time taken to parse & render a page.")
+	fmt.Fprintln(w, "# TYPE gomd_render_seconds histogram")
+	var count uint64
+	for i, le := range renderBuckets {
+		count += renders[i]
+		fmt.Fprintf(w, "gomd_render_seconds_bucket{le=%q} %d\n", strconv.FormatFloat(le, 'g', -1, 64), count)
+	}
+	count += renders[len(renderBuckets)]
+	fmt.Fprintf(w, "gomd_render_seconds_bucket{le=\"+Inf\"} %d\n", count)
+	fmt.Fprintf(w, "gomd_render_seconds_sum %g\n", renderS)
+	fmt.Fprintf(w, "gomd_render_seconds_count %d\n", count)
+	fmt.Fprintln(w, "# HELP gomd_cache_hits_total Pages, or conversions by the daemon, answered from the cache.")
+	fmt.Fprintln(w, "# TYPE gomd_cache_hits_total counter")
+	fmt.Fprintf(w, "gomd_cache_hits_total %d\n", m.cacheHits.Load())
+	fmt.Fprintln(w, "# HELP gomd_cache_misses_total Pages, or conversions by the daemon, not in the cache, being new or changed.")
+	fmt.Fprintln(w, "# TYPE gomd_cache_misses_total counter")
+	fmt.Fprintf(w, "gomd_cache_misses_total %d\n", m.cacheMisses.Load())
+	fmt.Fprintln(w, "# HELP gomd_active_watchers Pages open in a browser waiting for their source to change.")
+	fmt.Fprintln(w, "# TYPE gomd_active_watchers gauge")
+	fmt.Fprintf(w, "gomd_active_watchers %d\n", m.watchers.Load())
+	fmt.Fprintln(w, "# HELP gomd_requests_total Requests served, by status code.")
+	fmt.Fprintln(w, "# TYPE gomd_requests_total counter")
+	for _, status := range statuses {
+		fmt.Fprintf(w, "gomd_requests_total{code=\"%d\"} %d\n", status, requests[status])
+	}
+}
+
+// health says the server is up
+func health(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+// handleService adds the health endpoint to the server, and the metrics
+// endpoint if withMetrics is set
+func handleService(withMetrics bool) {
+	http.HandleFunc(healthPath, health)
+	if withMetrics {
+		http.Handle(metricsPath, serverMetrics)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"./parser"
+)
+
+// outlineEntry is a heading in a document's outline, as written by
+// outline -json
+type outlineEntry struct {
+	Level    int             `json:"level"`
+	Text     string          `json:"text"`
+	ID       string          `json:"id"`
+	Line     int             `json:"line"`
+	Children []*outlineEntry `json:"children,omitempty"`
+}
+
+// outline prints the heading hierarchy of markdown files
+func outline(args []string) error {
+	fs := flag.NewFlagSet("outline", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the outline as JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s outline [-json] [file or pattern ...]\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Prints the headings of each markdown file, or standard input if none are")
+		fmt.Fprintln(os.Stderr, "given, as a tree with the line each is on and its anchor.")
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+	}
+	args, err := expandArgs(parseInterspersed(fs, args))
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		args = []string{stdinName}
+	}
+	p := parser.New(parser.WithSourceRanges())
+	outlines := make(map[string][]*outlineEntry)
+	for i, path := range args {
+		name, src, err := readSource(path)
+		if err != nil {
+			return err
+		}
+		doc, err := p.Parse(name, src)
+		if err != nil {
+			return err
+		}
+		entries := outlineEntries(doc, doc.Outline())
+		if *asJSON {
+			outlines[name] = entries
+			continue
+		}
+		if len(args) > 1 {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Println(name + ":")
+		}
+		printOutline(entries, 0)
+	}
+	if *asJSON {
+		b, err := json.MarshalIndent(outlines, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\n", b)
+	}
+	return nil
+}
+
+// outlineEntries converts the headings of doc in outline
+func outlineEntries(doc *parser.Document, outline []*parser.Heading) []*outlineEntry {
+	entries := []*outlineEntry{}
+	for _, h := range outline {
+		e := &outlineEntry{
+			Level: h.Level,
+			Text:  h.Text,
+			ID:    h.ID,
+			Line:  doc.Position(h.Node.Range.Start).Line,
+		}
+		if len(h.Children) > 0 {
+			e.Children = outlineEntries(doc, h.Children)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// printOutline prints entries as a tree, nested indent levels deep
+func printOutline(entries []*outlineEntry, indent int) {
+	for _, e := range entries {
+		fmt.Printf("%5d  %s%s %s  #%s\n", e.Line, strings.Repeat("  ", indent), strings.Repeat("#", e.Level), e.Text, e.ID)
+		printOutline(e.Children, indent+1)
+	}
+}
@@ -0,0 +1,605 @@
+// Package pandoc converts documents to & from the JSON form of Pandoc's
+// syntax tree, so the filters written for Pandoc, which rewrite that JSON,
+// can rewrite gomd's documents too
+package pandoc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"../parser"
+)
+
+// APIVersion is the version of pandoc-types the JSON is written for
+var APIVersion = []int{1, 23, 1}
+
+// Document is a Pandoc document, as read from & written to JSON
+type Document struct {
+	APIVersion []int           `json:"pandoc-api-version"`
+	Meta       map[string]Elem `json:"meta"`
+	Blocks     []Elem          `json:"blocks"`
+}
+
+// Elem is an element of a Pandoc document: a block, an inline or a
+// metadata value. Its type is T, and C is its contents, whose form depends
+// on T; elements in C are decoded as maps & slices
+type Elem struct {
+	T string      `json:"t"`
+	C interface{} `json:"c,omitempty"`
+}
+
+// FromDocument returns doc as a Pandoc document
+func FromDocument(doc *parser.Document) *Document {
+	p := &Document{APIVersion: APIVersion, Meta: make(map[string]Elem), Blocks: []Elem{}}
+	for k, v := range doc.Meta {
+		p.Meta[k] = Elem{"MetaInlines", inlines(textNode(v))}
+	}
+	for _, n := range doc.Root.Children {
+		if b, ok := block(n); ok {
+			p.Blocks = append(p.Blocks, b)
+		}
+	}
+	return p
+}
+
+// FromNode returns a Pandoc document of n, a block or a document, alone
+func FromNode(n *parser.Node) *Document {
+	root := n
+	if n.Kind != parser.NodeDocument {
+		root = parser.NewNode(parser.NodeDocument)
+		root.Children = []*parser.Node{n}
+	}
+	return FromDocument(&parser.Document{Root: root})
+}
+
+// attr returns a Pandoc Attr with id & classes
+func attr(id string, classes ...string) []interface{} {
+	if classes == nil {
+		classes = []string{}
+	}
+	return []interface{}{id, classes, []interface{}{}}
+}
+
+// block returns the block n as a Pandoc element, and false if Pandoc has
+// none for it
+func block(n *parser.Node) (Elem, bool) {
+	switch n.Kind {
+	case parser.NodeParagraph:
+		return Elem{"Para", inlines(n.Children...)}, true
+	case parser.NodeHeading:
+		return Elem{"Header", []interface{}{n.Level, attr(n.ID), inlines(n.Children...)}}, true
+	case parser.NodeThematicBreak:
+		return Elem{T: "HorizontalRule"}, true
+	case parser.NodeCodeBlock:
+		var classes []string
+		if lang := strings.Fields(n.Info); len(lang) > 0 {
+			classes = lang[:1]
+		}
+		return Elem{"CodeBlock", []interface{}{attr("", classes...), strings.TrimSuffix(n.Literal, "\n")}}, true
+	case parser.NodeBlockQuote:
+		return Elem{"BlockQuote", blocks(n.Children)}, true
+	case parser.NodeList:
+		items := []interface{}{}
+		for _, item := range n.Children {
+			items = append(items, []Elem{{"Plain", inlines(item.Children...)}})
+		}
+		if n.Ordered {
+			return Elem{"OrderedList", []interface{}{[]interface{}{1, Elem{T: "Decimal"}, Elem{T: "Period"}}, items}}, true
+		}
+		return Elem{"BulletList", items}, true
+	case parser.NodeTable:
+		return table(n), true
+	}
+	return Elem{}, false
+}
+
+// pandocAligns are the Pandoc Alignments of the alignments of table cells
+var pandocAligns = map[string]string{"left": "AlignLeft", "center": "AlignCenter", "right": "AlignRight", "": "AlignDefault"}
+
+// table returns the table n as a Pandoc Table, its first row the head &
+// the rest a single body
+func table(n *parser.Node) Elem {
+	row := func(r *parser.Node) []interface{} {
+		cells := []interface{}{}
+		for _, c := range r.Children {
+			cells = append(cells, []interface{}{attr(""), Elem{T: pandocAligns[c.Info]}, 1, 1, []Elem{{"Plain", inlines(c.Children...)}}})
+		}
+		return []interface{}{attr(""), cells}
+	}
+	cols, head, body := []interface{}{}, []interface{}{}, []interface{}{}
+	for i, r := range n.Children {
+		if i == 0 {
+			for _, c := range r.Children {
+				cols = append(cols, []interface{}{Elem{T: pandocAligns[c.Info]}, Elem{T: "ColWidthDefault"}})
+			}
+			head = append(head, row(r))
+		} else {
+			body = append(body, row(r))
+		}
+	}
+	return Elem{"Table", []interface{}{
+		attr(""),
+		[]interface{}{nil, []Elem{}}, // Caption: no short caption, no blocks
+		cols,
+		[]interface{}{attr(""), head},
+		[]interface{}{[]interface{}{attr(""), 0, []interface{}{}, body}},
+		[]interface{}{attr(""), []interface{}{}},
+	}}
+}
+
+// blocks returns the blocks ns as Pandoc elements
+func blocks(ns []*parser.Node) []Elem {
+	out := []Elem{}
+	for _, n := range ns {
+		if b, ok := block(n); ok {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// inlines returns the inline nodes ns as Pandoc elements, with text split
+// into words & the spaces between them as Pandoc does
+func inlines(ns ...*parser.Node) []Elem {
+	out := []Elem{}
+	for _, n := range ns {
+		switch n.Kind {
+		case parser.NodeText:
+			for i, word := range strings.Split(n.Literal, " ") {
+				if i > 0 {
+					out = append(out, Elem{T: "Space"})
+				}
+				if word != "" {
+					out = append(out, Elem{"Str", word})
+				}
+			}
+		case parser.NodeSoftBreak:
+			out = append(out, Elem{T: "SoftBreak"})
+		case parser.NodeHardBreak:
+			out = append(out, Elem{T: "LineBreak"})
+		case parser.NodeEmphasis:
+			out = append(out, Elem{"Emph", inlines(n.Children...)})
+		case parser.NodeStrong:
+			out = append(out, Elem{"Strong", inlines(n.Children...)})
+		case parser.NodeStrikethrough:
+			out = append(out, Elem{"Strikeout", inlines(n.Children...)})
+		case parser.NodeCodeSpan:
+			out = append(out, Elem{"Code", []interface{}{attr(""), n.Literal}})
+		case parser.NodeLink:
+			out = append(out, Elem{"Link", []interface{}{attr(""), inlines(n.Children...), []string{n.Dest, n.Title}}})
+		case parser.NodeImage:
+			out = append(out, Elem{"Image", []interface{}{attr(""), inlines(n.Children...), []string{n.Dest, n.Title}}})
+		default:
+			out = append(out, inlines(n.Children...)...)
+		}
+	}
+	return out
+}
+
+// textNode returns a Text node of s
+func textNode(s string) *parser.Node {
+	n := parser.NewNode(parser.NodeText)
+	n.Literal = s
+	return n
+}
+
+// ToDocument returns the Pandoc document p as a Document named name.
+// Elements gomd has no node for keep what they contain, or their text, and
+// lists within list items are flattened into the list they're in
+func ToDocument(name string, p *Document) (*parser.Document, error) {
+	doc := &parser.Document{Name: name, Meta: make(map[string]string), Root: parser.NewNode(parser.NodeDocument)}
+	var keys []string
+	for k, v := range p.Meta {
+		doc.Meta[k] = metaString(v.T, v.C)
+		keys = append(keys, k)
+	}
+	if len(keys) > 0 {
+		sort.Strings(keys)
+		var b strings.Builder
+		b.WriteString("---\n")
+		for _, k := range keys {
+			fmt.Fprintf(&b, "%s: %s\n", k, doc.Meta[k])
+		}
+		b.WriteString("---\n")
+		doc.FrontMatter = b.String()
+	}
+	var d decoder
+	for _, b := range p.Blocks {
+		d.block(doc.Root, b.T, b.C)
+	}
+	return doc, d.err
+}
+
+// metaString returns the metadata value of type t with contents c as a
+// front matter value
+func metaString(t string, c interface{}) string {
+	switch t {
+	case "MetaString":
+		s, _ := c.(string)
+		return s
+	case "MetaBool":
+		return fmt.Sprint(c)
+	case "MetaInlines":
+		p := parser.NewNode(parser.NodeParagraph)
+		new(decoder).inlines(p, c)
+		return p.PlainText()
+	case "MetaBlocks":
+		root := parser.NewNode(parser.NodeDocument)
+		new(decoder).blocks(root, c)
+		return strings.TrimSpace(root.PlainText())
+	case "MetaList":
+		var values []string
+		for _, e := range list(c) {
+			values = append(values, metaString(elem(e)))
+		}
+		return "[" + strings.Join(values, ", ") + "]"
+	}
+	return ""
+}
+
+// decoder builds nodes from decoded Pandoc JSON, noting the first element
+// that isn't in the form its type should have
+type decoder struct {
+	err error
+}
+
+// elem returns the type & contents of a decoded element
+func elem(v interface{}) (string, interface{}) {
+	m, _ := v.(map[string]interface{})
+	t, _ := m["t"].(string)
+	return t, m["c"]
+}
+
+// list returns v as a slice, or nil if it isn't one
+func list(v interface{}) []interface{} {
+	l, _ := v.([]interface{})
+	return l
+}
+
+// str returns v as a string, or "" if it isn't one
+func str(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+// malformed notes that the element of type t isn't in the right form
+func (d *decoder) malformed(t string) {
+	if d.err == nil {
+		d.err = errors.New("pandoc: malformed " + t)
+	}
+}
+
+// blocks appends the blocks in c to parent
+func (d *decoder) blocks(parent *parser.Node, c interface{}) {
+	for _, b := range list(c) {
+		t, c := elem(b)
+		d.block(parent, t, c)
+	}
+}
+
+// block appends the block of type t with contents c to parent
+func (d *decoder) block(parent *parser.Node, t string, c interface{}) {
+	add := func(kind parser.NodeKind, inl interface{}) *parser.Node {
+		n := parser.NewNode(kind)
+		d.inlines(n, inl)
+		parent.AppendChild(n)
+		return n
+	}
+	switch t {
+	case "Para", "Plain":
+		add(parser.NodeParagraph, c)
+	case "LineBlock":
+		p := parser.NewNode(parser.NodeParagraph)
+		for i, line := range list(c) {
+			if i > 0 {
+				p.AppendChild(parser.NewNode(parser.NodeHardBreak))
+			}
+			d.inlines(p, line)
+		}
+		parent.AppendChild(p)
+	case "Header":
+		args := list(c)
+		if len(args) != 3 {
+			d.malformed(t)
+			return
+		}
+		level, _ := args[0].(float64)
+		h := add(parser.NodeHeading, args[2])
+		h.Level = int(level)
+		if a := list(args[1]); len(a) > 0 {
+			h.ID = str(a[0])
+		}
+	case "HorizontalRule":
+		hr := parser.NewNode(parser.NodeThematicBreak)
+		hr.Literal = "---"
+		parent.AppendChild(hr)
+	case "CodeBlock":
+		args := list(c)
+		if len(args) != 2 {
+			d.malformed(t)
+			return
+		}
+		n := parser.NewNode(parser.NodeCodeBlock)
+		if a := list(args[0]); len(a) > 1 {
+			if classes := list(a[1]); len(classes) > 0 {
+				n.Info = str(classes[0])
+			}
+		}
+		if n.Literal = str(args[1]); n.Literal != "" {
+			n.Literal += "\n"
+		}
+		parent.AppendChild(n)
+	case "BlockQuote":
+		q := parser.NewNode(parser.NodeBlockQuote)
+		d.blocks(q, c)
+		parent.AppendChild(q)
+	case "BulletList", "OrderedList":
+		items := c
+		if t == "OrderedList" {
+			if args := list(c); len(args) == 2 {
+				items = args[1]
+			} else {
+				d.malformed(t)
+				return
+			}
+		}
+		l := parser.NewNode(parser.NodeList)
+		l.Ordered = t == "OrderedList"
+		d.items(l, items)
+		parent.AppendChild(l)
+	case "Div":
+		if args := list(c); len(args) == 2 {
+			d.blocks(parent, args[1])
+		}
+	case "Figure":
+		if args := list(c); len(args) == 3 {
+			d.blocks(parent, args[2])
+		}
+	case "DefinitionList":
+		for _, item := range list(c) {
+			if pair := list(item); len(pair) == 2 {
+				add(parser.NodeParagraph, pair[0])
+				for _, def := range list(pair[1]) {
+					d.blocks(parent, def)
+				}
+			}
+		}
+	case "Table":
+		if args := list(c); len(args) == 6 {
+			d.table(parent, args)
+		} else {
+			d.malformed(t)
+		}
+	}
+	// Raw blocks are dropped, having no node to hold them
+}
+
+// table appends the table whose Table element has args to parent, the
+// rows of its head, bodies & foot all rows of the one table. Cells
+// spanning several columns are read as one, & those holding more than
+// text keep only their paragraphs
+func (d *decoder) table(parent *parser.Node, args []interface{}) {
+	tbl := parser.NewNode(parser.NodeTable)
+	var aligns []string
+	for _, col := range list(args[2]) {
+		align := ""
+		if spec := list(col); len(spec) == 2 {
+			t, _ := elem(spec[0])
+			for a, pt := range pandocAligns {
+				if pt == t {
+					align = a
+				}
+			}
+		}
+		aligns = append(aligns, align)
+	}
+	rows := func(rs interface{}) {
+		for _, r := range list(rs) {
+			cells := list(r)
+			if len(cells) != 2 {
+				d.malformed("Row")
+				continue
+			}
+			row := parser.NewNode(parser.NodeTableRow)
+			for _, c := range list(cells[1]) {
+				cell := parser.NewNode(parser.NodeTableCell)
+				if args := list(c); len(args) == 5 {
+					p := parser.NewNode(parser.NodeParagraph)
+					d.blocks(p, args[4])
+					for _, b := range p.Children {
+						if b.Kind != parser.NodeParagraph {
+							continue // Cells hold only text
+						}
+						if len(cell.Children) > 0 {
+							cell.AppendChild(parser.NewNode(parser.NodeSoftBreak))
+						}
+						for _, inl := range b.Children {
+							cell.AppendChild(inl)
+						}
+					}
+				}
+				if i := len(row.Children); i < len(aligns) {
+					cell.Info = aligns[i]
+				}
+				row.AppendChild(cell)
+			}
+			for len(row.Children) < len(aligns) {
+				row.AppendChild(parser.NewNode(parser.NodeTableCell))
+			}
+			tbl.AppendChild(row)
+		}
+	}
+	if head := list(args[3]); len(head) == 2 {
+		rows(head[1])
+	}
+	for _, body := range list(args[4]) {
+		if b := list(body); len(b) == 4 {
+			rows(b[2])
+			rows(b[3])
+		}
+	}
+	if foot := list(args[5]); len(foot) == 2 {
+		rows(foot[1])
+	}
+	if len(tbl.Children) > 0 {
+		parent.AppendChild(tbl)
+	}
+}
+
+// items appends the items of a list to l, flattening the lists in them
+func (d *decoder) items(l *parser.Node, items interface{}) {
+	for _, item := range list(items) {
+		li := parser.NewNode(parser.NodeListItem)
+		var nested []interface{}
+		for _, b := range list(item) {
+			switch t, c := elem(b); t {
+			case "Para", "Plain":
+				if len(li.Children) > 0 {
+					li.AppendChild(parser.NewNode(parser.NodeSoftBreak))
+				}
+				d.inlines(li, c)
+			case "BulletList":
+				nested = append(nested, c)
+			case "OrderedList":
+				if args := list(c); len(args) == 2 {
+					nested = append(nested, args[1])
+				}
+			}
+		}
+		l.AppendChild(li)
+		for _, sub := range nested {
+			d.items(l, sub)
+		}
+	}
+}
+
+// inlines appends the inline elements in c to parent, joining the text of
+// words & spaces back together
+func (d *decoder) inlines(parent *parser.Node, c interface{}) {
+	for _, e := range list(c) {
+		t, c := elem(e)
+		d.inline(parent, t, c)
+	}
+}
+
+// inline appends the inline of type t with contents c to parent
+func (d *decoder) inline(parent *parser.Node, t string, c interface{}) {
+	text := func(s string) {
+		if last := parent.LastChild(); last != nil && last.Kind == parser.NodeText {
+			last.Literal += s
+			return
+		}
+		parent.AppendChild(textNode(s))
+	}
+	wrap := func(kind parser.NodeKind, inl interface{}) *parser.Node {
+		n := parser.NewNode(kind)
+		d.inlines(n, inl)
+		parent.AppendChild(n)
+		return n
+	}
+	switch t {
+	case "Str":
+		text(str(c))
+	case "Space":
+		text(" ")
+	case "SoftBreak":
+		parent.AppendChild(parser.NewNode(parser.NodeSoftBreak))
+	case "LineBreak":
+		parent.AppendChild(parser.NewNode(parser.NodeHardBreak))
+	case "Emph":
+		wrap(parser.NodeEmphasis, c)
+	case "Strong":
+		wrap(parser.NodeStrong, c)
+	case "Code":
+		args := list(c)
+		if len(args) != 2 {
+			d.malformed(t)
+			return
+		}
+		n := parser.NewNode(parser.NodeCodeSpan)
+		n.Literal = str(args[1])
+		parent.AppendChild(n)
+	case "Link", "Image":
+		args := list(c)
+		if len(args) != 3 || len(list(args[2])) != 2 {
+			d.malformed(t)
+			return
+		}
+		kind := parser.NodeLink
+		if t == "Image" {
+			kind = parser.NodeImage
+		}
+		n := wrap(kind, args[1])
+		target := list(args[2])
+		n.Dest, n.Title = str(target[0]), str(target[1])
+	case "Quoted":
+		if args := list(c); len(args) == 2 {
+			q := `"`
+			if qt, _ := elem(args[0]); qt == "SingleQuote" {
+				q = "'"
+			}
+			text(q)
+			d.inlines(parent, args[1])
+			text(q)
+		}
+	case "Span", "Cite": // Attributes or citations, then the inlines
+		if args := list(c); len(args) == 2 {
+			d.inlines(parent, args[1])
+		}
+	case "Math":
+		if args := list(c); len(args) == 2 {
+			text(str(args[1]))
+		}
+	case "Strikeout":
+		wrap(parser.NodeStrikethrough, c)
+	case "Underline", "Superscript", "Subscript", "SmallCaps":
+		d.inlines(parent, c)
+	case "Note":
+		// Footnotes have no node, so are dropped
+	}
+}
+
+// Filter pipes doc through the Pandoc filter at path as JSON, replacing
+// its contents & front matter with what the filter writes back. format is
+// the format the document is on its way to, which filters are told. As
+// Pandoc does, .lua filters are run by pandoc itself, .py ones by python3
+// and others as programs in their own right
+func Filter(doc *parser.Document, format, path string) error {
+	in, err := json.Marshal(FromDocument(doc))
+	if err != nil {
+		return err
+	}
+	var cmd *exec.Cmd
+	switch filepath.Ext(path) {
+	case ".lua":
+		cmd = exec.Command("pandoc", "--from", "json", "--to", "json", "--lua-filter", path)
+	case ".py":
+		cmd = exec.Command("python3", path, format)
+	default:
+		cmd = exec.Command(path, format)
+	}
+	var out, stderr bytes.Buffer
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = bytes.NewReader(in), &out, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("filter %s: %v: %s", path, err, msg)
+		}
+		return fmt.Errorf("filter %s: %v", path, err)
+	}
+	var p Document
+	if err := json.Unmarshal(out.Bytes(), &p); err != nil {
+		return fmt.Errorf("filter %s: %v", path, err)
+	}
+	filtered, err := ToDocument(doc.Name, &p)
+	if err != nil {
+		return fmt.Errorf("filter %s: %v", path, err)
+	}
+	doc.Root, doc.Meta, doc.FrontMatter = filtered.Root, filtered.Meta, filtered.FrontMatter
+	return nil
+}
@@ -0,0 +1,55 @@
+package parser
+
+// arenaBlock is how many nodes an Arena allocates at a time
+const arenaBlock = 1024
+
+// Arena allocates the nodes of parsed documents in large blocks, so parsing
+// many small documents, such as comments on a busy server, makes a handful
+// of allocations rather than one per node. Reset frees every node at once
+// for the next parse to reuse.
+//
+// The zero value is ready to use. An Arena must not be used by more than
+// one goroutine at a time; give each worker its own, or keep them in a
+// sync.Pool
+type Arena struct {
+	blocks [][]Node
+	block  int // Block nodes are being handed out from
+	used   int // Nodes handed out from that block
+}
+
+// alloc returns a node in a, set to n. A nil Arena allocates from the heap
+func (a *Arena) alloc(n Node) *Node {
+	if a == nil {
+		h := new(Node) // Rather than &n, which would put every n on the heap
+		*h = n
+		return h
+	}
+	if a.block == len(a.blocks) {
+		a.blocks = append(a.blocks, make([]Node, arenaBlock))
+	}
+	slot := &a.blocks[a.block][a.used]
+	*slot = n
+	if a.used++; a.used == arenaBlock {
+		a.block, a.used = a.block+1, 0
+	}
+	return slot
+}
+
+// Reset makes all of a's memory available to be reused. None of the nodes
+// of documents parsed with a may be used afterwards
+func (a *Arena) Reset() {
+	for i := 0; i < a.block; i++ {
+		clearNodes(a.blocks[i])
+	}
+	if a.block < len(a.blocks) {
+		clearNodes(a.blocks[a.block][:a.used])
+	}
+	a.block, a.used = 0, 0
+}
+
+// clearNodes zeroes nodes so they no longer keep what they referred to alive
+func clearNodes(nodes []Node) {
+	for i := range nodes {
+		nodes[i] = Node{}
+	}
+}
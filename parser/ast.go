@@ -0,0 +1,206 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// NodeKind identifies what a Node in the syntax tree represents
+type NodeKind int
+
+const (
+	NodeDocument NodeKind = iota
+	NodeParagraph
+	NodeHeading
+	NodeThematicBreak
+	NodeList
+	NodeListItem
+	NodeCodeBlock
+	NodeBlockQuote
+	NodeText
+	NodeSoftBreak
+	NodeHardBreak
+	NodeEmphasis
+	NodeStrong
+	NodeCodeSpan
+	NodeLink
+	NodeImage
+)
+
+var nodeKindNames = []string{
+	NodeDocument:      "Document",
+	NodeParagraph:     "Paragraph",
+	NodeHeading:       "Heading",
+	NodeThematicBreak: "ThematicBreak",
+	NodeList:          "List",
+	NodeListItem:      "ListItem",
+	NodeCodeBlock:     "CodeBlock",
+	NodeBlockQuote:    "BlockQuote",
+	NodeText:          "Text",
+	NodeSoftBreak:     "SoftBreak",
+	NodeHardBreak:     "HardBreak",
+	NodeEmphasis:      "Emphasis",
+	NodeStrong:        "Strong",
+	NodeCodeSpan:      "CodeSpan",
+	NodeLink:          "Link",
+	NodeImage:         "Image",
+}
+
+// Kinds registered by extensions
+var (
+	customKindsMu sync.RWMutex
+	customBlocks  = make(map[NodeKind]bool)
+)
+
+// NewNodeKind registers a kind of node for an extension to use, named name.
+// block says whether nodes of this kind are block-level
+func NewNodeKind(name string, block bool) NodeKind {
+	customKindsMu.Lock()
+	defer customKindsMu.Unlock()
+	k := NodeKind(len(nodeKindNames))
+	nodeKindNames = append(nodeKindNames, name)
+	customBlocks[k] = block
+	return k
+}
+
+func (k NodeKind) String() string {
+	customKindsMu.RLock()
+	defer customKindsMu.RUnlock()
+	if k >= 0 && int(k) < len(nodeKindNames) {
+		return nodeKindNames[k]
+	}
+	return fmt.Sprintf("NodeKind(%d)", int(k))
+}
+
+// NodeKindNamed returns the kind, built in or registered, whose String is
+// name
+func NodeKindNamed(name string) (NodeKind, bool) {
+	customKindsMu.RLock()
+	defer customKindsMu.RUnlock()
+	for k, n := range nodeKindNames {
+		if n == name {
+			return NodeKind(k), true
+		}
+	}
+	return 0, false
+}
+
+// Node is a single element of the syntax tree. Which fields are meaningful
+// depends on Kind
+type Node struct {
+	Kind     NodeKind
+	Parent   *Node
+	Children []*Node
+
+	Literal string // Contents of Text, CodeSpan & CodeBlock nodes, marker of a ThematicBreak
+	Level   int    // Heading level, 1-6
+	Ordered bool   // Whether a List is numbered
+	Info    string // Info string of a fenced CodeBlock
+	Dest    string // Destination of a Link or Image
+	Title   string // Title of a Link or Image
+	ID      string // Anchor of a Heading, with WithHeadingIDs
+
+	Data interface{} // Free for extensions to attach their own values
+
+	Range Range // Source the node was parsed from, with WithSourceRanges
+}
+
+// Range is the span of a Document's Source from byte offset Start up to End
+type Range struct {
+	Start, End int
+}
+
+// NewNode returns a detached node of the given kind
+func NewNode(k NodeKind) *Node {
+	return &Node{Kind: k}
+}
+
+// AppendChild adds c as the last child of n
+func (n *Node) AppendChild(c *Node) {
+	c.Parent = n
+	n.Children = append(n.Children, c)
+}
+
+// LastChild returns the last child of n, or nil if n has none
+func (n *Node) LastChild() *Node {
+	if len(n.Children) == 0 {
+		return nil
+	}
+	return n.Children[len(n.Children)-1]
+}
+
+// IsBlock reports whether n is a block-level node
+func (n *Node) IsBlock() bool {
+	if n.Kind <= NodeBlockQuote {
+		return true
+	}
+	customKindsMu.RLock()
+	defer customKindsMu.RUnlock()
+	return customBlocks[n.Kind]
+}
+
+// PlainText returns the text beneath n with all markup removed
+func (n *Node) PlainText() string {
+	var b strings.Builder
+	n.plainText(&b)
+	return b.String()
+}
+
+func (n *Node) plainText(b *strings.Builder) {
+	switch n.Kind {
+	case NodeText, NodeCodeSpan:
+		b.WriteString(n.Literal)
+	case NodeSoftBreak, NodeHardBreak:
+		b.WriteByte(' ')
+	}
+	for _, c := range n.Children {
+		c.plainText(b)
+	}
+}
+
+// Document is the result of parsing a single markdown input
+type Document struct {
+	Name string
+	Meta map[string]string // Front matter fields
+	Root *Node
+
+	// The front matter block exactly as written, delimiters included
+	FrontMatter string
+
+	// The input as parsed, front matter included, with WithSourceRanges
+	Source string
+
+	Stats *Stats // How the document was parsed, with WithStats
+}
+
+// Raw returns the source text n was parsed from, exactly as written. It's
+// empty unless the document was parsed WithSourceRanges, and for nodes
+// added after parsing
+func (d *Document) Raw(n *Node) string {
+	if n.Range.End > len(d.Source) {
+		return ""
+	}
+	return d.Source[n.Range.Start:n.Range.End]
+}
+
+// Position returns the line & column of the byte offset in Source, such as
+// the start of a node's Range
+func (d *Document) Position(offset int) Position {
+	line := strings.Count(d.Source[:offset], "\n")
+	return Position{Offset: offset, Line: line + 1, Column: offset - strings.LastIndexByte(d.Source[:offset], '\n')}
+}
+
+// Title returns the title from the front matter, falling back to the text
+// of the first heading and then the document's name
+func (d *Document) Title() string {
+	if t := d.Meta["title"]; t != "" {
+		return t
+	}
+	for _, n := range d.Root.Children {
+		if n.Kind == NodeHeading {
+			return n.PlainText()
+		}
+	}
+	return d.Name
+}
@@ -0,0 +1,37 @@
+package parser_test
+
+import (
+	"testing"
+
+	"../bench"
+	"../parser"
+)
+
+func BenchmarkParse(b *testing.B) {
+	for _, in := range bench.Inputs() {
+		in := in
+		b.Run(in.Name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(in.Src)))
+			for i := 0; i < b.N; i++ {
+				parser.Parse(in.Name, in.Src)
+			}
+		})
+	}
+}
+
+func BenchmarkParseArena(b *testing.B) {
+	p := parser.New()
+	for _, in := range bench.Inputs() {
+		in := in
+		b.Run(in.Name, func(b *testing.B) {
+			var a parser.Arena
+			b.ReportAllocs()
+			b.SetBytes(int64(len(in.Src)))
+			for i := 0; i < b.N; i++ {
+				p.ParseWithArena(in.Name, in.Src, &a)
+				a.Reset()
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package parser
+
+import "strings"
+
+// BlockParseFunc parses a custom block starting at the scanner's current
+// line, advancing past every line that belongs to the block. It returns
+// the block's node, or nil to leave the line to be lexed as ordinary text
+type BlockParseFunc func(s *BlockScanner) *Node
+
+// BlockScanner gives a custom block parser line by line access to the input
+type BlockScanner struct {
+	l       *lexer
+	p       *Parser
+	inlines []pendingInline
+}
+
+// pendingInline is text a block parser has left to be parsed as the inline
+// content of node, at offset at of the input
+type pendingInline struct {
+	node *Node
+	text string
+	at   int
+}
+
+// Line returns the rest of the current line, without its line ending
+func (s *BlockScanner) Line() string {
+	rest := s.l.input[s.l.pos:]
+	i := strings.Index(rest, string(s.l.br))
+	if s.l.anyBr {
+		i = strings.IndexAny(rest, "\r\n")
+	}
+	if i >= 0 {
+		return rest[:i]
+	}
+	return rest
+}
+
+// Advance moves to the start of the next line. It returns false once there
+// are no more lines
+func (s *BlockScanner) Advance() bool {
+	s.l.acceptUntilNewLine()
+	s.l.nextNTimes(s.l.lineEnding())
+	return !s.EOF()
+}
+
+// EOF reports whether the whole input has been consumed
+func (s *BlockScanner) EOF() bool {
+	return s.l.pos >= len(s.l.input)
+}
+
+// Parse parses src as markdown with the same Parser, for blocks whose
+// contents are themselves markdown. The returned nodes are detached, ready
+// to be appended to the custom block's node
+func (s *BlockScanner) Parse(src string) ([]*Node, error) {
+	doc, err := s.p.Parse(s.l.name, src)
+	if err != nil {
+		return nil, err
+	}
+	children := doc.Root.Children
+	for _, c := range children {
+		c.Parent = nil
+	}
+	return children, nil
+}
+
+// Inline has text parsed as the inline content of n, with the document's
+// link references & inline extensions, once the block is done, as for the
+// cells of a table. i is where text starts in the current line, from which
+// Ranges within it are kept
+func (s *BlockScanner) Inline(n *Node, text string, i int) {
+	s.inlines = append(s.inlines, pendingInline{n, text, s.l.pos + i})
+}
+
+// AddBlockParser registers parse to handle blocks whose first line begins
+// with trigger, such as "%%%" for a "%%% spoiler" block. Built-in block
+// syntax takes precedence, then block parsers in the order they were added.
+// The node parse returns should be of a kind made with NewNodeKind. A
+// block must take up at least its first line: parsing fails if parse
+// returns a node without advancing
+func (p *Parser) AddBlockParser(trigger string, parse BlockParseFunc) {
+	p.addBlockStarter(trigger, func(l *lexer) stateFn {
+		c := l.checkpoint()
+		s := &BlockScanner{l: l, p: p}
+		n := parse(s)
+		if n == nil {
+			l.restore(c)
+			return lexLine
+		}
+		if l.pos == c.pos { // Lexing on from here would find the block again
+			l.restore(c)
+			return l.errorf("%q block parser returned a block without advancing past its first line", trigger)
+		}
+		l.emitItem(Token{Kind: TokenBlock, Node: n, inlines: s.inlines})
+		l.ignore()
+		if l.pos >= len(l.input) {
+			l.emit(TokenEOF)
+			return nil
+		}
+		return lexText
+	})
+}
@@ -0,0 +1,37 @@
+package parser_test
+
+import (
+	"testing"
+	"time"
+
+	"../parser"
+)
+
+var nodeStuck = parser.NewNodeKind("Stuck", false)
+
+// TestBlockParserWithoutProgress checks a block parser returning a block
+// without advancing fails the parse rather than being run again forever
+func TestBlockParserWithoutProgress(t *testing.T) {
+	p := parser.New(parser.WithExtensions(parser.ExtensionFunc(func(p *parser.Parser) {
+		p.AddBlockParser("%%%", func(s *parser.BlockScanner) *parser.Node {
+			return parser.NewNode(nodeStuck)
+		})
+	})))
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.Parse("test", "text\n\n%%% stuck\n")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		perr, ok := err.(*parser.ParseError)
+		if !ok {
+			t.Fatalf("got error %v, want a ParseError", err)
+		}
+		if perr.Pos.Line != 3 {
+			t.Errorf("error at line %d, want 3: %v", perr.Pos.Line, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("parsing never finished")
+	}
+}
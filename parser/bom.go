@@ -0,0 +1,127 @@
+package parser
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Byte order marks that may start a file
+const (
+	bomUTF8    = "\xef\xbb\xbf"
+	bomUTF16LE = "\xff\xfe"
+	bomUTF16BE = "\xfe\xff"
+)
+
+// decodeBOM strips a leading UTF-8 byte order mark from s, and transcodes
+// s to UTF-8 if it starts with a UTF-16 one. Without a mark s is assumed to
+// be UTF-8 already
+func decodeBOM(s string) string {
+	switch {
+	case strings.HasPrefix(s, bomUTF8):
+		return s[len(bomUTF8):]
+	case strings.HasPrefix(s, bomUTF16LE):
+		return decodeUTF16(s[len(bomUTF16LE):], false)
+	case strings.HasPrefix(s, bomUTF16BE):
+		return decodeUTF16(s[len(bomUTF16BE):], true)
+	}
+	return s
+}
+
+// decodeUTF16 transcodes UTF-16 to UTF-8. A trailing odd byte is dropped
+func decodeUTF16(s string, bigEndian bool) string {
+	units := make([]uint16, len(s)/2)
+	for i := range units {
+		lo, hi := s[2*i], s[2*i+1]
+		if bigEndian {
+			lo, hi = hi, lo
+		}
+		units[i] = uint16(lo) | uint16(hi)<<8
+	}
+	var b strings.Builder
+	b.Grow(len(units))
+	for _, r := range utf16.Decode(units) {
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// bomReader returns a reader of r's contents with any byte order mark
+// handled as decodeBOM does
+func bomReader(r io.Reader) io.Reader {
+	in := bufio.NewReader(r)
+	mark, _ := in.Peek(len(bomUTF8))
+	switch {
+	case strings.HasPrefix(string(mark), bomUTF8):
+		in.Discard(len(bomUTF8))
+	case strings.HasPrefix(string(mark), bomUTF16LE):
+		in.Discard(len(bomUTF16LE))
+		return &utf16Reader{in: in}
+	case strings.HasPrefix(string(mark), bomUTF16BE):
+		in.Discard(len(bomUTF16BE))
+		return &utf16Reader{in: in, bigEndian: true}
+	}
+	return in
+}
+
+// utf16Reader transcodes a stream of UTF-16 to UTF-8 as it is read
+type utf16Reader struct {
+	in        *bufio.Reader
+	bigEndian bool
+	out       []byte // Transcoded but not yet read
+}
+
+func (u *utf16Reader) Read(p []byte) (int, error) {
+	for len(u.out) == 0 {
+		r, err := u.readRune()
+		if err != nil {
+			return 0, err
+		}
+		u.out = utf8.AppendRune(u.out[:0], r)
+	}
+	n := copy(p, u.out)
+	u.out = u.out[n:]
+	return n, nil
+}
+
+// readRune decodes the next character, pairing up surrogates
+func (u *utf16Reader) readRune() (rune, error) {
+	c, err := u.readUnit()
+	if err != nil {
+		return 0, err
+	}
+	if !utf16.IsSurrogate(rune(c)) {
+		return rune(c), nil
+	}
+	next, err := u.in.Peek(2)
+	if err != nil {
+		return utf8.RuneError, nil
+	}
+	c2 := u.unit(next)
+	r := utf16.DecodeRune(rune(c), rune(c2))
+	if r != utf8.RuneError {
+		u.in.Discard(2)
+	}
+	return r, nil
+}
+
+// readUnit reads one 16 bit code unit. A trailing odd byte is dropped
+func (u *utf16Reader) readUnit() (uint16, error) {
+	var b [2]byte
+	if _, err := io.ReadFull(u.in, b[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return 0, err
+	}
+	return u.unit(b[:]), nil
+}
+
+func (u *utf16Reader) unit(b []byte) uint16 {
+	if u.bigEndian {
+		return uint16(b[0])<<8 | uint16(b[1])
+	}
+	return uint16(b[0]) | uint16(b[1])<<8
+}
@@ -0,0 +1,36 @@
+package parser_test
+
+import (
+	"testing"
+
+	"../parser"
+)
+
+// TestAddAfterNew checks a Parser can't be extended once New has handed
+// it out, when another goroutine could be parsing with it
+func TestAddAfterNew(t *testing.T) {
+	p := parser.New()
+	for name, add := range map[string]func(){
+		"AddBlockParser": func() {
+			p.AddBlockParser("!", func(*parser.BlockScanner) *parser.Node { return nil })
+		},
+		"AddTransformer": func() {
+			p.AddTransformer(parser.TransformerFunc(func(*parser.Document) {}))
+		},
+		"AddInlineParser": func() {
+			p.AddInlineParser('!', parser.BeforeBuiltins, func(string, int) (*parser.Node, int) { return nil, 0 })
+		},
+		"AddInlineContainer": func() {
+			p.AddInlineContainer('!', parser.BeforeBuiltins, func(string, int) (*parser.Node, int, int, int) { return nil, 0, 0, 0 })
+		},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s after New didn't panic", name)
+				}
+			}()
+			add()
+		}()
+	}
+}
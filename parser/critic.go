@@ -0,0 +1,112 @@
+package parser
+
+import "strings"
+
+// Kinds of the nodes CriticMarkup makes
+var (
+	// NodeCriticInsert is text added, {++like this++}
+	NodeCriticInsert = NewNodeKind("CriticInsert", false)
+	// NodeCriticDelete is text taken out, {--like this--}
+	NodeCriticDelete = NewNodeKind("CriticDelete", false)
+	// NodeCriticSubstitute is text replaced, {~~this~>with this~~}: a
+	// NodeCriticDelete of the old followed by a NodeCriticInsert of the new
+	NodeCriticSubstitute = NewNodeKind("CriticSubstitute", false)
+	// NodeCriticComment is a note to the author, {>>like this<<}, its text
+	// the Literal
+	NodeCriticComment = NewNodeKind("CriticComment", false)
+)
+
+// criticSeparator parts the old text of a substitution from the new
+const criticSeparator = "~>"
+
+// maxCritic is the longest an edit may be, so a line of them left open
+// isn't searched to the end from each
+const maxCritic = 4096
+
+// criticClose returns where the markup opened at line[pos] by open is
+// closed by close, or -1 if it isn't
+func criticClose(line string, pos int, open, close string) int {
+	if !strings.HasPrefix(line[pos:], open) {
+		return -1
+	}
+	start := pos + len(open)
+	end := len(line)
+	if end > start+maxCritic {
+		end = start + maxCritic
+	}
+	i := strings.Index(line[start:end], close)
+	if i <= 0 {
+		return -1
+	}
+	return start + i
+}
+
+// CriticMarkup reads the edits of CriticMarkup, {++insertions++},
+// {--deletions--}, {~~substitutions~>of one text for another~~} and
+// {>>comments<<}, for reviewing documents. What's inserted, deleted &
+// substituted is markdown too; comments are plain text. Like links, edits
+// can't span lines. Renderers show the edits unless told to accept or
+// reject them
+var CriticMarkup Extension = ExtensionFunc(func(p *Parser) {
+	for _, edit := range []struct {
+		open, close string
+		kind        NodeKind
+	}{
+		{"{++", "++}", NodeCriticInsert},
+		{"{--", "--}", NodeCriticDelete},
+		{"{~~", "~~}", NodeCriticSubstitute},
+	} {
+		edit := edit
+		p.AddInlineContainer('{', BeforeBuiltins, func(line string, pos int) (*Node, int, int, int) {
+			stop := criticClose(line, pos, edit.open, edit.close)
+			if stop < 0 {
+				return nil, 0, 0, 0
+			}
+			if edit.kind == NodeCriticSubstitute && !strings.Contains(line[pos:stop], criticSeparator) {
+				return nil, 0, 0, 0
+			}
+			return NewNode(edit.kind), pos + len(edit.open), stop, stop + len(edit.close)
+		})
+	}
+	p.AddInlineParser('{', BeforeBuiltins, func(line string, pos int) (*Node, int) {
+		stop := criticClose(line, pos, "{>>", "<<}")
+		if stop < 0 {
+			return nil, 0
+		}
+		n := NewNode(NodeCriticComment)
+		n.Literal = line[pos+3 : stop]
+		return n, stop + 3
+	})
+	p.AddTransformer(TransformerFunc(func(doc *Document) {
+		splitSubstitutions(doc.Root)
+	}))
+})
+
+// splitSubstitutions parts what each substitution within n holds into the
+// old text & the new, at the first separator outside any other element
+func splitSubstitutions(n *Node) {
+	for _, c := range n.Children {
+		splitSubstitutions(c)
+	}
+	if n.Kind != NodeCriticSubstitute {
+		return
+	}
+	del, ins := NewNode(NodeCriticDelete), NewNode(NodeCriticInsert)
+	to := del
+	for _, c := range n.Children {
+		if i := strings.Index(c.Literal, criticSeparator); to == del && c.Kind == NodeText && i >= 0 {
+			if i > 0 {
+				del.AppendChild(&Node{Kind: NodeText, Literal: c.Literal[:i]})
+			}
+			if rest := c.Literal[i+len(criticSeparator):]; rest != "" {
+				ins.AppendChild(&Node{Kind: NodeText, Literal: rest})
+			}
+			to = ins
+			continue
+		}
+		to.AppendChild(c)
+	}
+	n.Children = nil
+	n.AppendChild(del)
+	n.AppendChild(ins)
+}
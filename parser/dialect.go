@@ -0,0 +1,83 @@
+package parser
+
+import "fmt"
+
+// Dialect is a flavour of Markdown, standing for the bundle of extensions
+// that makes a Parser read documents the way its authors expect
+type Dialect int
+
+const (
+	// CommonMark follows the CommonMark spec, fenced code blocks included
+	CommonMark Dialect = iota
+	// GFM is GitHub Flavored Markdown: CommonMark with Tables,
+	// Strikethrough, TaskLists & Autolinks
+	GFM
+	// OriginalMarkdown is Markdown as first described by John Gruber,
+	// without fenced code blocks, where a list needs a blank line before it
+	// and a heading needs no space after its #'s
+	OriginalMarkdown
+	// MultiMarkdown has fenced code blocks, tables and a block of metadata
+	// at the top of the document, "Key: value" lines up to a blank line,
+	// read into the Document's Meta with its keys in lower case & without
+	// spaces. Front matter between "---" lines is read too
+	MultiMarkdown
+)
+
+var dialectNames = []string{
+	CommonMark:       "CommonMark",
+	GFM:              "GFM",
+	OriginalMarkdown: "OriginalMarkdown",
+	MultiMarkdown:    "MultiMarkdown",
+}
+
+// dialectExtensions lists the extensions of each Dialect
+var dialectExtensions = [][]Extension{
+	CommonMark:       {FencedCode},
+	GFM:              {FencedCode, Tables, Strikethrough, TaskLists, Autolinks},
+	OriginalMarkdown: {listsNeedBlankLine, headingsNeedNoSpace},
+	MultiMarkdown:    {FrontMatter, multiMarkdownMeta, FencedCode, Tables},
+}
+
+// listsNeedBlankLine keeps lists from interrupting a paragraph, as in
+// Markdown.pl, unless RelaxListInterrupt lets them
+var listsNeedBlankLine Extension = ExtensionFunc(func(p *Parser) {
+	p.noInterrupt = true
+})
+
+// headingsNeedNoSpace reads "#Heading" as a heading, as Markdown.pl does,
+// whatever the Relaxations
+var headingsNeedNoSpace Extension = ExtensionFunc(func(p *Parser) {
+	p.headingNoSpace = true
+})
+
+// multiMarkdownMeta reads the metadata at the top of a MultiMarkdown
+// document
+var multiMarkdownMeta Extension = ExtensionFunc(func(p *Parser) {
+	p.mmdMeta = true
+})
+
+func (d Dialect) String() string {
+	if d >= 0 && int(d) < len(dialectNames) {
+		return dialectNames[d]
+	}
+	return fmt.Sprintf("Dialect(%d)", int(d))
+}
+
+// Extensions returns the extensions d enables, or DefaultExtensions if d
+// isn't one of the Dialects
+func (d Dialect) Extensions() []Extension {
+	if d < 0 || int(d) >= len(dialectExtensions) {
+		return append([]Extension(nil), DefaultExtensions...)
+	}
+	return append([]Extension(nil), dialectExtensions[d]...)
+}
+
+// WithDialect enables the extensions of d in place of the current set. To
+// add more on top of d's, pass them all to WithExtensions instead:
+//
+//	p := parser.New(parser.WithExtensions(append(parser.GFM.Extensions(), myExt)...))
+func WithDialect(d Dialect) Option {
+	return func(p *Parser) {
+		p.extensions = d.Extensions()
+	}
+}
@@ -0,0 +1,122 @@
+package parser_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"../parser"
+)
+
+// TestListInterrupt checks a list interrupts a paragraph as the spec of
+// each dialect says, strictly, and always with RelaxListInterrupt
+func TestListInterrupt(t *testing.T) {
+	for _, tc := range []struct {
+		dialect parser.Dialect
+		relax   parser.Relaxation
+		list    bool
+	}{
+		{parser.CommonMark, parser.Strict, true},
+		{parser.OriginalMarkdown, parser.Strict, false},
+		{parser.OriginalMarkdown, parser.RelaxListInterrupt, true},
+	} {
+		p := parser.New(parser.WithDialect(tc.dialect), parser.WithRelaxations(tc.relax))
+		doc, err := p.Parse("test", "Foo\n- bar\n- baz\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		list := len(doc.Root.Children) == 2 && doc.Root.Children[1].Kind == parser.NodeList
+		if list != tc.list {
+			t.Errorf("%v with relaxations %b: list %t, want %t", tc.dialect, tc.relax, list, tc.list)
+		}
+	}
+}
+
+// TestDialects checks what each dialect reads differently from CommonMark
+func TestDialects(t *testing.T) {
+	for _, tc := range []struct {
+		dialect parser.Dialect
+		md      string
+		kind    parser.NodeKind // Of the first block
+		other   parser.NodeKind // In CommonMark
+	}{
+		{parser.OriginalMarkdown, "#Heading\n", parser.NodeHeading, parser.NodeParagraph},
+		{parser.GFM, "| a | b |\n| - | - |\n| 1 | 2 |\n", parser.NodeTable, parser.NodeParagraph},
+		{parser.MultiMarkdown, "| a | b |\n| - | - |\n", parser.NodeTable, parser.NodeParagraph},
+	} {
+		for _, d := range []parser.Dialect{tc.dialect, parser.CommonMark} {
+			doc, err := parser.New(parser.WithDialect(d)).Parse("test", tc.md)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tc.kind
+			if d == parser.CommonMark {
+				want = tc.other
+			}
+			if got := doc.Root.Children[0].Kind; got != want {
+				t.Errorf("%v read %q as %v, want %v", d, tc.md, got, want)
+			}
+		}
+	}
+}
+
+func TestGFMInlines(t *testing.T) {
+	p := parser.New(parser.WithDialect(parser.GFM))
+	doc, err := p.Parse("test", "- [x] ~~struck~~ at www.example.com/a_(b).\n- [ ] ~~~not~~~ https://x.org\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, todo := doc.Root.Children[0].Children[0], doc.Root.Children[0].Children[1]
+	if box := done.Children[0]; box.Kind != parser.NodeTaskCheckbox || box.Literal != "x" {
+		t.Errorf("done task starts with %v %q", box.Kind, box.Literal)
+	}
+	if box := todo.Children[0]; box.Kind != parser.NodeTaskCheckbox || box.Literal != " " {
+		t.Errorf("task to do starts with %v %q", box.Kind, box.Literal)
+	}
+	if k := done.Children[1].Kind; k != parser.NodeText {
+		t.Errorf("the checkbox is followed by %v", k)
+	}
+	var links []string
+	var struck int
+	var walk func(n *parser.Node)
+	walk = func(n *parser.Node) {
+		switch n.Kind {
+		case parser.NodeLink:
+			links = append(links, n.Dest)
+		case parser.NodeStrikethrough:
+			struck++
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(doc.Root)
+	if want := []string{"http://www.example.com/a_(b)", "https://x.org"}; strings.Join(links, " ") != strings.Join(want, " ") {
+		t.Errorf("links to %q, want %q", links, want)
+	}
+	if struck != 1 {
+		t.Errorf("%d struck out, want 1", struck)
+	}
+}
+
+func TestMultiMarkdownMeta(t *testing.T) {
+	p := parser.New(parser.WithDialect(parser.MultiMarkdown))
+	doc, err := p.Parse("test", "Title: A Doc\nBase Header Level: 2\nAuthor: Ann\n    & Bob\n\n# Body\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"title": "A Doc", "baseheaderlevel": "2", "author": "Ann & Bob"}
+	if !reflect.DeepEqual(doc.Meta, want) {
+		t.Errorf("meta %q, want %q", doc.Meta, want)
+	}
+	if len(doc.Root.Children) != 1 || doc.Root.Children[0].Kind != parser.NodeHeading {
+		t.Errorf("body is %d blocks, want the heading", len(doc.Root.Children))
+	}
+	doc, err = p.Parse("test", "Not: metadata\nafter all\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Meta != nil || len(doc.Root.Children) != 1 {
+		t.Errorf("a paragraph was read as metadata %q", doc.Meta)
+	}
+}
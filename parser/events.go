@@ -0,0 +1,52 @@
+package parser
+
+// EventHandler receives the elements of a document in order, with entering
+// set when an element starts & unset once its children have been seen.
+// Parsing stops at the first error it returns
+type EventHandler func(n *Node, entering bool) error
+
+// ParseEvents parses input with the default configuration, reporting each
+// element to h
+func ParseEvents(name, input string, h EventHandler) error {
+	return defaultParser.ParseEvents(name, input, h)
+}
+
+// ParseEvents parses input like Parse, but reports each element to h as
+// it's completed rather than building a Document, for extractors such as
+// link collectors & word counters run over large inputs. Only the
+// top-level block being assembled is held in memory; once the next one
+// starts it's passed to h and released, so nodes must not be kept after h
+// returns. The Document node is entered first and exited last, with no
+// Children. Transformers are not run
+func (p *Parser) ParseEvents(name, input string, h EventHandler) error {
+	if err := p.checkSize(name, len(input)); err != nil {
+		return err
+	}
+	src := sanitize(decodeBOM(input))
+	meta, input := p.splitMeta(src)
+	b := p.newBuilder(name, meta, src, input, p.references(input), nil)
+	b.onBlock = func(n *Node) error {
+		return walkEvents(n, h)
+	}
+	root := b.doc.Root
+	if err := h(root, true); err != nil {
+		return err
+	}
+	if err := b.run(lex(name, input, p)); err != nil {
+		return err
+	}
+	return h(root, false)
+}
+
+// walkEvents reports n and everything beneath it to h
+func walkEvents(n *Node, h EventHandler) error {
+	if err := h(n, true); err != nil {
+		return err
+	}
+	for _, c := range n.Children {
+		if err := walkEvents(c, h); err != nil {
+			return err
+		}
+	}
+	return h(n, false)
+}
@@ -0,0 +1,126 @@
+package parser
+
+// Extension adds optional syntax or behaviour to a Parser. Extend is called
+// once while the Parser is being built, and registers whatever block
+// starters, inline parsers & transformers the extension needs
+type Extension interface {
+	Extend(p *Parser)
+}
+
+// ExtensionFunc adapts an ordinary function to an Extension
+type ExtensionFunc func(p *Parser)
+
+// Extend calls f(p)
+func (f ExtensionFunc) Extend(p *Parser) {
+	f(p)
+}
+
+// Transformer rewrites a Document once it has been parsed
+type Transformer interface {
+	Transform(doc *Document)
+}
+
+// TransformerFunc adapts an ordinary function to a Transformer
+type TransformerFunc func(doc *Document)
+
+// Transform calls f(doc)
+func (f TransformerFunc) Transform(doc *Document) {
+	f(doc)
+}
+
+// blockStarter switches the lexer into state when a line begins with prefix
+type blockStarter struct {
+	prefix string
+	state  stateFn
+}
+
+var (
+	// FrontMatter reads a leading block of "key: value" lines between "---"
+	// delimiters into the Document's Meta
+	FrontMatter Extension = ExtensionFunc(func(p *Parser) {
+		p.frontMatter = true
+	})
+
+	// FencedCode recognises ``` delimited code blocks
+	FencedCode Extension = ExtensionFunc(func(p *Parser) {
+		p.addBlockStarter(codeFence, lexCode)
+	})
+)
+
+// DefaultExtensions are the extensions a Parser enables unless told otherwise
+var DefaultExtensions = []Extension{FrontMatter, FencedCode}
+
+// AddTransformer arranges for t to run over every Document p parses, after
+// the transformers added before it. Like the other Add methods it may only
+// be called from an Extension's Extend
+func (p *Parser) AddTransformer(t Transformer) {
+	p.mustBeBuilding()
+	p.transformers = append(p.transformers, t)
+}
+
+// addBlockStarter registers a lexer state to enter for lines beginning with
+// prefix. Starters are tried in the order they were added, after the
+// built-in block syntax
+func (p *Parser) addBlockStarter(prefix string, state stateFn) {
+	p.mustBeBuilding()
+	p.blockStarters = append(p.blockStarters, blockStarter{prefix, state})
+}
+
+// mustBeBuilding panics if p has already been handed out by New, since
+// changing it then would race with concurrent calls to Parse
+func (p *Parser) mustBeBuilding() {
+	if p.built {
+		panic("parser: extensions must be registered from Extension.Extend, not after New returns")
+	}
+}
+
+// InlineParseFunc parses a custom inline element starting at line[pos], the
+// trigger byte it was registered for. It returns the element's node and
+// the index just past it, or a nil node to decline
+type InlineParseFunc func(line string, pos int) (n *Node, end int)
+
+// Precedence orders a custom inline parser relative to the built-in rules
+// for the same trigger byte
+type Precedence int
+
+const (
+	BeforeBuiltins Precedence = iota // Tried before any built-in rule
+	AfterBuiltins                    // Tried only if the built-in rules decline
+)
+
+// InlineContainerFunc parses a custom inline element with markdown inside
+// it, starting at line[pos], the trigger byte it was registered for. It
+// returns the element's node, where its contents start & stop in line, and
+// the index just past it, or a nil node to decline. The contents are
+// parsed as the node's children
+type InlineContainerFunc func(line string, pos int) (n *Node, start, stop, end int)
+
+// inlineRule is a custom inline parser and when to try it
+type inlineRule struct {
+	prec      Precedence
+	parse     InlineParseFunc
+	container InlineContainerFunc // Instead of parse
+}
+
+// AddInlineParser registers parse to be tried wherever trigger appears in
+// inline text, such as '@' for mentions or '#' for issue references.
+// Parsers sharing a trigger & precedence are tried in the order added
+func (p *Parser) AddInlineParser(trigger byte, prec Precedence, parse InlineParseFunc) {
+	p.mustBeBuilding()
+	if p.inlineRules == nil {
+		p.inlineRules = make(map[byte][]inlineRule)
+	}
+	p.inlineRules[trigger] = append(p.inlineRules[trigger], inlineRule{prec: prec, parse: parse})
+}
+
+// AddInlineContainer registers parse to be tried wherever trigger appears
+// in inline text, like AddInlineParser, for elements such as ^[notes]
+// whose contents are markdown too. Past the nesting limit, elements are
+// left as text
+func (p *Parser) AddInlineContainer(trigger byte, prec Precedence, parse InlineContainerFunc) {
+	p.mustBeBuilding()
+	if p.inlineRules == nil {
+		p.inlineRules = make(map[byte][]inlineRule)
+	}
+	p.inlineRules[trigger] = append(p.inlineRules[trigger], inlineRule{prec: prec, container: parse})
+}
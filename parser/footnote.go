@@ -0,0 +1,83 @@
+package parser
+
+import "strconv"
+
+// Kinds of the nodes InlineFootnotes makes
+var (
+	// NodeFootnoteRef marks where a note was written, with the note's
+	// number as its ID & its NodeFootnote as its Data, for formats that
+	// write notes where they're made
+	NodeFootnoteRef = NewNodeKind("FootnoteRef", false)
+	// NodeFootnotes holds the notes of a document, at its end
+	NodeFootnotes = NewNodeKind("Footnotes", true)
+	// NodeFootnote is a note, numbered by its ID, holding what it says as
+	// inline content, like a paragraph does
+	NodeFootnote = NewNodeKind("Footnote", true)
+)
+
+// maxFootnote is the longest an inline note may be. Without a limit every
+// "^[" of a line of them left open would be looked for the end of to the
+// end of the line
+const maxFootnote = 4096
+
+// InlineFootnotes reads Pandoc's inline notes, ^[what the note says], in
+// place of each leaving a NodeFootnoteRef, and gathers what they say into
+// a NodeFootnotes at the end of the document. Notes are numbered from 1 in
+// the order they're written, those within notes after the note they're in
+var InlineFootnotes Extension = ExtensionFunc(func(p *Parser) {
+	p.AddInlineContainer('^', BeforeBuiltins, func(line string, pos int) (*Node, int, int, int) {
+		if pos+1 >= len(line) || line[pos+1] != '[' {
+			return nil, 0, 0, 0
+		}
+		depth := 0
+		for i := pos + 1; i < len(line) && i-pos <= maxFootnote; i++ {
+			switch line[i] {
+			case '\\':
+				i++
+			case '[':
+				depth++
+			case ']':
+				if depth--; depth == 0 {
+					if i == pos+2 {
+						return nil, 0, 0, 0
+					}
+					return NewNode(NodeFootnoteRef), pos + 2, i, i + 1
+				}
+			}
+		}
+		return nil, 0, 0, 0
+	})
+	p.AddTransformer(TransformerFunc(numberFootnotes))
+})
+
+// numberFootnotes numbers the notes in doc, moving what each says to the
+// end of it
+func numberFootnotes(doc *Document) {
+	var refs []*Node
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		if n.Kind == NodeFootnoteRef {
+			refs = append(refs, n)
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(doc.Root)
+	if len(refs) == 0 {
+		return
+	}
+	notes := NewNode(NodeFootnotes)
+	for i, ref := range refs {
+		ref.ID = strconv.Itoa(i + 1)
+		note := NewNode(NodeFootnote)
+		note.ID = ref.ID
+		for _, c := range ref.Children {
+			note.AppendChild(c)
+		}
+		ref.Children = nil
+		ref.Data = note
+		notes.AppendChild(note)
+	}
+	doc.Root.AppendChild(notes)
+}
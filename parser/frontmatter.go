@@ -0,0 +1,96 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+const frontMatterDelim = "---"
+
+// splitFrontMatter separates a leading front matter block from the body of
+// input. Front matter sits between two "---" lines at the very start of the
+// input and holds one "key: value" pair per line
+func splitFrontMatter(input string) (map[string]string, string) {
+	first := strings.IndexByte(input, '\n')
+	if first < 0 || strings.TrimRight(input[:first], "\r") != frontMatterDelim {
+		return nil, input
+	}
+	meta := make(map[string]string)
+	for pos := first + 1; pos < len(input); {
+		end := strings.IndexByte(input[pos:], '\n')
+		next := len(input)
+		if end >= 0 {
+			next = pos + end + 1
+		}
+		line := strings.TrimRight(input[pos:next], "\r\n")
+		if line == frontMatterDelim {
+			return meta, input[next:]
+		}
+		if i := strings.IndexByte(line, ':'); i > 0 {
+			key := strings.TrimSpace(line[:i])
+			meta[key] = unquote(strings.TrimSpace(line[i+1:]))
+		}
+		pos = next
+	}
+	// Never closed, so it wasn't front matter after all
+	return nil, input
+}
+
+// unquote strips a matching pair of surrounding quotes from s
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// splitMeta separates the metadata at the top of input from its body: the
+// front matter, with FrontMatter, or else MultiMarkdown's metadata if the
+// Parser reads it
+func (p *Parser) splitMeta(input string) (map[string]string, string) {
+	if p.frontMatter {
+		if meta, body := splitFrontMatter(input); meta != nil {
+			return meta, body
+		}
+	}
+	if p.mmdMeta {
+		return splitMultiMarkdownMeta(input)
+	}
+	return nil, input
+}
+
+// multiMarkdownKey matches the first line of MultiMarkdown's metadata
+var multiMarkdownKey = regexp.MustCompile(`^[A-Za-z0-9][\w -]*:`)
+
+// splitMultiMarkdownMeta separates MultiMarkdown's metadata, "Key: value"
+// lines at the top of input up to a blank line, from its body. Lines
+// indented under one carry on its value. Keys are in lower case, without
+// their spaces
+func splitMultiMarkdownMeta(input string) (map[string]string, string) {
+	if !multiMarkdownKey.MatchString(input) {
+		return nil, input
+	}
+	meta := make(map[string]string)
+	var key string
+	pos := 0
+	for pos < len(input) {
+		next := len(input)
+		if end := strings.IndexByte(input[pos:], '\n'); end >= 0 {
+			next = pos + end + 1
+		}
+		line := strings.TrimRight(input[pos:next], "\r\n")
+		if strings.TrimSpace(line) == "" {
+			return meta, input[next:]
+		}
+		if i := strings.IndexByte(line, ':'); i > 0 && multiMarkdownKey.MatchString(line) {
+			key = strings.ToLower(strings.Replace(line[:i], " ", "", -1))
+			meta[key] = strings.TrimSpace(line[i+1:])
+		} else if key != "" && (line[0] == ' ' || line[0] == '\t') {
+			meta[key] = strings.TrimSpace(meta[key] + " " + strings.TrimSpace(line))
+		} else {
+			return nil, input // Not metadata after all
+		}
+		pos = next
+	}
+	return meta, ""
+}
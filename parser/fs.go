@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ParseFS parses the file name in fsys with the default configuration, as
+// (*Parser).ParseFS does
+func ParseFS(fsys fs.FS, name string) (*Document, error) {
+	return defaultParser.ParseFS(fsys, name)
+}
+
+// ParseFS parses the file name in fsys, such as an embed.FS, a zip archive
+// or OSFiles, with the files it includes in its place. A line of just
+// {{path}}, outside code, includes the file at path, from the directory of
+// the one including it, as MultiMarkdown's transclusion does, without its
+// front matter. The Document's Source is the input with what's included
+func (p *Parser) ParseFS(fsys fs.FS, name string) (*Document, error) {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	var src strings.Builder
+	if err := p.include(&src, fsys, name, string(b), nil); err != nil {
+		return nil, err
+	}
+	return p.Parse(name, src.String())
+}
+
+// includeLine matches a line including a file, {{path}}
+var includeLine = regexp.MustCompile(`^ {0,3}\{\{([^{}]+)\}\}[ \t]*\r?$`)
+
+// maxIncludeDepth is how deep includes can be nested
+const maxIncludeDepth = 32
+
+// include writes input, the file name of fsys, to b with the files it
+// includes in their place. including are the files including it, in order
+func (p *Parser) include(b *strings.Builder, fsys fs.FS, name, input string, including []string) error {
+	if len(including) >= maxIncludeDepth {
+		return fmt.Errorf("%s: includes are nested over %d deep", name, maxIncludeDepth)
+	}
+	including = append(including, name)
+	var fence string
+	for _, line := range strings.SplitAfter(input, "\n") {
+		if err := p.checkSize(including[0], b.Len()+len(line)); err != nil {
+			return err
+		}
+		trimmed := strings.TrimLeft(line, " ")
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(strings.TrimSpace(trimmed), fence[:1]) == "" {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:len(trimmed)-len(strings.TrimLeft(trimmed, trimmed[:1]))]
+		}
+		m := includeLine.FindStringSubmatch(strings.TrimSuffix(line, "\n"))
+		if fence != "" || m == nil {
+			b.WriteString(line)
+			continue
+		}
+		file := path.Join(path.Dir(name), strings.TrimSpace(m[1]))
+		for _, f := range including {
+			if f == file {
+				return fmt.Errorf("%s includes %s, which includes it", name, file)
+			}
+		}
+		src, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return fmt.Errorf("%s: including %w", name, err)
+		}
+		_, body := p.splitMeta(sanitize(decodeBOM(string(src))))
+		if err := p.include(b, fsys, file, body, including); err != nil {
+			return err
+		}
+		if body != "" && !strings.HasSuffix(body, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	return nil
+}
+
+// OSFiles is the OS's files, named by their paths with / between the
+// names in them, as Parse's name usually is. Unlike os.DirFS's, they can
+// be absolute or go up to a parent directory
+var OSFiles fs.FS = osFiles{}
+
+type osFiles struct{}
+
+func (osFiles) Open(name string) (fs.File, error) {
+	return os.Open(filepath.FromSlash(name))
+}
@@ -0,0 +1,41 @@
+package parser_test
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"../parser"
+)
+
+func TestParseFSIncludes(t *testing.T) {
+	fsys := fstest.MapFS{
+		"docs/main.md":        {Data: []byte("# Main\n\n{{parts/intro.md}}\n\n```\n{{not/included.md}}\n```\n")},
+		"docs/parts/intro.md": {Data: []byte("---\ntitle: Intro\n---\nIntro text.\n\n{{more.md}}")},
+		"docs/parts/more.md":  {Data: []byte("More text.\n")},
+		"docs/loop.md":        {Data: []byte("{{loop2.md}}\n")},
+		"docs/loop2.md":       {Data: []byte("{{loop.md}}\n")},
+		"docs/outside.md":     {Data: []byte("{{../../etc/passwd}}\n")},
+		"docs/missing.md":     {Data: []byte("{{nowhere.md}}\n")},
+	}
+	doc, err := parser.ParseFS(fsys, "docs/main.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text []string
+	for _, n := range doc.Root.Children {
+		text = append(text, n.Kind.String()+": "+strings.TrimSpace(n.PlainText()+n.Literal))
+	}
+	want := []string{"Heading: Main", "Paragraph: Intro text.", "Paragraph: More text.", "CodeBlock: {{not/included.md}}"}
+	if strings.Join(text, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(text, "\n"), strings.Join(want, "\n"))
+	}
+	if doc.Meta["title"] != "" {
+		t.Errorf("the included file's front matter was read as the document's: %q", doc.Meta)
+	}
+	for _, name := range []string{"docs/loop.md", "docs/outside.md", "docs/missing.md"} {
+		if _, err := parser.ParseFS(fsys, name); err == nil {
+			t.Errorf("%s parsed", name)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package parser_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"../parser"
+	"../spec"
+)
+
+// seeds covers each construct the parser knows about, in both line endings,
+// along with bytes that aren't UTF-8, to start fuzzing from on top of the
+// spec's examples
+var seeds = func() []string {
+	s := []string{
+		"",
+		"plain text",
+		"# Heading\r\n",
+		"###### Deep heading\r\n#nospace\r\n",
+		"Settext\r\n===\r\n",
+		"Settext\r\n---\r\n",
+		"***\r\n---\r\n* * *\r\n",
+		"- one\r\n- two\r\n+ three\r\n* four\r\n",
+		"1. one\r\n1. two\r\n",
+		"> quote\r\n> more\r\n>\r\n",
+		"```go\r\ncode\r\n```\r\n",
+		"```\r\nunclosed",
+		"*em* **strong** ***both*** _u_ __uu__\r\n",
+		"`code` ``a`b`` \\*escaped\\*\r\n",
+		"[link](http://a.b \"title\") ![img](i.png)\r\n",
+		"[ref] [text][ref] [ref][]\r\n\r\n[ref]: http://r.s \"t\"\r\n",
+		"<http://auto.link> <not a link>\r\n",
+		"hard  \r\nbreak\r\n",
+		"---\r\ntitle: Front\r\n---\r\nbody\r\n",
+		"[[[nested]]](x)\r\n",
+		"*a **a *a **a\r\n",
+		"nul\x00 *in\x00side* `co\x00de`\r\n",
+		"trunc\xe2\x82\r\n\xf0\x9f\x98\r\n# \xc3\r\n",
+		"```\xe2\r\n\xff\xfe\r\n```\r\n[\xe2\x82](\xf0)\r\n",
+		"ok \xe2\x82\xac \xf0\x9f\x98\x80 \xc3\xa9\r\n",
+		"\xef\xbb\xbf# BOM\r\n",
+		"\xff\xfe#\x00 \x00h\x00\r\x00\n\x00*\x00a\x00*\x00\x3d\xd8\x00\xde",
+		"\xfe\xff\x00#\x00 \x00h\x00\n\xd8\x3d",
+	}
+	for _, v := range s[:len(s):len(s)] {
+		s = append(s, strings.Replace(v, "\r", "", -1))
+	}
+	for _, ex := range spec.Examples {
+		s = append(s, ex.Markdown)
+	}
+	return s
+}()
+
+// Parsers for each way of handling line endings, with and without
+// extensions, and for each level of strictness
+var parsers = []*parser.Parser{
+	parser.New(),
+	parser.New(parser.WithNormalization(parser.NormalizeAll)),
+	parser.New(parser.WithLineEndings(parser.CRLF)),
+	parser.New(parser.WithLineEndings(parser.LF)),
+	parser.New(parser.WithExtensions()),
+	parser.New(parser.WithExtensions(), parser.WithLineEndings(parser.LF)),
+	parser.New(parser.WithSourceRanges()),
+	parser.New(parser.WithRelaxations(parser.Strict)),
+	parser.New(parser.WithRelaxations(parser.Permissive)),
+}
+
+// FuzzLex checks the tokens of any input cover it exactly, & that
+// retokenizing it after an edit gives the tokens lexing it afresh does
+func FuzzLex(f *testing.F) {
+	for _, s := range seeds {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		for i, p := range parsers {
+			if err := lossless(p.Tokenize("fuzz", src), src); err != nil {
+				t.Fatalf("parser %d: %v", i, err)
+			}
+			if err := incremental(p, src); err != nil {
+				t.Fatalf("parser %d: %v", i, err)
+			}
+		}
+	})
+}
+
+// FuzzParse checks any input parses without panicking into a tree whose
+// ranges nest, & that parsing it into an arena, as a stream read a byte at
+// a time or as events gives the same tree
+func FuzzParse(f *testing.F) {
+	for _, s := range seeds {
+		f.Add(s)
+	}
+	var arena parser.Arena // Reused by every input, as a server would
+	f.Fuzz(func(t *testing.T, src string) {
+		for i, p := range parsers {
+			doc, err := p.Parse("fuzz", src)
+			if err != nil {
+				continue // Rejecting input is fine, crashing on it isn't
+			}
+			if doc.Source != "" {
+				if err := nested(doc.Root, len(doc.Source)); err != nil {
+					t.Fatalf("parser %d: %v", i, err)
+				}
+			}
+			want := events(doc.Root)
+			adoc, err := p.ParseWithArena("fuzz", src, &arena)
+			if err != nil {
+				t.Fatalf("parser %d: arena: %v", i, err)
+			}
+			got := events(adoc.Root)
+			arena.Reset()
+			if got != want {
+				t.Fatalf("parser %d: arena tree\n%s\ndiffers from\n%s", i, got, want)
+			}
+			// Reading a byte at a time splits every multi-byte sequence
+			// across reads, which mustn't change the blocks read
+			var streamed strings.Builder
+			err = p.ParseStream("fuzz", iotest.OneByteReader(strings.NewReader(src)), func(d *parser.Document) error {
+				streamed.WriteString(events(d.Root.Children...))
+				return nil
+			})
+			if got, want := streamed.String(), events(doc.Root.Children...); err != nil || got != want {
+				t.Fatalf("parser %d: streamed blocks\n%s\ndiffer from\n%s\n(%v)", i, got, want, err)
+			}
+			var b strings.Builder
+			err = p.ParseEvents("fuzz", src, func(n *parser.Node, entering bool) error {
+				b.WriteString(event(n, entering) + "\n")
+				return nil
+			})
+			if got := b.String(); err != nil || got != want {
+				t.Fatalf("parser %d: events\n%s\ndiffer from\n%s\n(%v)", i, got, want, err)
+			}
+		}
+	})
+}
+
+// nested checks the Range of each child of n lies within n's, after those
+// of the children before it, and that none reach past size
+func nested(n *parser.Node, size int) error {
+	r := n.Range
+	if r.Start > r.End || r.End > size {
+		return fmt.Errorf("%v has range %v of %d bytes", n.Kind, r, size)
+	}
+	from := r.Start
+	for _, c := range n.Children {
+		if c.Range == (parser.Range{}) {
+			continue // Made by an extension without a Range
+		}
+		if c.Range.Start < from || c.Range.End > r.End {
+			return fmt.Errorf("%v range %v isn't within %v %v after %d", c.Kind, c.Range, n.Kind, r, from)
+		}
+		from = c.Range.End
+		if err := nested(c, size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// events describes nodes and everything beneath them, a line for each node
+// entered & left, as ParseEvents reports them
+func events(nodes ...*parser.Node) string {
+	var b strings.Builder
+	var walk func(n *parser.Node)
+	walk = func(n *parser.Node) {
+		b.WriteString(event(n, true) + "\n")
+		for _, c := range n.Children {
+			walk(c)
+		}
+		b.WriteString(event(n, false) + "\n")
+	}
+	for _, n := range nodes {
+		walk(n)
+	}
+	return b.String()
+}
+
+// event describes a node & whether it's being entered, for comparison
+func event(n *parser.Node, entering bool) string {
+	if !entering {
+		return "/" + n.Kind.String()
+	}
+	return fmt.Sprintf("%v %q %d %t %q %q %q", n.Kind, n.Literal, n.Level, n.Ordered, n.Info, n.Dest, n.Title)
+}
+
+// lossless checks tokens cover src exactly, each starting where the last
+// ended
+func lossless(tokens []parser.Token, src string) error {
+	offset := 0
+	for _, t := range tokens {
+		if t.Pos.Offset != offset || !strings.HasPrefix(src[offset:], t.Raw()) {
+			return fmt.Errorf("token %v at %d doesn't continue from offset %d", t.Kind, t.Pos.Offset, offset)
+		}
+		offset += len(t.Raw())
+	}
+	if offset != len(src) {
+		return fmt.Errorf("tokens cover %d of %d bytes", offset, len(src))
+	}
+	return nil
+}
+
+// incremental checks that retokenizing src after a few edits, made at
+// places depending on its length, gives the tokens lexing the edited input
+// afresh does
+func incremental(p *parser.Parser, src string) error {
+	prev := p.Tokenize("fuzz", src)
+	n := len(src)
+	for _, e := range []parser.Edit{
+		{Start: n / 3, End: n / 2, Text: "\n\n# x\n"},
+		{Start: n / 2, End: n / 2, Text: "`"},
+		{Start: 0, End: n / 4},
+		{Start: n, End: n, Text: "\n\n```\n"},
+	} {
+		input := src[:e.Start] + e.Text + src[e.End:]
+		got, _ := p.Retokenize("fuzz", prev, input, e)
+		want := p.Tokenize("fuzz", input)
+		if len(got) != len(want) {
+			return fmt.Errorf("retokenized %q into %d tokens, not %d", input, len(got), len(want))
+		}
+		for i := range got {
+			if g, w := got[i], want[i]; g.Kind != w.Kind || g.Val != w.Val || g.Pos != w.Pos || g.Raw() != w.Raw() {
+				return fmt.Errorf("retokenized %q with token %d %v %q at %v, not %v %q at %v", input, i, g.Kind, g.Val, g.Pos, w.Kind, w.Val, w.Pos)
+			}
+		}
+	}
+	return nil
+}
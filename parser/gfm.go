@@ -0,0 +1,267 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Kinds of the nodes the GitHub Flavored Markdown extensions make
+var (
+	// NodeStrikethrough is text struck out, ~~like this~~
+	NodeStrikethrough = NewNodeKind("Strikethrough", false)
+	// NodeTable is a table, its children NodeTableRows, the first of them
+	// its header
+	NodeTable = NewNodeKind("Table", true)
+	// NodeTableRow is a row of a table, its children NodeTableCells
+	NodeTableRow = NewNodeKind("TableRow", true)
+	// NodeTableCell is a cell of a table, its children inline nodes. Info
+	// is how the column is aligned: "left", "center", "right" or ""
+	NodeTableCell = NewNodeKind("TableCell", true)
+	// NodeTaskCheckbox starts a list item that's a task, its Literal "x"
+	// if the task is done and " " if it isn't
+	NodeTaskCheckbox = NewNodeKind("TaskCheckbox", false)
+)
+
+var (
+	// Strikethrough reads text between ~~two~~ or ~one~ tildes as struck
+	// out, as GitHub does. Runs of three or more are left as they are
+	Strikethrough Extension = ExtensionFunc(func(p *Parser) {
+		p.AddInlineContainer('~', BeforeBuiltins, parseStrikethrough)
+	})
+
+	// Tables reads GitHub's tables: a header row, a row of dashes setting
+	// how each column is aligned, then rows up to a blank line or another
+	// block. Cells are parted by |, which \| escapes. Tables start with a
+	// | and can't interrupt a paragraph
+	Tables Extension = ExtensionFunc(func(p *Parser) {
+		p.AddBlockParser("|", parseTable)
+	})
+
+	// TaskLists reads list items starting [ ] or [x] as tasks, to be done
+	// or done, with a NodeTaskCheckbox in place of the marker
+	TaskLists Extension = ExtensionFunc(func(p *Parser) {
+		p.AddTransformer(TransformerFunc(findTasks))
+	})
+
+	// Autolinks links the bare URLs GitHub does, those starting http://,
+	// https:// or www., which is taken to be http://
+	Autolinks Extension = ExtensionFunc(func(p *Parser) {
+		for _, trigger := range []byte{'h', 'w'} {
+			p.AddInlineParser(trigger, AfterBuiltins, parseAutolink)
+		}
+	})
+)
+
+// parseStrikethrough parses text struck out by the run of one or two
+// tildes at line[pos], closed by a run as long
+func parseStrikethrough(line string, pos int) (*Node, int, int, int) {
+	if pos > 0 && line[pos-1] == '~' {
+		return nil, 0, 0, 0
+	}
+	n := tildes(line, pos)
+	start := pos + n
+	if n > 2 || start >= len(line) || isSpace(rune(line[start])) {
+		return nil, 0, 0, 0
+	}
+	for i := start; i < len(line); i++ {
+		if line[i] != '~' {
+			continue
+		}
+		run := tildes(line, i)
+		if run == n && i > start && !isSpace(rune(line[i-1])) {
+			return NewNode(NodeStrikethrough), start, i, i + n
+		}
+		i += run - 1
+	}
+	return nil, 0, 0, 0
+}
+
+// tildes returns the length of the run of tildes at line[i]
+func tildes(line string, i int) int {
+	n := 0
+	for i+n < len(line) && line[i+n] == '~' {
+		n++
+	}
+	return n
+}
+
+// tableDelimiter matches a cell of the row under a table's header
+var tableDelimiter = regexp.MustCompile(`^:?-+:?$`)
+
+// parseTable parses a table whose header row is the current line
+func parseTable(s *BlockScanner) *Node {
+	heads := splitTableRow(s.Line())
+	table := NewNode(NodeTable)
+	table.AppendChild(tableRow(s, heads, len(heads)))
+	if !s.Advance() {
+		return nil
+	}
+	delims := splitTableRow(s.Line())
+	if len(delims) != len(heads) {
+		return nil
+	}
+	aligns := make([]string, len(delims))
+	for i, d := range delims {
+		if !tableDelimiter.MatchString(d.text) {
+			return nil
+		}
+		switch left, right := d.text[0] == ':', d.text[len(d.text)-1] == ':'; {
+		case left && right:
+			aligns[i] = "center"
+		case left:
+			aligns[i] = "left"
+		case right:
+			aligns[i] = "right"
+		}
+	}
+	for s.Advance() && !endsTable(s.Line()) {
+		table.AppendChild(tableRow(s, splitTableRow(s.Line()), len(heads)))
+	}
+	for _, row := range table.Children {
+		for i, cell := range row.Children {
+			cell.Info = aligns[i]
+		}
+	}
+	return table
+}
+
+// tableCell is the text of a cell & where it starts in its line
+type tableCell struct {
+	text string
+	at   int
+}
+
+// splitTableRow splits a row of a table into its cells, without the pipes
+// starting & ending it or the space around each cell
+func splitTableRow(line string) []tableCell {
+	var cells []tableCell
+	start := 0
+	for i := 0; i <= len(line); i++ {
+		switch {
+		case i < len(line) && line[i] == '\\':
+			i++
+		case i == len(line) || line[i] == '|':
+			cells = append(cells, tableCell{line[start:i], start})
+			start = i + 1
+		}
+	}
+	if strings.TrimSpace(cells[0].text) == "" && len(cells) > 1 {
+		cells = cells[1:]
+	}
+	if last := cells[len(cells)-1]; strings.TrimSpace(last.text) == "" && len(cells) > 1 {
+		cells = cells[:len(cells)-1]
+	}
+	for i, c := range cells {
+		text := strings.TrimLeft(c.text, " \t")
+		cells[i] = tableCell{strings.TrimRight(text, " \t"), c.at + len(c.text) - len(text)}
+	}
+	return cells
+}
+
+// tableRow returns a row of n cells, for s to parse the contents of from
+// cells, leaving out any past n
+func tableRow(s *BlockScanner, cells []tableCell, n int) *Node {
+	row := NewNode(NodeTableRow)
+	for i := 0; i < n; i++ {
+		cell := NewNode(NodeTableCell)
+		if i < len(cells) && cells[i].text != "" {
+			s.Inline(cell, strings.Replace(cells[i].text, `\|`, "|", -1), cells[i].at)
+		}
+		row.AppendChild(cell)
+	}
+	return row
+}
+
+// endsTable reports whether line ends the table before it, being blank or
+// starting another block
+func endsTable(line string) bool {
+	s := strings.TrimLeft(line, " ")
+	return s == "" || hp(s, blockQuote) || hp(s, atxHeader) || hp(s, codeFence) || isListItem(s) || isThematicBreak(s)
+}
+
+// isThematicBreak reports whether line is a thematic break
+func isThematicBreak(line string) bool {
+	s := strings.Replace(strings.TrimSpace(line), " ", "", -1)
+	return len(s) >= 3 && (strings.Trim(s, hr1) == "" || strings.Trim(s, hr2) == "" || strings.Trim(s, "_") == "")
+}
+
+// findTasks turns the [ ] or [x] starting each list item in doc into a
+// NodeTaskCheckbox
+func findTasks(doc *Document) {
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		if n.Kind == NodeListItem && len(n.Children) > 0 && n.Children[0].Kind == NodeText {
+			text := n.Children[0]
+			if s := text.Literal; len(s) >= 4 && s[0] == '[' && s[2] == ']' && s[3] == ' ' && strings.IndexByte(" xX", s[1]) >= 0 {
+				box := NewNode(NodeTaskCheckbox)
+				box.Literal = strings.ToLower(s[1:2])
+				box.Parent = n
+				text.Literal = s[4:]
+				if text.Range != (Range{}) {
+					box.Range = Range{text.Range.Start, text.Range.Start + 3}
+					text.Range.Start += 4
+				}
+				n.Children = append([]*Node{box}, n.Children...)
+			}
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(doc.Root)
+}
+
+// autolinkDomain matches the domain a bare URL starts with
+var autolinkDomain = regexp.MustCompile(`^[\w-]+(?:\.[\w-]+)+`)
+
+// parseAutolink links the bare URL at line[pos], if there is one
+func parseAutolink(line string, pos int) (*Node, int) {
+	if pos > 0 && strings.IndexByte(" \t*_~(", line[pos-1]) < 0 {
+		return nil, 0
+	}
+	rest := line[pos:]
+	var scheme string
+	switch {
+	case strings.HasPrefix(rest, "www."):
+		scheme = "http://"
+	case strings.HasPrefix(rest, "http://"), strings.HasPrefix(rest, "https://"):
+		rest = rest[strings.Index(rest, "//")+2:]
+	default:
+		return nil, 0
+	}
+	domain := autolinkDomain.FindString(rest)
+	if domain == "" {
+		return nil, 0
+	}
+	if parts := strings.Split(domain, "."); strings.Contains(parts[len(parts)-1]+parts[len(parts)-2], "_") {
+		return nil, 0
+	}
+	end := pos + len(line[pos:]) - len(rest) + len(domain)
+	for end < len(line) && line[end] != ' ' && line[end] != '\t' && line[end] != '<' {
+		end++
+	}
+	end = trimAutolink(line[pos:end]) + pos
+	link := NewNode(NodeLink)
+	link.Dest = scheme + line[pos:end]
+	text := NewNode(NodeText)
+	text.Literal = line[pos:end]
+	link.AppendChild(text)
+	return link, end
+}
+
+// trimAutolink returns how much of url is the link, without punctuation
+// ending the sentence around it or a ) it isn't inside of
+func trimAutolink(url string) int {
+	for {
+		switch {
+		case url == "":
+			return 0
+		case strings.IndexByte("?!.,:*_~'\"", url[len(url)-1]) >= 0:
+			url = url[:len(url)-1]
+		case url[len(url)-1] == ')' && strings.Count(url, ")") > strings.Count(url, "("):
+			url = url[:len(url)-1]
+		default:
+			return len(url)
+		}
+	}
+}
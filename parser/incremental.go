@@ -0,0 +1,119 @@
+package parser
+
+import (
+	"sort"
+	"strings"
+)
+
+// Edit is a change to an input: the bytes from Start up to End replaced by
+// Text
+type Edit struct {
+	Start, End int
+	Text       string
+}
+
+// TokenChange says which tokens an Edit changed: those from Start up to End
+// of the new tokens replace those from OldStart up to OldEnd of the old.
+// The tokens before are the same, and those after are the same but moved
+type TokenChange struct {
+	OldStart, OldEnd int
+	Start, End       int
+}
+
+// Lines returns the first & last line of the new input the changed tokens
+// cover, which an editor highlighting them needs to repaint
+func (c TokenChange) Lines(tokens []Token) (first, last int) {
+	if c.Start == c.End {
+		if c.Start < len(tokens) {
+			return tokens[c.Start].Pos.Line, tokens[c.Start].Pos.Line
+		}
+		return 0, 0
+	}
+	t := tokens[c.End-1]
+	return tokens[c.Start].Pos.Line, t.Pos.Line + strings.Count(strings.TrimRight(t.raw, "\r\n"), "\n")
+}
+
+// Retokenize returns the tokens of input, from the file name, which is the
+// input prev are the tokens of after the edit e, and which of them
+// changed. Rather than lexing input afresh it starts from the block
+// boundary before the edit, a line after a blank one where the lexer
+// starts over, and stops at the first one past it where the new tokens
+// agree with prev, reusing the rest of them. prev must be every token
+// Tokenize, or Retokenize, returned for the old input, with p
+func (p *Parser) Retokenize(name string, prev []Token, input string, e Edit) ([]Token, TokenChange) {
+	oldLen := 0
+	if len(prev) > 0 {
+		last := prev[len(prev)-1]
+		oldLen = last.Pos.Offset + len(last.raw)
+	}
+	delta := len(e.Text) - (e.End - e.Start)
+	if e.Start < 0 || e.Start > e.End || e.End > oldLen || oldLen+delta != len(input) {
+		tokens := p.Tokenize(name, input) // Not an edit of prev, so start over
+		return tokens, TokenChange{0, len(prev), 0, len(tokens)}
+	}
+
+	// Back to the last boundary before the edit. Tokens before it end
+	// before the edit, and don't look as far ahead as it to tell where a
+	// line ends, so are unchanged
+	from := sort.Search(len(prev), func(i int) bool { return prev[i].Pos.Offset >= e.Start }) - 1
+	for from > 0 && !restartsAt(prev, from) {
+		from--
+	}
+	tokens := append([]Token{}, prev[:max(from, 0)]...)
+	l := lex(name, input, p)
+	if from > 0 {
+		pos := prev[from].Pos
+		l.start, l.pos, l.rawStart, l.counted = pos.Offset, pos.Offset, pos.Offset, pos.Offset
+		l.line, l.lineStart = pos.Line-1, pos.Offset
+	}
+	change := TokenChange{OldStart: len(tokens), OldEnd: len(prev), Start: len(tokens)}
+	editEnd := e.Start + len(e.Text)
+	for t, ok := l.nextItem(); ok; t, ok = l.nextItem() {
+		tokens = append(tokens, t)
+		// Past the edit, at a boundary in both the new tokens & the old,
+		// the rest of the input is the same, and so are its tokens
+		next := t.Pos.Offset + len(t.raw)
+		if next <= editEnd || next == len(input) || !restartsAt(tokens, len(tokens)) {
+			continue
+		}
+		old := sort.Search(len(prev), func(i int) bool { return prev[i].Pos.Offset >= next-delta })
+		if old == len(prev) || prev[old].Pos.Offset != next-delta || !restartsAt(prev, old) {
+			continue
+		}
+		change.OldEnd, change.End = old, len(tokens)
+		lines := l.position(next).Line - prev[old].Pos.Line
+		for _, t := range prev[old:] {
+			t.Pos.Offset += delta
+			t.Pos.Line += lines
+			tokens = append(tokens, t)
+		}
+		return tokens, change
+	}
+	change.End = len(tokens)
+	return tokens, change
+}
+
+// restartsAt reports whether the lexer starts over at tokens[i], as it
+// does after a blank line, at the start of the input, and at the end of
+// tokens if i is len(tokens)
+func restartsAt(tokens []Token, i int) bool {
+	if i == 0 {
+		return true
+	}
+	if k := tokens[i-1].Kind; k != TokenNewLine && k != TokenHardNewLine {
+		return false
+	}
+	for j := i - 2; j >= 0; j-- {
+		switch tokens[j].Kind {
+		case TokenNewLine, TokenHardNewLine:
+			return true
+		case TokenText:
+			if strings.TrimSpace(tokens[j].raw) != "" {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
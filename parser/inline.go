@@ -0,0 +1,442 @@
+package parser
+
+import (
+	"strings"
+)
+
+const escapable = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+
+// maxLabel is the longest a link label may be, as in CommonMark. Without a
+// limit every '[' of deeply nested brackets would look up the whole line
+const maxLabel = 999
+
+// inlineContext holds what inline parsing needs from the document & Parser
+type inlineContext struct {
+	arena    *Arena               // Where nodes are allocated, nil for the heap
+	refs     map[string]Reference // Link reference definitions by label
+	maxDepth int                  // How deeply elements may nest
+	rules    map[byte][]inlineRule
+	ranges   bool // Whether to record the Range of each node
+}
+
+// inlineParser turns the text of a single line into inline nodes
+type inlineParser struct {
+	input  string
+	pos    int
+	at     int   // Offset of input in the document's Source
+	parent *Node // Node the parsed nodes are appended to
+
+	// Pending literal text is input[litStart:litEnd] while it is one
+	// unbroken run of the input, and is only copied into text once an
+	// escape breaks it up
+	litStart, litEnd int
+	text             strings.Builder
+	textFrom         int // Where the markup of the pending text starts
+
+	ctx   *inlineContext
+	depth int // How deeply nested this run of text is
+
+	// Caches that keep pathological input from taking quadratic time, each
+	// filled in the first time it's needed
+	brackets  []int       // Index of the ']' matching each '[', or -1
+	backticks map[int]int // Start of the last run of each length of backticks
+	noCloser  [2][4]bool  // Runs of '*' & '_' by length known to have no closer
+}
+
+// parseInlines splits s into text, emphasis, code span, link & image
+// nodes, along with any nodes made by custom inline parsers, and appends
+// them to parent. at is where s starts in the document's Source
+func parseInlines(s string, at int, ctx *inlineContext, parent *Node) {
+	p := &inlineParser{input: s, at: at, parent: parent, ctx: ctx}
+	p.run()
+}
+
+// sub parses input[i:j] as the contents of parent, an element found by p
+func (p *inlineParser) sub(i, j int, parent *Node) {
+	c := &inlineParser{input: p.input[i:j], at: p.at + i, parent: parent, ctx: p.ctx, depth: p.depth + 1}
+	c.run()
+}
+
+// span sets the Range of n to input[i:j] if ranges are being kept
+func (p *inlineParser) span(n *Node, i, j int) *Node {
+	if p.ctx.ranges {
+		n.Range = Range{p.at + i, p.at + j}
+	}
+	return n
+}
+
+// nestable reports whether another level of elements may be opened
+func (p *inlineParser) nestable() bool {
+	return p.depth < p.ctx.maxDepth
+}
+
+// custom tries the custom inline parsers registered for c with precedence
+// prec, adding the first node one of them produces
+func (p *inlineParser) custom(c byte, prec Precedence) bool {
+	for _, rule := range p.ctx.rules[c] {
+		if rule.prec != prec {
+			continue
+		}
+		if rule.container != nil {
+			if !p.nestable() {
+				continue
+			}
+			n, start, stop, end := rule.container(p.input, p.pos)
+			if n != nil && end > p.pos && p.pos <= start && start <= stop && stop <= end {
+				p.sub(start, stop, n)
+				p.add(p.span(n, p.pos, end))
+				p.pos = end
+				return true
+			}
+			continue
+		}
+		n, end := rule.parse(p.input, p.pos)
+		if n != nil && end > p.pos {
+			p.add(p.span(n, p.pos, end))
+			p.pos = end
+			return true
+		}
+	}
+	return false
+}
+
+func (p *inlineParser) run() {
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		if p.custom(c, BeforeBuiltins) {
+			continue
+		}
+		switch {
+		case c == '\\' && p.pos+1 < len(p.input) && strings.IndexByte(escapable, p.input[p.pos+1]) >= 0:
+			p.literal(p.pos+1, p.pos+2)
+			p.pos += 2
+		case c == '`' && p.codeSpan():
+		case c == '!' && strings.HasPrefix(p.input[p.pos:], img) && p.link(NodeImage):
+		case c == '[' && p.link(NodeLink):
+		case c == '<' && p.autoLink():
+		case (c == '*' || c == '_') && p.emphasis():
+		case p.custom(c, AfterBuiltins):
+		default:
+			p.literal(p.pos, p.pos+1)
+			p.pos++
+		}
+	}
+	p.flush()
+}
+
+// literal adds input[i:j], from markup starting at pos, to the pending
+// literal text
+func (p *inlineParser) literal(i, j int) {
+	if p.text.Len() == 0 && p.litStart == p.litEnd {
+		p.textFrom = p.pos
+	}
+	switch {
+	case p.text.Len() > 0:
+		p.text.WriteString(p.input[i:j])
+	case p.litStart == p.litEnd:
+		p.litStart, p.litEnd = i, j
+	case p.litEnd == i:
+		p.litEnd = j
+	default:
+		p.text.WriteString(p.input[p.litStart:p.litEnd])
+		p.text.WriteString(p.input[i:j])
+	}
+}
+
+// flush moves any pending literal text into a Text node
+func (p *inlineParser) flush() {
+	var lit string
+	if p.text.Len() > 0 {
+		lit = p.text.String()
+		p.text.Reset()
+	} else if p.litStart < p.litEnd {
+		lit = p.input[p.litStart:p.litEnd]
+	} else {
+		return
+	}
+	p.litStart, p.litEnd = 0, 0
+	p.parent.AppendChild(p.span(p.ctx.arena.alloc(Node{Kind: NodeText, Literal: lit}), p.textFrom, p.pos))
+}
+
+// add appends n after flushing pending text
+func (p *inlineParser) add(n *Node) {
+	p.flush()
+	p.parent.AppendChild(n)
+}
+
+// codeSpan parses a backtick code span at pos, returning false if the
+// opening run of backticks has no matching closing run
+func (p *inlineParser) codeSpan() bool {
+	s := p.input[p.pos:]
+	n := len(s) - len(strings.TrimLeft(s, "`"))
+	if p.backticks == nil {
+		p.backticks = backtickRuns(p.input)
+	}
+	if p.backticks[n] <= p.pos { // No run this long later on to close it
+		p.literal(p.pos, p.pos+n)
+		p.pos += n
+		return true
+	}
+	fence := s[:n]
+	rest := s[n:]
+	for i := 0; i < len(rest); {
+		j := strings.Index(rest[i:], fence)
+		if j < 0 {
+			break
+		}
+		j += i
+		// The closing run must be exactly as long as the opening one
+		if run := len(rest[j:]) - len(strings.TrimLeft(rest[j:], "`")); run != n {
+			i = j + run
+			continue
+		}
+		lit := rest[:j]
+		if len(lit) > 2 && lit[0] == ' ' && lit[len(lit)-1] == ' ' && strings.Trim(lit, " ") != "" {
+			lit = lit[1 : len(lit)-1]
+		}
+		p.add(p.span(p.ctx.arena.alloc(Node{Kind: NodeCodeSpan, Literal: lit}), p.pos, p.pos+n+j+n))
+		p.pos += n + j + n
+		return true
+	}
+	// No match, the backticks are literal
+	p.literal(p.pos, p.pos+n)
+	p.pos += n
+	return true
+}
+
+// link parses an inline link [text](dest "title"), a reference link
+// [text][label], [label][] or [label], or the image form of any of them
+func (p *inlineParser) link(kind NodeKind) bool {
+	if !p.nestable() {
+		return false
+	}
+	start := p.pos
+	if kind == NodeImage {
+		start++
+	}
+	if p.brackets == nil {
+		p.brackets = matchBrackets(p.input)
+	}
+	closing := p.brackets[start]
+	if closing < 0 {
+		return false
+	}
+	text := p.input[start+1 : closing]
+	var dest, title string
+	var end int
+	if closing+1 < len(p.input) && p.input[closing+1] == '(' {
+		var ok bool
+		dest, title, end, ok = parseLinkTail(p.input, closing+2)
+		if !ok {
+			return false
+		}
+	} else {
+		label, labelEnd := text, closing+1
+		if closing+1 < len(p.input) && p.input[closing+1] == '[' {
+			if j := strings.IndexByte(p.input[closing+2:], ']'); j >= 0 {
+				if j > 0 {
+					label = p.input[closing+2 : closing+2+j]
+				}
+				labelEnd = closing + 3 + j
+			}
+		}
+		if len(label) > maxLabel {
+			return false
+		}
+		ref, ok := p.ctx.refs[normalizeLabel(label)]
+		if !ok {
+			return false
+		}
+		dest, title, end = ref.Dest, ref.Title, labelEnd
+	}
+	n := p.span(p.ctx.arena.alloc(Node{Kind: kind, Dest: dest, Title: title}), p.pos, end)
+	p.sub(start+1, closing, n)
+	p.add(n)
+	p.pos = end
+	return true
+}
+
+// backtickRuns returns where the last run of backticks of each length in s
+// starts, so a code span opener with no closing run can be spotted without
+// searching the rest of the line for one
+func backtickRuns(s string) map[int]int {
+	runs := make(map[int]int)
+	for i := 0; i < len(s); {
+		if s[i] != '`' {
+			i++
+			continue
+		}
+		j := i
+		for j < len(s) && s[j] == '`' {
+			j++
+		}
+		runs[j-i] = i
+		i = j
+	}
+	return runs
+}
+
+// matchBrackets returns the index of the ']' matching each '[' in s, or -1
+// for those left open. Finding them all in one pass means a line of
+// unclosed brackets doesn't get rescanned from each of them
+func matchBrackets(s string) []int {
+	match := make([]int, len(s))
+	var open []int
+	for i := 0; i < len(s); i++ {
+		match[i] = -1
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				match[i] = -1
+			}
+		case '[':
+			open = append(open, i)
+		case ']':
+			if len(open) > 0 {
+				match[open[len(open)-1]] = i
+				open = open[:len(open)-1]
+			}
+		}
+	}
+	return match
+}
+
+// parseLinkTail parses the destination & optional title of an inline link,
+// starting just after the opening '('. It returns the index following ')'
+func parseLinkTail(s string, i int) (dest, title string, end int, ok bool) {
+	for i < len(s) && s[i] == ' ' {
+		i++
+	}
+	if i < len(s) && s[i] == '<' {
+		// A destination can't contain '<', so stop looking there rather
+		// than searching the rest of the line for a '>'
+		j := strings.IndexAny(s[i+1:], "<>") + 1
+		if j <= 0 || s[i+j] != '>' {
+			return "", "", 0, false
+		}
+		dest = s[i+1 : i+j]
+		i += j + 1
+	} else {
+		j := i
+		depth := 0
+		for ; j < len(s) && s[j] != ' '; j++ {
+			if s[j] == '(' {
+				depth++
+			} else if s[j] == ')' {
+				if depth == 0 {
+					break
+				}
+				depth--
+			}
+		}
+		dest = s[i:j]
+		i = j
+	}
+	for i < len(s) && s[i] == ' ' {
+		i++
+	}
+	if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+		q := s[i]
+		j := strings.IndexByte(s[i+1:], q)
+		if j < 0 {
+			return "", "", 0, false
+		}
+		title = s[i+1 : i+1+j]
+		i += j + 2
+		for i < len(s) && s[i] == ' ' {
+			i++
+		}
+	}
+	if i >= len(s) || s[i] != ')' {
+		return "", "", 0, false
+	}
+	return dest, title, i + 1, true
+}
+
+// autoLink parses <scheme:address> at pos
+func (p *inlineParser) autoLink() bool {
+	s := p.input[p.pos:]
+	j := strings.IndexAny(s[1:], "<> ")
+	if j < 0 || s[1+j] != '>' {
+		return false
+	}
+	dest := s[1 : 1+j]
+	colon := strings.IndexByte(dest, ':')
+	if colon < 2 || !isScheme(dest[:colon]) {
+		return false
+	}
+	n := p.span(p.ctx.arena.alloc(Node{Kind: NodeLink, Dest: dest}), p.pos, p.pos+j+2)
+	n.AppendChild(p.span(p.ctx.arena.alloc(Node{Kind: NodeText, Literal: dest}), p.pos+1, p.pos+j+1))
+	p.add(n)
+	p.pos += j + 2
+	return true
+}
+
+// isScheme reports whether s looks like a URI scheme
+func isScheme(s string) bool {
+	for i, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && (r >= '0' && r <= '9' || r == '+' || r == '.' || r == '-')) {
+			return false
+		}
+	}
+	return true
+}
+
+// emphasis parses a run of '*' or '_' at pos into Emphasis and/or Strong
+// nodes, returning false if there is no matching closing run
+func (p *inlineParser) emphasis() bool {
+	s := p.input[p.pos:]
+	c := s[0]
+	n := len(s) - len(strings.TrimLeft(s, string(c)))
+	if !p.nestable() {
+		p.literal(p.pos, p.pos+n)
+		p.pos += n
+		return true
+	}
+	if n > 3 || n == len(s) || isSpace(rune(s[n])) {
+		return false
+	}
+	// Whether a run closes doesn't depend on its opener, so once a search
+	// for a closer comes up empty every later opener like this one would too
+	known := &p.noCloser[strings.IndexByte("*_", c)][n]
+	if *known {
+		return false
+	}
+	delim := s[:n]
+	for i := n; i < len(s); {
+		j := strings.Index(s[i:], delim)
+		if j < 0 {
+			*known = true
+			return false
+		}
+		j += i
+		run := len(s[j:]) - len(strings.TrimLeft(s[j:], string(c)))
+		if run == n && !isSpace(rune(s[j-1])) {
+			var outer, inner *Node
+			switch n {
+			case 1:
+				outer = p.ctx.arena.alloc(Node{Kind: NodeEmphasis})
+				inner = outer
+			case 2:
+				outer = p.ctx.arena.alloc(Node{Kind: NodeStrong})
+				inner = outer
+			default:
+				outer = p.ctx.arena.alloc(Node{Kind: NodeEmphasis})
+				inner = p.ctx.arena.alloc(Node{Kind: NodeStrong})
+				outer.AppendChild(inner)
+			}
+			p.span(outer, p.pos, p.pos+j+n)
+			if inner != outer {
+				p.span(inner, p.pos+1, p.pos+j+n-1)
+			}
+			p.sub(p.pos+n, p.pos+j, inner)
+			p.add(outer)
+			p.pos += j + n
+			return true
+		}
+		i = j + run
+	}
+	*known = true
+	return false
+}
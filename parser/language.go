@@ -0,0 +1,138 @@
+package parser
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// Classifier guesses the language code is written in, returning "" if it
+// can't tell
+type Classifier interface {
+	Classify(code string) string
+}
+
+// ClassifierFunc adapts an ordinary function to a Classifier
+type ClassifierFunc func(code string) string
+
+// Classify calls f(code)
+func (f ClassifierFunc) Classify(code string) string {
+	return f(code)
+}
+
+// GuessedLanguage is the Data of a code block whose language was guessed
+// by InferLanguages
+type GuessedLanguage struct {
+	Lang string
+}
+
+// InferLanguages returns an Extension guessing the language of each code
+// block without an info string with c, or HeuristicClassifier if c is
+// nil, noting the guess in the block's Data so renderers can highlight it.
+// With rewrite set the guess becomes the block's Info too, as if it had
+// been written after the opening fence
+func InferLanguages(c Classifier, rewrite bool) Extension {
+	if c == nil {
+		c = HeuristicClassifier
+	}
+	return ExtensionFunc(func(p *Parser) {
+		p.AddTransformer(TransformerFunc(func(doc *Document) {
+			var walk func(n *Node)
+			walk = func(n *Node) {
+				if n.Kind == NodeCodeBlock && n.Info == "" && n.Data == nil {
+					if lang := c.Classify(n.Literal); lang != "" {
+						n.Data = GuessedLanguage{lang}
+						if rewrite {
+							n.Info = lang
+						}
+					}
+				}
+				for _, c := range n.Children {
+					walk(c)
+				}
+			}
+			walk(doc.Root)
+		}))
+	})
+}
+
+// languageClue is a sign that code is in a language, worth weight points
+type languageClue struct {
+	lang   string
+	weight int
+	re     *regexp.Regexp
+}
+
+// languageClues are what HeuristicClassifier looks for
+var languageClues = func() []languageClue {
+	clues := []struct {
+		lang    string
+		weight  int
+		pattern string
+	}{
+		{"go", 3, `(?m)^package \w+$`},
+		{"go", 2, `(?m)^func (\(\w+ \*?\w+\) )?\w+\(`},
+		{"go", 1, `:= |\bfmt\.\w+\(|\berr != nil\b`},
+		{"python", 3, `(?m)^\s*def \w+\(.*\):\s*$`},
+		{"python", 2, `(?m)^(from \w+(\.\w+)* )?import \w+( as \w+)?$|^\s*class \w+(\(.*\))?:\s*$`},
+		{"python", 1, `\bself\.|\bprint\(|\bNone\b|\belif\b`},
+		{"javascript", 2, `\bfunction\s*\w*\(|\bconsole\.log\(|\brequire\(['"]`},
+		{"javascript", 1, `(?m)^\s*(const|let|var) \w+ = |=> |===`},
+		{"typescript", 4, `(?m)^\s*(interface|type) \w+ (= )?\{|: (string|number|boolean)\b`},
+		{"rust", 3, `(?m)^\s*(pub )?fn \w+(<.*>)?\(|\blet mut\b|\bimpl\b.*\{`},
+		{"rust", 1, `\bprintln!\(|::new\(`},
+		{"java", 3, `\bpublic (static )?(class|void)\b|\bSystem\.out\.`},
+		{"c", 3, `(?m)^#include\s*[<"]`},
+		{"c", 1, `\bprintf\(|\bint main\(`},
+		{"cpp", 2, `\bstd::|\bcout\b|#include <iostream>`},
+		{"ruby", 2, `(?m)^\s*(def \w+[?!]?(\(.*\))?|end|require ['"].*['"])\s*$`},
+		{"ruby", 1, `\bputs\b|\bdo \|\w+\|`},
+		{"php", 4, `<\?php`},
+		{"sql", 3, `(?is)\b(select\b.+\bfrom|insert into|create table|update \w+ set|delete from)\b`},
+		{"html", 4, `(?i)<!doctype html|<html\b`},
+		{"html", 2, `(?i)</(div|span|p|a|body|head|ul|li|table|script)>`},
+		{"xml", 4, `^\s*<\?xml\b`},
+		{"css", 2, `(?m)^\s*[.#]?[\w-]+(\s*[,>+~]?\s*[.#:]?[\w-]+)*\s*\{\s*$`},
+		{"css", 2, `(?m)^\s*[\w-]+:\s*[^;{}]+;\s*$`},
+		{"shell", 4, `^#!/(usr/)?bin/(env )?(ba|z)?sh`},
+		{"shell", 3, `(?m)^\$ \w`},
+		{"shell", 2, `(?m)^\s*(sudo|apt(-get)?|brew|npm|yarn|pip3?|go (get|install|run|build|test)|git|cd|export|echo|curl|make|docker|chmod|mkdir)\b`},
+		{"diff", 4, `(?m)^(\+\+\+ |--- |@@ -\d)`},
+		{"dockerfile", 4, `(?m)^FROM \S+`},
+		{"dockerfile", 1, `(?m)^(RUN|COPY|CMD|ENTRYPOINT|WORKDIR) `},
+		{"yaml", 2, `^---\s*\n`},
+		{"yaml", 1, `(?m)^\s*([\w-]+|- [\w-]+): \S`},
+	}
+	out := make([]languageClue, len(clues))
+	for i, c := range clues {
+		out[i] = languageClue{c.lang, c.weight, regexp.MustCompile(c.pattern)}
+	}
+	return out
+}()
+
+// HeuristicClassifier guesses the language of code from its telltale
+// keywords & syntax, choosing the language with the most points worth of
+// clues if that's enough to be sure of. JSON is recognised by parsing it
+var HeuristicClassifier Classifier = ClassifierFunc(func(code string) string {
+	trimmed := strings.TrimSpace(code)
+	if trimmed == "" {
+		return ""
+	}
+	if (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid([]byte(trimmed)) {
+		return "json"
+	}
+	scores := make(map[string]int)
+	best := ""
+	for _, c := range languageClues {
+		if c.re.MatchString(code) {
+			scores[c.lang] += c.weight
+			if s := scores[c.lang]; s > scores[best] || s == scores[best] && c.lang < best {
+				best = c.lang
+			}
+		}
+	}
+	if scores[best] < 2 {
+		return ""
+	}
+	return best
+})
@@ -1,353 +1,520 @@
-package parser
-
-import (
-	"fmt"
-	"os"
-	"strings"
-	"unicode"
-	"unicode/utf8"
-)
-
-const (
-	itemText          itemType = iota // Line of text
-	itemBlockQuote
-	itemUl
-	itemOl
-	itemCode
-	itemHr
-	itemSetTextHeader
-	itemH1
-	itemH2
-	itemH3
-	itemH4
-	itemH5
-	itemH6
-	itemEOF
-	itemNewLine
-	itemHardNewLine
-	itemError
-)
-
-const eof = -1
-
-const (
-	br             delim = "\r\n"
-	hardBr               = "  " + br
-	ul0 = "-"
-	ul1 = "+"
-	ul2 = "*"
-	hr1 = "*"
-	hr2 = "-"
-	ol                   = "1."
-	atxHeader            = "#"
-	setTextHeader1       = "="
-	setTextHeader2       = "-"
-	link                 = "["
-	img                  = "!["
-)
-
-const inlineChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890!@#$%^&*()_-[]{};':\",./>? "
-
-type itemType int
-type delim string
-
-type item struct {
-	typ itemType
-	val string
-}
-
-func (i item) String() string {
-	switch {
-	case i.typ == itemEOF:
-		return "EOF"
-	case i.typ == itemError:
-		return i.val
-	case i.typ == itemHardNewLine:
-		return "Hard return"
-	case i.typ == itemNewLine:
-		return "Soft return"
-	case i.typ == itemText:
-		return fmt.Sprintf("Text: %q", i.val)
-	case i.typ == itemUl:
-		return "UL Item: " + i.val
-	case i.typ >= itemH1 && i.typ <= itemH6:
-		return fmt.Sprintf("Header H%v", i.typ-itemH1+1)
-		// case len(i.val) > 10:
-		// 	return fmt.Sprintf("%.10q...", i.val)
-	}
-	return fmt.Sprintf("%q", i.val)
-}
-
-type lexer struct {
-	name  string
-	input string
-	start int
-	pos   int
-	width int
-	items chan item
-}
-
-// run starts the lexing process
-func (l *lexer) run() {
-	for state := lexText; state != nil; {
-		state = state(l)
-	}
-	close(l.items)
-}
-
-// emit sends an item out on the items channel and resets pos & start
-func (l *lexer) emit(t itemType) {
-	l.items <- item{t, l.input[l.start:l.pos]}
-	l.start = l.pos
-}
-
-// next returns the next rune in the input string and moves pos forward
-func (l *lexer) next() rune {
-	if l.pos >= len(l.input) {
-		l.width = 0
-		return eof
-	}
-	var r rune
-	r, l.width = utf8.DecodeRuneInString(l.input[l.pos:])
-	l.pos += l.width
-	return r
-}
-
-// nextNTimes runs next n times
-func (l *lexer) nextNTimes(n int) []rune {
-	res := make([]rune, n)
-	for i := 0; i < n; i++ {
-		res[i] = l.next()
-	}
-	return res
-}
-
-// ignore skips over the substr between l.start & l.pos
-func (l *lexer) ignore() {
-	l.start = l.pos
-}
-
-// ignoreNext ignores the next n runes
-func (l *lexer) ignoreNext(n int) {
-	l.nextNTimes(n)
-	l.ignore()
-}
-
-// ignoreRun ignores all the following successive occurrences of r
-func (l *lexer) ignoreRun(r rune) {
-	for l.accept(string(r)) {
-	}
-	l.ignore()
-}
-
-// backup moves the pos cursor one step back
-// WARNING: only safe to run once in between runs of next()
-func (l *lexer) backup() {
-	l.pos -= l.width
-}
-
-// backupNSpaces backs up n times
-// WARNING: only safe to run when you are certain the previous n characters are identical
-func (l *lexer) backupNSpaces(n int) {
-	l.pos -= n * l.width
-}
-
-// peek returns the next rune without altering the state of the lexer
-func (l *lexer) peek() rune {
-	defer l.backup()
-	return l.next()
-}
-
-// accept absorbs one rune from the valid string into the current item
-func (l *lexer) accept(valid string) bool {
-	if strings.IndexRune(valid, l.next()) >= 0 {
-		return true
-	}
-	l.backup()
-	return false
-}
-
-// acceptRun accepts successive characters as long as they are in the valid string
-func (l *lexer) acceptRun(valid string) int {
-	n := 0
-	for strings.IndexRune(valid, rune(l.next())) >= 0 {
-		n++
-	}
-	l.backup()
-	return n
-}
-
-func (l *lexer) acceptUntilNewLine() {
-	for ; (!hp(l.input[l.pos:], br) && l.peek() != eof); l.next() { }
-}
-
-// errorf returns an error token and terminates the scan by passing
-// back a nil pointer that will be the next state, terminating l.nextItem.
-func (l *lexer) errorf(format string, args ...interface{}) stateFn {
-	l.items <- item{itemError, fmt.Sprintf(format, args...)}
-	return nil
-}
-
-// lex provisions the whole lexing scheme and passes back references
-// to the lexer instance and items channel
-func lex(name, input string) (*lexer, chan item) {
-	l := &lexer{
-		name:  name,
-		input: input,
-		items: make(chan item),
-	}
-	go l.run()
-	return l, l.items
-}
-
-type stateFn func(*lexer) stateFn
-
-// hp is a shorthand for strings.HasPrefix that accepts a delim param
-func hp(s string, d delim) bool {
-	return strings.HasPrefix(s, string(d))
-}
-
-// isSpace reports whether r is a space character.
-func isSpace(r rune) bool {
-	return r == ' ' || r == '\t'
-}
-
-// isEndOfLine reports whether r is an end-of-line character.
-func isEndOfLine(r rune) bool {
-	return r == '\r' || r == '\n'
-}
-
-// isAlphaNumeric reports whether r is an alphabetic, digit, or underscore.
-func isAlphaNumeric(r rune) bool {
-	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
-}
-
-// ============================================================ //
-// ========================= STATES =========================== //
-// ============================================================ //
-
-func lexText(l *lexer) stateFn {
-	/* What are we looking at right now? */
-	s := l.input[l.pos:]
-	if hp(s, atxHeader) {
-		return lexAtxHeader
-	} else if hp(s, ul0) || hp(s, ul2) {
-		return lexHr
-	} else if hp(s, ul1) && s[1] == ' ' {
-		l.acceptRun(" " + string(ul1))
-		return lexUl
-	} else if hp(s, ol) && s[2] == ' ' {
-		return lexOl
-	}
-	l.acceptUntilNewLine()
-	lexTextNewLine(l)
-	// Cursor now immediately after newline
-	/* What were we just looking at? */
-	l.acceptRun(" ") // Ignore leading spaces
-	s = l.input[l.pos:] // Start checking line contents
-	if hp(s, setTextHeader1) || hp(s, setTextHeader2) { // Previous line was setTextheader
-		l.acceptRun(string(setTextHeader1) + string(setTextHeader2) + " ") // Accept all ='s, -'s and trailing spaces
-		if !hp(l.input[l.pos:], br) {	// settext header stuff has trailing chars
-			l.acceptUntilNewLine()
-			lexTextNewLine(l)
-			return lexText
-		}
-		// valid settext header declaration
-		l.emit(itemSetTextHeader)
-		l.nextNTimes(len(br))
-		l.ignore()
-		l.emit(itemNewLine)
-	}
-	return lexText
-}
-
-// lexTextNewLine lexes the newline at the end of text, emitting the correct line ending type
-// cursor should be directly before "\r\n" when called
-// cursor is moved to the start of the next line
-func lexTextNewLine(l *lexer) {
-	if (l.pos + len(br)) > len(l.input) {
-		l.emit(itemEOF)
-		os.Exit(0)
-	}
-	if l.input[l.pos - 2:l.pos + len(br)] == string(hardBr) {
-		l.backupNSpaces(2)
-		if (l.pos > l.start) {
-			l.emit(itemText)
-		}
-		l.nextNTimes(len(hardBr))
-		l.ignore()	// Ignore literal \r\n chars
-		l.emit(itemHardNewLine)
-	} else {
-		if l.pos > l.start {
-			l.emit(itemText)
-		}
-		l.nextNTimes(len(br))
-		l.ignore()
-		l.emit(itemNewLine)
-	}
-}
-
-func lexSetTextHeader(l *lexer) stateFn {
-	fmt.Println("Entered lexSetTextHeader")
-	return nil
-}
-
-func lexAtxHeader(l *lexer) stateFn {
-	var typ itemType
-	n := l.acceptRun("#") // Find which level of header this is
-	if l.peek() != ' ' {
-		l.acceptUntilNewLine()
-		lexTextNewLine(l)
-		return lexText
-	}
-	l.acceptRun(" ")
-	switch n { // Map to item type
-	case 0:
-		typ = itemError
-	case 1:
-		typ = itemH1
-	case 2:
-		typ = itemH2
-	case 3:
-		typ = itemH3
-	case 4:
-		typ = itemH4
-	case 5:
-		typ = itemH5
-	default:
-		typ = itemH6
-	}
-	if typ == itemError {
-		return l.errorf("Expected \"#\" at start of ATX header") // Send error & exit
-	}
-	l.ignore()
-	l.emit(typ)
-	return lexText
-}
-
-func lexHr(l *lexer) stateFn {
-	hrChar := l.input[l.pos:l.pos+1] // '-' or '*'
-	for !hp(l.input[l.pos:], br) {
-		if !l.accept(hrChar) {
-			l.ignore()
-			return lexUl
-		}
-		l.acceptRun(" ")		
-	}
-	l.nextNTimes(len(br))
-	l.ignore()
-	l.emit(itemHr)
-	return lexText
-}
-
-func lexUl(l *lexer) stateFn {
-	l.emit(itemUl)
-	return lexText
-}
-
-func lexOl(l *lexer) stateFn {
-	return nil
-}
-// ============================================================ //
-// ======================= END STATES ========================= //
-// ============================================================ //
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const eof = -1
+
+const (
+	br             delim = "\r\n" // Default line ending
+	hardBrSpaces         = "  "
+	ul0 = "-"
+	ul1 = "+"
+	ul2 = "*"
+	hr1 = "*"
+	hr2 = "-"
+	ol                   = "1."
+	codeFence            = "```"
+	blockQuote           = ">"
+	atxHeader            = "#"
+	setTextHeader1       = "="
+	setTextHeader2       = "-"
+	link                 = "["
+	img                  = "!["
+)
+
+const inlineChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890!@#$%^&*()_-[]{};':\",./>? "
+
+type delim string
+
+type lexer struct {
+	name  string
+	input string
+	start int
+	pos   int
+	width int
+	state stateFn // State to run when more items are needed
+	items []Token  // Emitted items, handed out by nextItem from head onwards
+	head  int
+	br       delim          // Line ending the input uses
+	anyBr    bool           // Whether \r\n, \n & \r all end lines, whatever br is
+	starters []blockStarter // Block syntax added by extensions
+	relax    Relaxation     // Mistakes to accept
+	noInterrupt bool        // Lists can't interrupt a paragraph
+	headingNoSpace bool     // "#Heading" is a heading
+	listed   bool           // Whether the current block is a list
+
+	// The next token's Raw text starts at rawStart. Lines have been counted
+	// up to counted, line of them, the last starting at lineStart
+	rawStart, counted, line, lineStart int
+}
+
+// nextItem returns the next item, running the state machine only as far as
+// is needed to produce it. ok is false once lexing has finished
+func (l *lexer) nextItem() (it Token, ok bool) {
+	for l.head == len(l.items) {
+		if l.state == nil {
+			return Token{}, false
+		}
+		l.items, l.head = l.items[:0], 0 // All handed out, reuse the space
+		l.state = l.state(l)
+	}
+	it = l.items[l.head]
+	l.head++
+	return it, true
+}
+
+// emit queues an item for nextItem and resets pos & start
+func (l *lexer) emit(t TokenKind) {
+	l.emitItem(Token{Kind: t, Val: l.input[l.start:l.pos]})
+	l.start = l.pos
+}
+
+// emitItem queues it for nextItem, covering the input since the last token
+func (l *lexer) emitItem(it Token) {
+	it.Pos = l.position(l.rawStart)
+	it.raw = l.input[l.rawStart:l.pos]
+	l.rawStart = l.pos
+	l.items = append(l.items, it)
+}
+
+// position returns the Position of offset, which mustn't be before any
+// offset it was called with previously
+func (l *lexer) position(offset int) Position {
+	seg := l.input[l.counted:offset]
+	if l.anyBr && strings.IndexByte(seg, '\r') >= 0 { // Lone \r's end lines too
+		for i := l.counted; i < offset; i++ {
+			if c := l.input[i]; c == '\n' || c == '\r' && (i+1 == len(l.input) || l.input[i+1] != '\n') {
+				l.line++
+				l.lineStart = i + 1
+			}
+		}
+	} else if n := strings.Count(seg, "\n"); n > 0 {
+		l.line += n
+		l.lineStart = l.counted + strings.LastIndexByte(seg, '\n') + 1
+	}
+	l.counted = offset
+	return Position{Offset: offset, Line: l.line + 1, Column: offset - l.lineStart + 1}
+}
+
+// next returns the next rune in the input string and moves pos forward
+func (l *lexer) next() rune {
+	if l.pos >= len(l.input) {
+		l.width = 0
+		return eof
+	}
+	var r rune
+	r, l.width = utf8.DecodeRuneInString(l.input[l.pos:])
+	l.pos += l.width
+	return r
+}
+
+// nextNTimes runs next n times
+func (l *lexer) nextNTimes(n int) {
+	for i := 0; i < n; i++ {
+		l.next()
+	}
+}
+
+// ignore skips over the substr between l.start & l.pos
+func (l *lexer) ignore() {
+	l.start = l.pos
+}
+
+// ignoreNext ignores the next n runes
+func (l *lexer) ignoreNext(n int) {
+	l.nextNTimes(n)
+	l.ignore()
+}
+
+// ignoreRun ignores all the following successive occurrences of r
+func (l *lexer) ignoreRun(r rune) {
+	for l.accept(string(r)) {
+	}
+	l.ignore()
+}
+
+// backup moves the pos cursor one step back
+// WARNING: only safe to run once in between runs of next()
+func (l *lexer) backup() {
+	l.pos -= l.width
+}
+
+// checkpoint is a saved lexer position, for scanning ahead speculatively
+type checkpoint struct {
+	start, pos, width int
+	items             int // Items queued when the checkpoint was taken
+
+	rawStart, counted, line, lineStart int
+}
+
+// checkpoint saves the lexer's position for restore to return to
+func (l *lexer) checkpoint() checkpoint {
+	return checkpoint{
+		start: l.start, pos: l.pos, width: l.width, items: len(l.items),
+		rawStart: l.rawStart, counted: l.counted, line: l.line, lineStart: l.lineStart,
+	}
+}
+
+// restore rewinds the lexer to c, dropping any items emitted since. c must
+// have been taken during the current state function
+func (l *lexer) restore(c checkpoint) {
+	l.start, l.pos, l.width = c.start, c.pos, c.width
+	l.items = l.items[:c.items]
+	l.rawStart, l.counted, l.line, l.lineStart = c.rawStart, c.counted, c.line, c.lineStart
+}
+
+// peek returns the next rune without altering the state of the lexer
+func (l *lexer) peek() rune {
+	defer l.backup()
+	return l.next()
+}
+
+// accept absorbs one rune from the valid string into the current item
+func (l *lexer) accept(valid string) bool {
+	if strings.IndexRune(valid, l.next()) >= 0 {
+		return true
+	}
+	l.backup()
+	return false
+}
+
+// acceptRun accepts successive characters as long as they are in the valid string
+func (l *lexer) acceptRun(valid string) int {
+	n := 0
+	for strings.IndexRune(valid, rune(l.next())) >= 0 {
+		n++
+	}
+	l.backup()
+	return n
+}
+
+// acceptUntilNewLine moves pos up to the next line ending, or the end of the
+// input. It finds it with IndexByte, which outpaces stepping rune by rune
+// on anything but the shortest lines
+func (l *lexer) acceptUntilNewLine() {
+	rest := l.input[l.pos:]
+	var i int
+	if l.anyBr {
+		i = strings.IndexByte(rest, '\n')
+		if i < 0 {
+			i = len(rest)
+		}
+		if j := strings.IndexByte(rest[:i], '\r'); j >= 0 {
+			i = j
+		}
+	} else if i = strings.Index(rest, string(l.br)); i < 0 {
+		i = len(rest)
+	}
+	l.pos += i
+	l.width = 0 // As next leaves it at the end of the input, or
+	if l.pos < len(l.input) {
+		l.width = 1 // the width of the line ending's first byte
+	}
+}
+
+// lineEnding returns the length of the line ending at pos, or 0 if there
+// isn't one there
+func (l *lexer) lineEnding() int {
+	s := l.input[l.pos:]
+	switch {
+	case !l.anyBr:
+		if hp(s, l.br) {
+			return len(l.br)
+		}
+	case hp(s, delim(CRLF)):
+		return len(CRLF)
+	case s != "" && isEndOfLine(rune(s[0])):
+		return 1
+	}
+	return 0
+}
+
+// errorf returns an error token and terminates the scan by passing
+// back a nil pointer that will be the next state, terminating l.nextItem.
+func (l *lexer) errorf(format string, args ...interface{}) stateFn {
+	l.emitItem(Token{Kind: TokenError, Val: fmt.Sprintf(format, args...)})
+	return nil
+}
+
+// lex provisions the whole lexing scheme and passes back the lexer, ready
+// for items to be pulled from it with nextItem
+func lex(name, input string, p *Parser) *lexer {
+	return &lexer{
+		name:     name,
+		input:    input,
+		state:    lexText,
+		br:       delim(p.lineEnding),
+		anyBr:    p.normalization != NormalizeOff,
+		starters: p.blockStarters,
+		relax:    p.relax,
+		noInterrupt: p.noInterrupt,
+		headingNoSpace: p.headingNoSpace,
+	}
+}
+
+type stateFn func(*lexer) stateFn
+
+// hp is a shorthand for strings.HasPrefix that accepts a delim param
+func hp(s string, d delim) bool {
+	return strings.HasPrefix(s, string(d))
+}
+
+// isSpace reports whether r is a space character.
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t'
+}
+
+// isEndOfLine reports whether r is an end-of-line character.
+func isEndOfLine(r rune) bool {
+	return r == '\r' || r == '\n'
+}
+
+// isListItem reports whether s starts with a list item's marker, and not a
+// thematic break made of the same character
+func isListItem(s string) bool {
+	if !hp(s, ul0+" ") && !hp(s, ul1+" ") && !hp(s, ul2+" ") && !hp(s, ol+" ") {
+		return false
+	}
+	line := s
+	if i := strings.IndexAny(s, "\r\n"); i >= 0 {
+		line = s[:i]
+	}
+	return strings.Trim(line, " "+s[:1]) != ""
+}
+
+// isAlphaNumeric reports whether r is an alphabetic, digit, or underscore.
+func isAlphaNumeric(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// ============================================================ //
+// ========================= STATES =========================== //
+// ============================================================ //
+
+func lexText(l *lexer) stateFn {
+	/* What are we looking at right now? */
+	s := l.input[l.pos:]
+	if hp(s, atxHeader) {
+		return lexAtxHeader
+	} else if hp(s, ul0) || hp(s, ul2) {
+		return lexHr
+	} else if hp(s, ul1+" ") {
+		l.acceptRun(" " + string(ul1))
+		return lexUl
+	} else if hp(s, ol+" ") {
+		return lexOl
+	} else if hp(s, blockQuote) {
+		return lexBlockQuote
+	}
+	for _, st := range l.starters {
+		if hp(s, delim(st.prefix)) {
+			return st.state
+		}
+	}
+	return lexLine
+}
+
+// lexLine lexes a line of paragraph text, then checks whether the line
+// after it underlines it as a settext header
+func lexLine(l *lexer) stateFn {
+	l.acceptUntilNewLine()
+	blank := strings.TrimSpace(l.input[l.start:l.pos]) == ""
+	if !lexTextNewLine(l) {
+		return nil
+	}
+	if blank { // Only a line of text can be underlined as a settext header
+		l.listed = false
+		return lexText
+	}
+	return lexFollowingLine
+}
+
+// lexFollowingLine lexes the start of the line after a line of text, which
+// may underline it as a settext header or carry on its paragraph
+func lexFollowingLine(l *lexer) stateFn {
+	// Cursor now immediately after newline
+	/* What were we just looking at? */
+	l.acceptRun(" ") // Ignore leading spaces
+	s := l.input[l.pos:] // Start checking line contents
+	if isListItem(s) {
+		if l.listed || !l.noInterrupt || l.relax&RelaxListInterrupt != 0 {
+			return lexText
+		}
+		// Here a list needs a blank line before it, so this is text
+		l.acceptUntilNewLine()
+		if !lexTextNewLine(l) {
+			return nil
+		}
+		return lexFollowingLine
+	}
+	if hp(s, setTextHeader1) || hp(s, setTextHeader2) { // Previous line was setTextheader
+		l.acceptRun(string(setTextHeader1) + string(setTextHeader2) + " ") // Accept all ='s, -'s and trailing spaces
+		if l.lineEnding() == 0 {	// settext header stuff has trailing chars
+			l.acceptUntilNewLine()
+			if !lexTextNewLine(l) {
+				return nil
+			}
+			return lexText
+		}
+		// valid settext header declaration
+		l.emit(TokenSetextUnderline)
+		l.nextNTimes(l.lineEnding())
+		l.ignore()
+		l.emit(TokenNewLine)
+	}
+	return lexText
+}
+
+// lexTextNewLine lexes the newline at the end of text, emitting the correct line ending type
+// cursor should be directly before "\r\n" when called
+// cursor is moved to the start of the next line
+// returns false once the input is exhausted, after emitting EOF
+func lexTextNewLine(l *lexer) bool {
+	n := l.lineEnding()
+	if n == 0 { // Only the end of the input stops a line without one
+		if l.pos > l.start {
+			l.emit(TokenText)
+		}
+		l.emit(TokenEOF)
+		return false
+	}
+	if l.pos >= len(hardBrSpaces) && l.input[l.pos - len(hardBrSpaces):l.pos] == hardBrSpaces {
+		brAt := l.pos
+		l.pos -= len(hardBrSpaces) // Leave the spaces out of the text
+		if (l.pos > l.start) {
+			l.emit(TokenText)
+		}
+		l.pos = brAt + n
+		l.ignore()	// Ignore literal \r\n chars
+		l.emit(TokenHardNewLine)
+	} else {
+		if l.pos > l.start {
+			l.emit(TokenText)
+		}
+		l.nextNTimes(n)
+		l.ignore()
+		l.emit(TokenNewLine)
+	}
+	return true
+}
+
+func lexSetTextHeader(l *lexer) stateFn {
+	fmt.Println("Entered lexSetTextHeader")
+	return nil
+}
+
+func lexAtxHeader(l *lexer) stateFn {
+	var typ TokenKind
+	n := l.acceptRun("#") // Find which level of header this is
+	if next := l.peek(); next != ' ' && ((l.relax&RelaxHeadingSpace == 0 && !l.headingNoSpace) || next == eof || isEndOfLine(next)) {
+		l.acceptUntilNewLine()
+		if !lexTextNewLine(l) {
+			return nil
+		}
+		return lexText
+	}
+	l.acceptRun(" ")
+	l.listed = false
+	switch n { // Map to item type
+	case 0:
+		typ = TokenError
+	case 1:
+		typ = TokenH1
+	case 2:
+		typ = TokenH2
+	case 3:
+		typ = TokenH3
+	case 4:
+		typ = TokenH4
+	case 5:
+		typ = TokenH5
+	default:
+		typ = TokenH6
+	}
+	if typ == TokenError {
+		return l.errorf("Expected \"#\" at start of ATX header") // Send error & exit
+	}
+	l.ignore()
+	l.emit(typ)
+	return lexText
+}
+
+func lexHr(l *lexer) stateFn {
+	c := l.checkpoint()
+	hrChar := l.input[l.pos:l.pos+1] // '-' or '*'
+	for l.lineEnding() == 0 {
+		if !l.accept(hrChar) {
+			if s := l.input[c.pos:]; len(s) < 2 || s[1] != ' ' { // Not a list item either, e.g. *emphasis*
+				l.restore(c)
+				return lexLine
+			}
+			l.ignore()
+			return lexUl
+		}
+		l.acceptRun(" ")		
+	}
+	l.listed = false
+	l.emit(TokenThematicBreak) // Keep the marker, renderers may care how it was written
+	l.nextNTimes(l.lineEnding())
+	l.ignore()
+	return lexText
+}
+
+func lexUl(l *lexer) stateFn {
+	l.listed = true
+	l.emit(TokenBulletItem)
+	return lexText
+}
+
+func lexOl(l *lexer) stateFn {
+	l.nextNTimes(len(ol))
+	l.acceptRun(" ")
+	l.listed = true
+	l.emit(TokenOrderedItem)
+	return lexText
+}
+
+// lexBlockQuote lexes the '>' marking a quoted line, leaving the rest of
+// the line to be lexed as usual
+func lexBlockQuote(l *lexer) stateFn {
+	l.acceptRun(" ")
+	l.accept(blockQuote)
+	l.accept(" ")
+	l.listed = false
+	l.emit(TokenBlockQuote)
+	return lexText
+}
+
+// lexCode lexes a fenced code block, emitting the info string followed by
+// the contents between the fences exactly as written
+func lexCode(l *lexer) stateFn {
+	l.listed = false
+	l.acceptRun("`")
+	l.ignore()
+	l.acceptUntilNewLine()
+	l.emit(TokenCodeFence)
+	l.nextNTimes(l.lineEnding())
+	l.ignore()
+	for !hp(l.input[l.pos:], codeFence) {
+		if l.peek() == eof {
+			l.emit(TokenCode)
+			l.emit(TokenEOF)
+			return nil
+		}
+		l.acceptUntilNewLine()
+		l.nextNTimes(l.lineEnding())
+	}
+	l.emit(TokenCode)
+	l.acceptUntilNewLine() // Closing fence
+	l.nextNTimes(l.lineEnding())
+	l.ignore()
+	return lexText
+}
+// ============================================================ //
+// ======================= END STATES ========================= //
+// ============================================================ //
@@ -0,0 +1,128 @@
+package parser_test
+
+import (
+	"strings"
+	"testing"
+
+	"../parser"
+)
+
+type wantToken struct {
+	kind parser.TokenKind
+	val  string
+	pos  parser.Position
+}
+
+func at(offset, line, column int) parser.Position {
+	return parser.Position{Offset: offset, Line: line, Column: column}
+}
+
+// TestTokenizeInvalidUTF8 checks truncated & stray bytes are lexed as the
+// text they're in, without shifting the positions of what follows. Columns
+// count bytes, so an invalid byte takes one like any other
+func TestTokenizeInvalidUTF8(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		want  []wantToken
+	}{
+		{"truncated at end of line", "trunc\xe2\x82\n", []wantToken{
+			{parser.TokenText, "trunc\xe2\x82", at(0, 1, 1)},
+			{parser.TokenNewLine, "", at(7, 1, 8)},
+			{parser.TokenEOF, "", at(8, 2, 1)},
+		}},
+		{"truncated at end of input", "\xf0\x9f\x98", []wantToken{
+			{parser.TokenText, "\xf0\x9f\x98", at(0, 1, 1)},
+			{parser.TokenEOF, "", at(3, 1, 4)},
+		}},
+		{"stray byte", "a\xffb", []wantToken{
+			{parser.TokenText, "a\xffb", at(0, 1, 1)},
+			{parser.TokenEOF, "", at(3, 1, 4)},
+		}},
+		{"NUL", "a\x00b\n", []wantToken{
+			{parser.TokenText, "a\x00b", at(0, 1, 1)},
+			{parser.TokenNewLine, "", at(3, 1, 4)},
+			{parser.TokenEOF, "", at(4, 2, 1)},
+		}},
+		{"after valid multi-byte", "é\xe2\x82\n*x*\n", []wantToken{
+			{parser.TokenText, "é\xe2\x82", at(0, 1, 1)},
+			{parser.TokenNewLine, "", at(4, 1, 5)},
+			{parser.TokenText, "*x*", at(5, 2, 1)},
+			{parser.TokenNewLine, "", at(8, 2, 4)},
+			{parser.TokenEOF, "", at(9, 3, 1)},
+		}},
+		{"heading", "# \xc3\n", []wantToken{
+			{parser.TokenH1, "", at(0, 1, 1)},
+			{parser.TokenText, "\xc3", at(2, 1, 3)},
+			{parser.TokenNewLine, "", at(3, 1, 4)},
+			{parser.TokenEOF, "", at(4, 2, 1)},
+		}},
+		{"quote & bullet with CRLF", "> \xe2\x82\r\n- \xff\r\n", []wantToken{
+			{parser.TokenBlockQuote, "> ", at(0, 1, 1)},
+			{parser.TokenText, "\xe2\x82", at(2, 1, 3)},
+			{parser.TokenNewLine, "", at(4, 1, 5)},
+			{parser.TokenBulletItem, "", at(6, 2, 1)},
+			{parser.TokenText, "\xff", at(8, 2, 3)},
+			{parser.TokenNewLine, "", at(9, 2, 4)},
+			{parser.TokenEOF, "", at(11, 3, 1)},
+		}},
+		{"ordered item", "1. \xf0\x9f\n", []wantToken{
+			{parser.TokenOrderedItem, "1. ", at(0, 1, 1)},
+			{parser.TokenText, "\xf0\x9f", at(3, 1, 4)},
+			{parser.TokenNewLine, "", at(5, 1, 6)},
+			{parser.TokenEOF, "", at(6, 2, 1)},
+		}},
+		{"fenced code", "```\xe2\n\xff\n```\n", []wantToken{
+			{parser.TokenCodeFence, "\xe2", at(0, 1, 1)},
+			{parser.TokenCode, "\xff\n", at(4, 1, 5)},
+			{parser.TokenEOF, "", at(7, 3, 1)},
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := parser.New().Tokenize("test", tc.input)
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %d tokens %v, want %d", len(got), got, len(tc.want))
+			}
+			for i, w := range tc.want {
+				if g := got[i]; g.Kind != w.kind || g.Val != w.val || g.Pos != w.pos {
+					t.Errorf("token %d is %v %q at %v (offset %d), want %v %q at %v (offset %d)",
+						i, g.Kind, g.Val, g.Pos, g.Pos.Offset, w.kind, w.val, w.pos, w.pos.Offset)
+				}
+			}
+			if err := lossless(got, tc.input); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// TestParseInvalidUTF8 checks Parse replaces NULs & each byte of invalid
+// UTF-8 with U+FFFD
+func TestParseInvalidUTF8(t *testing.T) {
+	for input, want := range map[string]string{
+		"trunc\xe2\x82\n": "trunc��",
+		"\xf0\x9f\x98":    "���",
+		"a\xffb\x00c\n":   "a�b�c",
+		"é\xe2\x82€\n":    "é��€",
+	} {
+		doc, err := parser.New().Parse("test", input)
+		if err != nil {
+			t.Fatalf("%q: %v", input, err)
+		}
+		if got := text(doc.Root); got != want {
+			t.Errorf("%q parsed to text %q, want %q", input, got, want)
+		}
+	}
+}
+
+// text returns the text within n
+func text(n *parser.Node) string {
+	var b strings.Builder
+	if n.Kind == parser.NodeText {
+		b.WriteString(n.Literal)
+	}
+	for _, c := range n.Children {
+		b.WriteString(text(c))
+	}
+	return b.String()
+}
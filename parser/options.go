@@ -0,0 +1,225 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LineEnding is the line terminator a Parser expects its input to use
+type LineEnding string
+
+const (
+	CRLF LineEnding = "\r\n"
+	LF   LineEnding = "\n"
+)
+
+// Normalization controls which line endings a Parser splits input on
+type Normalization int
+
+const (
+	// NormalizeOff only splits lines on the LineEnding set by WithLineEndings
+	NormalizeOff Normalization = iota
+	// NormalizeText treats \r\n, \n & \r alike as line endings. Code
+	// blocks keep the endings they were written with
+	NormalizeText
+	// NormalizeAll is NormalizeText with the endings within code blocks
+	// converted to \n as well
+	NormalizeAll
+)
+
+// DefaultMaxNesting is how deeply inline elements may nest by default
+const DefaultMaxNesting = 32
+
+// Reference is the target of a reference-style link, as written in a
+// [label]: destination "title" definition
+type Reference struct {
+	Dest  string
+	Title string
+}
+
+// Parser turns markdown into Documents according to its configuration.
+// Build one with New.
+//
+// A Parser is never modified once New returns, and each call to Parse keeps
+// its working state to itself, so one Parser can be shared by any number of
+// goroutines parsing at the same time
+type Parser struct {
+	extensions    []Extension
+	lineEnding    LineEnding
+	normalization Normalization
+	maxNesting    int
+	maxInput      int // Longest input accepted in bytes, 0 for no limit
+	ranges        bool
+	headingIDs    bool
+	relax         Relaxation
+	stats         bool
+	statsHooks    []StatsHook
+	refs          map[string]Reference
+
+	// Registered by extensions
+	built          bool // Set once New returns, after which p is read-only
+	frontMatter    bool
+	noInterrupt    bool // Lists can't interrupt a paragraph, as in OriginalMarkdown
+	headingNoSpace bool // "#Heading" is a heading, as in OriginalMarkdown
+	mmdMeta        bool // MultiMarkdown's metadata is read
+	blockStarters  []blockStarter
+	inlineRules    map[byte][]inlineRule
+	transformers   []Transformer
+}
+
+// Option configures a Parser
+type Option func(*Parser)
+
+// New returns a Parser configured by opts, and lets each of its extensions
+// register itself. Without options it enables DefaultExtensions and
+// accepts any mix of line endings, as NormalizeText
+func New(opts ...Option) *Parser {
+	p := &Parser{
+		extensions:    DefaultExtensions,
+		lineEnding:    CRLF,
+		normalization: NormalizeText,
+		maxNesting:    DefaultMaxNesting,
+		relax:         DefaultRelaxations,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	for _, ext := range p.extensions {
+		ext.Extend(p)
+	}
+	p.built = true
+	return p
+}
+
+// WithExtensions replaces the set of enabled extensions with exts. Pass no
+// extensions to get plain Markdown
+func WithExtensions(exts ...Extension) Option {
+	return func(p *Parser) {
+		p.extensions = exts
+	}
+}
+
+// WithLineEndings splits input only on le, turning normalization off
+func WithLineEndings(le LineEnding) Option {
+	return func(p *Parser) {
+		p.lineEnding = le
+		p.normalization = NormalizeOff
+	}
+}
+
+// WithNormalization sets how line endings are normalized. Use it after
+// WithLineEndings to pick the ending NormalizeOff splits on
+func WithNormalization(n Normalization) Option {
+	return func(p *Parser) {
+		p.normalization = n
+	}
+}
+
+// Relaxation is a set of common mistakes for a Parser to accept, reading
+// the markup the way the author meant it rather than strictly as written
+type Relaxation uint
+
+const (
+	// RelaxHeadingSpace reads a line like "#Heading", without a space after
+	// the #'s, as a heading. Lines such as "#hashtag" become headings too
+	RelaxHeadingSpace Relaxation = 1 << iota
+	// RelaxListInterrupt lets a list start straight after a line of a
+	// paragraph, with no blank line between them, in dialects such as
+	// OriginalMarkdown needing one. In CommonMark lists always may
+	RelaxListInterrupt
+)
+
+const (
+	// Strict accepts no mistakes, reading documents exactly as the spec of
+	// their Dialect says
+	Strict Relaxation = 0
+	// Permissive accepts every mistake there's a Relaxation for, as many
+	// wikis do
+	Permissive = RelaxHeadingSpace | RelaxListInterrupt
+	// DefaultRelaxations are the mistakes a Parser accepts unless told
+	// otherwise
+	DefaultRelaxations = RelaxListInterrupt
+)
+
+// WithRelaxations sets the mistakes the Parser accepts to r, such as
+// Strict, Permissive, or any of the Relaxations or'd together
+func WithRelaxations(r Relaxation) Option {
+	return func(p *Parser) {
+		p.relax = r
+	}
+}
+
+// WithMaxNesting limits how deeply emphasis, links & images may nest. Markup
+// beyond the limit is left as literal text
+func WithMaxNesting(n int) Option {
+	return func(p *Parser) {
+		p.maxNesting = n
+	}
+}
+
+// WithMaxInputSize rejects input longer than n bytes with an
+// *InputTooLargeError before any of it is parsed. n of 0 means no limit
+func WithMaxInputSize(n int) Option {
+	return func(p *Parser) {
+		p.maxInput = n
+	}
+}
+
+// WithSourceRanges records the Range of input each node was parsed from,
+// and keeps the input as the Document's Source, so tools like formatters
+// can reproduce the original text of anything they don't rewrite. A block's
+// Range runs from its first marker to the end of its last line, without the
+// line ending; an inline's covers its markup, delimiters included. Nodes
+// made by custom block parsers only have Ranges at the top level
+func WithSourceRanges() Option {
+	return func(p *Parser) {
+		p.ranges = true
+	}
+}
+
+// WithHeadingIDs gives every heading an ID to link to, the way GitHub
+// does, which the HTML renderer writes out as its id attribute. IDs are
+// assigned before any transformers run, so they can change them
+func WithHeadingIDs() Option {
+	return func(p *Parser) {
+		p.headingIDs = true
+	}
+}
+
+// InputTooLargeError is returned for input over the limit set with
+// WithMaxInputSize
+type InputTooLargeError struct {
+	Name  string
+	Size  int // Bytes of input, or how many had been read when streaming
+	Limit int
+}
+
+func (e *InputTooLargeError) Error() string {
+	return fmt.Sprintf("%s: input of %d bytes exceeds the limit of %d", e.Name, e.Size, e.Limit)
+}
+
+// checkSize returns an *InputTooLargeError if size bytes are over the limit
+func (p *Parser) checkSize(name string, size int) error {
+	if p.maxInput > 0 && size > p.maxInput {
+		return &InputTooLargeError{Name: name, Size: size, Limit: p.maxInput}
+	}
+	return nil
+}
+
+// WithReferenceMap supplies link reference definitions, keyed by label, that
+// every parsed document can use. Definitions within a document take
+// precedence over these
+func WithReferenceMap(refs map[string]Reference) Option {
+	return func(p *Parser) {
+		p.refs = make(map[string]Reference, len(refs))
+		for label, ref := range refs {
+			p.refs[normalizeLabel(label)] = ref
+		}
+	}
+}
+
+// normalizeLabel folds case & collapses whitespace so that labels match the
+// way CommonMark matches them
+func normalizeLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
@@ -0,0 +1,98 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Heading is an entry in a Document's Outline
+type Heading struct {
+	Level    int
+	Text     string     // The heading's plain text
+	ID       string     // Slug of Text, unique within the document
+	Node     *Node      // The NodeHeading it was made from
+	Children []*Heading // The headings beneath it, of higher levels
+}
+
+// Outline returns the top-level headings of the document, each with the
+// headings that follow it at deeper levels nested inside it. Skipped
+// levels are not filled in, so an h3 directly after an h1 is its child
+func (d *Document) Outline() []*Heading {
+	var outline []*Heading
+	var open []*Heading // The most recent heading at each depth
+	ids := HeadingIDs(d.Root)
+	for _, n := range d.Root.Children {
+		if n.Kind != NodeHeading {
+			continue
+		}
+		h := &Heading{Level: n.Level, Text: n.PlainText(), ID: ids[n], Node: n}
+		for len(open) > 0 && open[len(open)-1].Level >= h.Level {
+			open = open[:len(open)-1]
+		}
+		if len(open) == 0 {
+			outline = append(outline, h)
+		} else {
+			parent := open[len(open)-1]
+			parent.Children = append(parent.Children, h)
+		}
+		open = append(open, h)
+	}
+	return outline
+}
+
+// HeadingIDs returns the anchor of each heading beneath n: its ID if it
+// has one, or else the Slug of its text, numbered if need be to make it
+// unique
+func HeadingIDs(n *Node) map[*Node]string {
+	ids := make(map[*Node]string)
+	used := make(map[string]bool)
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		if n.Kind == NodeHeading {
+			if n.ID != "" {
+				ids[n], used[n.ID] = n.ID, true
+			} else {
+				ids[n] = uniqueID(Slug(n.PlainText()), used)
+			}
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(n)
+	return ids
+}
+
+// setHeadingIDs gives each heading beneath n its anchor as its ID
+func setHeadingIDs(n *Node) {
+	for h, id := range HeadingIDs(n) {
+		h.ID = id
+	}
+}
+
+// Slug turns heading text into an anchor the way GitHub does: lower case,
+// with spaces as hyphens and punctuation other than - and _ dropped
+func Slug(text string) string {
+	var b strings.Builder
+	for _, r := range strings.TrimSpace(text) {
+		switch {
+		case r == ' ':
+			b.WriteByte('-')
+		case r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r):
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+// uniqueID returns slug, or if it's in ids already slug followed by the
+// lowest number that makes it unique, adding the result to ids
+func uniqueID(slug string, ids map[string]bool) string {
+	id := slug
+	for i := 1; ids[id]; i++ {
+		id = slug + "-" + strconv.Itoa(i)
+	}
+	ids[id] = true
+	return id
+}
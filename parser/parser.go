@@ -1,15 +1,447 @@
-package parser
-
-import "fmt"
-
-func Lex(name, input string) []item {
-	_, items := lex(name, input)
-	res := make([]item, 200)
-	i := 0
-	for elem := range items {
-		res[i] = elem
-		fmt.Println(elem)
-		i++
-	}
-	return res
-}
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// refDefinition matches a [label]: destination "title" line
+var refDefinition = regexp.MustCompile(`(?m)^ {0,3}\[((?:[^\]\\]|\\.)+)\]:[ \t]*<?([^\s>]+)>?(?:[ \t]+(?:"([^"]*)"|'([^']*)'|\(([^)]*)\)))?[ \t]*\r?$`)
+
+var defaultParser = New()
+
+// toLF converts every kind of line ending to \n
+var toLF = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+// Lex lexes input into Tokens with the default configuration, as
+// Tokenize does
+func Lex(name, input string) []Token {
+	return defaultParser.Tokenize(name, input)
+}
+
+// Tokenize lexes input into the Tokens the parser works from, without
+// building a Document, for tools that work at the syntax level such as
+// highlighters. input is lexed exactly as given, front matter included, so
+// the Raw text of the tokens adds up to input. The last token is TokenEOF,
+// or TokenError if lexing failed
+func (p *Parser) Tokenize(name, input string) []Token {
+	var tokens []Token
+	l := lex(name, input, p)
+	for t, ok := l.nextItem(); ok; t, ok = l.nextItem() {
+		tokens = append(tokens, t)
+	}
+	return tokens
+}
+
+// Parse parses input with the default configuration
+func Parse(name, input string) (*Document, error) {
+	return defaultParser.Parse(name, input)
+}
+
+// Parse lexes input and assembles the items into a Document. Any front
+// matter at the top of input is stored in the Document's Meta
+func (p *Parser) Parse(name, input string) (*Document, error) {
+	return p.ParseWithArena(name, input, nil)
+}
+
+// ParseWithArena parses input like Parse, allocating the Document's nodes
+// in a. The nodes are only valid until a is Reset. A nil Arena allocates
+// from the heap, as Parse does
+func (p *Parser) ParseWithArena(name, input string, a *Arena) (*Document, error) {
+	if err := p.checkSize(name, len(input)); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	src := sanitize(decodeBOM(input))
+	meta, input := p.splitMeta(src)
+	return p.parse(name, meta, src, input, p.references(input), a, start)
+}
+
+// ParseError is returned when input can't be parsed
+type ParseError struct {
+	Name string
+	Pos  Position // Where in the input, front matter included
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s:%v: %s", e.Name, e.Pos, e.Msg)
+}
+
+// parse assembles the blocks of input, the end of src after any front
+// matter, into a Document resolving links against refs, with nodes
+// allocated in a. Preparing src began at start
+func (p *Parser) parse(name string, meta map[string]string, src, input string, refs map[string]Reference, a *Arena, start time.Time) (*Document, error) {
+	b := p.newBuilder(name, meta, src, input, refs, a)
+	parsing := time.Now()
+	if err := b.run(lex(name, input, p)); err != nil {
+		return nil, err
+	}
+	transforming := time.Now()
+	if p.headingIDs {
+		setHeadingIDs(b.doc.Root)
+	}
+	for _, t := range p.transformers {
+		t.Transform(b.doc)
+	}
+	if p.stats {
+		b.doc.Stats = &Stats{
+			Name:      name,
+			Bytes:     len(src),
+			Tokens:    b.tokens,
+			Nodes:     countNodes(b.doc.Root),
+			Prepare:   parsing.Sub(start),
+			Parse:     transforming.Sub(parsing),
+			Transform: time.Since(transforming),
+		}
+		for _, h := range p.statsHooks {
+			h.ParseStats(b.doc.Stats)
+		}
+	}
+	return b.doc, nil
+}
+
+// newBuilder returns a builder for a document of input, the end of src
+// after the given front matter
+func (p *Parser) newBuilder(name string, meta map[string]string, src, input string, refs map[string]Reference, a *Arena) *builder {
+	b := &builder{
+		doc:      &Document{Name: name, Meta: meta, FrontMatter: src[:len(src)-len(input)], Root: a.alloc(Node{Kind: NodeDocument})},
+		codeToLF: p.normalization == NormalizeAll,
+		ranges:   p.ranges,
+		base:     len(src) - len(input),
+		inline: &inlineContext{
+			arena:    a,
+			refs:     refs,
+			maxDepth: p.maxNesting,
+			rules:    p.inlineRules,
+			ranges:   p.ranges,
+		},
+	}
+	if p.ranges {
+		b.doc.Source = src
+		b.doc.Root.Range = Range{0, len(src)}
+	}
+	return b
+}
+
+// references gathers the link reference definitions in input on top of
+// the ones the Parser was configured with
+func (p *Parser) references(input string) map[string]Reference {
+	refs := make(map[string]Reference, len(p.refs))
+	for label, ref := range p.refs {
+		refs[label] = ref
+	}
+	collectReferences(refs, make(map[string]bool), input)
+	return refs
+}
+
+// collectReferences adds the link reference definitions in input to refs,
+// skipping labels already seen
+func collectReferences(refs map[string]Reference, seen map[string]bool, input string) {
+	for _, m := range refDefinition.FindAllStringSubmatch(input, -1) {
+		label := normalizeLabel(m[1])
+		if seen[label] { // The first definition of a label wins
+			continue
+		}
+		seen[label] = true
+		refs[label] = Reference{Dest: m[2], Title: m[3] + m[4] + m[5]}
+	}
+}
+
+// builder tracks which blocks are open while items are consumed
+type builder struct {
+	doc      *Document
+	inline   *inlineContext
+	tip      *Node    // Open block that text is added to
+	list     *Node    // Open list, if any
+	quote    *Node    // Open block quote, if any
+	quoted   bool     // Whether the current line started with '>'
+	newlines int      // Line endings seen since the last text
+	pending  NodeKind // Break to insert before the next text on the tip
+	codeToLF bool     // Whether to convert line endings in code blocks to \n
+	tokens   int      // Tokens handled so far
+
+	// With ranges set, nodes get the Range of the tokens they're built
+	// from, offset by base to skip front matter. pendingRange is the line
+	// ending pending is made from, code the code block whose closing fence
+	// the next token starts with
+	ranges       bool
+	base         int
+	pendingRange Range
+	code         *Node
+
+	// When set, completed top-level blocks are passed to onBlock and
+	// dropped rather than kept in the document
+	onBlock func(*Node) error
+	err     error // Returned by onBlock
+}
+
+// run folds every item l produces into the document
+func (b *builder) run(l *lexer) error {
+	for it, ok := l.nextItem(); ok; it, ok = l.nextItem() {
+		b.tokens++
+		if err := b.handle(it); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handle folds a single item into the document
+func (b *builder) handle(it Token) error {
+	if b.code != nil {
+		it = b.closeFence(it)
+	}
+	switch it.Kind {
+	case TokenText:
+		b.text(it)
+	case TokenNewLine, TokenHardNewLine:
+		quoted := b.quoted
+		b.quoted = false
+		if b.tip != nil && b.tip.Kind == NodeHeading {
+			b.closeTip()
+			b.newlines = 1
+			break
+		}
+		b.newlines++
+		if b.newlines >= 2 {
+			b.closeAll()
+			if !quoted {
+				b.quote = nil
+			}
+			break
+		}
+		b.pending = NodeSoftBreak
+		if it.Kind == TokenHardNewLine {
+			b.pending = NodeHardBreak
+		}
+		if b.ranges {
+			b.pendingRange.Start = b.base + it.Pos.Offset
+			b.pendingRange.End = b.pendingRange.Start + len(it.raw)
+		}
+	case TokenH1, TokenH2, TokenH3, TokenH4, TokenH5, TokenH6:
+		b.closeAll()
+		b.tip = b.block(Node{Kind: NodeHeading, Level: int(it.Kind-TokenH1) + 1}, it)
+		b.appendBlock(b.tip)
+	case TokenSetextUnderline:
+		if b.tip == nil || b.tip.Kind != NodeParagraph {
+			b.text(it)
+			break
+		}
+		b.extend(b.tip, it)
+		b.tip.Kind = NodeHeading
+		b.tip.Level = 2
+		if strings.HasPrefix(it.Val, string(setTextHeader1)) {
+			b.tip.Level = 1
+		}
+		b.closeTip()
+		b.newlines = 0
+	case TokenThematicBreak:
+		b.closeAll()
+		marker := strings.Replace(strings.TrimSpace(it.Val), " ", "", -1)
+		b.appendBlock(b.block(Node{Kind: NodeThematicBreak, Literal: marker}, it))
+		b.newlines = 1
+	case TokenBulletItem, TokenOrderedItem:
+		b.closeTip()
+		ordered := it.Kind == TokenOrderedItem
+		if b.list == nil || b.list.Ordered != ordered {
+			b.list = b.block(Node{Kind: NodeList, Ordered: ordered}, it)
+			b.appendBlock(b.list)
+		}
+		b.tip = b.block(Node{Kind: NodeListItem}, it)
+		b.list.AppendChild(b.tip)
+		b.extend(b.tip, it)
+		b.newlines = 0
+	case TokenCodeFence:
+		b.closeAll()
+		b.appendBlock(b.block(Node{Kind: NodeCodeBlock, Info: strings.TrimSpace(it.Val)}, it))
+	case TokenCode:
+		if b.codeToLF {
+			it.Val = toLF.Replace(it.Val)
+		}
+		code := b.container().LastChild()
+		code.Literal = it.Val
+		if b.ranges {
+			b.extend(code, it)
+			b.code = code
+		}
+		b.newlines = 1
+	case TokenBlockQuote:
+		if b.quote == nil {
+			b.closeAll()
+			b.quote = b.block(Node{Kind: NodeBlockQuote}, it)
+			b.flushBlocks(false)
+			b.doc.Root.AppendChild(b.quote)
+		}
+		b.extend(b.quote, it)
+		b.quoted = true
+	case TokenBlock:
+		b.closeAll()
+		for _, in := range it.inlines {
+			parseInlines(in.text, b.base+in.at, b.inline, in.node)
+		}
+		if b.ranges {
+			it.Node.Range = b.span(it)
+		}
+		b.appendBlock(it.Node)
+		b.newlines = 1
+	case TokenError:
+		pos := it.Pos
+		pos.Offset += b.base
+		pos.Line += strings.Count(b.doc.FrontMatter, "\n")
+		return &ParseError{Name: b.doc.Name, Pos: pos, Msg: it.Val}
+	case TokenEOF:
+		b.closeAll()
+		b.flushBlocks(true)
+	}
+	return b.err
+}
+
+// text adds the line of inline content in it to the tip, opening a
+// paragraph if no block is waiting for text
+func (b *builder) text(it Token) {
+	s := it.Val
+	if b.tip == nil {
+		if refDefinition.MatchString(s) { // Already collected, not content
+			return
+		}
+		b.tip = b.block(Node{Kind: NodeParagraph}, it)
+		b.appendBlock(b.tip)
+	} else if b.pending != 0 && len(b.tip.Children) > 0 {
+		b.tip.AppendChild(b.node(Node{Kind: b.pending, Range: b.pendingRange}))
+	}
+	b.extend(b.tip, it)
+	b.pending = 0
+	b.newlines = 0
+	s = strings.TrimLeft(s, " ")
+	// Val ends where the token does, so s starts len(s) before the end
+	at := b.base + it.Pos.Offset + len(it.raw) - len(s)
+	if b.tip.Kind == NodeHeading {
+		s = trimClosingSequence(s)
+	}
+	parseInlines(s, at, b.inline, b.tip)
+}
+
+// node allocates a node set to n in the parse's arena
+func (b *builder) node(n Node) *Node {
+	return b.inline.arena.alloc(n)
+}
+
+// block allocates a block node set to n, starting with it
+func (b *builder) block(n Node, it Token) *Node {
+	if b.ranges {
+		n.Range = b.span(it)
+	}
+	return b.node(n)
+}
+
+// span returns the Range of it, leaving out the line ending it finishes with
+func (b *builder) span(it Token) Range {
+	start := b.base + it.Pos.Offset
+	return Range{start, start + len(strings.TrimRight(it.raw, "\r\n"))}
+}
+
+// closeFence stretches the code block awaiting its closing fence over the
+// line it starts it with, returning it without that line
+func (b *builder) closeFence(it Token) Token {
+	fence := len(it.raw)
+	if i := strings.IndexAny(it.raw, "\r\n"); i >= 0 {
+		fence = i + 1
+		if strings.HasPrefix(it.raw[i:], "\r\n") {
+			fence++
+		}
+	}
+	b.extend(b.code, Token{Pos: it.Pos, raw: it.raw[:fence]})
+	b.code = nil
+	it.Pos.Offset += fence
+	it.raw = it.raw[fence:]
+	return it
+}
+
+// extend stretches n & the blocks containing it to take in it
+func (b *builder) extend(n *Node, it Token) {
+	if b.ranges {
+		b.stretch(n, b.span(it).End)
+	}
+}
+
+// stretch moves the end of n & the blocks containing it up to end
+func (b *builder) stretch(n *Node, end int) {
+	for ; n != nil && n != b.doc.Root; n = n.Parent {
+		if end > n.Range.End {
+			n.Range.End = end
+		}
+	}
+}
+
+// appendBlock adds n to the current container. Blocks the ones before it
+// on the document can no longer change are handed to onBlock first
+func (b *builder) appendBlock(n *Node) {
+	c := b.container()
+	if c == b.doc.Root {
+		b.flushBlocks(false)
+	}
+	c.AppendChild(n)
+	b.stretch(c, n.Range.End)
+}
+
+// flushBlocks hands the document's finished top-level blocks to onBlock &
+// drops them. The blocks from the first one still open onwards are kept,
+// unless all is set
+func (b *builder) flushBlocks(all bool) {
+	if b.onBlock == nil {
+		return
+	}
+	root := b.doc.Root
+	done := len(root.Children)
+	for i, c := range root.Children {
+		if !all && (c == b.list || c == b.quote || c == b.tip) {
+			done = i
+			break
+		}
+		if b.err == nil {
+			b.err = b.onBlock(c)
+		}
+	}
+	n := copy(root.Children, root.Children[done:])
+	for i := n; i < len(root.Children); i++ {
+		root.Children[i] = nil // Let the flushed blocks be collected
+	}
+	root.Children = root.Children[:n]
+}
+
+// container returns the block new blocks are added to. An open block
+// quote only takes blocks that start on a quoted line
+func (b *builder) container() *Node {
+	if b.quote != nil {
+		if b.quoted {
+			return b.quote
+		}
+		b.quote = nil
+	}
+	return b.doc.Root
+}
+
+// closeTip stops adding text to the current block
+func (b *builder) closeTip() {
+	b.tip = nil
+	b.pending = 0
+}
+
+// closeAll closes the current block and any open list
+func (b *builder) closeAll() {
+	b.closeTip()
+	b.list = nil
+}
+
+// trimClosingSequence strips the optional trailing #'s of an ATX header
+func trimClosingSequence(s string) string {
+	s = strings.TrimRight(s, " ")
+	t := strings.TrimRight(s, atxHeader)
+	if t == "" || strings.HasSuffix(t, " ") {
+		return strings.TrimRight(t, " ")
+	}
+	return s
+}
@@ -0,0 +1,28 @@
+package parser_test
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"../bench"
+	"../parser"
+)
+
+var budget = flag.Duration("budget", time.Second, "time each pathological input may take to parse")
+
+// TestPathological parses each pathological input at a size where
+// quadratic behaviour would blow well past the budget
+func TestPathological(t *testing.T) {
+	for _, pc := range bench.Pathological {
+		pc := pc
+		t.Run(pc.Name, func(t *testing.T) {
+			src := pc.Gen(50000)
+			start := time.Now()
+			parser.Parse(pc.Name, src)
+			if took := time.Since(start); took > *budget {
+				t.Errorf("%d bytes took %v, over the budget of %v", len(src), took, *budget)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package parser
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// sanitize replaces NUL bytes with U+FFFD as CommonMark requires, along with
+// each byte of invalid UTF-8, so every rune the lexer decodes is valid and
+// has the width it occupies in the input
+func sanitize(s string) string {
+	if strings.IndexByte(s, 0) < 0 && utf8.ValidString(s) {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s) + 16)
+	for i := 0; i < len(s); {
+		r, w := utf8.DecodeRuneInString(s[i:])
+		if r == 0 || r == utf8.RuneError && w == 1 {
+			b.WriteRune(utf8.RuneError)
+		} else {
+			b.WriteString(s[i : i+w])
+		}
+		i += w
+	}
+	return b.String()
+}
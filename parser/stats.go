@@ -0,0 +1,55 @@
+package parser
+
+import "time"
+
+// Stats describes the work done to parse a single document
+type Stats struct {
+	Name   string
+	Bytes  int // Size of the input, front matter included
+	Tokens int // Tokens lexed
+	Nodes  int // Nodes in the finished document, the root included
+
+	// Time spent on each phase: decoding the input & gathering its front
+	// matter & link definitions, lexing & assembling the nodes, and
+	// running transformers
+	Prepare   time.Duration
+	Parse     time.Duration
+	Transform time.Duration
+}
+
+// Total returns the time taken by all of the phases
+func (s *Stats) Total() time.Duration {
+	return s.Prepare + s.Parse + s.Transform
+}
+
+// StatsHook is told the Stats of every document a Parser produces, so they
+// can be exported as metrics. ParseStats may be called from several
+// goroutines at once when the Parser is shared
+type StatsHook interface {
+	ParseStats(s *Stats)
+}
+
+// WithStats sets the Stats of each Document parsed
+func WithStats() Option {
+	return func(p *Parser) {
+		p.stats = true
+	}
+}
+
+// WithStatsHook passes the Stats of each Document parsed to h, as well as
+// setting them on the Document
+func WithStatsHook(h StatsHook) Option {
+	return func(p *Parser) {
+		p.stats = true
+		p.statsHooks = append(p.statsHooks, h)
+	}
+}
+
+// countNodes returns how many nodes make up the tree under n
+func countNodes(n *Node) int {
+	count := 1
+	for _, c := range n.Children {
+		count += countNodes(c)
+	}
+	return count
+}
@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+)
+
+// StreamChunkSize is how much input ParseStream gathers before it looks for
+// a place to stop and parse what it has
+const StreamChunkSize = 64 << 10
+
+// ParseStream parses markdown read from r with the default configuration,
+// a run of blocks at a time
+func ParseStream(name string, r io.Reader, fn func(*Document) error) error {
+	return defaultParser.ParseStream(name, r, fn)
+}
+
+// ParseStream parses markdown read from r without ever holding all of it in
+// memory. Input is gathered until at least StreamChunkSize has been read and
+// a blank line outside a fenced code block ends the last block, then the
+// blocks so far are parsed & handed to fn as a Document and released.
+// Rendering each one as it arrives keeps memory bounded by the chunk size
+// and the largest single block:
+//
+//	err := p.ParseStream(name, r, func(doc *parser.Document) error {
+//		return html.Render(w, doc.Root)
+//	})
+//
+// Every Document has the same Name & Meta, and its own chunk of the input
+// as Source for WithSourceRanges. A link may use any definition that came
+// before it in the stream, or in the same chunk, but not later ones.
+// Transformers see one chunk at a time, and Stats describe one. Parsing
+// stops at the first error, from r or returned by fn, or once more input
+// has been read than WithMaxInputSize allows
+func (p *Parser) ParseStream(name string, r io.Reader, fn func(*Document) error) error {
+	if p.maxInput > 0 { // Never read more than a byte past the limit
+		r = io.LimitReader(r, int64(p.maxInput)+1)
+	}
+	var (
+		in     = bufio.NewReader(bomReader(r))
+		chunk  strings.Builder
+		meta   map[string]string
+		refs   = p.references("")
+		seen   = make(map[string]bool)
+		first  = true
+		fenced bool
+		total  int
+	)
+	flush := func() error {
+		if chunk.Len() == 0 {
+			return nil
+		}
+		// Chunks only ever end after a '\n', which can't be part of a
+		// multi-byte sequence, so no sequence is split between two
+		start := time.Now()
+		src := sanitize(chunk.String())
+		chunk.Reset()
+		input := src
+		if first {
+			meta, input = p.splitMeta(src)
+		}
+		first = false
+		collectReferences(refs, seen, input)
+		doc, err := p.parse(name, meta, src, input, refs, nil, start)
+		if err != nil {
+			return err
+		}
+		return fn(doc)
+	}
+	for {
+		line, err := in.ReadString('\n')
+		total += len(line)
+		if serr := p.checkSize(name, total); serr != nil {
+			return serr
+		}
+		chunk.WriteString(line)
+		content := strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(strings.TrimLeft(content, " "), codeFence) {
+			fenced = !fenced
+		}
+		if content == "" && line != "" && !fenced && chunk.Len() >= StreamChunkSize {
+			if ferr := flush(); ferr != nil {
+				return ferr
+			}
+		}
+		if err == io.EOF {
+			return flush()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package parser
+
+import "fmt"
+
+// TokenKind identifies what a Token is
+type TokenKind int
+
+const (
+	TokenText            TokenKind = iota // Line of text
+	TokenBlockQuote                       // '>' starting a quoted line
+	TokenBulletItem                       // Marker of a bullet list item
+	TokenOrderedItem                      // Marker of an ordered list item
+	TokenCodeFence                        // Info string of a fenced code block
+	TokenCode                             // Contents of a fenced code block, as written
+	TokenThematicBreak                    // Thematic break, Val holding its marker
+	TokenSetextUnderline                  // Line of '=' or '-' under a settext heading
+	TokenH1                               // Start of an ATX heading of each level
+	TokenH2
+	TokenH3
+	TokenH4
+	TokenH5
+	TokenH6
+	TokenEOF
+	TokenNewLine     // Line ending
+	TokenHardNewLine // Line ending preceded by two spaces
+	TokenBlock       // Node built by a custom block parser
+	TokenError       // Lexing failed, Val holding why
+)
+
+var tokenKindNames = []string{
+	TokenText:            "Text",
+	TokenBlockQuote:      "BlockQuote",
+	TokenBulletItem:      "BulletItem",
+	TokenOrderedItem:     "OrderedItem",
+	TokenCodeFence:       "CodeFence",
+	TokenCode:            "Code",
+	TokenThematicBreak:   "ThematicBreak",
+	TokenSetextUnderline: "SetextUnderline",
+	TokenH1:              "H1",
+	TokenH2:              "H2",
+	TokenH3:              "H3",
+	TokenH4:              "H4",
+	TokenH5:              "H5",
+	TokenH6:              "H6",
+	TokenEOF:             "EOF",
+	TokenNewLine:         "NewLine",
+	TokenHardNewLine:     "HardNewLine",
+	TokenBlock:           "Block",
+	TokenError:           "Error",
+}
+
+func (k TokenKind) String() string {
+	if k >= 0 && int(k) < len(tokenKindNames) {
+		return tokenKindNames[k]
+	}
+	return fmt.Sprintf("TokenKind(%d)", int(k))
+}
+
+// Position is a place in the input. Line & Column count from 1, and Column
+// counts bytes
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Token is a single piece of lexed markdown
+type Token struct {
+	Kind TokenKind
+	Pos  Position // Where the token's Raw text starts
+	Val  string   // Text the parser works from, without markers & line endings
+	Node *Node    // Set for TokenBlock
+
+	raw     string
+	inlines []pendingInline // Of a TokenBlock's Node, to parse
+}
+
+// Raw returns the exact input the token covers, including the markers &
+// whitespace left out of Val. The Raw text of every token of an input, in
+// order, adds up to the input
+func (t Token) Raw() string {
+	return t.raw
+}
+
+func (t Token) String() string {
+	switch {
+	case t.Kind == TokenEOF:
+		return "EOF"
+	case t.Kind == TokenError:
+		return t.Val
+	case t.Kind == TokenHardNewLine:
+		return "Hard return"
+	case t.Kind == TokenNewLine:
+		return "Soft return"
+	case t.Kind == TokenText:
+		return fmt.Sprintf("Text: %q", t.Val)
+	case t.Kind == TokenBulletItem:
+		return "UL Item: " + t.Val
+	case t.Kind >= TokenH1 && t.Kind <= TokenH6:
+		return fmt.Sprintf("Header H%v", t.Kind-TokenH1+1)
+	}
+	return fmt.Sprintf("%q", t.Val)
+}
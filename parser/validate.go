@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ProblemKind is a kind of Problem Validate or MissingAltText finds
+type ProblemKind int
+
+const (
+	SkippedLevel   ProblemKind = iota + 1 // A heading more than one level deeper than the one before it
+	ExtraTitle                            // A level 1 heading after the first
+	EmptyHeading                          // A heading with no text
+	MissingAltText                        // An image with no alt text
+)
+
+// Problem is something wrong with the structure of a document
+type Problem struct {
+	Kind ProblemKind
+	Node *Node    // The node it's found at
+	Pos  Position // Where Node starts, if the document was parsed with source ranges
+	Msg  string
+}
+
+// ValidationError is returned by Validate, listing the problems found
+type ValidationError struct {
+	Name     string
+	Problems []Problem // In order of position
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		if p.Pos.Line > 0 {
+			lines[i] = fmt.Sprintf("%s:%v: %s", e.Name, p.Pos, p.Msg)
+		} else {
+			lines[i] = fmt.Sprintf("%s: %s", e.Name, p.Msg)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Validate checks that the document's headings, those its Outline is made
+// of, form a consistent hierarchy: that none skips a level below the one
+// before it, that there's only one level 1 heading, and that none is
+// empty. It returns a *ValidationError listing what's wrong, or nil
+func (d *Document) Validate() error {
+	var problems []Problem
+	report := func(kind ProblemKind, n *Node, format string, args ...interface{}) {
+		problems = append(problems, d.problem(kind, n, format, args...))
+	}
+	var prev, title *Node
+	for _, n := range d.Root.Children {
+		if n.Kind != NodeHeading {
+			continue
+		}
+		if strings.TrimSpace(n.PlainText()) == "" {
+			report(EmptyHeading, n, "empty level %d heading", n.Level)
+		}
+		if prev != nil && n.Level > prev.Level+1 {
+			report(SkippedLevel, n, "heading level %d follows level %d; expected level %d", n.Level, prev.Level, prev.Level+1)
+		}
+		if n.Level == 1 {
+			if title != nil {
+				report(ExtraTitle, n, "more than one level 1 heading; the first is %q", title.PlainText())
+			} else {
+				title = n
+			}
+		}
+		prev = n
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Name: d.Name, Problems: problems}
+}
+
+// MissingAltText returns a Problem for each image in the document without
+// alt text, which leaves readers who can't see it with nothing to go on,
+// in order
+func (d *Document) MissingAltText() []Problem {
+	var problems []Problem
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		if n.Kind == NodeImage && strings.TrimSpace(n.PlainText()) == "" {
+			problems = append(problems, d.problem(MissingAltText, n, "image %s has no alt text", n.Dest))
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(d.Root)
+	return problems
+}
+
+// problem returns a Problem of kind at n, placed if d has source ranges
+func (d *Document) problem(kind ProblemKind, n *Node, format string, args ...interface{}) Problem {
+	p := Problem{Kind: kind, Node: n, Msg: fmt.Sprintf(format, args...)}
+	if n.Range.End > 0 && n.Range.Start <= len(d.Source) {
+		p.Pos = d.Position(n.Range.Start)
+	}
+	return p
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"./parser"
+	"./render"
+)
+
+// pluginProtocol is the version of the protocol plugins are spoken to in
+const pluginProtocol = 1
+
+// plugin is a program extending gomd, started once & sent every document
+// parsed, in turn, to rewrite. Each is a line of JSON on its standard
+// input, {"protocol": 1, "document": {...}}, the document as -to json
+// writes it, to which it answers with a line on its standard output,
+// {"document": {...}} holding the document as it should be, or
+// {"error": "why"}. What it writes to standard error is passed on
+type plugin struct {
+	path    string
+	timeout time.Duration // Longest a document may be with it, if not 0
+
+	mu     sync.Mutex // Held while a document is with the plugin
+	cmd    *exec.Cmd  // nil once it's been killed & couldn't be restarted
+	in     io.WriteCloser
+	out    *bufio.Reader
+	failed int // Documents it couldn't rewrite
+	closed bool
+}
+
+// pluginRequest is what a plugin is sent for each document
+type pluginRequest struct {
+	Protocol int             `json:"protocol"`
+	Document json.RawMessage `json:"document"`
+}
+
+// pluginResponse is what a plugin answers with
+type pluginResponse struct {
+	Document json.RawMessage `json:"document"`
+	Error    string          `json:"error"`
+}
+
+// interpreters run plugins written as scripts, by extension
+var interpreters = map[string]string{
+	".lua": "lua",
+	".py":  "python3",
+	".js":  "node",
+	".rb":  "ruby",
+}
+
+// scriptExts lists the extensions of scripts interpreters run, as prose
+func scriptExts() string {
+	var exts []string
+	for ext := range interpreters {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return "a " + strings.Join(exts[:len(exts)-1], ", ") + " or " + exts[len(exts)-1] + " file"
+}
+
+// startPlugins starts the plugins at paths, scripts with their language's
+// interpreter and anything else as a program. Any taking longer than
+// timeout, if it isn't 0, over a document is killed & started again
+func startPlugins(paths []string, timeout time.Duration) ([]*plugin, error) {
+	var plugins []*plugin
+	for _, path := range paths {
+		pl := &plugin{path: path, timeout: timeout}
+		if err := pl.start(); err != nil {
+			closePlugins(plugins)
+			return nil, fmt.Errorf("plugin %s: %v", path, err)
+		}
+		plugins = append(plugins, pl)
+	}
+	return plugins, nil
+}
+
+// start starts the plugin's program
+func (pl *plugin) start() error {
+	cmd := exec.Command(pl.path)
+	if interp, ok := interpreters[strings.ToLower(filepath.Ext(pl.path))]; ok {
+		cmd = exec.Command(interp, pl.path)
+	}
+	cmd.Stderr = os.Stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	pl.cmd, pl.in, pl.out = cmd, in, bufio.NewReader(out)
+	return nil
+}
+
+// restart kills the plugin, stuck on a document, & starts it again for
+// the next, returning why the document wasn't rewritten
+func (pl *plugin) restart() error {
+	pl.cmd.Process.Kill()
+	pl.cmd.Wait()
+	if err := pl.start(); err != nil {
+		pl.cmd = nil
+		return fmt.Errorf("took over %v, so was killed, and couldn't be restarted: %v", pl.timeout, err)
+	}
+	return fmt.Errorf("took over %v, so was restarted", pl.timeout)
+}
+
+// closePlugins stops the plugins, returning an error if any failed. Those
+// already stopped are left alone
+func closePlugins(plugins []*plugin) error {
+	var errs []error
+	for _, pl := range plugins {
+		if pl.closed {
+			continue
+		}
+		pl.closed = true
+		if pl.cmd == nil {
+			errs = append(errs, fmt.Errorf("plugin %s stopped, failing on %s", pl.path, plural(pl.failed, "file")))
+			continue
+		}
+		pl.in.Close()
+		if err := pl.cmd.Wait(); err != nil {
+			errs = append(errs, fmt.Errorf("plugin %s: %v", pl.path, err))
+		} else if pl.failed > 0 {
+			errs = append(errs, fmt.Errorf("plugin %s failed on %s", pl.path, plural(pl.failed, "file")))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// pluginExtensions returns plugins as parser extensions
+func pluginExtensions(plugins []*plugin) []parser.Extension {
+	var exts []parser.Extension
+	for _, pl := range plugins {
+		exts = append(exts, pl)
+	}
+	return exts
+}
+
+func (pl *plugin) Extend(p *parser.Parser) {
+	p.AddTransformer(parser.TransformerFunc(func(doc *parser.Document) {
+		pl.mu.Lock()
+		defer pl.mu.Unlock()
+		if err := pl.rewrite(doc); err != nil {
+			logger.Error(fmt.Sprintf("%s: plugin %s: %v", doc.Name, pl.path, err), "file", doc.Name, "plugin", pl.path, "err", err)
+			pl.failed++
+		}
+	}))
+}
+
+// rewrite has the plugin rewrite doc, leaving it as it is if that fails
+func (pl *plugin) rewrite(doc *parser.Document) error {
+	if pl.cmd == nil {
+		return errors.New("isn't running")
+	}
+	var b bytes.Buffer
+	if err := render.NewJSONRenderer(render.WithMinify()).RenderDocument(&b, doc); err != nil {
+		return err
+	}
+	req, err := json.Marshal(&pluginRequest{Protocol: pluginProtocol, Document: bytes.TrimSpace(b.Bytes())})
+	if err != nil {
+		return err
+	}
+	line, err := pl.exchange(req)
+	if err != nil {
+		return err
+	}
+	var resp pluginResponse
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return fmt.Errorf("bad answer: %v", err)
+	}
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+	rewritten, err := render.DecodeJSON(bytes.NewReader(resp.Document))
+	if err != nil {
+		return fmt.Errorf("bad document: %v", err)
+	}
+	doc.Root, doc.Meta = rewritten.Root, rewritten.Meta
+	return nil
+}
+
+// exchange sends the plugin the request req, returning its answer. One
+// not answered within the timeout has the plugin restarted
+func (pl *plugin) exchange(req []byte) ([]byte, error) {
+	if pl.timeout == 0 {
+		return roundTrip(pl.in, pl.out, req)
+	}
+	type answer struct {
+		line []byte
+		err  error
+	}
+	answered := make(chan answer, 1)
+	in, out := pl.in, pl.out
+	go func() {
+		line, err := roundTrip(in, out, req)
+		answered <- answer{line, err}
+	}()
+	timer := time.NewTimer(pl.timeout)
+	defer timer.Stop()
+	select {
+	case a := <-answered:
+		return a.line, a.err
+	case <-timer.C:
+		return nil, pl.restart()
+	}
+}
+
+// roundTrip writes the request req, a line, to in & reads the line
+// answering it from out
+func roundTrip(in io.Writer, out *bufio.Reader, req []byte) ([]byte, error) {
+	if _, err := in.Write(append(req, '\n')); err != nil {
+		return nil, err
+	}
+	line, err := out.ReadBytes('\n')
+	if err != nil {
+		if err == io.EOF {
+			return nil, errors.New("exited without answering")
+		}
+		return nil, err
+	}
+	return line, nil
+}
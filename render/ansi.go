@@ -0,0 +1,145 @@
+package render
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+
+	"../parser"
+)
+
+// ANSI escape sequences, each style with the sequence turning it off
+// again, so styles can nest
+const (
+	ansiBold       = "\x1b[1m"
+	ansiBoldOff    = "\x1b[22m"
+	ansiDim        = "\x1b[2m"
+	ansiItalic     = "\x1b[3m"
+	ansiItalicOff  = "\x1b[23m"
+	ansiUnderline  = "\x1b[4m"
+	ansiUnderOff   = "\x1b[24m"
+	ansiHeading    = "\x1b[35m" // Magenta
+	ansiCode       = "\x1b[36m" // Cyan
+	ansiLink       = "\x1b[34m" // Blue
+	ansiDefaultFg  = "\x1b[39m"
+	ansiResetStyle = "\x1b[0m"
+)
+
+// ANSIRenderer writes a node tree out as text styled with ANSI escape
+// sequences, for reading in a terminal: headings, emphasis & links in
+// bold, italics & colour, code in colour, and quotes & lists indented
+type ANSIRenderer struct {
+	cfg Config
+}
+
+// NewANSIRenderer returns a terminal renderer configured by opts
+func NewANSIRenderer(opts ...Option) *ANSIRenderer {
+	return &ANSIRenderer{cfg: newConfig(FormatANSI, opts)}
+}
+
+// Render writes n to w styled for a terminal
+func (r *ANSIRenderer) Render(w io.Writer, n *parser.Node) error {
+	w, done := r.cfg.track(w, n)
+	defer done()
+	b := getBuffer()
+	defer putBuffer(b)
+	r.renderNode(b, n)
+	return r.cfg.writeTrimmed(w, b)
+}
+
+func (r *ANSIRenderer) renderChildren(b *bytes.Buffer, n *parser.Node) {
+	for _, c := range n.Children {
+		r.renderNode(b, c)
+	}
+}
+
+// renderIndented renders the children of n with each line prefixed by
+// rest, but the first, which is prefixed by first
+func (r *ANSIRenderer) renderIndented(b *bytes.Buffer, n *parser.Node, first, rest string) {
+	var inner bytes.Buffer
+	r.renderChildren(&inner, n)
+	lines := strings.Split(strings.TrimRight(inner.String(), "\n"), "\n")
+	for i, line := range lines {
+		prefix := rest
+		if i == 0 {
+			prefix = first
+		}
+		if line == "" {
+			prefix = strings.TrimRight(prefix, " ")
+		}
+		b.WriteString(prefix + line + "\n")
+	}
+}
+
+func (r *ANSIRenderer) renderNode(b *bytes.Buffer, n *parser.Node) {
+	if r.cfg.skip[n.Kind] {
+		return
+	}
+	if h := r.cfg.hooks[n.Kind]; h != nil {
+		h(b, n, true)
+		r.renderChildren(b, n)
+		h(b, n, false)
+		return
+	}
+	switch n.Kind {
+	case parser.NodeDocument:
+		r.renderChildren(b, n)
+	case parser.NodeHeading:
+		b.WriteString(ansiBold + ansiHeading)
+		if n.Level == 1 {
+			b.WriteString(ansiUnderline)
+		} else {
+			b.WriteString(strings.Repeat("#", n.Level) + " ")
+		}
+		r.renderChildren(b, n)
+		b.WriteString(ansiResetStyle + "\n\n")
+	case parser.NodeParagraph:
+		r.renderChildren(b, n)
+		b.WriteString("\n\n")
+	case parser.NodeBlockQuote:
+		r.renderIndented(b, n, ansiDim+"│"+ansiResetStyle+" ", ansiDim+"│"+ansiResetStyle+" ")
+		b.WriteString("\n")
+	case parser.NodeList:
+		r.renderChildren(b, n)
+		b.WriteString("\n")
+	case parser.NodeListItem:
+		marker := "• "
+		if n.Parent != nil && n.Parent.Ordered {
+			marker = strconv.Itoa(itemNumber(n)) + ". "
+		}
+		r.renderIndented(b, n, ansiBold+marker+ansiBoldOff, strings.Repeat(" ", len([]rune(marker))))
+	case parser.NodeCodeBlock:
+		for _, line := range strings.Split(strings.TrimSuffix(n.Literal, "\n"), "\n") {
+			b.WriteString("    " + ansiCode + line + ansiDefaultFg + "\n")
+		}
+		b.WriteString("\n")
+	case parser.NodeThematicBreak:
+		b.WriteString(ansiDim + strings.Repeat("─", 40) + ansiResetStyle + "\n\n")
+	case parser.NodeText:
+		b.WriteString(n.Literal)
+	case parser.NodeCodeSpan:
+		b.WriteString(ansiCode + n.Literal + ansiDefaultFg)
+	case parser.NodeEmphasis:
+		b.WriteString(ansiItalic)
+		r.renderChildren(b, n)
+		b.WriteString(ansiItalicOff)
+	case parser.NodeStrong:
+		b.WriteString(ansiBold)
+		r.renderChildren(b, n)
+		b.WriteString(ansiBoldOff)
+	case parser.NodeLink:
+		b.WriteString(ansiLink + ansiUnderline)
+		r.renderChildren(b, n)
+		b.WriteString(ansiUnderOff + ansiDefaultFg)
+		if text := n.PlainText(); n.Dest != "" && text != n.Dest && "mailto:"+text != n.Dest {
+			b.WriteString(" " + ansiDim + "(" + n.Dest + ")" + ansiResetStyle)
+		}
+	case parser.NodeImage:
+		b.WriteString(ansiDim + "[image: " + n.PlainText() + "]" + ansiResetStyle)
+	case parser.NodeSoftBreak, parser.NodeHardBreak:
+		b.WriteString("\n")
+	default:
+		r.renderChildren(b, n)
+	}
+}
@@ -0,0 +1,159 @@
+package render
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"../parser"
+)
+
+// asciiDocEscaper writes the characters AsciiDoc reads as inline markup,
+// formatting marks, attribute references & macros among them, as the
+// character references Asciidoctor passes through
+var asciiDocEscaper = strings.NewReplacer(
+	"*", "&#42;",
+	"_", "&#95;",
+	"`", "&#96;",
+	"#", "&#35;",
+	"^", "&#94;",
+	"~", "&#126;",
+	"+", "&#43;",
+	"{", "&#123;",
+	"}", "&#125;",
+	"[", "&#91;",
+	"]", "&#93;",
+	"<", "&#60;",
+)
+
+// asciiDocBlockStart matches text that would start a block at the start
+// of a line: a block title, heading, list, comment, table or attribute
+// entry. The last character matched is the one escaped
+var asciiDocBlockStart = regexp.MustCompile(`^(?:[.=\-/|:'>]|[0-9]+\.)`)
+
+// asciiDocText writes s to b, escaped so that it reads as text wherever it
+// falls
+func asciiDocText(b *bytes.Buffer, s string) {
+	s = asciiDocEscaper.Replace(s)
+	if b.Len() == 0 || b.Bytes()[b.Len()-1] == '\n' {
+		if m := asciiDocBlockStart.FindStringIndex(s); m != nil {
+			i := m[1] - 1
+			b.WriteString(s[:i] + "&#" + strconv.Itoa(int(s[i])) + ";")
+			s = s[i+1:]
+		}
+	}
+	b.WriteString(s)
+}
+
+// AsciiDocRenderer writes a node tree out as AsciiDoc, as understood by
+// Asciidoctor & Antora
+type AsciiDocRenderer struct {
+	cfg Config
+}
+
+// NewAsciiDocRenderer returns an AsciiDoc renderer configured by opts
+func NewAsciiDocRenderer(opts ...Option) *AsciiDocRenderer {
+	return &AsciiDocRenderer{cfg: newConfig(FormatAsciiDoc, opts)}
+}
+
+// Render writes n to w as AsciiDoc
+func (r *AsciiDocRenderer) Render(w io.Writer, n *parser.Node) error {
+	w, done := r.cfg.track(w, n)
+	defer done()
+	b := getBuffer()
+	defer putBuffer(b)
+	r.renderNode(b, n)
+	return r.cfg.writeTrimmed(w, b)
+}
+
+func (r *AsciiDocRenderer) renderChildren(b *bytes.Buffer, n *parser.Node) {
+	for _, c := range n.Children {
+		r.renderNode(b, c)
+	}
+}
+
+func (r *AsciiDocRenderer) renderNode(b *bytes.Buffer, n *parser.Node) {
+	if r.cfg.skip[n.Kind] {
+		return
+	}
+	if h := r.cfg.hooks[n.Kind]; h != nil {
+		h(b, n, true)
+		r.renderChildren(b, n)
+		h(b, n, false)
+		return
+	}
+	switch n.Kind {
+	case parser.NodeDocument:
+		r.renderChildren(b, n)
+	case parser.NodeParagraph:
+		r.renderChildren(b, n)
+		b.WriteString("\n\n")
+	case parser.NodeHeading:
+		b.WriteString(strings.Repeat("=", n.Level) + " ")
+		r.renderChildren(b, n)
+		b.WriteString("\n\n")
+	case parser.NodeThematicBreak:
+		b.WriteString("'''\n\n")
+	case parser.NodeList:
+		r.renderChildren(b, n)
+		b.WriteString("\n")
+	case parser.NodeListItem:
+		b.WriteString(listMarkers(n, "*", ".") + " ")
+		r.renderChildren(b, n)
+		b.WriteString("\n")
+	case parser.NodeCodeBlock:
+		if lang := strings.Fields(n.Info); len(lang) > 0 {
+			b.WriteString("[source," + lang[0] + "]\n")
+		}
+		b.WriteString("----\n" + n.Literal)
+		if !strings.HasSuffix(n.Literal, "\n") {
+			b.WriteString("\n")
+		}
+		b.WriteString("----\n\n")
+	case parser.NodeBlockQuote:
+		b.WriteString("____\n")
+		var inner bytes.Buffer
+		r.renderChildren(&inner, n)
+		b.WriteString(strings.TrimRight(inner.String(), "\n"))
+		b.WriteString("\n____\n\n")
+	case parser.NodeText:
+		if inTableCell(n) {
+			asciiDocText(b, strings.Replace(n.Literal, "|", `\|`, -1))
+		} else {
+			asciiDocText(b, n.Literal)
+		}
+	case parser.NodeSoftBreak:
+		b.WriteString("\n")
+	case parser.NodeHardBreak:
+		b.WriteString(" +\n")
+	case parser.NodeEmphasis:
+		b.WriteString("_")
+		r.renderChildren(b, n)
+		b.WriteString("_")
+	case parser.NodeStrong:
+		b.WriteString("*")
+		r.renderChildren(b, n)
+		b.WriteString("*")
+	case parser.NodeCodeSpan:
+		if strings.Contains(n.Literal, "+") { // Would end the +passthrough+
+			b.WriteString("`pass:c[" + strings.Replace(n.Literal, "]", `\]`, -1) + "]`")
+			break
+		}
+		b.WriteString("`+" + n.Literal + "+`")
+	case parser.NodeLink:
+		b.WriteString("link:" + n.Dest + "[")
+		var text bytes.Buffer
+		r.renderChildren(&text, n)
+		b.WriteString(strings.Replace(text.String(), "]", `\]`, -1) + "]")
+	case parser.NodeImage:
+		b.WriteString("image:" + n.Dest + "[" + strings.Replace(n.PlainText(), "]", `\]`, -1) + "]")
+	case parser.NodeFootnoteRef:
+		if note := footnoteOf(n); note != nil {
+			var text bytes.Buffer
+			r.renderChildren(&text, note)
+			b.WriteString("footnote:[" + strings.Replace(text.String(), "]", `\]`, -1) + "]")
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package render_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"../parser"
+	"../render"
+)
+
+func TestAsciiDocEscaping(t *testing.T) {
+	for md, want := range map[string]string{
+		"\\*x\\* and {name}\n":  "&#42;x&#42; and &#123;name&#125;",
+		"a `+` b\\[c\\]\n":      "a `pass:c[+]` b&#91;c&#93;",
+		"`x[0]`\n":              "`+x[0]+`",
+		".title\n":              "&#46;title",
+		"foo\n\\- not a list\n": "foo\n&#45; not a list",
+		"1\\. not a list\n":     "1&#46; not a list",
+		"[a \\] *b*](u)\n":      "link:u[a &#93; _b_]",
+		"plain *text*\n":        "plain _text_",
+	} {
+		doc, err := parser.Parse("test", md)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := render.NewAsciiDocRenderer().Render(&b, doc.Root); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSpace(b.String()); got != want {
+			t.Errorf("%q rendered as\n%s\nnot\n%s", md, got, want)
+		}
+	}
+}
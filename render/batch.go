@@ -0,0 +1,131 @@
+//go:build !js
+
+package render
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"../parser"
+)
+
+// Batch converts many files concurrently. The zero value is not usable,
+// Renderer and Dest must be set. It works on files, so isn't available
+// when building for js/wasm
+type Batch struct {
+	Parser   *parser.Parser // Parser to use, the default configuration if nil
+	Renderer Renderer
+	Dest     func(path string) string // Where the output for path is written
+	FS       fs.FS                    // Where paths & what they include are read from, if not the OS's files
+	Workers  int                      // Files converted at once, GOMAXPROCS if 0
+}
+
+// FileError records why a single file in a Batch could not be converted
+type FileError struct {
+	Path string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// BatchError lists every file a Batch failed to convert, in the order the
+// files were given
+type BatchError []*FileError
+
+func (e BatchError) Error() string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "%d files failed to convert:", len(e))
+	for _, fe := range e {
+		s.WriteString("\n\t" + fe.Error())
+	}
+	return s.String()
+}
+
+// Convert parses & renders each of paths, with the files they include as
+// Parser.ParseFS reads them, writing the results to the files Dest names
+// for them. Every file is attempted even if others fail; failures are
+// returned together as a BatchError
+func (b *Batch) Convert(paths []string) error {
+	workers := b.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	p := b.Parser
+	if p == nil {
+		p = parser.New()
+	}
+
+	errs := make([]error, len(paths))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				errs[i] = b.convert(p, paths[i])
+			}
+		}()
+	}
+	for i := range paths {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	var failed BatchError
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, &FileError{Path: paths[i], Err: err})
+		}
+	}
+	if len(failed) > 0 {
+		return failed
+	}
+	return nil
+}
+
+// convert parses & renders a single file
+func (b *Batch) convert(p *parser.Parser, path string) error {
+	dest := b.Dest(path)
+	if dest == path {
+		return errors.New("output would overwrite the input")
+	}
+	fsys := b.FS
+	if fsys == nil {
+		fsys = parser.OSFiles
+	}
+	doc, err := p.ParseFS(fsys, filepath.ToSlash(path))
+	if err != nil {
+		return err
+	}
+	out := getBuffer()
+	defer putBuffer(out)
+	if err := RenderDocument(b.Renderer, out, doc); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dest, out.Bytes(), 0644)
+}
+
+// ReplaceExt returns a Dest function writing each file's output beside it,
+// with its extension replaced by ext
+func ReplaceExt(ext string) func(string) string {
+	return func(path string) string {
+		if i := strings.LastIndexByte(path, '.'); i > strings.LastIndexAny(path, `/\`) {
+			path = path[:i]
+		}
+		return path + ext
+	}
+}
@@ -0,0 +1,132 @@
+package render
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"../parser"
+)
+
+// bbCodeHeadingSizes are the [size] percentages used for heading levels 1-6
+var bbCodeHeadingSizes = [...]string{"200", "170", "150", "130", "115", "100"}
+
+// bbCodeText keeps any tags in text from being read as such, within
+// [noparse]. A [/noparse] in s is ended before, & its [ written in a
+// [noparse] of its own
+func bbCodeText(s string) string {
+	if !strings.Contains(s, "[") {
+		return s
+	}
+	s = strings.Replace(s, "[/noparse]", "[/noparse][noparse][[/noparse][noparse]/noparse]", -1)
+	return "[noparse]" + s + "[/noparse]"
+}
+
+// bbCodeCode writes code as a [code] block. [code] is only ended by
+// [/code], so code holding one is split around it
+func bbCodeCode(code string) string {
+	return "[code]" + strings.Replace(code, "[/code]", "[/code][noparse][/code][/noparse][code]", -1) + "[/code]"
+}
+
+// BBCodeRenderer writes a node tree out as forum BBCode
+type BBCodeRenderer struct {
+	cfg Config
+}
+
+// NewBBCodeRenderer returns a BBCode renderer configured by opts
+func NewBBCodeRenderer(opts ...Option) *BBCodeRenderer {
+	return &BBCodeRenderer{cfg: newConfig(FormatBBCode, opts)}
+}
+
+// Render writes n to w as BBCode
+func (r *BBCodeRenderer) Render(w io.Writer, n *parser.Node) error {
+	w, done := r.cfg.track(w, n)
+	defer done()
+	b := getBuffer()
+	defer putBuffer(b)
+	r.renderNode(b, n)
+	return r.cfg.writeTrimmed(w, b)
+}
+
+func (r *BBCodeRenderer) renderChildren(b *bytes.Buffer, n *parser.Node) {
+	for _, c := range n.Children {
+		r.renderNode(b, c)
+	}
+}
+
+func (r *BBCodeRenderer) renderNode(b *bytes.Buffer, n *parser.Node) {
+	if r.cfg.skip[n.Kind] {
+		return
+	}
+	if h := r.cfg.hooks[n.Kind]; h != nil {
+		h(b, n, true)
+		r.renderChildren(b, n)
+		h(b, n, false)
+		return
+	}
+	switch n.Kind {
+	case parser.NodeDocument:
+		r.renderChildren(b, n)
+	case parser.NodeParagraph:
+		r.renderChildren(b, n)
+		b.WriteString("\n\n")
+	case parser.NodeHeading:
+		level := n.Level
+		if level < 1 {
+			level = 1
+		} else if level > len(bbCodeHeadingSizes) {
+			level = len(bbCodeHeadingSizes)
+		}
+		b.WriteString("[size=" + bbCodeHeadingSizes[level-1] + "][b]")
+		r.renderChildren(b, n)
+		b.WriteString("[/b][/size]\n\n")
+	case parser.NodeThematicBreak:
+		b.WriteString("[hr]\n\n")
+	case parser.NodeList:
+		if n.Ordered {
+			b.WriteString("[list=1]\n")
+		} else {
+			b.WriteString("[list]\n")
+		}
+		r.renderChildren(b, n)
+		b.WriteString("[/list]\n\n")
+	case parser.NodeListItem:
+		b.WriteString("[*]")
+		r.renderChildren(b, n)
+		b.WriteString("\n")
+	case parser.NodeCodeBlock:
+		b.WriteString(bbCodeCode(n.Literal) + "\n\n")
+	case parser.NodeBlockQuote:
+		b.WriteString("[quote]")
+		var inner bytes.Buffer
+		r.renderChildren(&inner, n)
+		b.WriteString(strings.TrimRight(inner.String(), "\n"))
+		b.WriteString("[/quote]\n\n")
+	case parser.NodeText:
+		b.WriteString(bbCodeText(n.Literal))
+	case parser.NodeSoftBreak:
+		b.WriteString(" ")
+	case parser.NodeHardBreak:
+		b.WriteString("\n")
+	case parser.NodeEmphasis:
+		b.WriteString("[i]")
+		r.renderChildren(b, n)
+		b.WriteString("[/i]")
+	case parser.NodeStrong:
+		b.WriteString("[b]")
+		r.renderChildren(b, n)
+		b.WriteString("[/b]")
+	case parser.NodeCodeSpan:
+		b.WriteString("[font=monospace]" + bbCodeText(n.Literal) + "[/font]")
+	case parser.NodeLink:
+		if n.PlainText() == n.Dest {
+			b.WriteString("[url]" + n.Dest + "[/url]")
+			break
+		}
+		b.WriteString("[url=" + n.Dest + "]")
+		r.renderChildren(b, n)
+		b.WriteString("[/url]")
+	case parser.NodeImage:
+		b.WriteString("[img]" + n.Dest + "[/img]")
+	}
+}
@@ -0,0 +1,49 @@
+package render_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"../parser"
+	"../render"
+)
+
+func TestBBCodeEscaping(t *testing.T) {
+	for md, want := range map[string]string{
+		"see \\[b]x\n":               "[noparse]see [b]x[/noparse]",
+		"a [/noparse] b\n":           "[noparse]a [/noparse][noparse][[/noparse][noparse]/noparse] b[/noparse]",
+		"`[i]`\n":                    "[font=monospace][noparse][i][/noparse][/font]",
+		"```\nA [/code] B\n```\n":    "[code]A [/code][noparse][/code][/noparse][code] B\n[/code]",
+		"no tags, just *emphasis*\n": "no tags, just [i]emphasis[/i]",
+	} {
+		doc, err := parser.Parse("test", md)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := render.NewBBCodeRenderer().Render(&b, doc.Root); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSpace(b.String()); got != want {
+			t.Errorf("%q rendered as\n%s\nnot\n%s", md, got, want)
+		}
+	}
+}
+
+// TestBBCodeHeadingLevels checks levels past those BBCode has sizes for,
+// which extensions may make, are clamped
+func TestBBCodeHeadingLevels(t *testing.T) {
+	for level, size := range map[int]string{0: "200", 1: "200", 6: "100", 9: "100"} {
+		h := parser.NewNode(parser.NodeHeading)
+		h.Level = level
+		h.AppendChild(&parser.Node{Kind: parser.NodeText, Literal: "h"})
+		var b bytes.Buffer
+		if err := render.NewBBCodeRenderer().Render(&b, h); err != nil {
+			t.Fatal(err)
+		}
+		if want := "[size=" + size + "][b]h[/b][/size]"; strings.TrimSpace(b.String()) != want {
+			t.Errorf("level %d rendered as %q, not %q", level, b.String(), want)
+		}
+	}
+}
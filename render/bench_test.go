@@ -0,0 +1,29 @@
+package render_test
+
+import (
+	"bytes"
+	"testing"
+
+	"../bench"
+	"../parser"
+	"../render"
+)
+
+func BenchmarkRenderHTML(b *testing.B) {
+	html := render.NewHTMLRenderer()
+	for _, in := range bench.Inputs() {
+		doc, err := parser.Parse(in.Name, in.Src)
+		if err != nil {
+			b.Fatalf("%s: %v", in.Name, err)
+		}
+		b.Run(in.Name, func(b *testing.B) {
+			var out bytes.Buffer
+			b.ReportAllocs()
+			b.SetBytes(int64(len(in.Src)))
+			for i := 0; i < b.N; i++ {
+				out.Reset()
+				html.Render(&out, doc.Root)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package render_test
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+
+	"../parser"
+	"../render"
+	"../spec"
+)
+
+// TestConcurrentUse parses & renders from many goroutines through one
+// Parser & one renderer of each format, as a server would, checking each
+// gets what it would alone. Run it with -race
+func TestConcurrentUse(t *testing.T) {
+	p := parser.New(parser.WithExtensions(append([]parser.Extension{parser.InlineFootnotes, parser.CriticMarkup}, parser.DefaultExtensions...)...))
+	formats := []string{
+		render.FormatHTML, render.FormatMarkdown, render.FormatLaTeX, render.FormatMan, render.FormatJSON,
+		render.FormatText, render.FormatDocBook, render.FormatAsciiDoc, render.FormatBBCode, render.FormatJira,
+	}
+	renderers := make([]render.Renderer, len(formats))
+	for i, format := range formats {
+		r, err := render.NewRenderer(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		renderers[i] = r
+	}
+	srcs := []string{"note^[with *em*] {++in++}{--out--}{~~a~>b~~}{>>why<<}\n"}
+	for _, ex := range spec.Examples {
+		srcs = append(srcs, ex.Markdown)
+	}
+	convert := func(src string, r render.Renderer) (string, error) {
+		doc, err := p.Parse("test", src)
+		if err != nil {
+			return "", err
+		}
+		var b bytes.Buffer
+		err = render.RenderDocument(r, &b, doc)
+		return b.String(), err
+	}
+	want := make([][]string, len(srcs))
+	for i, src := range srcs {
+		for _, r := range renderers {
+			out, err := convert(src, r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want[i] = append(want[i], out)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for n := range srcs {
+				i := (n + g*7) % len(srcs) // Each goroutine starts elsewhere
+				for j, r := range renderers {
+					got, err := convert(srcs[i], r)
+					if err == nil && got != want[i][j] {
+						err = fmt.Errorf("%s of %q is %q, not %q", formats[j], srcs[i], got, want[i][j])
+					}
+					if err != nil {
+						errs <- err
+						return
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
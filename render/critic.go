@@ -0,0 +1,135 @@
+package render
+
+import (
+	"io"
+
+	"../parser"
+)
+
+// How renderers treat the edits parser.CriticMarkup reads
+const (
+	CriticShow   = "show"   // Marked as edits, the default
+	CriticAccept = "accept" // As if made: insertions kept, deletions left out
+	CriticReject = "reject" // As if not: deletions kept, insertions left out
+)
+
+// CriticModes are the ways edits can be treated
+var CriticModes = []string{CriticShow, CriticAccept, CriticReject}
+
+// WithCriticMarkup treats CriticMarkup edits as mode, one of CriticModes,
+// says. Comments are left out unless edits are shown
+func WithCriticMarkup(mode string) Option {
+	return WithExtensions(criticMarkup(mode))
+}
+
+// criticStyle is how a format marks edits: what goes around insertions
+// & deletions, and how a comment is written
+type criticStyle struct {
+	insert, insertEnd string
+	del, delEnd       string
+	comment           func(text string) string
+}
+
+// criticStyles are the styles of the formats with markup of their own for
+// edits, besides HTML's, which depends on the Config
+var criticStyles = map[string]criticStyle{
+	FormatLaTeX: {`\uline{`, `}`, `\sout{`, `}`, func(s string) string {
+		return `\textit{[` + latexEscaper.Replace(s) + `]}`
+	}},
+	FormatDocBook: {`<phrase revisionflag="added">`, `</phrase>`, `<phrase revisionflag="deleted">`, `</phrase>`, func(s string) string {
+		return `<remark>` + escaper.Replace(s) + `</remark>`
+	}},
+	FormatAsciiDoc: {"[.underline]##", "##", "[.line-through]##", "##", func(s string) string {
+		return "[.comment]##" + asciiDocEscaper.Replace(s) + "##"
+	}},
+	FormatBBCode: {"[u]", "[/u]", "[s]", "[/s]", func(s string) string {
+		return "[i]" + bbCodeText(s) + "[/i]"
+	}},
+	FormatJira: {"+", "+", "-", "-", func(s string) string {
+		return "_" + jiraEscaper.Replace(s) + "_"
+	}},
+}
+
+// criticEscapers escape comments for the formats edits are written in as
+// CriticMarkup, whose markers are plain text to all of them
+var criticEscapers = map[string]func(string) string{
+	FormatMan: manEscaper.Replace,
+}
+
+// criticMarkup draws edits as it says to: shown with the markup of
+// criticStyles, or elsewhere in CriticMarkup, or accepted or rejected in
+// every format. LaTeX marks them with the ulem package's \uline & \sout
+type criticMarkup string
+
+func (mode criticMarkup) ExtendRenderer(c *Config) {
+	for _, k := range []parser.NodeKind{parser.NodeCriticInsert, parser.NodeCriticDelete, parser.NodeCriticComment} {
+		delete(c.skip, k)
+	}
+	plain := func(w io.Writer, n *parser.Node, entering bool) {}
+	switch mode {
+	case CriticAccept:
+		c.SkipNodes(parser.NodeCriticDelete, parser.NodeCriticComment)
+		c.SetNodeRenderer(parser.NodeCriticInsert, plain)
+		c.SetNodeRenderer(parser.NodeCriticSubstitute, plain)
+		return
+	case CriticReject:
+		c.SkipNodes(parser.NodeCriticInsert, parser.NodeCriticComment)
+		c.SetNodeRenderer(parser.NodeCriticDelete, plain)
+		c.SetNodeRenderer(parser.NodeCriticSubstitute, plain)
+		return
+	}
+	tag := func(open, close string) NodeRenderer {
+		return func(w io.Writer, n *parser.Node, entering bool) {
+			if entering {
+				io.WriteString(w, open)
+			} else {
+				io.WriteString(w, close)
+			}
+		}
+	}
+	comment := func(write func(text string) string) NodeRenderer {
+		return func(w io.Writer, n *parser.Node, entering bool) {
+			if entering {
+				io.WriteString(w, write(n.Literal))
+			}
+		}
+	}
+	style, ok := criticStyles[c.Format]
+	if c.Format == FormatHTML {
+		class := ` class="` + escaper.Replace(c.ClassPrefix+"critic") + `"`
+		style, ok = criticStyle{"<ins" + class + ">", "</ins>", "<del" + class + ">", "</del>", func(s string) string {
+			return `<span class="` + escaper.Replace(c.ClassPrefix+"critic-comment") + `">` + escaper.Replace(s) + `</span>`
+		}}, true
+	}
+	if ok {
+		c.SetNodeRenderer(parser.NodeCriticInsert, tag(style.insert, style.insertEnd))
+		c.SetNodeRenderer(parser.NodeCriticDelete, tag(style.del, style.delEnd))
+		c.SetNodeRenderer(parser.NodeCriticSubstitute, plain)
+		c.SetNodeRenderer(parser.NodeCriticComment, comment(style.comment))
+		return
+	}
+	inSubstitution := func(n *parser.Node) bool {
+		return n.Parent != nil && n.Parent.Kind == parser.NodeCriticSubstitute
+	}
+	insert, del := tag("{++", "++}"), tag("{--", "--}")
+	c.SetNodeRenderer(parser.NodeCriticInsert, func(w io.Writer, n *parser.Node, entering bool) {
+		if !inSubstitution(n) {
+			insert(w, n, entering)
+		}
+	})
+	c.SetNodeRenderer(parser.NodeCriticDelete, func(w io.Writer, n *parser.Node, entering bool) {
+		if !inSubstitution(n) {
+			del(w, n, entering)
+		} else if !entering {
+			io.WriteString(w, "~>")
+		}
+	})
+	c.SetNodeRenderer(parser.NodeCriticSubstitute, tag("{~~", "~~}"))
+	escape := criticEscapers[c.Format]
+	c.SetNodeRenderer(parser.NodeCriticComment, comment(func(s string) string {
+		if escape != nil {
+			s = escape(s)
+		}
+		return "{>>" + s + "<<}"
+	}))
+}
@@ -0,0 +1,45 @@
+package render_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"../parser"
+	"../render"
+)
+
+// TestCriticShow checks edits are shown in each format's own markup, or in
+// CriticMarkup, with what the comment says escaped as the format's text is
+func TestCriticShow(t *testing.T) {
+	const md = "a {++in++} {--out--} {~~old~>new~~} {>>50% of {x} & \\e<<}\n"
+	p := parser.New(parser.WithExtensions(parser.CriticMarkup))
+	doc, err := p.Parse("test", md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for format, want := range map[string]string{
+		render.FormatHTML: `a <ins class="critic">in</ins> <del class="critic">out</del> <del class="critic">old</del><ins class="critic">new</ins> ` +
+			`<span class="critic-comment">50% of {x} &amp; \e</span>`,
+		render.FormatLaTeX:    `a \uline{in} \sout{out} \sout{old}\uline{new} \textit{[50\% of \{x\} \& \textbackslash{}e]}`,
+		render.FormatDocBook:  `a <phrase revisionflag="added">in</phrase> <phrase revisionflag="deleted">out</phrase> <phrase revisionflag="deleted">old</phrase><phrase revisionflag="added">new</phrase> <remark>50% of {x} &amp; \e</remark>`,
+		render.FormatAsciiDoc: `a [.underline]##in## [.line-through]##out## [.line-through]##old##[.underline]##new## [.comment]##50% of &#123;x&#125; & \e##`,
+		render.FormatBBCode:   `a [u]in[/u] [s]out[/s] [s]old[/s][u]new[/u] [i]50% of {x} & \e[/i]`,
+		render.FormatJira:     `a +in+ -out- -old-+new+ _50% of \{x\} & \e_`,
+		render.FormatMan:      `a {++in++} {--out--} {~~old~>new~~} {>>50% of {x} & \ee<<}`,
+		render.FormatMarkdown: `a {++in++} {--out--} {~~old~>new~~} {>>50% of {x} & \e<<}`,
+		render.FormatText:     `a {++in++} {--out--} {~~old~>new~~} {>>50% of {x} & \e<<}`,
+	} {
+		r, err := render.NewRenderer(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := r.Render(&b, doc.Root.Children[0]); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("%s rendered\n%s\nwithout\n%s", format, b.String(), want)
+		}
+	}
+}
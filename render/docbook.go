@@ -0,0 +1,147 @@
+package render
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"../parser"
+)
+
+const docBookHeader = `<?xml version="1.0" encoding="UTF-8"?>
+<article xmlns="http://docbook.org/ns/docbook" xmlns:xlink="http://www.w3.org/1999/xlink" version="5.0">
+`
+
+// DocBookRenderer writes a node tree out as DocBook 5 XML. Headings open
+// nested <section>s which run until the next heading of the same or a
+// higher level
+type DocBookRenderer struct {
+	cfg Config
+}
+
+// NewDocBookRenderer returns a DocBook renderer configured by opts
+func NewDocBookRenderer(opts ...Option) *DocBookRenderer {
+	return &DocBookRenderer{cfg: newConfig(FormatDocBook, opts)}
+}
+
+// Render writes n to w as DocBook. A Document node produces a complete
+// <article>, anything else an XML fragment
+func (r *DocBookRenderer) Render(w io.Writer, n *parser.Node) error {
+	w, done := r.cfg.track(w, n)
+	defer done()
+	b := getBuffer()
+	defer putBuffer(b)
+	if n.Kind == parser.NodeDocument {
+		b.WriteString(docBookHeader)
+		r.renderSections(b, n.Children)
+		b.WriteString("</article>\n")
+	} else {
+		r.renderSections(b, []*parser.Node{n})
+	}
+	return r.cfg.write(w, b)
+}
+
+// renderSections renders a run of sibling blocks, wrapping everything
+// following a heading in a <section> of that heading's level
+func (r *DocBookRenderer) renderSections(b *bytes.Buffer, blocks []*parser.Node) {
+	var open []int // Levels of the currently open sections
+	for _, n := range blocks {
+		if n.Kind != parser.NodeHeading {
+			r.renderNode(b, n)
+			continue
+		}
+		for len(open) > 0 && open[len(open)-1] >= n.Level {
+			b.WriteString("</section>\n")
+			open = open[:len(open)-1]
+		}
+		open = append(open, n.Level)
+		b.WriteString("<section>\n<title>")
+		r.renderChildren(b, n)
+		b.WriteString("</title>\n")
+	}
+	for range open {
+		b.WriteString("</section>\n")
+	}
+}
+
+func (r *DocBookRenderer) renderChildren(b *bytes.Buffer, n *parser.Node) {
+	for _, c := range n.Children {
+		r.renderNode(b, c)
+	}
+}
+
+func (r *DocBookRenderer) renderNode(b *bytes.Buffer, n *parser.Node) {
+	if r.cfg.skip[n.Kind] {
+		return
+	}
+	if h := r.cfg.hooks[n.Kind]; h != nil {
+		h(b, n, true)
+		r.renderChildren(b, n)
+		h(b, n, false)
+		return
+	}
+	switch n.Kind {
+	case parser.NodeDocument:
+		r.renderSections(b, n.Children)
+	case parser.NodeParagraph:
+		b.WriteString("<para>")
+		r.renderChildren(b, n)
+		b.WriteString("</para>\n")
+	case parser.NodeHeading:
+		r.renderSections(b, []*parser.Node{n})
+	case parser.NodeThematicBreak:
+		// DocBook has no equivalent
+	case parser.NodeList:
+		tag := "itemizedlist"
+		if n.Ordered {
+			tag = "orderedlist"
+		}
+		b.WriteString("<" + tag + ">\n")
+		r.renderChildren(b, n)
+		b.WriteString("</" + tag + ">\n")
+	case parser.NodeListItem:
+		b.WriteString("<listitem><para>")
+		r.renderChildren(b, n)
+		b.WriteString("</para></listitem>\n")
+	case parser.NodeCodeBlock:
+		b.WriteString("<programlisting")
+		if lang := strings.Fields(n.Info); len(lang) > 0 {
+			b.WriteString(` language="` + escaper.Replace(lang[0]) + `"`)
+		}
+		b.WriteString(">" + escaper.Replace(n.Literal) + "</programlisting>\n")
+	case parser.NodeBlockQuote:
+		b.WriteString("<blockquote>\n")
+		r.renderSections(b, n.Children)
+		b.WriteString("</blockquote>\n")
+	case parser.NodeText:
+		b.WriteString(escaper.Replace(n.Literal))
+	case parser.NodeSoftBreak, parser.NodeHardBreak:
+		b.WriteByte('\n')
+	case parser.NodeEmphasis:
+		b.WriteString("<emphasis>")
+		r.renderChildren(b, n)
+		b.WriteString("</emphasis>")
+	case parser.NodeStrong:
+		b.WriteString(`<emphasis role="strong">`)
+		r.renderChildren(b, n)
+		b.WriteString("</emphasis>")
+	case parser.NodeCodeSpan:
+		b.WriteString("<literal>" + escaper.Replace(n.Literal) + "</literal>")
+	case parser.NodeLink:
+		b.WriteString(`<link xlink:href="` + escaper.Replace(n.Dest) + `">`)
+		r.renderChildren(b, n)
+		b.WriteString("</link>")
+	case parser.NodeImage:
+		b.WriteString(`<inlinemediaobject><imageobject><imagedata fileref="` + escaper.Replace(n.Dest) + `"/></imageobject>`)
+		if alt := n.PlainText(); alt != "" {
+			b.WriteString("<textobject><phrase>" + escaper.Replace(alt) + "</phrase></textobject>")
+		}
+		b.WriteString("</inlinemediaobject>")
+	case parser.NodeFootnoteRef:
+		if note := footnoteOf(n); note != nil {
+			b.WriteString("<footnote><para>")
+			r.renderChildren(b, note)
+			b.WriteString("</para></footnote>")
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package render
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"strings"
+
+	"../parser"
+)
+
+const epubContainer = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles>
+<rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+</rootfiles>
+</container>
+`
+
+const epubChapter = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.1//EN" "http://www.w3.org/TR/xhtml11/DTD/xhtml11.dtd">
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head>
+<title>%s</title>
+</head>
+<body>
+%s</body>
+</html>
+`
+
+// epubBook holds the metadata shared by the package files of an EPUB
+type epubBook struct {
+	title, author, lang, id, date string
+	chapters                      []string // Chapter titles, in reading order
+}
+
+// WriteEPUB packages docs as the chapters of an EPUB, in order, and writes
+// the archive to w. The book's title, author, language, identifier & date
+// are taken from the front matter of the first document
+func WriteEPUB(w io.Writer, docs []*parser.Document, opts ...Option) error {
+	if len(docs) == 0 {
+		return fmt.Errorf("epub: no chapters")
+	}
+	meta := docs[0].Meta
+	book := &epubBook{
+		title:  docs[0].Title(),
+		author: meta["author"],
+		lang:   meta["lang"],
+		id:     meta["identifier"],
+		date:   meta["date"],
+	}
+	if book.lang == "" {
+		book.lang = "en"
+	}
+	if book.id == "" {
+		book.id = bookID(book.title, book.author)
+	}
+
+	z := zip.NewWriter(w)
+	// The mimetype must be the first entry, and stored uncompressed
+	f, err := z.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, "application/epub+zip"); err != nil {
+		return err
+	}
+	if err := writeZipFile(z, "META-INF/container.xml", epubContainer); err != nil {
+		return err
+	}
+
+	html := NewHTMLRenderer(opts...)
+	for i, doc := range docs {
+		var body strings.Builder
+		if err := html.RenderDocument(&body, doc); err != nil {
+			return err
+		}
+		title := doc.Title()
+		book.chapters = append(book.chapters, title)
+		page := fmt.Sprintf(epubChapter, escaper.Replace(title), body.String())
+		if err := writeZipFile(z, "OEBPS/"+chapterFile(i), page); err != nil {
+			return err
+		}
+	}
+	if err := writeZipFile(z, "OEBPS/content.opf", book.opf()); err != nil {
+		return err
+	}
+	if err := writeZipFile(z, "OEBPS/toc.ncx", book.ncx()); err != nil {
+		return err
+	}
+	return z.Close()
+}
+
+// writeZipFile adds a deflated file called name to z
+func writeZipFile(z *zip.Writer, name, contents string) error {
+	f, err := z.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(f, contents)
+	return err
+}
+
+// chapterFile is the name of the XHTML file holding chapter i
+func chapterFile(i int) string {
+	return fmt.Sprintf("chapter%03d.xhtml", i+1)
+}
+
+// bookID derives a stable identifier for books without one in their front
+// matter, so rebuilding the same book doesn't change its identity
+func bookID(title, author string) string {
+	h := sha1.Sum([]byte(title + "\x00" + author))
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", h[0:4], h[4:6], h[6:8], h[8:10], h[10:16])
+}
+
+// opf returns the package document listing the book's metadata & files
+func (b *epubBook) opf() string {
+	var s strings.Builder
+	s.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" unique-identifier="bookid" version="2.0">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:opf="http://www.idpf.org/2007/opf">
+`)
+	fmt.Fprintf(&s, "<dc:title>%s</dc:title>\n", escaper.Replace(b.title))
+	if b.author != "" {
+		fmt.Fprintf(&s, "<dc:creator opf:role=\"aut\">%s</dc:creator>\n", escaper.Replace(b.author))
+	}
+	if b.date != "" {
+		fmt.Fprintf(&s, "<dc:date>%s</dc:date>\n", escaper.Replace(b.date))
+	}
+	fmt.Fprintf(&s, "<dc:language>%s</dc:language>\n", escaper.Replace(b.lang))
+	fmt.Fprintf(&s, "<dc:identifier id=\"bookid\">%s</dc:identifier>\n", escaper.Replace(b.id))
+	s.WriteString("</metadata>\n<manifest>\n")
+	s.WriteString(`<item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>` + "\n")
+	for i := range b.chapters {
+		fmt.Fprintf(&s, "<item id=\"chapter%d\" href=\"%s\" media-type=\"application/xhtml+xml\"/>\n", i+1, chapterFile(i))
+	}
+	s.WriteString("</manifest>\n<spine toc=\"ncx\">\n")
+	for i := range b.chapters {
+		fmt.Fprintf(&s, "<itemref idref=\"chapter%d\"/>\n", i+1)
+	}
+	s.WriteString("</spine>\n</package>\n")
+	return s.String()
+}
+
+// ncx returns the navigation document used by readers' tables of contents
+func (b *epubBook) ncx() string {
+	var s strings.Builder
+	s.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+<head>
+`)
+	fmt.Fprintf(&s, "<meta name=\"dtb:uid\" content=\"%s\"/>\n", escaper.Replace(b.id))
+	s.WriteString(`<meta name="dtb:depth" content="1"/>
+<meta name="dtb:totalPageCount" content="0"/>
+<meta name="dtb:maxPageNumber" content="0"/>
+</head>
+`)
+	fmt.Fprintf(&s, "<docTitle><text>%s</text></docTitle>\n<navMap>\n", escaper.Replace(b.title))
+	for i, title := range b.chapters {
+		fmt.Fprintf(&s, "<navPoint id=\"chapter%d\" playOrder=\"%d\"><navLabel><text>%s</text></navLabel><content src=\"%s\"/></navPoint>\n",
+			i+1, i+1, escaper.Replace(title), chapterFile(i))
+	}
+	s.WriteString("</navMap>\n</ncx>\n")
+	return s.String()
+}
@@ -0,0 +1,94 @@
+package render
+
+import (
+	"fmt"
+	"io"
+
+	"../parser"
+)
+
+// footnotes draws the notes parser.InlineFootnotes makes: in HTML as
+// superscript links to a list of them at the end, and in formats without
+// notes of their own as [1] where each was written & a paragraph of each
+// at the end. LaTeX, DocBook & AsciiDoc write their own notes where they
+// were made, from the NodeFootnote each NodeFootnoteRef has as its Data,
+// & Markdown as the inline notes, ^[...], they were read from. Every
+// renderer has it, before its options
+type footnotes struct{}
+
+// footnoteStyle is how a format without notes of its own writes them:
+// the marker where each was written, & what goes before & after what it
+// says at the end. Each has %s for the note's number
+type footnoteStyle struct {
+	ref, open, close string
+}
+
+// footnoteStyles are the styles of the formats not using defaultFootnotes
+var footnoteStyles = map[string]footnoteStyle{
+	FormatMan:  {"[%s]", ".IP [%s] 4\n", "\n"},
+	FormatJira: {`\[%s\]`, `\[%s\] `, "\n\n"},
+}
+
+var defaultFootnotes = footnoteStyle{"[%s]", "[%s] ", "\n\n"}
+
+func (footnotes) ExtendRenderer(c *Config) {
+	switch c.Format {
+	case FormatLaTeX, FormatDocBook, FormatAsciiDoc, FormatMarkdown:
+		c.SkipNodes(parser.NodeFootnotes)
+		return
+	case FormatHTML:
+		class := func(name string) string {
+			return ` class="` + escaper.Replace(c.ClassPrefix+name) + `"`
+		}
+		nl := "\n"
+		if c.Minify {
+			nl = ""
+		}
+		c.SetNodeRenderer(parser.NodeFootnoteRef, func(w io.Writer, n *parser.Node, entering bool) {
+			if entering {
+				id := escaper.Replace(n.ID)
+				io.WriteString(w, `<sup`+class("footnote-ref")+`><a href="#fn`+id+`" id="fnref`+id+`">`+id+`</a></sup>`)
+			}
+		})
+		c.SetNodeRenderer(parser.NodeFootnotes, func(w io.Writer, n *parser.Node, entering bool) {
+			if entering {
+				io.WriteString(w, `<section`+class("footnotes")+`>`+nl+`<hr />`+nl+`<ol>`+nl)
+			} else {
+				io.WriteString(w, `</ol>`+nl+`</section>`+nl)
+			}
+		})
+		c.SetNodeRenderer(parser.NodeFootnote, func(w io.Writer, n *parser.Node, entering bool) {
+			id := escaper.Replace(n.ID)
+			if entering {
+				io.WriteString(w, `<li id="fn`+id+`"><p>`)
+			} else {
+				io.WriteString(w, ` <a href="#fnref`+id+`"`+class("footnote-back")+`>↩</a></p></li>`+nl)
+			}
+		})
+		return
+	}
+	style, ok := footnoteStyles[c.Format]
+	if !ok {
+		style = defaultFootnotes
+	}
+	c.SetNodeRenderer(parser.NodeFootnoteRef, func(w io.Writer, n *parser.Node, entering bool) {
+		if entering {
+			fmt.Fprintf(w, style.ref, n.ID)
+		}
+	})
+	c.SetNodeRenderer(parser.NodeFootnotes, func(w io.Writer, n *parser.Node, entering bool) {})
+	c.SetNodeRenderer(parser.NodeFootnote, func(w io.Writer, n *parser.Node, entering bool) {
+		if entering {
+			fmt.Fprintf(w, style.open, n.ID)
+		} else {
+			io.WriteString(w, style.close)
+		}
+	})
+}
+
+// footnoteOf returns the note ref marks the place of, or nil if it has
+// none
+func footnoteOf(ref *parser.Node) *parser.Node {
+	note, _ := ref.Data.(*parser.Node)
+	return note
+}
@@ -0,0 +1,41 @@
+package render_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"../parser"
+	"../render"
+)
+
+func TestFootnotes(t *testing.T) {
+	const md = "Text^[A *note* with 50%.] and more.\n\nNext.\n"
+	p := parser.New(parser.WithExtensions(parser.InlineFootnotes))
+	doc, err := p.Parse("test", md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for format, want := range map[string]string{
+		render.FormatHTML: `<p>Text<sup class="footnote-ref"><a href="#fn1" id="fnref1">1</a></sup> and more.</p>` + "\n<p>Next.</p>\n" +
+			`<section class="footnotes">` + "\n<hr />\n<ol>\n" + `<li id="fn1"><p>A <em>note</em> with 50%. <a href="#fnref1" class="footnote-back">↩</a></p></li>`,
+		render.FormatLaTeX:    `Text\footnote{A \emph{note} with 50\%.} and more.` + "\n\nNext.\n",
+		render.FormatDocBook:  `<para>Text<footnote><para>A <emphasis>note</emphasis> with 50%.</para></footnote> and more.</para>` + "\n<para>Next.</para>\n",
+		render.FormatAsciiDoc: "Textfootnote:[A _note_ with 50%.] and more.\n\nNext.\n",
+		render.FormatMan:      ".PP\nText[1] and more.\n.PP\nNext.\n.IP [1] 4\nA \\fInote\\fP with 50%.\n",
+		render.FormatMarkdown: "Text^[A *note* with 50%.] and more.\n\nNext.\n",
+		render.FormatText:     "Text[1] and more.\n\nNext.\n\n[1] A note with 50%.\n",
+	} {
+		r, err := render.NewRenderer(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := r.Render(&b, doc.Root); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("%s rendered\n%s\nwithout\n%s", format, b.String(), want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package render_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"../parser"
+	"../render"
+	"../spec"
+)
+
+// FuzzRenderHTML checks any input, with & without the extensions that
+// render markup of their own, renders to well-formed HTML
+func FuzzRenderHTML(f *testing.F) {
+	for _, ex := range spec.Examples {
+		f.Add(ex.Markdown)
+	}
+	for _, s := range []string{
+		"nul\x00 *in\x00side* `co\x00de`\r\n",
+		"trunc\xe2\x82\r\n\xf0\x9f\x98\r\n# \xc3\r\n",
+		"\xef\xbf\xbf \xef\xbf\xbe &#xffff; &#0; &bogus; &amp\n",
+		"note^[with *em* and ^[another]] {++in++}{--out--}{~~a~>b~~}{>>why<<}\n",
+	} {
+		f.Add(s)
+	}
+	parsers := []*parser.Parser{
+		parser.New(),
+		parser.New(parser.WithExtensions(append([]parser.Extension{parser.InlineFootnotes, parser.CriticMarkup}, parser.DefaultExtensions...)...)),
+	}
+	html := render.NewHTMLRenderer()
+	f.Fuzz(func(t *testing.T, src string) {
+		for i, p := range parsers {
+			doc, err := p.Parse("fuzz", src)
+			if err != nil {
+				continue
+			}
+			var out bytes.Buffer
+			if err := html.Render(&out, doc.Root); err != nil {
+				t.Fatalf("parser %d: %v", i, err)
+			}
+			if err := wellFormed(out.String()); err != nil {
+				t.Fatalf("parser %d: malformed output %q: %v", i, out.String(), err)
+			}
+		}
+	})
+}
+
+// wellFormed reports whether the renderer's XHTML style output has
+// balanced tags, valid entities & is valid UTF-8 without NULs. Characters
+// HTML allows but XML doesn't, such as U+FFFF, are let through
+func wellFormed(s string) error {
+	if !utf8.ValidString(s) || strings.IndexByte(s, 0) >= 0 {
+		return fmt.Errorf("NUL or invalid UTF-8")
+	}
+	s = strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' || r >= 0x20 && r < 0xfffe || r > 0xffff {
+			return r
+		}
+		return utf8.RuneError
+	}, s)
+	d := xml.NewDecoder(strings.NewReader("<root>" + s + "</root>"))
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package render
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"../parser"
+)
+
+// gfm draws the nodes of the GitHub Flavored Markdown extensions,
+// parser.Strikethrough, parser.Tables & parser.TaskLists, in each format.
+// Every renderer has it, before its options
+type gfm struct{}
+
+// strikeStyles are what goes around struck out text in each format, those
+// it hasn't writing it as it is. LaTeX strikes it out with the ulem
+// package's \sout
+var strikeStyles = map[string][2]string{
+	FormatHTML:     {"<del>", "</del>"},
+	FormatMarkdown: {"~~", "~~"},
+	FormatLaTeX:    {`\sout{`, `}`},
+	FormatDocBook:  {`<emphasis role="strikethrough">`, `</emphasis>`},
+	FormatJira:     {"-", "-"},
+	FormatAsciiDoc: {"[.line-through]##", "##"},
+	FormatBBCode:   {"[s]", "[/s]"},
+	FormatANSI:     {"\x1b[9m", "\x1b[29m"},
+}
+
+// checkboxes are how each format writes the boxes of tasks to be done &
+// done, those it hasn't being written [ ] & [x]
+var checkboxes = map[string][2]string{
+	FormatHTML:  {`<input disabled="" type="checkbox"/> `, `<input disabled="" type="checkbox" checked=""/> `},
+	FormatLaTeX: {`\texttt{[ ]} `, `\texttt{[x]} `},
+	FormatJira:  {"(/) ", "(x) "},
+	FormatANSI:  {"☐ ", "☑ "},
+}
+
+// tableStyle is how a format writes a table: what goes around it, each
+// row & each cell, between cells, & after the header row. table is given
+// the table, to write what depends on its columns
+type tableStyle struct {
+	table, tableEnd func(n *parser.Node) string
+	row, rowEnd     string
+	headEnd         func(n *parser.Node) string
+	head, headCell  string // Around the cells of the header row
+	cell, cellEnd   string
+	sep             string
+}
+
+// tableStyles are the styles of the formats besides HTML's, which
+// depends on the Config
+var tableStyles = map[string]tableStyle{
+	FormatMarkdown: {
+		row: "|", rowEnd: "\n", headEnd: mdDelimiterRow,
+		head: " ", headCell: " |", cell: " ", cellEnd: " |",
+		tableEnd: always("\n"),
+	},
+	FormatLaTeX: {
+		table: latexTabular, tableEnd: always("\\end{tabular}\n\n"),
+		rowEnd: " \\\\\n", headEnd: always("\\hline\n"), sep: " & ",
+	},
+	FormatDocBook: {
+		table: docBookTable, tableEnd: func(n *parser.Node) string {
+			if len(n.Children) > 1 {
+				return "</tbody>\n</tgroup>\n</informaltable>\n"
+			}
+			return "</tgroup>\n</informaltable>\n"
+		},
+		row: "<row>", rowEnd: "</row>\n",
+		headEnd: func(n *parser.Node) string {
+			if len(n.Children) > 1 {
+				return "</thead>\n<tbody>\n"
+			}
+			return "</thead>\n"
+		},
+		head: "<entry>", headCell: "</entry>", cell: "<entry>", cellEnd: "</entry>",
+	},
+	FormatJira: {
+		rowEnd: "\n", tableEnd: always("\n"),
+		head: "||", headCell: "", cell: "|", cellEnd: "",
+	},
+	FormatAsciiDoc: {
+		table: asciiDocTable, tableEnd: always("|===\n\n"),
+		rowEnd: "\n", headEnd: always("\n"),
+		head: "|", cell: "|", sep: " ",
+	},
+	FormatBBCode: {
+		table: always("[table]\n"), tableEnd: always("[/table]\n\n"),
+		row: "[tr]", rowEnd: "[/tr]\n",
+		head: "[th]", headCell: "[/th]", cell: "[td]", cellEnd: "[/td]",
+	},
+	FormatMan: {
+		table: always(".PP\n"), rowEnd: "\n.br\n", sep: " | ",
+	},
+}
+
+// plainTable is the style of the formats without tables of their own,
+// which write a row per line with | between cells
+var plainTable = tableStyle{rowEnd: "\n", sep: " | ", tableEnd: always("\n")}
+
+// always returns a func writing s, whatever the node
+func always(s string) func(*parser.Node) string {
+	return func(*parser.Node) string { return s }
+}
+
+func (gfm) ExtendRenderer(c *Config) {
+	if c.Format == FormatJSON || c.Format == FormatPandoc {
+		return
+	}
+	tag := func(open, close string) NodeRenderer {
+		return func(w io.Writer, n *parser.Node, entering bool) {
+			if entering {
+				io.WriteString(w, open)
+			} else {
+				io.WriteString(w, close)
+			}
+		}
+	}
+	strike := strikeStyles[c.Format]
+	c.SetNodeRenderer(parser.NodeStrikethrough, tag(strike[0], strike[1]))
+	boxes, ok := checkboxes[c.Format]
+	if !ok {
+		boxes = [2]string{"[ ] ", "[x] "}
+	}
+	c.SetNodeRenderer(parser.NodeTaskCheckbox, func(w io.Writer, n *parser.Node, entering bool) {
+		if !entering {
+			return
+		}
+		if n.Literal == "x" {
+			io.WriteString(w, boxes[1])
+		} else {
+			io.WriteString(w, boxes[0])
+		}
+	})
+	if c.Format == FormatHTML {
+		htmlTables(c)
+		return
+	}
+	style, ok := tableStyles[c.Format]
+	if !ok {
+		style = plainTable
+	}
+	c.SetNodeRenderer(parser.NodeTable, func(w io.Writer, n *parser.Node, entering bool) {
+		switch {
+		case entering && style.table != nil:
+			io.WriteString(w, style.table(n))
+		case !entering && style.tableEnd != nil:
+			io.WriteString(w, style.tableEnd(n))
+		}
+	})
+	c.SetNodeRenderer(parser.NodeTableRow, func(w io.Writer, n *parser.Node, entering bool) {
+		if entering {
+			io.WriteString(w, style.row)
+			return
+		}
+		io.WriteString(w, style.rowEnd)
+		if isHeaderRow(n) && style.headEnd != nil {
+			io.WriteString(w, style.headEnd(n.Parent))
+		}
+	})
+	c.SetNodeRenderer(parser.NodeTableCell, func(w io.Writer, n *parser.Node, entering bool) {
+		head := isHeaderRow(n.Parent)
+		switch {
+		case entering && n.Parent.Children[0] != n:
+			io.WriteString(w, style.sep)
+			fallthrough
+		case entering:
+			if head {
+				io.WriteString(w, style.head)
+			} else {
+				io.WriteString(w, style.cell)
+			}
+		case head:
+			io.WriteString(w, style.headCell)
+		default:
+			io.WriteString(w, style.cellEnd)
+		}
+	})
+}
+
+// htmlTables draws tables in HTML, their header row in a thead & the rest
+// in a tbody, with the alignment of each column in the style of its cells
+func htmlTables(c *Config) {
+	nl := func() string {
+		if c.Minify {
+			return ""
+		}
+		return "\n"
+	}
+	c.SetNodeRenderer(parser.NodeTable, func(w io.Writer, n *parser.Node, entering bool) {
+		if entering {
+			io.WriteString(w, "<table>"+nl())
+		} else {
+			io.WriteString(w, "</tbody>"+nl()+"</table>"+nl())
+		}
+	})
+	c.SetNodeRenderer(parser.NodeTableRow, func(w io.Writer, n *parser.Node, entering bool) {
+		head := isHeaderRow(n)
+		switch {
+		case entering && head:
+			io.WriteString(w, "<thead>"+nl()+"<tr>"+nl())
+		case entering:
+			io.WriteString(w, "<tr>"+nl())
+		case head:
+			io.WriteString(w, "</tr>"+nl()+"</thead>"+nl()+"<tbody>"+nl())
+		default:
+			io.WriteString(w, "</tr>"+nl())
+		}
+	})
+	c.SetNodeRenderer(parser.NodeTableCell, func(w io.Writer, n *parser.Node, entering bool) {
+		tag := "td"
+		if isHeaderRow(n.Parent) {
+			tag = "th"
+		}
+		switch {
+		case !entering:
+			io.WriteString(w, "</"+tag+">"+nl())
+		case n.Info != "":
+			io.WriteString(w, "<"+tag+` style="text-align: `+escaper.Replace(n.Info)+`">`)
+		default:
+			io.WriteString(w, "<"+tag+">")
+		}
+	})
+}
+
+// isHeaderRow reports whether row is the first of its table
+func isHeaderRow(row *parser.Node) bool {
+	return row.Parent != nil && row.Parent.Children[0] == row
+}
+
+// inTableCell reports whether n is within a table's cell
+func inTableCell(n *parser.Node) bool {
+	for ; n != nil; n = n.Parent {
+		if n.Kind == parser.NodeTableCell {
+			return true
+		}
+	}
+	return false
+}
+
+// mdDelimiterRow returns the row of dashes under the header of table in
+// Markdown, with colons where its columns are aligned
+func mdDelimiterRow(table *parser.Node) string {
+	var b strings.Builder
+	b.WriteString("|")
+	for _, cell := range table.Children[0].Children {
+		switch cell.Info {
+		case "left":
+			b.WriteString(" :-- |")
+		case "center":
+			b.WriteString(" :-: |")
+		case "right":
+			b.WriteString(" --: |")
+		default:
+			b.WriteString(" --- |")
+		}
+	}
+	return b.String() + "\n"
+}
+
+// latexTabular starts a tabular of the columns of table, each aligned as
+// its cells are
+func latexTabular(table *parser.Node) string {
+	var cols strings.Builder
+	for _, cell := range table.Children[0].Children {
+		switch cell.Info {
+		case "center":
+			cols.WriteString("c")
+		case "right":
+			cols.WriteString("r")
+		default:
+			cols.WriteString("l")
+		}
+	}
+	return `\begin{tabular}{` + cols.String() + "}\n"
+}
+
+// docBookTable starts an informaltable of the columns of table, each
+// aligned as its cells are, & its thead
+func docBookTable(table *parser.Node) string {
+	var b strings.Builder
+	b.WriteString("<informaltable>\n<tgroup cols=\"")
+	b.WriteString(strconv.Itoa(len(table.Children[0].Children)))
+	b.WriteString("\">\n")
+	for _, cell := range table.Children[0].Children {
+		if cell.Info != "" {
+			b.WriteString(`<colspec align="` + cell.Info + `"/>` + "\n")
+		} else {
+			b.WriteString("<colspec/>\n")
+		}
+	}
+	b.WriteString("<thead>\n")
+	return b.String()
+}
+
+// asciiDocTable starts a table of the columns of table, each aligned as
+// its cells are, with a header row
+func asciiDocTable(table *parser.Node) string {
+	var cols []string
+	for _, cell := range table.Children[0].Children {
+		switch cell.Info {
+		case "center":
+			cols = append(cols, "^")
+		case "right":
+			cols = append(cols, ">")
+		default:
+			cols = append(cols, "<")
+		}
+	}
+	return `[cols="` + strings.Join(cols, ",") + `",options="header"]` + "\n|===\n"
+}
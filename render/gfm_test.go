@@ -0,0 +1,64 @@
+package render_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"../parser"
+	"../render"
+)
+
+// TestGFMFormats checks struck out text, tasks & tables are drawn in each
+// format's own markup
+func TestGFMFormats(t *testing.T) {
+	const md = "- [x] ~~old~~\n\n| a | b \\| c |\n|:-|--:|\n| 1 | *2* |\n"
+	doc, err := parser.New(parser.WithDialect(parser.GFM)).Parse("test", md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for format, want := range map[string]string{
+		render.FormatHTML: `<li><input disabled="" type="checkbox" checked=""/> <del>old</del></li>` + "\n</ul>\n" +
+			"<table>\n<thead>\n<tr>\n" + `<th style="text-align: left">a</th>` + "\n" + `<th style="text-align: right">b | c</th>` +
+			"\n</tr>\n</thead>\n<tbody>\n<tr>\n" + `<td style="text-align: left">1</td>` + "\n" +
+			`<td style="text-align: right"><em>2</em></td>` + "\n</tr>\n</tbody>\n</table>\n",
+		render.FormatMarkdown: "- [x] ~~old~~\n\n| a | b \\| c |\n| :-- | --: |\n| 1 | *2* |\n",
+		render.FormatLaTeX:    "\\item \\texttt{[x]} \\sout{old}\n\\end{itemize}\n\n\\begin{tabular}{lr}\na & b | c \\\\\n\\hline\n1 & \\emph{2} \\\\\n\\end{tabular}\n",
+		render.FormatDocBook:  `<thead>` + "\n<row><entry>a</entry><entry>b | c</entry></row>\n</thead>\n<tbody>\n<row><entry>1</entry><entry><emphasis>2</emphasis></entry></row>\n</tbody>\n",
+		render.FormatJira:     "* (x) -old-\n\n||a||b \\| c\n|1|_2_\n",
+		render.FormatAsciiDoc: "* [x] [.line-through]##old##\n\n[cols=\"<,>\",options=\"header\"]\n|===\n|a |b \\| c\n\n|1 |_2_\n|===\n",
+		render.FormatBBCode:   "[tr][th]a[/th][th]b | c[/th][/tr]\n[tr][td]1[/td][td][i]2[/i][/td][/tr]\n",
+		render.FormatMan:      "[x] old\n.PP\na | b | c\n.br\n1 | \\fI2\\fP\n",
+		render.FormatText:     "- [x] old\n\na | b | c\n1 | 2\n",
+	} {
+		r, err := render.NewRenderer(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := r.Render(&b, doc.Root); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("%s rendered\n%s\nwithout\n%s", format, b.String(), want)
+		}
+	}
+}
+
+// TestGFMMarkdownRoundTrip checks tables written as Markdown read back as
+// they were
+func TestGFMMarkdownRoundTrip(t *testing.T) {
+	const md = "| a | b \\| c |\n| :-- | :-: |\n| `x` | **y** |\n| z |  |\n"
+	p := parser.New(parser.WithDialect(parser.GFM))
+	doc, err := p.Parse("test", md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := render.NewMarkdownRenderer().Render(&b, doc.Root); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != md {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), md)
+	}
+}
@@ -0,0 +1,196 @@
+package render
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"unicode"
+
+	"../parser"
+)
+
+// HTMLRenderer writes a node tree out as an HTML fragment
+type HTMLRenderer struct {
+	cfg Config
+}
+
+// NewHTMLRenderer returns an HTML renderer configured by opts
+func NewHTMLRenderer(opts ...Option) *HTMLRenderer {
+	return &HTMLRenderer{cfg: newConfig(FormatHTML, opts)}
+}
+
+// Render writes n and everything beneath it to w as HTML. n can be any
+// node, so a single section or list item can be rendered on its own
+func (r *HTMLRenderer) Render(w io.Writer, n *parser.Node) error {
+	w, done := r.cfg.track(w, n)
+	defer done()
+	b := getBuffer()
+	defer putBuffer(b)
+	r.renderNode(b, n)
+	return r.cfg.write(w, b)
+}
+
+// RenderDocument writes the whole of doc to w as HTML
+func (r *HTMLRenderer) RenderDocument(w io.Writer, doc *parser.Document) error {
+	return r.Render(w, doc.Root)
+}
+
+// class returns a class attribute listing names, each carrying the
+// configured prefix. All classes must be emitted through here
+func (r *HTMLRenderer) class(names ...string) string {
+	for i, name := range names {
+		names[i] = r.cfg.ClassPrefix + name
+	}
+	return ` class="` + escaper.Replace(strings.Join(names, " ")) + `"`
+}
+
+// url returns dest, the URL of a link or, if image, an image, or "" if it
+// could run a script and the renderer isn't unsafe
+func (r *HTMLRenderer) url(dest string, image bool) string {
+	if r.cfg.Unsafe || safeURL(dest, image) {
+		return dest
+	}
+	return ""
+}
+
+// safeURL reports whether dest, the URL of a link or, if image, an image,
+// is safe to write in HTML: it hasn't a scheme that runs scripts, and it
+// isn't data but an image's. Browsers skip whitespace & control
+// characters in schemes, and they're told apart from paths by their colon
+func safeURL(dest string, image bool) bool {
+	colon := strings.IndexByte(dest, ':')
+	if colon < 0 || strings.ContainsAny(dest[:colon], "/?#") {
+		return true // Relative
+	}
+	scheme := strings.Map(func(c rune) rune {
+		if c <= ' ' || c == 0x7f {
+			return -1
+		}
+		return unicode.ToLower(c)
+	}, dest[:colon])
+	switch scheme {
+	case "javascript", "vbscript":
+		return false
+	case "data":
+		return image && strings.HasPrefix(strings.ToLower(strings.TrimLeft(dest[colon+1:], " \t\n\r")), "image/")
+	}
+	return true
+}
+
+// nl ends a line of block-level output unless minifying
+func (r *HTMLRenderer) nl(b *bytes.Buffer) {
+	if !r.cfg.Minify {
+		b.WriteByte('\n')
+	}
+}
+
+func (r *HTMLRenderer) renderChildren(b *bytes.Buffer, n *parser.Node) {
+	for _, c := range n.Children {
+		r.renderNode(b, c)
+	}
+}
+
+func (r *HTMLRenderer) renderNode(b *bytes.Buffer, n *parser.Node) {
+	if r.cfg.skip[n.Kind] {
+		return
+	}
+	if h := r.cfg.hooks[n.Kind]; h != nil {
+		h(b, n, true)
+		r.renderChildren(b, n)
+		h(b, n, false)
+		return
+	}
+	switch n.Kind {
+	case parser.NodeDocument:
+		r.renderChildren(b, n)
+	case parser.NodeParagraph:
+		b.WriteString("<p>")
+		r.renderChildren(b, n)
+		b.WriteString("</p>")
+		r.nl(b)
+	case parser.NodeHeading:
+		b.WriteString("<h")
+		b.WriteByte('0' + byte(n.Level))
+		if n.ID != "" {
+			b.WriteString(` id="` + escaper.Replace(n.ID) + `"`)
+		}
+		b.WriteByte('>')
+		r.renderChildren(b, n)
+		b.WriteString("</h")
+		b.WriteByte('0' + byte(n.Level))
+		b.WriteByte('>')
+		r.nl(b)
+	case parser.NodeThematicBreak:
+		b.WriteString("<hr />")
+		r.nl(b)
+	case parser.NodeList:
+		tag := "ul"
+		if n.Ordered {
+			tag = "ol"
+		}
+		b.WriteString("<" + tag + ">")
+		r.nl(b)
+		r.renderChildren(b, n)
+		b.WriteString("</" + tag + ">")
+		r.nl(b)
+	case parser.NodeListItem:
+		b.WriteString("<li>")
+		r.renderChildren(b, n)
+		b.WriteString("</li>")
+		r.nl(b)
+	case parser.NodeCodeBlock:
+		b.WriteString("<pre><code")
+		if lang := strings.Fields(n.Info); len(lang) > 0 {
+			b.WriteString(r.class("language-" + lang[0]))
+		} else if guess, ok := n.Data.(parser.GuessedLanguage); ok {
+			b.WriteString(r.class("language-" + guess.Lang))
+		}
+		b.WriteString(">")
+		escaper.WriteString(b, n.Literal)
+		b.WriteString("</code></pre>")
+		r.nl(b)
+	case parser.NodeBlockQuote:
+		b.WriteString("<blockquote>")
+		r.nl(b)
+		r.renderChildren(b, n)
+		b.WriteString("</blockquote>")
+		r.nl(b)
+	case parser.NodeText:
+		escaper.WriteString(b, n.Literal)
+	case parser.NodeSoftBreak:
+		if r.cfg.Minify {
+			b.WriteByte(' ')
+		} else {
+			b.WriteByte('\n')
+		}
+	case parser.NodeHardBreak:
+		b.WriteString("<br />")
+		r.nl(b)
+	case parser.NodeEmphasis:
+		b.WriteString("<em>")
+		r.renderChildren(b, n)
+		b.WriteString("</em>")
+	case parser.NodeStrong:
+		b.WriteString("<strong>")
+		r.renderChildren(b, n)
+		b.WriteString("</strong>")
+	case parser.NodeCodeSpan:
+		b.WriteString("<code>" + escaper.Replace(n.Literal) + "</code>")
+	case parser.NodeLink:
+		b.WriteString(`<a href="` + escaper.Replace(r.url(n.Dest, false)) + `"`)
+		if n.Title != "" {
+			b.WriteString(` title="` + escaper.Replace(n.Title) + `"`)
+		}
+		b.WriteString(">")
+		r.renderChildren(b, n)
+		b.WriteString("</a>")
+	case parser.NodeImage:
+		b.WriteString(`<img src="` + escaper.Replace(r.url(n.Dest, true)) + `" alt="`)
+		b.WriteString(escaper.Replace(n.PlainText()))
+		b.WriteString(`"`)
+		if n.Title != "" {
+			b.WriteString(` title="` + escaper.Replace(n.Title) + `"`)
+		}
+		b.WriteString(" />")
+	}
+}
@@ -0,0 +1,55 @@
+package render_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"../parser"
+	"../render"
+)
+
+func TestHTMLURLs(t *testing.T) {
+	for _, tt := range []struct {
+		md, want string
+	}{
+		{"[x](http://example.com)", `<a href="http://example.com">`},
+		{"[x](page.md#a:b)", `<a href="page.md#a:b">`},
+		{"[x](mailto:a@example.com)", `<a href="mailto:a@example.com">`},
+		{"[x](javascript:alert(document.cookie))", `<a href="">`},
+		{"[x](JavaScript:alert(1))", `<a href="">`},
+		{"[x](<java\tscript:alert(1)>)", `<a href="">`},
+		{"[x](vbscript:msgbox)", `<a href="">`},
+		{"[x](data:text/html;base64,PHNjcmlwdD4=)", `<a href="">`},
+		{"[x](data:image/png;base64,AAAA)", `<a href="">`},
+		{"![x](data:image/png;base64,AAAA)", `<img src="data:image/png;base64,AAAA"`},
+		{"![x](data:text/html;base64,AAAA)", `<img src=""`},
+		{"![x](javascript:alert(1))", `<img src=""`},
+	} {
+		doc, err := parser.Parse("test.md", tt.md)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := render.NewHTMLRenderer().Render(&b, doc.Root); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(b.String(), tt.want) {
+			t.Errorf("%q: got %q, want %q in it", tt.md, b.String(), tt.want)
+		}
+	}
+}
+
+func TestHTMLUnsafeURLs(t *testing.T) {
+	doc, err := parser.Parse("test.md", "[x](javascript:alert(1))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := render.NewHTMLRenderer(render.WithUnsafe()).Render(&b, doc.Root); err != nil {
+		t.Fatal(err)
+	}
+	if want := `<a href="javascript:alert(1)">`; !strings.Contains(b.String(), want) {
+		t.Errorf("got %q, want %q in it", b.String(), want)
+	}
+}
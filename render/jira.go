@@ -0,0 +1,140 @@
+package render
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"../parser"
+)
+
+// jiraEscaper escapes characters that Jira would read as markup
+var jiraEscaper = strings.NewReplacer(
+	"{", `\{`,
+	"}", `\}`,
+	"[", `\[`,
+	"]", `\]`,
+	"*", `\*`,
+	"_", `\_`,
+	"|", `\|`,
+)
+
+// JiraRenderer writes a node tree out as Jira/Confluence wiki markup
+type JiraRenderer struct {
+	cfg Config
+}
+
+// NewJiraRenderer returns a Jira wiki markup renderer configured by opts
+func NewJiraRenderer(opts ...Option) *JiraRenderer {
+	return &JiraRenderer{cfg: newConfig(FormatJira, opts)}
+}
+
+// Render writes n to w as Jira wiki markup
+func (r *JiraRenderer) Render(w io.Writer, n *parser.Node) error {
+	w, done := r.cfg.track(w, n)
+	defer done()
+	b := getBuffer()
+	defer putBuffer(b)
+	r.renderNode(b, n)
+	return r.cfg.writeTrimmed(w, b)
+}
+
+func (r *JiraRenderer) renderChildren(b *bytes.Buffer, n *parser.Node) {
+	for _, c := range n.Children {
+		r.renderNode(b, c)
+	}
+}
+
+func (r *JiraRenderer) renderNode(b *bytes.Buffer, n *parser.Node) {
+	if r.cfg.skip[n.Kind] {
+		return
+	}
+	if h := r.cfg.hooks[n.Kind]; h != nil {
+		h(b, n, true)
+		r.renderChildren(b, n)
+		h(b, n, false)
+		return
+	}
+	switch n.Kind {
+	case parser.NodeDocument:
+		r.renderChildren(b, n)
+	case parser.NodeParagraph:
+		r.renderChildren(b, n)
+		b.WriteString("\n\n")
+	case parser.NodeHeading:
+		b.WriteString("h" + string(rune('0'+n.Level)) + ". ")
+		r.renderChildren(b, n)
+		b.WriteString("\n\n")
+	case parser.NodeThematicBreak:
+		b.WriteString("----\n\n")
+	case parser.NodeList:
+		r.renderChildren(b, n)
+		b.WriteString("\n")
+	case parser.NodeListItem:
+		b.WriteString(listMarkers(n, "*", "#") + " ")
+		r.renderChildren(b, n)
+		b.WriteString("\n")
+	case parser.NodeCodeBlock:
+		b.WriteString("{code")
+		if lang := strings.Fields(n.Info); len(lang) > 0 {
+			b.WriteString(":" + lang[0])
+		}
+		b.WriteString("}\n" + n.Literal)
+		if !strings.HasSuffix(n.Literal, "\n") {
+			b.WriteString("\n")
+		}
+		b.WriteString("{code}\n\n")
+	case parser.NodeBlockQuote:
+		b.WriteString("{quote}\n")
+		var inner bytes.Buffer
+		r.renderChildren(&inner, n)
+		b.WriteString(strings.TrimRight(inner.String(), "\n"))
+		b.WriteString("\n{quote}\n\n")
+	case parser.NodeText:
+		b.WriteString(jiraEscaper.Replace(n.Literal))
+	case parser.NodeSoftBreak:
+		b.WriteString("\n")
+	case parser.NodeHardBreak:
+		b.WriteString("\\\\\n")
+	case parser.NodeEmphasis:
+		b.WriteString("_")
+		r.renderChildren(b, n)
+		b.WriteString("_")
+	case parser.NodeStrong:
+		b.WriteString("*")
+		r.renderChildren(b, n)
+		b.WriteString("*")
+	case parser.NodeCodeSpan:
+		b.WriteString("{{" + n.Literal + "}}")
+	case parser.NodeLink:
+		b.WriteString("[")
+		if len(n.Children) > 0 {
+			r.renderChildren(b, n)
+			b.WriteString("|")
+		}
+		b.WriteString(n.Dest + "]")
+	case parser.NodeImage:
+		b.WriteString("!" + n.Dest)
+		if alt := n.PlainText(); alt != "" {
+			b.WriteString("|alt=" + alt)
+		}
+		b.WriteString("!")
+	}
+}
+
+// listMarkers returns the run of bullet or number markers that prefixes a
+// list item in wiki syntaxes where nesting depth is shown by repetition
+func listMarkers(item *parser.Node, bullet, number string) string {
+	var markers []string
+	for p := item.Parent; p != nil; p = p.Parent {
+		if p.Kind != parser.NodeList {
+			continue
+		}
+		if p.Ordered {
+			markers = append([]string{number}, markers...)
+		} else {
+			markers = append([]string{bullet}, markers...)
+		}
+	}
+	return strings.Join(markers, "")
+}
@@ -0,0 +1,132 @@
+package render
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"../parser"
+)
+
+// jsonNode is the JSON form of a Node, leaving out fields that are unset
+type jsonNode struct {
+	Kind     string      `json:"kind"`
+	Level    int         `json:"level,omitempty"`
+	Ordered  bool        `json:"ordered,omitempty"`
+	Info     string      `json:"info,omitempty"`
+	Literal  string      `json:"literal,omitempty"`
+	Dest     string      `json:"dest,omitempty"`
+	Title    string      `json:"title,omitempty"`
+	ID       string      `json:"id,omitempty"`
+	Range    *[2]int     `json:"range,omitempty"` // Start & end offsets, with WithSourceRanges
+	Children []*jsonNode `json:"children,omitempty"`
+}
+
+// jsonDocument is the JSON form of a Document
+type jsonDocument struct {
+	Name string            `json:"name"`
+	Meta map[string]string `json:"meta,omitempty"`
+	Root *jsonNode         `json:"root"`
+}
+
+// JSONRenderer writes a node tree out as JSON, for tools in other languages
+// to work with the syntax tree. Each node is an object with its "kind",
+// the fields that are set for it, and its "children"
+type JSONRenderer struct {
+	cfg Config
+}
+
+// NewJSONRenderer returns a JSON renderer configured by opts. Minifying
+// leaves out the indentation
+func NewJSONRenderer(opts ...Option) *JSONRenderer {
+	return &JSONRenderer{cfg: newConfig(FormatJSON, opts)}
+}
+
+// Render writes n & everything beneath it to w as a JSON object
+func (r *JSONRenderer) Render(w io.Writer, n *parser.Node) error {
+	return r.render(w, n, toJSON(n))
+}
+
+// RenderDocument writes doc to w as a JSON object holding its name, front
+// matter & "root" node
+func (r *JSONRenderer) RenderDocument(w io.Writer, doc *parser.Document) error {
+	return r.render(w, doc.Root, &jsonDocument{Name: doc.Name, Meta: doc.Meta, Root: toJSON(doc.Root)})
+}
+
+func (r *JSONRenderer) render(w io.Writer, n *parser.Node, v interface{}) error {
+	w, done := r.cfg.track(w, n)
+	defer done()
+	b := getBuffer()
+	defer putBuffer(b)
+	enc := json.NewEncoder(b)
+	enc.SetEscapeHTML(false)
+	if !r.cfg.Minify {
+		enc.SetIndent("", "  ")
+	}
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	return r.cfg.write(w, b)
+}
+
+// toJSON converts the tree under n to its JSON form
+func toJSON(n *parser.Node) *jsonNode {
+	j := &jsonNode{
+		Kind:    n.Kind.String(),
+		Level:   n.Level,
+		Ordered: n.Ordered,
+		Info:    n.Info,
+		Literal: n.Literal,
+		Dest:    n.Dest,
+		Title:   n.Title,
+		ID:      n.ID,
+	}
+	if n.Range != (parser.Range{}) {
+		j.Range = &[2]int{n.Range.Start, n.Range.End}
+	}
+	for _, c := range n.Children {
+		j.Children = append(j.Children, toJSON(c))
+	}
+	return j
+}
+
+// DecodeJSON reads a document back from the JSON RenderDocument writes, so
+// a tool can hand a syntax tree it changed back to be rendered. Nodes'
+// Data isn't kept in JSON, so is lost
+func DecodeJSON(r io.Reader) (*parser.Document, error) {
+	var j jsonDocument
+	if err := json.NewDecoder(r).Decode(&j); err != nil {
+		return nil, err
+	}
+	if j.Root == nil {
+		return nil, errors.New("no root node")
+	}
+	root, err := fromJSON(j.Root)
+	if err != nil {
+		return nil, err
+	}
+	return &parser.Document{Name: j.Name, Meta: j.Meta, Root: root}, nil
+}
+
+// fromJSON converts the JSON form of a tree back to nodes
+func fromJSON(j *jsonNode) (*parser.Node, error) {
+	kind, ok := parser.NodeKindNamed(j.Kind)
+	if !ok {
+		return nil, fmt.Errorf("unknown node kind %q", j.Kind)
+	}
+	n := parser.NewNode(kind)
+	n.Level, n.Ordered, n.Info, n.Literal = j.Level, j.Ordered, j.Info, j.Literal
+	n.Dest, n.Title, n.ID = j.Dest, j.Title, j.ID
+	if j.Range != nil {
+		n.Range = parser.Range{Start: j.Range[0], End: j.Range[1]}
+	}
+	for _, c := range j.Children {
+		child, err := fromJSON(c)
+		if err != nil {
+			return nil, err
+		}
+		n.AppendChild(child)
+	}
+	return n, nil
+}
@@ -0,0 +1,168 @@
+package render
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"../parser"
+)
+
+const latexHeader = `\documentclass{article}
+\usepackage[utf8]{inputenc}
+\usepackage{hyperref}
+\usepackage{graphicx}
+\usepackage[normalem]{ulem}
+\usepackage{alltt}
+\begin{document}
+
+`
+
+// latexEscaper escapes LaTeX's special characters in text
+var latexEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	"{", `\{`,
+	"}", `\}`,
+	"$", `\$`,
+	"&", `\&`,
+	"#", `\#`,
+	"%", `\%`,
+	"_", `\_`,
+	"~", `\textasciitilde{}`,
+	"^", `\textasciicircum{}`,
+)
+
+// latexAllttEscaper escapes the characters alltt doesn't take literally,
+// leaving nothing in code that could end it
+var latexAllttEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	"{", `\{`,
+	"}", `\}`,
+)
+
+// latexURLEscaper escapes the characters \href & \includegraphics don't
+// take literally in a URL
+var latexURLEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"{", `\{`,
+	"}", `\}`,
+	"#", `\#`,
+	"%", `\%`,
+)
+
+// latexSections are the sectioning commands for each level of heading
+var latexSections = []string{`\section`, `\subsection`, `\subsubsection`, `\paragraph`, `\subparagraph`, `\subparagraph`}
+
+// LaTeXRenderer writes a node tree out as LaTeX
+type LaTeXRenderer struct {
+	cfg Config
+}
+
+// NewLaTeXRenderer returns a LaTeX renderer configured by opts
+func NewLaTeXRenderer(opts ...Option) *LaTeXRenderer {
+	return &LaTeXRenderer{cfg: newConfig(FormatLaTeX, opts)}
+}
+
+// Render writes n to w as LaTeX. A Document node produces a complete
+// article, anything else a fragment to include in one
+func (r *LaTeXRenderer) Render(w io.Writer, n *parser.Node) error {
+	w, done := r.cfg.track(w, n)
+	defer done()
+	b := getBuffer()
+	defer putBuffer(b)
+	if n.Kind == parser.NodeDocument {
+		b.WriteString(latexHeader)
+		r.renderNode(b, n)
+		b.WriteString(`\end{document}` + "\n")
+	} else {
+		r.renderNode(b, n)
+	}
+	return r.cfg.writeTrimmed(w, b)
+}
+
+func (r *LaTeXRenderer) renderChildren(b *bytes.Buffer, n *parser.Node) {
+	for _, c := range n.Children {
+		r.renderNode(b, c)
+	}
+}
+
+func (r *LaTeXRenderer) renderNode(b *bytes.Buffer, n *parser.Node) {
+	if r.cfg.skip[n.Kind] {
+		return
+	}
+	if h := r.cfg.hooks[n.Kind]; h != nil {
+		h(b, n, true)
+		r.renderChildren(b, n)
+		h(b, n, false)
+		return
+	}
+	switch n.Kind {
+	case parser.NodeDocument:
+		r.renderChildren(b, n)
+	case parser.NodeParagraph:
+		r.renderChildren(b, n)
+		b.WriteString("\n\n")
+	case parser.NodeHeading:
+		b.WriteString(latexSections[n.Level-1] + "{")
+		r.renderChildren(b, n)
+		b.WriteString("}\n\n")
+	case parser.NodeThematicBreak:
+		b.WriteString(`\noindent\rule{\textwidth}{0.4pt}` + "\n\n")
+	case parser.NodeList:
+		env := "itemize"
+		if n.Ordered {
+			env = "enumerate"
+		}
+		b.WriteString(`\begin{` + env + "}\n")
+		r.renderChildren(b, n)
+		b.WriteString(`\end{` + env + "}\n\n")
+	case parser.NodeListItem:
+		b.WriteString(`\item `)
+		r.renderChildren(b, n)
+		b.WriteString("\n")
+	case parser.NodeCodeBlock:
+		env, code := "verbatim", n.Literal
+		if strings.Contains(code, `\end{verbatim}`) { // It would end verbatim early
+			env, code = "alltt", latexAllttEscaper.Replace(code)
+		}
+		b.WriteString(`\begin{` + env + "}\n" + code)
+		if !strings.HasSuffix(code, "\n") {
+			b.WriteString("\n")
+		}
+		b.WriteString(`\end{` + env + "}\n\n")
+	case parser.NodeBlockQuote:
+		b.WriteString(`\begin{quote}` + "\n")
+		var inner bytes.Buffer
+		r.renderChildren(&inner, n)
+		b.WriteString(strings.TrimRight(inner.String(), "\n"))
+		b.WriteString("\n" + `\end{quote}` + "\n\n")
+	case parser.NodeText:
+		latexEscaper.WriteString(b, n.Literal)
+	case parser.NodeSoftBreak:
+		b.WriteString("\n")
+	case parser.NodeHardBreak:
+		b.WriteString(`\\` + "\n")
+	case parser.NodeEmphasis:
+		b.WriteString(`\emph{`)
+		r.renderChildren(b, n)
+		b.WriteString("}")
+	case parser.NodeStrong:
+		b.WriteString(`\textbf{`)
+		r.renderChildren(b, n)
+		b.WriteString("}")
+	case parser.NodeCodeSpan:
+		b.WriteString(`\texttt{` + latexEscaper.Replace(n.Literal) + "}")
+	case parser.NodeLink:
+		b.WriteString(`\href{` + latexURLEscaper.Replace(n.Dest) + "}{")
+		r.renderChildren(b, n)
+		b.WriteString("}")
+	case parser.NodeImage:
+		b.WriteString(`\includegraphics{` + latexURLEscaper.Replace(n.Dest) + "}")
+	case parser.NodeFootnoteRef:
+		if note := footnoteOf(n); note != nil {
+			b.WriteString(`\footnote{`)
+			r.renderChildren(b, note)
+			b.WriteString("}")
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package render_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"../parser"
+	"../render"
+)
+
+func TestLaTeXCodeBlocks(t *testing.T) {
+	for md, want := range map[string]string{
+		"```\nx := `a` & {b}\n```\n":                 "\\begin{verbatim}\nx := `a` & {b}\n\\end{verbatim}",
+		"```\na\n\\end{verbatim}\n\\input{x}\n```\n": "\\begin{alltt}\na\n\\textbackslash{}end\\{verbatim\\}\n\\textbackslash{}input\\{x\\}\n\\end{alltt}",
+	} {
+		doc, err := parser.Parse("test", md)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := render.NewLaTeXRenderer().Render(&b, doc.Root.Children[0]); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSpace(b.String()); got != want {
+			t.Errorf("%q rendered as\n%s\nnot\n%s", md, got, want)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"../parser"
+)
+
+// manEscaper escapes text for roff, which reads backslashes as escapes
+var manEscaper = strings.NewReplacer(`\`, `\e`)
+
+// ManRenderer writes a node tree out as a roff man page. Top-level headings
+// become sections (.SH) and the rest subsections (.SS)
+type ManRenderer struct {
+	cfg Config
+}
+
+// NewManRenderer returns a man page renderer configured by opts
+func NewManRenderer(opts ...Option) *ManRenderer {
+	return &ManRenderer{cfg: newConfig(FormatMan, opts)}
+}
+
+// Render writes n to w as roff. A Document node produces a whole page,
+// titled after its first heading, in section 1
+func (r *ManRenderer) Render(w io.Writer, n *parser.Node) error {
+	title := ""
+	for _, c := range n.Children {
+		if c.Kind == parser.NodeHeading {
+			title = c.PlainText()
+			break
+		}
+	}
+	return r.render(w, n, title, "1", "")
+}
+
+// RenderDocument writes doc to w as a man page titled after doc, in the
+// section & with the date given by its "section" & "date" fields
+func (r *ManRenderer) RenderDocument(w io.Writer, doc *parser.Document) error {
+	section := doc.Meta["section"]
+	if section == "" {
+		section = "1"
+	}
+	return r.render(w, doc.Root, doc.Title(), section, doc.Meta["date"])
+}
+
+func (r *ManRenderer) render(w io.Writer, n *parser.Node, title, section, date string) error {
+	w, done := r.cfg.track(w, n)
+	defer done()
+	b := getBuffer()
+	defer putBuffer(b)
+	if n.Kind == parser.NodeDocument {
+		fmt.Fprintf(b, ".TH %s %s %s\n", manQuote(strings.ToUpper(title)), manQuote(section), manQuote(date))
+	}
+	r.renderNode(b, n)
+	return r.cfg.writeTrimmed(w, b)
+}
+
+// manQuote makes s a single argument to a roff request
+func manQuote(s string) string {
+	return `"` + strings.Replace(manEscaper.Replace(s), `"`, `""`, -1) + `"`
+}
+
+// manLine writes a line of text to b, protecting it from being read as a
+// request if it starts with a dot or an apostrophe where b is at the start
+// of a line
+func manLine(b *bytes.Buffer, line string) {
+	if (b.Len() == 0 || b.Bytes()[b.Len()-1] == '\n') && (strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'")) {
+		b.WriteString(`\&`)
+	}
+	b.WriteString(line)
+}
+
+func (r *ManRenderer) renderChildren(b *bytes.Buffer, n *parser.Node) {
+	for _, c := range n.Children {
+		r.renderNode(b, c)
+	}
+}
+
+// renderText renders the inline children of n as lines of text
+func (r *ManRenderer) renderText(b *bytes.Buffer, n *parser.Node) {
+	r.renderChildren(b, n)
+	b.WriteString("\n")
+}
+
+func (r *ManRenderer) renderNode(b *bytes.Buffer, n *parser.Node) {
+	if r.cfg.skip[n.Kind] {
+		return
+	}
+	if h := r.cfg.hooks[n.Kind]; h != nil {
+		h(b, n, true)
+		r.renderChildren(b, n)
+		h(b, n, false)
+		return
+	}
+	switch n.Kind {
+	case parser.NodeDocument, parser.NodeList:
+		r.renderChildren(b, n)
+	case parser.NodeParagraph:
+		b.WriteString(".PP\n")
+		r.renderText(b, n)
+	case parser.NodeHeading:
+		if n.Level == 1 {
+			b.WriteString(".SH ")
+		} else {
+			b.WriteString(".SS ")
+		}
+		b.WriteString(manQuote(n.PlainText()) + "\n")
+	case parser.NodeThematicBreak:
+		b.WriteString(".sp\n")
+	case parser.NodeListItem:
+		if n.Parent != nil && n.Parent.Ordered {
+			b.WriteString(".IP " + strconv.Itoa(itemNumber(n)) + ". 4\n")
+		} else {
+			b.WriteString(".IP \\(bu 2\n")
+		}
+		r.renderText(b, n)
+	case parser.NodeCodeBlock:
+		b.WriteString(".PP\n.RS 4\n.nf\n")
+		for _, line := range strings.SplitAfter(strings.TrimSuffix(n.Literal, "\n"), "\n") {
+			manLine(b, manEscaper.Replace(line))
+		}
+		b.WriteString("\n.fi\n.RE\n")
+	case parser.NodeBlockQuote:
+		b.WriteString(".RS 4\n")
+		r.renderChildren(b, n)
+		b.WriteString(".RE\n")
+	case parser.NodeText:
+		manLine(b, manEscaper.Replace(n.Literal))
+	case parser.NodeSoftBreak:
+		b.WriteString("\n")
+	case parser.NodeHardBreak:
+		b.WriteString("\n.br\n")
+	case parser.NodeEmphasis:
+		b.WriteString(`\fI`)
+		r.renderChildren(b, n)
+		b.WriteString(`\fP`)
+	case parser.NodeStrong, parser.NodeCodeSpan:
+		b.WriteString(`\fB`)
+		if n.Kind == parser.NodeCodeSpan {
+			manEscaper.WriteString(b, n.Literal)
+		}
+		r.renderChildren(b, n)
+		b.WriteString(`\fP`)
+	case parser.NodeLink:
+		r.renderChildren(b, n)
+		if n.PlainText() != n.Dest {
+			b.WriteString(" <" + manEscaper.Replace(n.Dest) + ">")
+		}
+	case parser.NodeImage:
+		manLine(b, manEscaper.Replace(n.PlainText()))
+	}
+}
@@ -0,0 +1,243 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"../parser"
+)
+
+// mdEscaper escapes characters that would otherwise be read as inline
+// markup
+var mdEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"*", `\*`,
+	"_", `\_`,
+	"`", "\\`",
+	"[", `\[`,
+	"]", `\]`,
+	"<", `\<`,
+)
+
+// MarkdownRenderer writes a node tree back out as Markdown in a canonical
+// style: ATX headings, "-" bullets, "```" fences, "*" emphasis & "**"
+// strong emphasis, with blocks separated by blank lines. Links & images
+// are written inline, [text](dest "title"), unless ReferenceLinks is set
+type MarkdownRenderer struct {
+	cfg Config
+
+	// ReferenceLinks writes links & images in reference style, [text][1],
+	// with the definitions of the references numbered in order of first
+	// use at the end
+	ReferenceLinks bool
+
+	refs *mdRefs // Collected while rendering with ReferenceLinks
+}
+
+// mdRefs are the link references of a Markdown rendering, numbered in
+// order of first use
+type mdRefs struct {
+	labels map[string]int // By definition
+	defs   []string       // Destination & title of each, by number - 1
+}
+
+// NewMarkdownRenderer returns a Markdown renderer configured by opts
+func NewMarkdownRenderer(opts ...Option) *MarkdownRenderer {
+	return &MarkdownRenderer{cfg: newConfig(FormatMarkdown, opts)}
+}
+
+// Render writes n to w as Markdown
+func (r *MarkdownRenderer) Render(w io.Writer, n *parser.Node) error {
+	return r.render(w, n, "")
+}
+
+// RenderDocument writes doc to w as Markdown, front matter included
+func (r *MarkdownRenderer) RenderDocument(w io.Writer, doc *parser.Document) error {
+	return r.render(w, doc.Root, doc.FrontMatter)
+}
+
+func (r *MarkdownRenderer) render(w io.Writer, n *parser.Node, frontMatter string) error {
+	w, done := r.cfg.track(w, n)
+	defer done()
+	b := getBuffer()
+	defer putBuffer(b)
+	b.WriteString(frontMatter)
+	if r.ReferenceLinks {
+		rr := *r // With references of its own, as r may be in use elsewhere
+		rr.refs = &mdRefs{labels: make(map[string]int)}
+		r = &rr
+	}
+	r.renderNode(b, n)
+	if r.refs != nil && len(r.refs.defs) > 0 {
+		b.Truncate(len(bytes.TrimRight(b.Bytes(), "\n")))
+		b.WriteString("\n\n")
+		for i, def := range r.refs.defs {
+			fmt.Fprintf(b, "[%d]: %s\n", i+1, def)
+		}
+	}
+	return r.cfg.writeTrimmed(w, b)
+}
+
+// linkTail returns the (destination "title") of a link or image, or its
+// [label] if references are being collected
+func (r *MarkdownRenderer) linkTail(n *parser.Node) string {
+	if r.refs == nil {
+		return "(" + linkDef(n) + ")"
+	}
+	def := linkDef(n)
+	label, ok := r.refs.labels[def]
+	if !ok {
+		r.refs.defs = append(r.refs.defs, def)
+		label = len(r.refs.defs)
+		r.refs.labels[def] = label
+	}
+	return "[" + strconv.Itoa(label) + "]"
+}
+
+func (r *MarkdownRenderer) renderChildren(b *bytes.Buffer, n *parser.Node) {
+	for _, c := range n.Children {
+		r.renderNode(b, c)
+	}
+}
+
+// renderLines renders the children of n, then escapes whatever would be
+// read as the start of a block at the start of each line
+func (r *MarkdownRenderer) renderLines(b *bytes.Buffer, n *parser.Node) {
+	var inner bytes.Buffer
+	r.renderChildren(&inner, n)
+	lines := strings.Split(inner.String(), "\n")
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		if startsBlock(line) {
+			b.WriteByte('\\')
+		}
+		b.WriteString(line)
+	}
+}
+
+// startsBlock reports whether line, as text in a paragraph, would be read
+// as a block marker
+func startsBlock(line string) bool {
+	s := strings.TrimLeft(line, " ")
+	if s == "" {
+		return false
+	}
+	switch s[0] {
+	case '#', '>', '-', '+', '=':
+		return true
+	}
+	return strings.HasPrefix(s, "1. ")
+}
+
+func (r *MarkdownRenderer) renderNode(b *bytes.Buffer, n *parser.Node) {
+	if r.cfg.skip[n.Kind] {
+		return
+	}
+	if h := r.cfg.hooks[n.Kind]; h != nil {
+		h(b, n, true)
+		r.renderChildren(b, n)
+		h(b, n, false)
+		return
+	}
+	switch n.Kind {
+	case parser.NodeDocument:
+		r.renderChildren(b, n)
+	case parser.NodeParagraph:
+		r.renderLines(b, n)
+		b.WriteString("\n\n")
+	case parser.NodeHeading:
+		b.WriteString(strings.Repeat("#", n.Level) + " ")
+		var text bytes.Buffer
+		r.renderChildren(&text, n)
+		s := strings.Replace(text.String(), "\n", " ", -1)
+		if t := strings.TrimRight(s, "#"); t != s && (t == "" || strings.HasSuffix(t, " ")) {
+			s = t + `\` + s[len(t):] // Not a closing sequence
+		}
+		b.WriteString(s + "\n\n")
+	case parser.NodeThematicBreak:
+		b.WriteString("---\n\n")
+	case parser.NodeList:
+		r.renderChildren(b, n)
+		b.WriteString("\n")
+	case parser.NodeListItem:
+		marker := "- "
+		if n.Parent != nil && n.Parent.Ordered {
+			marker = "1. " // Numbered in order however they're written
+		}
+		var text bytes.Buffer
+		r.renderLines(&text, n)
+		b.WriteString(marker)
+		b.WriteString(strings.Replace(text.String(), "\n", "\n"+strings.Repeat(" ", len(marker)), -1))
+		b.WriteString("\n")
+	case parser.NodeCodeBlock:
+		b.WriteString("```" + n.Info + "\n" + n.Literal)
+		if n.Literal != "" && !strings.HasSuffix(n.Literal, "\n") {
+			b.WriteString("\n")
+		}
+		b.WriteString("```\n\n")
+	case parser.NodeBlockQuote:
+		var inner bytes.Buffer
+		r.renderChildren(&inner, n)
+		for _, line := range strings.Split(strings.TrimRight(inner.String(), "\n"), "\n") {
+			b.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+		}
+		b.WriteString("\n")
+	case parser.NodeText:
+		if inTableCell(n) {
+			b.WriteString(strings.Replace(mdEscaper.Replace(n.Literal), "|", `\|`, -1))
+		} else {
+			mdEscaper.WriteString(b, n.Literal)
+		}
+	case parser.NodeSoftBreak:
+		b.WriteString("\n")
+	case parser.NodeHardBreak:
+		b.WriteString("  \n")
+	case parser.NodeEmphasis:
+		b.WriteString("*")
+		r.renderChildren(b, n)
+		b.WriteString("*")
+	case parser.NodeStrong:
+		b.WriteString("**")
+		r.renderChildren(b, n)
+		b.WriteString("**")
+	case parser.NodeCodeSpan:
+		fence := "`"
+		for strings.Contains(n.Literal, fence) {
+			fence += "`"
+		}
+		lit := n.Literal
+		if strings.HasPrefix(lit, "`") || strings.HasSuffix(lit, "`") {
+			lit = " " + lit + " "
+		}
+		b.WriteString(fence + lit + fence)
+	case parser.NodeLink:
+		b.WriteString("[")
+		r.renderChildren(b, n)
+		b.WriteString("]" + r.linkTail(n))
+	case parser.NodeImage:
+		b.WriteString("![" + mdEscaper.Replace(n.PlainText()) + "]" + r.linkTail(n))
+	case parser.NodeFootnoteRef:
+		if note := footnoteOf(n); note != nil {
+			b.WriteString("^[")
+			r.renderChildren(b, note)
+			b.WriteString("]")
+		}
+	}
+}
+
+// linkDef returns the destination "title" of a link or image
+func linkDef(n *parser.Node) string {
+	dest := n.Dest
+	if dest == "" || strings.ContainsAny(dest, " ()") {
+		dest = "<" + dest + ">"
+	}
+	if n.Title != "" {
+		dest += ` "` + n.Title + `"`
+	}
+	return dest
+}
@@ -0,0 +1,79 @@
+package render
+
+import (
+	"fmt"
+	"io"
+
+	"../parser"
+)
+
+const htmlPage = `<!DOCTYPE html>
+<html%s>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>%s</title>
+%s</head>
+<body>
+<main>
+%s</main>
+%s</body>
+</html>
+`
+
+// pageMeta are the front matter fields a page describes itself with in
+// meta elements
+var pageMeta = []string{"author", "description", "keywords"}
+
+// PageRenderer wraps the HTML its Body renderer writes in a complete page,
+// titled after the document and styled by a theme or stylesheet. The lang
+// field of the front matter sets the page's language, and its author,
+// description & keywords are given in meta elements
+type PageRenderer struct {
+	Body       Renderer
+	Theme      string // Name of one of the Themes to include in the page
+	Stylesheet string // URL of a stylesheet to link to, after any Theme
+	Head       string // Raw HTML added to the end of the head, such as styles
+	Foot       string // Raw HTML added to the end of the body, such as scripts
+}
+
+// NewPageRenderer returns a renderer writing complete HTML pages of the
+// HTML rendered with opts, styled by the named theme, if not empty
+func NewPageRenderer(theme string, opts ...Option) (*PageRenderer, error) {
+	if _, ok := Themes[theme]; !ok && theme != "" {
+		return nil, fmt.Errorf("unknown theme %q", theme)
+	}
+	return &PageRenderer{Body: NewHTMLRenderer(opts...), Theme: theme}, nil
+}
+
+// Render writes n to w as a page titled after its first heading
+func (r *PageRenderer) Render(w io.Writer, n *parser.Node) error {
+	return r.RenderDocument(w, &parser.Document{Root: n})
+}
+
+// RenderDocument writes doc to w as a page titled after doc
+func (r *PageRenderer) RenderDocument(w io.Writer, doc *parser.Document) error {
+	body := getBuffer()
+	defer putBuffer(body)
+	if err := RenderDocument(r.Body, body, doc); err != nil {
+		return err
+	}
+	lang, head := "", ""
+	if l := doc.Meta["lang"]; l != "" {
+		lang = ` lang="` + escaper.Replace(l) + `"`
+	}
+	for _, name := range pageMeta {
+		if v := doc.Meta[name]; v != "" {
+			head += `<meta name="` + name + `" content="` + escaper.Replace(v) + `">` + "\n"
+		}
+	}
+	if css := Themes[r.Theme]; css != "" {
+		head += "<style>\n" + css + "</style>\n"
+	}
+	if r.Stylesheet != "" {
+		head += `<link rel="stylesheet" href="` + escaper.Replace(r.Stylesheet) + `">` + "\n"
+	}
+	head += r.Head
+	_, err := fmt.Fprintf(w, htmlPage, lang, escaper.Replace(doc.Title()), head, body.String(), r.Foot)
+	return err
+}
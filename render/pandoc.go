@@ -0,0 +1,48 @@
+package render
+
+import (
+	"encoding/json"
+	"io"
+
+	"../pandoc"
+	"../parser"
+)
+
+// PandocRenderer writes a node tree out as Pandoc's JSON syntax tree, for
+// Pandoc to convert further or its filters to rewrite
+type PandocRenderer struct {
+	cfg Config
+}
+
+// NewPandocRenderer returns a Pandoc JSON renderer configured by opts.
+// Minifying leaves out the indentation
+func NewPandocRenderer(opts ...Option) *PandocRenderer {
+	return &PandocRenderer{cfg: newConfig(FormatPandoc, opts)}
+}
+
+// Render writes n, a block or a document, to w as a Pandoc document
+func (r *PandocRenderer) Render(w io.Writer, n *parser.Node) error {
+	return r.render(w, n, pandoc.FromNode(n))
+}
+
+// RenderDocument writes doc to w as a Pandoc document, its front matter
+// the document's metadata
+func (r *PandocRenderer) RenderDocument(w io.Writer, doc *parser.Document) error {
+	return r.render(w, doc.Root, pandoc.FromDocument(doc))
+}
+
+func (r *PandocRenderer) render(w io.Writer, n *parser.Node, p *pandoc.Document) error {
+	w, done := r.cfg.track(w, n)
+	defer done()
+	b := getBuffer()
+	defer putBuffer(b)
+	enc := json.NewEncoder(b)
+	enc.SetEscapeHTML(false)
+	if !r.cfg.Minify {
+		enc.SetIndent("", "  ")
+	}
+	if err := enc.Encode(p); err != nil {
+		return err
+	}
+	return r.cfg.write(w, b)
+}
@@ -0,0 +1,154 @@
+//go:build !js
+
+package render
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"../parser"
+)
+
+// FormatPDF is the format of PDFRenderer. It relies on an engine being
+// installed, so isn't available when building for js/wasm
+const FormatPDF = "pdf"
+
+func init() {
+	renderers[FormatPDF] = func(o ...Option) Renderer { return NewPDFRenderer("", o...) }
+}
+
+// PDFEngines are the commands a PDFRenderer without an Engine looks for on
+// the PATH, in order
+var PDFEngines = []string{"wkhtmltopdf", "weasyprint", "chromium", "chromium-browser", "google-chrome", "chrome"}
+
+// PDFRenderer writes documents as PDF by laying them out as HTML pages and
+// converting those with an external engine, such as wkhtmltopdf, WeasyPrint
+// or Chromium. Each page is headed with the title, author & date from the
+// document's front matter
+type PDFRenderer struct {
+	Page   *PageRenderer
+	Engine string // Command to convert HTML with, the first of PDFEngines found if empty
+}
+
+// NewPDFRenderer returns a renderer converting pages of the HTML rendered
+// with opts, in the print theme, to PDF with engine
+func NewPDFRenderer(engine string, opts ...Option) *PDFRenderer {
+	return &PDFRenderer{
+		Page:   &PageRenderer{Body: NewHTMLRenderer(opts...), Theme: ThemePrint},
+		Engine: engine,
+	}
+}
+
+// Render writes n to w as a PDF titled after its first heading
+func (r *PDFRenderer) Render(w io.Writer, n *parser.Node) error {
+	return r.RenderDocument(w, &parser.Document{Root: n})
+}
+
+// RenderDocument writes doc to w as a PDF. Images & links relative to the
+// file doc was read from are resolved against its directory
+func (r *PDFRenderer) RenderDocument(w io.Writer, doc *parser.Document) error {
+	engine, err := r.engine()
+	if err != nil {
+		return err
+	}
+	dir, err := ioutil.TempDir("", "gomd-pdf")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	in, out := filepath.Join(dir, "page.html"), filepath.Join(dir, "page.pdf")
+
+	page := *r.Page
+	left, right := pageHeader(doc)
+	page.Head += fmt.Sprintf("<style>\n@page { @top-left { content: %s; } @top-right { content: %s; } }\n</style>\n", cssString(left), cssString(right))
+	if doc.Name != "" && doc.Name != "stdin" {
+		if abs, err := filepath.Abs(filepath.Dir(doc.Name)); err == nil {
+			page.Head += `<base href="` + escaper.Replace(fileURL(abs)+"/") + `">` + "\n"
+		}
+	}
+	var html bytes.Buffer
+	if err := page.RenderDocument(&html, doc); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(in, html.Bytes(), 0644); err != nil {
+		return err
+	}
+	cmd := exec.Command(engine, pdfArgs(engine, in, out, left, right)...)
+	if msg, err := cmd.CombinedOutput(); err != nil {
+		if msg = bytes.TrimSpace(msg); len(msg) > 0 {
+			return fmt.Errorf("pdf: %s: %v: %s", engine, err, msg)
+		}
+		return fmt.Errorf("pdf: %s: %v", engine, err)
+	}
+	f, err := os.Open(out)
+	if err != nil {
+		return fmt.Errorf("pdf: %s wrote nothing", engine)
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// engine returns the command to convert HTML with
+func (r *PDFRenderer) engine() (string, error) {
+	if r.Engine != "" {
+		return r.Engine, nil
+	}
+	for _, e := range PDFEngines {
+		if path, err := exec.LookPath(e); err == nil {
+			return path, nil
+		}
+	}
+	return "", errors.New("pdf: no engine found, install one of " + strings.Join(PDFEngines, ", "))
+}
+
+// pdfArgs returns the arguments engine converts the HTML file in to the
+// PDF file out with, headed by left & right on each page where the engine
+// doesn't take the header from the page's styles
+func pdfArgs(engine, in, out, left, right string) []string {
+	name := strings.TrimSuffix(filepath.Base(engine), ".exe")
+	switch {
+	case name == "wkhtmltopdf":
+		args := []string{"--quiet", "--enable-local-file-access"}
+		if left != "" || right != "" {
+			args = append(args, "--header-left", left, "--header-right", right, "--header-font-size", "8", "--header-spacing", "5")
+		}
+		return append(args, in, out)
+	case strings.Contains(name, "chrom"):
+		return []string{"--headless", "--disable-gpu", "--no-pdf-header-footer", "--print-to-pdf=" + out, fileURL(in)}
+	}
+	return []string{in, out} // weasyprint & others taking the input then output
+}
+
+// pageHeader returns the text heading each page of doc: its title on the
+// left, and its author & date on the right
+func pageHeader(doc *parser.Document) (left, right string) {
+	var meta []string
+	for _, key := range []string{"author", "date"} {
+		if v := doc.Meta[key]; v != "" {
+			meta = append(meta, v)
+		}
+	}
+	return doc.Title(), strings.Join(meta, ", ")
+}
+
+// cssString quotes s as a CSS string
+func cssString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\a `, "<", `\3c `).Replace(s) + `"`
+}
+
+// fileURL returns the file URL of the absolute path
+func fileURL(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path // Windows drive letters
+	}
+	return "file://" + path
+}
@@ -0,0 +1,269 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"../parser"
+)
+
+// Renderer writes a node tree out in a particular format. Renderers are not
+// modified by rendering, so one can be shared between goroutines
+type Renderer interface {
+	Render(w io.Writer, n *parser.Node) error
+}
+
+// escaper escapes text for HTML & XML output
+var escaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	`"`, "&quot;",
+)
+
+// Output formats, as reported in Config.Format
+const (
+	FormatHTML     = "html"
+	FormatDocBook  = "docbook"
+	FormatJira     = "jira"
+	FormatAsciiDoc = "asciidoc"
+	FormatBBCode   = "bbcode"
+	FormatMarkdown = "md"
+	FormatText     = "text"
+	FormatLaTeX    = "latex"
+	FormatMan      = "man"
+	FormatJSON     = "json"
+	FormatANSI     = "ansi"
+	FormatPandoc   = "pandoc"
+
+	FormatSlides = "slides" // Reported as FormatHTML, which slides are made of
+)
+
+// renderers builds the renderer for each format NewRenderer knows
+var renderers = map[string]func(...Option) Renderer{
+	FormatHTML:     func(o ...Option) Renderer { return NewHTMLRenderer(o...) },
+	FormatSlides:   func(o ...Option) Renderer { return NewSlidesRenderer(o...) },
+	FormatDocBook:  func(o ...Option) Renderer { return NewDocBookRenderer(o...) },
+	FormatJira:     func(o ...Option) Renderer { return NewJiraRenderer(o...) },
+	FormatAsciiDoc: func(o ...Option) Renderer { return NewAsciiDocRenderer(o...) },
+	FormatBBCode:   func(o ...Option) Renderer { return NewBBCodeRenderer(o...) },
+	FormatMarkdown: func(o ...Option) Renderer { return NewMarkdownRenderer(o...) },
+	FormatText:     func(o ...Option) Renderer { return NewTextRenderer(o...) },
+	FormatLaTeX:    func(o ...Option) Renderer { return NewLaTeXRenderer(o...) },
+	FormatMan:      func(o ...Option) Renderer { return NewManRenderer(o...) },
+	FormatJSON:     func(o ...Option) Renderer { return NewJSONRenderer(o...) },
+	FormatANSI:     func(o ...Option) Renderer { return NewANSIRenderer(o...) },
+	FormatPandoc:   func(o ...Option) Renderer { return NewPandocRenderer(o...) },
+}
+
+// Formats returns the formats NewRenderer knows, sorted
+func Formats() []string {
+	formats := make([]string, 0, len(renderers))
+	for format := range renderers {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// NewRenderer returns the renderer for format, one of the Format constants,
+// configured by opts
+func NewRenderer(format string, opts ...Option) (Renderer, error) {
+	r, ok := renderers[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	return r(opts...), nil
+}
+
+// documentRenderer is implemented by renderers that can make use of a
+// Document's metadata, such as its title
+type documentRenderer interface {
+	RenderDocument(w io.Writer, doc *parser.Document) error
+}
+
+// RenderDocument writes doc to w with r, letting r make use of doc's
+// metadata if it can
+func RenderDocument(r Renderer, w io.Writer, doc *parser.Document) error {
+	if dr, ok := r.(documentRenderer); ok {
+		return dr.RenderDocument(w, doc)
+	}
+	return r.Render(w, doc.Root)
+}
+
+// Config holds the settings shared by the renderers
+type Config struct {
+	Format      string            // Set by the renderer, for extensions to inspect
+	Minify      bool              // Drop whitespace & newlines between tags
+	ClassPrefix string            // Prepended to every class attribute value
+	LineEnding  parser.LineEnding // Written for every line ending, if set
+	Unsafe      bool              // Write every link & image URL in HTML, scripts included
+
+	hooks      map[parser.NodeKind]NodeRenderer
+	skip       map[parser.NodeKind]bool
+	statsHooks []StatsHook
+}
+
+// NodeRenderer writes a node in place of the renderer's own handling of its
+// kind. It is called with entering set before the node's children are
+// rendered, and again with it unset afterwards
+type NodeRenderer func(w io.Writer, n *parser.Node, entering bool)
+
+// Extension customises a renderer, typically to render the node kinds a
+// parser extension introduces. ExtendRenderer is called while the renderer
+// is being built and can check c.Format to see which renderer it is
+type Extension interface {
+	ExtendRenderer(c *Config)
+}
+
+// Option modifies a renderer's Config
+type Option func(*Config)
+
+// WithMinify strips the whitespace and newlines between tags from HTML
+// output. The contents of <pre> & <code> elements are always written byte
+// for byte
+func WithMinify() Option {
+	return func(c *Config) {
+		c.Minify = true
+	}
+}
+
+// WithClassPrefix prepends prefix to every class the renderer emits, so
+// output can be embedded in a page without colliding with its styles
+func WithClassPrefix(prefix string) Option {
+	return func(c *Config) {
+		c.ClassPrefix = prefix
+	}
+}
+
+// WithLineEnding writes every line ending of the output as le, including
+// those within code blocks. By default renderers end lines with \n, and
+// code blocks keep whatever endings the parser left them with
+func WithLineEnding(le parser.LineEnding) Option {
+	return func(c *Config) {
+		c.LineEnding = le
+	}
+}
+
+// WithUnsafe writes the URLs of links & images in HTML as they are. By
+// default javascript: & vbscript: URLs are left out, as are data: URLs
+// but images', so that HTML from markdown someone else wrote can't run
+// scripts in the page it's shown in
+func WithUnsafe() Option {
+	return func(c *Config) {
+		c.Unsafe = true
+	}
+}
+
+// WithNodeRenderer renders nodes of kind with r rather than the renderer's
+// built-in handling
+func WithNodeRenderer(kind parser.NodeKind, r NodeRenderer) Option {
+	return func(c *Config) {
+		c.SetNodeRenderer(kind, r)
+	}
+}
+
+// WithExtensions lets each of exts customise the renderer
+func WithExtensions(exts ...Extension) Option {
+	return func(c *Config) {
+		for _, ext := range exts {
+			ext.ExtendRenderer(c)
+		}
+	}
+}
+
+// SetNodeRenderer renders nodes of kind with r, for use by extensions
+func (c *Config) SetNodeRenderer(kind parser.NodeKind, r NodeRenderer) {
+	if c.hooks == nil {
+		c.hooks = make(map[parser.NodeKind]NodeRenderer)
+	}
+	c.hooks[kind] = r
+}
+
+// SkipNodes leaves nodes of kinds, and everything beneath them, out of the
+// output, for use by extensions. The syntax tree formats keep them
+func (c *Config) SkipNodes(kinds ...parser.NodeKind) {
+	if c.skip == nil {
+		c.skip = make(map[parser.NodeKind]bool)
+	}
+	for _, k := range kinds {
+		c.skip[k] = true
+	}
+}
+
+// maxPooledBuffer is the largest buffer returned to bufPool, so one huge
+// document doesn't pin its buffer in memory indefinitely
+const maxPooledBuffer = 1 << 20
+
+// bufPool recycles output buffers between calls to Render
+var bufPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// getBuffer returns an empty buffer from the pool
+func getBuffer() *bytes.Buffer {
+	b := bufPool.Get().(*bytes.Buffer)
+	b.Reset()
+	return b
+}
+
+// putBuffer returns b to the pool once its contents are no longer needed
+func putBuffer(b *bytes.Buffer) {
+	if b.Cap() <= maxPooledBuffer {
+		bufPool.Put(b)
+	}
+}
+
+// write writes the rendered output in b to w, with the configured line
+// endings
+func (c *Config) write(w io.Writer, b *bytes.Buffer) error {
+	out := b.Bytes()
+	if c.LineEnding != "" {
+		out = convertLineEndings(out, string(c.LineEnding))
+	}
+	_, err := w.Write(out)
+	return err
+}
+
+// writeTrimmed writes b to w with any trailing blank lines reduced to a
+// single line ending, as text formats' block separators leave behind
+func (c *Config) writeTrimmed(w io.Writer, b *bytes.Buffer) error {
+	b.Truncate(len(bytes.TrimRight(b.Bytes(), "\n")))
+	b.WriteByte('\n')
+	return c.write(w, b)
+}
+
+// convertLineEndings returns b with each \r\n, \n & \r replaced by le
+func convertLineEndings(b []byte, le string) []byte {
+	out := make([]byte, 0, len(b)+len(b)/16)
+	for i := 0; i < len(b); i++ {
+		switch b[i] {
+		case '\r':
+			if i+1 < len(b) && b[i+1] == '\n' {
+				i++
+			}
+			out = append(out, le...)
+		case '\n':
+			out = append(out, le...)
+		default:
+			out = append(out, b[i])
+		}
+	}
+	return out
+}
+
+// newConfig applies opts on top of the default settings for format
+func newConfig(format string, opts []Option) Config {
+	c := Config{Format: format}
+	footnotes{}.ExtendRenderer(&c)
+	gfm{}.ExtendRenderer(&c)
+	criticMarkup(CriticShow).ExtendRenderer(&c)
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
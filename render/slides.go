@@ -0,0 +1,111 @@
+package render
+
+import (
+	"fmt"
+	"io"
+
+	"../parser"
+)
+
+const (
+	slideBreak         = "---" // Starts a new horizontal slide
+	verticalSlideBreak = "--"  // Starts a new slide below the current one
+	revealURL          = "https://cdn.jsdelivr.net/npm/reveal.js@5"
+)
+
+const slidesPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<link rel="stylesheet" href="` + revealURL + `/dist/reveal.css">
+<link rel="stylesheet" href="` + revealURL + `/dist/theme/white.css">
+</head>
+<body>
+<div class="reveal">
+<div class="slides">
+%s</div>
+</div>
+<script src="` + revealURL + `/dist/reveal.js"></script>
+<script>Reveal.initialize({hash: true});</script>
+</body>
+</html>
+`
+
+// SlidesRenderer writes a document out as a reveal.js presentation. Each
+// "---" thematic break starts a new slide, and each "--" a new vertical
+// slide stacked beneath the current one
+type SlidesRenderer struct {
+	html *HTMLRenderer
+}
+
+// NewSlidesRenderer returns a slide deck renderer. opts configure the HTML
+// rendered inside each slide
+func NewSlidesRenderer(opts ...Option) *SlidesRenderer {
+	return &SlidesRenderer{html: NewHTMLRenderer(opts...)}
+}
+
+// Render writes the blocks of n to w as a complete reveal.js page, titled
+// after the first heading
+func (r *SlidesRenderer) Render(w io.Writer, n *parser.Node) error {
+	title := ""
+	for _, c := range n.Children {
+		if c.Kind == parser.NodeHeading {
+			title = c.PlainText()
+			break
+		}
+	}
+	return r.render(w, n, title)
+}
+
+// RenderDocument writes doc to w as a reveal.js page titled after doc
+func (r *SlidesRenderer) RenderDocument(w io.Writer, doc *parser.Document) error {
+	return r.render(w, doc.Root, doc.Title())
+}
+
+func (r *SlidesRenderer) render(w io.Writer, n *parser.Node, title string) error {
+	w, done := r.html.cfg.track(w, n)
+	defer done()
+	b := getBuffer()
+	defer putBuffer(b)
+	for _, stack := range splitSlides(n) {
+		if len(stack) > 1 {
+			b.WriteString("<section>\n")
+		}
+		for _, slide := range stack {
+			b.WriteString("<section>\n")
+			for _, c := range slide {
+				r.html.renderNode(b, c)
+			}
+			b.WriteString("</section>\n")
+		}
+		if len(stack) > 1 {
+			b.WriteString("</section>\n")
+		}
+	}
+	page := getBuffer()
+	defer putBuffer(page)
+	fmt.Fprintf(page, slidesPage, escaper.Replace(title), b.String())
+	return r.html.cfg.write(w, page)
+}
+
+// splitSlides groups the children of n into horizontal stacks of vertical
+// slides, each slide being the blocks between two breaks
+func splitSlides(n *parser.Node) [][][]*parser.Node {
+	stacks := [][][]*parser.Node{{nil}}
+	for _, c := range n.Children {
+		stack := stacks[len(stacks)-1]
+		if c.Kind == parser.NodeThematicBreak {
+			switch c.Literal {
+			case slideBreak:
+				stacks = append(stacks, [][]*parser.Node{nil})
+				continue
+			case verticalSlideBreak:
+				stacks[len(stacks)-1] = append(stack, nil)
+				continue
+			}
+		}
+		stack[len(stack)-1] = append(stack[len(stack)-1], c)
+	}
+	return stacks
+}
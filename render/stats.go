@@ -0,0 +1,67 @@
+package render
+
+import (
+	"io"
+	"time"
+
+	"../parser"
+)
+
+// Stats describes a single call to a renderer
+type Stats struct {
+	Format   string
+	Nodes    int // Nodes in the rendered tree
+	Bytes    int // Bytes written
+	Duration time.Duration
+}
+
+// StatsHook is told the Stats of everything a renderer renders, so they can
+// be exported as metrics. RenderStats may be called from several
+// goroutines at once when the renderer is shared
+type StatsHook interface {
+	RenderStats(s *Stats)
+}
+
+// WithStatsHook passes the Stats of each call to Render to h
+func WithStatsHook(h StatsHook) Option {
+	return func(c *Config) {
+		c.statsHooks = append(c.statsHooks, h)
+	}
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+	return n, err
+}
+
+// track prepares to report rendering n to w to the StatsHooks. It returns
+// the writer to render to, and a func to call once done
+func (c *Config) track(w io.Writer, n *parser.Node) (io.Writer, func()) {
+	if len(c.statsHooks) == 0 {
+		return w, func() {}
+	}
+	start := time.Now()
+	cw := &countingWriter{w: w}
+	return cw, func() {
+		s := &Stats{Format: c.Format, Nodes: countNodes(n), Bytes: cw.n, Duration: time.Since(start)}
+		for _, h := range c.statsHooks {
+			h.RenderStats(s)
+		}
+	}
+}
+
+// countNodes returns how many nodes make up the tree under n
+func countNodes(n *parser.Node) int {
+	count := 1
+	for _, c := range n.Children {
+		count += countNodes(c)
+	}
+	return count
+}
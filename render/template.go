@@ -0,0 +1,55 @@
+package render
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+
+	"../parser"
+)
+
+// TemplateData is what a TemplateRenderer's template is executed with
+type TemplateData struct {
+	Title   string
+	Date    string // The date field of the front matter
+	Params  map[string]string
+	Body    template.HTML
+	Outline []*parser.Heading
+}
+
+// TemplateRenderer lays out each document by executing a template with
+// the document's HTML and front matter, as TemplateData
+type TemplateRenderer struct {
+	html *HTMLRenderer
+	tmpl *template.Template
+}
+
+// NewTemplateRenderer returns a renderer executing t with the HTML
+// rendered with opts
+func NewTemplateRenderer(t *template.Template, opts ...Option) *TemplateRenderer {
+	return &TemplateRenderer{html: NewHTMLRenderer(opts...), tmpl: t}
+}
+
+// Render executes the template for n, without any front matter
+func (r *TemplateRenderer) Render(w io.Writer, n *parser.Node) error {
+	return r.RenderDocument(w, &parser.Document{Root: n})
+}
+
+// RenderDocument executes the template for doc
+func (r *TemplateRenderer) RenderDocument(w io.Writer, doc *parser.Document) error {
+	var body bytes.Buffer
+	if err := r.html.Render(&body, doc.Root); err != nil {
+		return err
+	}
+	params := doc.Meta
+	if params == nil {
+		params = map[string]string{}
+	}
+	return r.tmpl.Execute(w, &TemplateData{
+		Title:   doc.Title(),
+		Date:    params["date"],
+		Params:  params,
+		Body:    template.HTML(body.String()),
+		Outline: doc.Outline(),
+	})
+}
@@ -0,0 +1,88 @@
+package render
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+
+	"../parser"
+)
+
+// TextRenderer writes a node tree out as plain text, with all markup
+// dropped but the numbering & bullets of lists, for reading in a terminal
+// or counting words
+type TextRenderer struct {
+	cfg Config
+}
+
+// NewTextRenderer returns a plain text renderer configured by opts
+func NewTextRenderer(opts ...Option) *TextRenderer {
+	return &TextRenderer{cfg: newConfig(FormatText, opts)}
+}
+
+// Render writes n to w as plain text
+func (r *TextRenderer) Render(w io.Writer, n *parser.Node) error {
+	w, done := r.cfg.track(w, n)
+	defer done()
+	b := getBuffer()
+	defer putBuffer(b)
+	r.renderNode(b, n)
+	return r.cfg.writeTrimmed(w, b)
+}
+
+func (r *TextRenderer) renderChildren(b *bytes.Buffer, n *parser.Node) {
+	for _, c := range n.Children {
+		r.renderNode(b, c)
+	}
+}
+
+func (r *TextRenderer) renderNode(b *bytes.Buffer, n *parser.Node) {
+	if r.cfg.skip[n.Kind] {
+		return
+	}
+	if h := r.cfg.hooks[n.Kind]; h != nil {
+		h(b, n, true)
+		r.renderChildren(b, n)
+		h(b, n, false)
+		return
+	}
+	switch n.Kind {
+	case parser.NodeDocument, parser.NodeBlockQuote:
+		r.renderChildren(b, n)
+	case parser.NodeParagraph, parser.NodeHeading:
+		r.renderChildren(b, n)
+		b.WriteString("\n\n")
+	case parser.NodeList:
+		r.renderChildren(b, n)
+		b.WriteString("\n")
+	case parser.NodeListItem:
+		if n.Parent != nil && n.Parent.Ordered {
+			b.WriteString(strconv.Itoa(itemNumber(n)) + ". ")
+		} else {
+			b.WriteString("- ")
+		}
+		r.renderChildren(b, n)
+		b.WriteString("\n")
+	case parser.NodeCodeBlock:
+		b.WriteString(n.Literal)
+		b.WriteString("\n")
+	case parser.NodeText, parser.NodeCodeSpan:
+		b.WriteString(n.Literal)
+	case parser.NodeSoftBreak, parser.NodeHardBreak:
+		b.WriteString("\n")
+	case parser.NodeImage:
+		b.WriteString(n.PlainText())
+	default:
+		r.renderChildren(b, n)
+	}
+}
+
+// itemNumber returns the position of a list item in its list, from 1
+func itemNumber(item *parser.Node) int {
+	for i, c := range item.Parent.Children {
+		if c == item {
+			return i + 1
+		}
+	}
+	return 1
+}
@@ -0,0 +1,70 @@
+package render
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Names of the bundled Themes
+const (
+	ThemeGitHub = "github"
+	ThemeDark   = "dark"
+	ThemePrint  = "print"
+)
+
+//go:embed themes/*.css
+var themeFiles embed.FS
+
+// ThemeFS holds the stylesheets of the bundled themes, name.css for each.
+// Files starting with _ are shared by the others rather than themes
+var ThemeFS fs.FS
+
+// Themes holds the CSS of the stylesheets bundled for styling pages, by
+// name
+var Themes map[string]string
+
+func init() {
+	ThemeFS, _ = fs.Sub(themeFiles, "themes")
+	var err error
+	if Themes, err = LoadThemes(ThemeFS); err != nil {
+		panic(err)
+	}
+}
+
+// importLine matches an @import of another stylesheet on a line of its own
+var importLine = regexp.MustCompile(`(?m)^@import "([^"/]+\.css)";\n`)
+
+// LoadThemes returns the CSS of each name.css file in fsys, by name, such
+// as from a directory of themes laid over ThemeFS. A line @import "x.css";
+// is replaced by the file x.css in fsys, so themes can share rules with
+// each other, and with the bundled ones
+func LoadThemes(fsys fs.FS) (map[string]string, error) {
+	files, err := fs.Glob(fsys, "*.css")
+	if err != nil {
+		return nil, err
+	}
+	themes := make(map[string]string)
+	for _, file := range files {
+		if strings.HasPrefix(file, "_") {
+			continue
+		}
+		b, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		css := string(b)
+		for _, m := range importLine.FindAllStringSubmatch(css, -1) {
+			imported, err := fs.ReadFile(fsys, m[1])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", file, err)
+			}
+			css = strings.Replace(css, m[0], string(imported), 1)
+		}
+		themes[strings.TrimSuffix(file, path.Ext(file))] = css
+	}
+	return themes, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"./parser"
+)
+
+// wikiTarget marks a link made by wikiLinks, as its Data, with what it
+// named
+type wikiTarget string
+
+// pageLinks are the links on a page, as written
+type pageLinks struct {
+	wiki     []string // Targets of [[wiki links]]
+	relative []string // Destinations of other links
+}
+
+// linksOf returns the links within n
+func linksOf(n *parser.Node) pageLinks {
+	var links pageLinks
+	var walk func(n *parser.Node)
+	walk = func(n *parser.Node) {
+		if n.Kind == parser.NodeLink {
+			if t, ok := n.Data.(wikiTarget); ok {
+				links.wiki = append(links.wiki, string(t))
+			} else {
+				links.relative = append(links.relative, n.Dest)
+			}
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(n)
+	return links
+}
+
+// linkResolver resolves the links between the pages of a wiki to the pages
+// they point at, wiki links by name and others by path, and knows which
+// pages link to each
+type linkResolver struct {
+	byURL     map[string]*wikiPage
+	byName    map[string][]*wikiPage // By wikiName of their file names & titles
+	backlinks map[*wikiPage][]*wikiPage
+}
+
+// newLinkResolver returns the resolver of links between pages, which are in
+// order of URL
+func newLinkResolver(pages []*wikiPage) *linkResolver {
+	r := &linkResolver{
+		byURL:     make(map[string]*wikiPage),
+		byName:    make(map[string][]*wikiPage),
+		backlinks: make(map[*wikiPage][]*wikiPage),
+	}
+	for _, page := range pages {
+		r.byURL[page.URL] = page
+		base := path.Base(strings.TrimSuffix(page.URL, "/"))
+		for _, name := range []string{wikiName(base), wikiName(page.Title)} {
+			if named := r.byName[name]; len(named) == 0 || named[len(named)-1] != page {
+				r.byName[name] = append(named, page)
+			}
+		}
+	}
+	for _, from := range pages {
+		seen := map[*wikiPage]bool{from: true}
+		add := func(to *wikiPage) {
+			if to != nil && !seen[to] {
+				seen[to] = true
+				r.backlinks[to] = append(r.backlinks[to], from)
+			}
+		}
+		for _, target := range from.links.wiki {
+			if named := r.named(target); len(named) == 1 {
+				add(named[0])
+			}
+		}
+		for _, dest := range from.links.relative {
+			add(r.relative(from, dest))
+		}
+	}
+	return r
+}
+
+// named returns the pages the wiki link target names, ignoring any
+// #fragment: none if it's a page yet to be written, and more than one if
+// the name is ambiguous
+func (r *linkResolver) named(target string) []*wikiPage {
+	if i := strings.IndexByte(target, '#'); i >= 0 {
+		target = target[:i]
+	}
+	return r.byName[wikiName(target)]
+}
+
+// relative returns the page dest, a link on the page from, points at, as a
+// path relative to it or to the root of the wiki, to the file or its URL.
+// It returns nil if dest is elsewhere
+func (r *linkResolver) relative(from *wikiPage, dest string) *wikiPage {
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return nil
+	}
+	dir := from.URL
+	if !strings.HasSuffix(dir, "/") {
+		dir = path.Dir(dir)
+	}
+	p := u.Path
+	if !strings.HasPrefix(p, "/") {
+		p = path.Join(dir, p)
+	}
+	if isMarkdown(p) {
+		return r.byURL["/"+strings.TrimPrefix(wikiURL(strings.TrimPrefix(p, "/")), "./")]
+	}
+	if page := r.byURL[p]; page != nil {
+		return page
+	}
+	return r.byURL[strings.TrimSuffix(p, "/")+"/"]
+}
+
+// linkedFrom returns the pages linking to page, in order of URL
+func (r *linkResolver) linkedFrom(page *wikiPage) []*wikiPage {
+	return r.backlinks[page]
+}
+
+// ambiguous returns the names more than one page has, in order
+func (r *linkResolver) ambiguous() []string {
+	var names []string
+	for name, pages := range r.byName {
+		if len(pages) > 1 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
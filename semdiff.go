@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"./parser"
+	"./render"
+)
+
+// block is a top-level block of a document being compared
+type block struct {
+	node    *parser.Node
+	text    string // Written out in the canonical style, so layout doesn't count
+	key     string // text with runs of space collapsed, outside code, to compare
+	line    int
+	section string // Text of the heading the block comes under
+}
+
+// blockChange is a difference between the blocks of two documents: the
+// old blocks that became the new ones
+type blockChange struct {
+	kind     string // "added", "removed", "changed" or "moved"
+	old, new []block
+}
+
+// diffDocs compares two markdown files block by block, reporting the
+// sections & blocks that moved and those that were added, removed or
+// changed. As diff(1) does, it fails if there are any differences
+func diffDocs(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s diff old.md new.md\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Compares two markdown files by their structure rather than their lines,")
+		fmt.Fprintln(os.Stderr, "so reflowed text and changes of style, such as * for - bullets, don't")
+		fmt.Fprintln(os.Stderr, "count. Sections & blocks that moved are reported as moves, changed")
+		fmt.Fprintln(os.Stderr, "paragraphs & headings show which words changed, and changed tables which")
+		fmt.Fprintln(os.Stderr, "rows were added or removed and which cells changed. It exits with")
+		fmt.Fprintln(os.Stderr, "status 1 if the files differ, as diff does.")
+	}
+	args = parseInterspersed(fs, args)
+	if len(args) != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	var docs [2][]block
+	for i, path := range args {
+		name, src, err := readSource(path)
+		if err != nil {
+			return err
+		}
+		if docs[i], err = blocks(name, src); err != nil {
+			return err
+		}
+	}
+	changes := compareBlocks(docs[0], docs[1])
+	for _, c := range changes {
+		fmt.Print(describeChange(args[0], args[1], c))
+	}
+	if len(changes) > 0 {
+		return fmt.Errorf("%s found", plural(len(changes), "difference"))
+	}
+	return nil
+}
+
+// blocks parses src, from the file name, into its top-level blocks
+func blocks(name, src string) ([]block, error) {
+	doc, err := parser.New(parser.WithSourceRanges(), parser.WithExtensions(parser.Tables)).Parse(name, src)
+	if err != nil {
+		return nil, err
+	}
+	md := render.NewMarkdownRenderer()
+	var bs []block
+	section := ""
+	for _, n := range doc.Root.Children {
+		if n.Kind == parser.NodeHeading {
+			section = n.PlainText()
+		}
+		var b bytes.Buffer
+		if err := md.Render(&b, n); err != nil {
+			return nil, err
+		}
+		text := strings.TrimSpace(b.String())
+		key := text
+		if n.Kind != parser.NodeCodeBlock { // Reflowing text changes nothing
+			key = strings.Join(strings.Fields(text), " ")
+		}
+		bs = append(bs, block{
+			node:    n,
+			text:    text,
+			key:     key,
+			line:    doc.Position(n.Range.Start).Line,
+			section: section,
+		})
+	}
+	return bs, nil
+}
+
+// compareBlocks works out the changes from the blocks old to new, in
+// document order
+func compareBlocks(old, new []block) []*blockChange {
+	texts := func(bs []block) []string {
+		var s []string
+		for _, b := range bs {
+			s = append(s, b.key)
+		}
+		return s
+	}
+	// Gather runs of removed & added blocks, each removal directly
+	// followed by the additions that replace it
+	var changes []*blockChange
+	var c *blockChange
+	i, j := 0, 0
+	for _, e := range diffLines(texts(old), texts(new)) {
+		if e.op == ' ' {
+			c = nil
+			i, j = i+1, j+1
+			continue
+		}
+		if c == nil {
+			c = &blockChange{}
+			changes = append(changes, c)
+		}
+		if e.op == '-' {
+			c.old = append(c.old, old[i])
+			i++
+		} else {
+			c.new = append(c.new, new[j])
+			j++
+		}
+	}
+	// Blocks removed from one place & added in another moved
+	var result []*blockChange
+	for _, c := range changes {
+		for len(c.old) > 0 || len(c.new) > 0 {
+			if m := findMove(changes, c); m != nil {
+				result = append(result, m)
+				continue
+			}
+			if len(c.old) > 0 && len(c.new) > 0 && c.old[0].node.Kind == c.new[0].node.Kind {
+				result = append(result, &blockChange{kind: "changed", old: c.old[:1], new: c.new[:1]})
+				c.old, c.new = c.old[1:], c.new[1:]
+			} else if len(c.old) > 0 {
+				result = append(result, &blockChange{kind: "removed", old: c.old[:1]})
+				c.old = c.old[1:]
+			} else {
+				result = append(result, &blockChange{kind: "added", new: c.new[:1]})
+				c.new = c.new[1:]
+			}
+		}
+	}
+	return pairChanges(result)
+}
+
+// pairChanges turns a block removed from a section and one of the same
+// kind added to a section of the same name, perhaps one that moved, into
+// a change of the block
+func pairChanges(changes []*blockChange) []*blockChange {
+	pairs := make(map[*blockChange]*blockChange) // Additions by removal
+	paired := make(map[*blockChange]bool)
+	for _, c := range changes {
+		if c.kind != "removed" {
+			continue
+		}
+		for _, a := range changes {
+			if a.kind == "added" && !paired[a] && a.new[0].section == c.old[0].section && a.new[0].node.Kind == c.old[0].node.Kind {
+				pairs[c], paired[a] = a, true
+				break
+			}
+		}
+	}
+	var result []*blockChange
+	for _, c := range changes {
+		if a := pairs[c]; a != nil {
+			c = &blockChange{kind: "changed", old: c.old, new: a.new}
+		} else if paired[c] {
+			continue
+		}
+		result = append(result, c)
+	}
+	return result
+}
+
+// findMove looks for the blocks starting c's removals added elsewhere, or
+// its additions removed from elsewhere, taking the longest such run out of
+// both changes as a move
+func findMove(changes []*blockChange, c *blockChange) *blockChange {
+	for _, other := range changes {
+		if other == c {
+			continue
+		}
+		if n := commonRun(c.old, other.new); n > 0 {
+			m := &blockChange{kind: "moved", old: c.old[:n]}
+			m.new, other.new = cut(other.new, c.old[0].key, n)
+			c.old = c.old[n:]
+			return m
+		}
+		if n := commonRun(c.new, other.old); n > 0 {
+			m := &blockChange{kind: "moved", new: c.new[:n]}
+			m.old, other.old = cut(other.old, c.new[0].key, n)
+			c.new = c.new[n:]
+			return m
+		}
+	}
+	return nil
+}
+
+// commonRun returns how many blocks from the start of a appear in the
+// same order one after another somewhere in b
+func commonRun(a, b []block) int {
+	best := 0
+	for k := range b {
+		n := 0
+		for n < len(a) && k+n < len(b) && a[n].key == b[k+n].key {
+			n++
+		}
+		if n > best {
+			best = n
+		}
+	}
+	return best
+}
+
+// cut removes the run of n blocks starting with one whose key is first
+// from bs, returning the run & what's left
+func cut(bs []block, first string, n int) (run, rest []block) {
+	for k := range bs {
+		if bs[k].key == first && k+n <= len(bs) {
+			run = append(run, bs[k:k+n]...)
+			rest = append(append(rest, bs[:k]...), bs[k+n:]...)
+			return run, rest
+		}
+	}
+	return nil, bs
+}
+
+// describeChange explains c, between the files oldName & newName
+func describeChange(oldName, newName string, c *blockChange) string {
+	var b strings.Builder
+	switch c.kind {
+	case "moved":
+		what := kindName(c.old[0].node)
+		if c.old[0].node.Kind == parser.NodeHeading {
+			what = fmt.Sprintf("section %q", c.old[0].node.PlainText())
+		} else if len(c.old) > 1 {
+			what = plural(len(c.old), "block")
+		}
+		fmt.Fprintf(&b, "moved %s from %s:%d to %s:%d\n", what, oldName, c.old[0].line, newName, c.new[0].line)
+	case "removed":
+		fmt.Fprintf(&b, "removed %s at %s:%d%s\n", kindName(c.old[0].node), oldName, c.old[0].line, inSection(c.old[0]))
+		writeIndented(&b, "- ", c.old[0].text)
+	case "added":
+		fmt.Fprintf(&b, "added %s at %s:%d%s\n", kindName(c.new[0].node), newName, c.new[0].line, inSection(c.new[0]))
+		writeIndented(&b, "+ ", c.new[0].text)
+	case "changed":
+		fmt.Fprintf(&b, "changed %s at %s:%d, %s:%d%s\n", kindName(c.new[0].node), oldName, c.old[0].line, newName, c.new[0].line, inSection(c.new[0]))
+		switch c.new[0].node.Kind {
+		case parser.NodeParagraph, parser.NodeHeading:
+			writeIndented(&b, "", wordDiff(c.old[0].text, c.new[0].text))
+		case parser.NodeTable:
+			b.WriteString(tableChanges(c.old[0].node, c.new[0].node))
+		default:
+			writeIndented(&b, "- ", c.old[0].text)
+			writeIndented(&b, "+ ", c.new[0].text)
+		}
+	}
+	return b.String()
+}
+
+// kindName names the kind of node n is for people
+func kindName(n *parser.Node) string {
+	switch n.Kind {
+	case parser.NodeThematicBreak:
+		return "thematic break"
+	case parser.NodeListItem:
+		return "list item"
+	case parser.NodeCodeBlock:
+		return "code block"
+	case parser.NodeBlockQuote:
+		return "block quote"
+	}
+	return strings.ToLower(n.Kind.String())
+}
+
+// inSection says which section b is in, if any
+func inSection(b block) string {
+	if b.section == "" || b.node.Kind == parser.NodeHeading {
+		return ""
+	}
+	return fmt.Sprintf(" in %q", b.section)
+}
+
+// writeIndented writes each line of text to b indented, after prefix
+func writeIndented(b *strings.Builder, prefix, text string) {
+	for _, line := range strings.Split(text, "\n") {
+		b.WriteString("  " + prefix + line + "\n")
+	}
+}
+
+// wordDiff marks the words removed from old as [-this-] and those added
+// in new as {+this+}, as git diff --word-diff does
+func wordDiff(old, new string) string {
+	var b strings.Builder
+	for i, e := range diffLines(strings.Fields(old), strings.Fields(new)) {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		switch e.op {
+		case '-':
+			b.WriteString("[-" + e.line + "-]")
+		case '+':
+			b.WriteString("{+" + e.line + "+}")
+		default:
+			b.WriteString(e.line)
+		}
+	}
+	return b.String()
+}
+
+// tableChanges describes how the table old became new: the columns
+// aligned differently, then row by row the rows added & removed, and the
+// cells that changed in the rest, by the header of their column
+func tableChanges(old, new *parser.Node) string {
+	oldRows, newRows := tableCells(old), tableCells(new)
+	var b strings.Builder
+	was := old.Children[0].Children
+	for col, cell := range new.Children[0].Children {
+		if col < len(was) && cell.Info != was[col].Info {
+			fmt.Fprintf(&b, "  %s aligned %s, was %s\n", columnName(newRows[0], col), alignName(cell.Info), alignName(was[col].Info))
+		}
+	}
+	keys := func(rows [][]string) []string {
+		var s []string
+		for _, r := range rows {
+			s = append(s, strings.Join(r, "\x00"))
+		}
+		return s
+	}
+	var removed []int // Rows of old removed, waiting to be paired with one added
+	flush := func() {
+		for _, i := range removed {
+			fmt.Fprintf(&b, "  - %s: %s\n", rowName(i), tableRow(oldRows[i]))
+		}
+		removed = nil
+	}
+	i, j := 0, 0
+	for _, e := range diffLines(keys(oldRows), keys(newRows)) {
+		switch e.op {
+		case ' ':
+			flush()
+			i, j = i+1, j+1
+		case '-':
+			removed = append(removed, i)
+			i++
+		case '+':
+			if len(removed) > 0 && len(oldRows[removed[0]]) == len(newRows[j]) {
+				was := oldRows[removed[0]]
+				removed = removed[1:]
+				for col, cell := range newRows[j] {
+					if cell != was[col] {
+						fmt.Fprintf(&b, "  %s, %s: %s\n", rowName(j), columnName(newRows[0], col), wordDiff(was[col], cell))
+					}
+				}
+			} else {
+				flush()
+				fmt.Fprintf(&b, "  + %s: %s\n", rowName(j), tableRow(newRows[j]))
+			}
+			j++
+		}
+	}
+	flush()
+	return b.String()
+}
+
+// tableCells returns the text of each cell of table, row by row, written
+// out in the canonical style
+func tableCells(table *parser.Node) [][]string {
+	md := render.NewMarkdownRenderer()
+	var rows [][]string
+	for _, row := range table.Children {
+		var cells []string
+		for _, cell := range row.Children {
+			var b bytes.Buffer
+			for _, n := range cell.Children {
+				md.Render(&b, n)
+				b.Truncate(len(bytes.TrimSuffix(b.Bytes(), []byte("\n"))))
+			}
+			cells = append(cells, b.String())
+		}
+		rows = append(rows, cells)
+	}
+	return rows
+}
+
+// rowName names row i of a table for people, the first being its header
+func rowName(i int) string {
+	if i == 0 {
+		return "header"
+	}
+	return fmt.Sprintf("row %d", i)
+}
+
+// columnName names column col of a table by its header, or its number if
+// that's empty
+func columnName(header []string, col int) string {
+	if col < len(header) && header[col] != "" {
+		return fmt.Sprintf("column %q", header[col])
+	}
+	return fmt.Sprintf("column %d", col+1)
+}
+
+// alignName names how the cells of a column are aligned
+func alignName(align string) string {
+	if align == "" {
+		return "by default"
+	}
+	return align
+}
+
+// tableRow writes the cells of a row as a row of a pipe table
+func tableRow(cells []string) string {
+	return "| " + strings.Join(cells, " | ") + " |"
+}
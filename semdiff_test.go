@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"old.md": "# A\n\nAlpha text.\n\n# B\n\nBeta text.\n\nA note.\n\n| x | y |\n| - | - |\n| 1 | two |\n| 3 | 4 |\n\nThe end.\n",
+		"new.md": "# B\n\nBeta text,\nreflowed.\n\n| x | y |\n| - | -: |\n| 1 | *2* |\n| 5 | 6 |\n\nThe end.\n\nA note.\n\n# A\n\nAlpha text.\n",
+	})
+	r := gomd(t, dir, "", "diff", "old.md", "new.md")
+	if r.code != exitFailure {
+		t.Errorf("exit %d for different documents, want %d", r.code, exitFailure)
+	}
+	for _, want := range []string{
+		`moved section "A" from old.md:1 to new.md:15`,
+		"moved paragraph from old.md:9 to new.md:13",
+		"changed paragraph at old.md:7, new.md:3 in \"B\"\n  Beta [-text.-] {+text,+} {+reflowed.+}\n",
+		"changed table at old.md:11, new.md:6 in \"B\"\n" +
+			"  column \"y\" aligned right, was by default\n" +
+			"  row 1, column \"y\": [-two-] {+*2*+}\n" +
+			"  row 2, column \"x\": [-3-] {+5+}\n",
+	} {
+		if !strings.Contains(r.stdout, want) {
+			t.Errorf("output\n%s\nwithout\n%s", r.stdout, want)
+		}
+	}
+
+	writeFiles(t, dir, map[string]string{"same.md": "# A\n\nAlpha\ntext.\n\n# B\n\nBeta text.\n\nA note.\n\n| x | y |\n|---|-|\n| 1 | two |\n| 3 | 4 |\n\nThe end.\n"})
+	r = gomd(t, dir, "", "diff", "old.md", "same.md")
+	if r.code != 0 || r.stdout != "" {
+		t.Errorf("exit %d, %q, comparing documents differing only in layout", r.code, r.stdout)
+	}
+}
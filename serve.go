@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"./parser"
+	"./render"
+)
+
+// eventsPath is where preview pages listen for changes to their source
+const eventsPath = "/_gomd/events"
+
+// reloadScript reloads a preview page whenever the server sends an event
+const reloadScript = `<script>new EventSource(%s + "?path=" + encodeURIComponent(%s)).onmessage = function() { location.reload() }</script>
+`
+
+// serve previews a markdown file, or a directory of them, over HTTP,
+// reloading pages in the browser when their source changes
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8080", "address to listen on")
+	theme := fs.String("theme", render.ThemeGitHub, "theme to style pages with: "+themeNames())
+	css := fs.String("css", "", "URL of a stylesheet to style pages with, instead of a theme")
+	withMetrics := fs.Bool("metrics", false, "serve Prometheus metrics at "+metricsPath)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s serve [-addr host:port] [-theme name] [-css url] [-metrics] file or dir\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Serves markdown files as HTML, reloading them in the browser as they")
+		fmt.Fprintln(os.Stderr, "change. Other files in a directory are served as they are; given a file,")
+		fmt.Fprintln(os.Stderr, "only those its links & images refer to are. Hidden files, and those")
+		fmt.Fprintln(os.Stderr, "in hidden directories, never are.")
+		fmt.Fprintf(os.Stderr, "%s answers ok while the server is up, for health checks.\n", healthPath)
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+	}
+	args = parseInterspersed(fs, args)
+	if len(args) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	info, err := os.Stat(args[0])
+	if err != nil {
+		return err
+	}
+	page, err := newPageRenderer(*theme, *css)
+	if err != nil {
+		return err
+	}
+	p := &previewer{root: args[0], page: page}
+	if !info.IsDir() { // Serve the file at /
+		p.root, p.index = filepath.Dir(args[0]), filepath.Base(args[0])
+	}
+	http.Handle("/", p)
+	http.HandleFunc(eventsPath, p.events)
+	handleService(*withMetrics)
+	logger.Info(fmt.Sprintf("serving %s at http://%s/", args[0], *addr), "path", args[0], "addr", *addr)
+	return http.ListenAndServe(*addr, instrument(http.DefaultServeMux))
+}
+
+// previewer serves the files under root, rendering markdown. Hidden files
+// aren't served, nor with an index any but it & those it refers to
+type previewer struct {
+	root  string
+	index string // File served for /, if root was given as a file
+	page  *render.PageRenderer
+}
+
+// file returns the path under root that the URL path name refers to
+func (p *previewer) file(name string) string {
+	name = path.Clean("/" + name)
+	if name == "/" && p.index != "" {
+		name = "/" + p.index
+	}
+	return filepath.Join(p.root, filepath.FromSlash(name))
+}
+
+// allowed reports whether the file at the URL path name may be served
+func (p *previewer) allowed(name string) bool {
+	name = path.Clean("/" + name)
+	if isHidden(name) || !within(p.root, p.file(name)) {
+		return false
+	}
+	if p.index == "" || name == "/" || name == "/"+p.index {
+		return true
+	}
+	return p.references()[name]
+}
+
+// references returns the URL paths of the local files the index's links
+// & images refer to
+func (p *previewer) references() map[string]bool {
+	refs := make(map[string]bool)
+	src, err := ioutil.ReadFile(filepath.Join(p.root, p.index))
+	if err != nil {
+		return refs
+	}
+	doc, err := parser.Parse(p.index, string(src))
+	if err != nil {
+		return refs
+	}
+	var walk func(n *parser.Node)
+	walk = func(n *parser.Node) {
+		if n.Kind == parser.NodeImage || n.Kind == parser.NodeLink {
+			if u, err := url.Parse(n.Dest); err == nil && u.Scheme == "" && u.Host == "" && u.Path != "" {
+				refs[path.Join("/", path.Dir(p.index), u.Path)] = true
+			}
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(doc.Root)
+	return refs
+}
+
+func (p *previewer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !p.allowed(r.URL.Path) {
+		http.NotFound(w, r)
+		return
+	}
+	name := p.file(r.URL.Path)
+	if !isMarkdown(name) {
+		http.FileServer(noHiddenFiles{http.Dir(p.root)}).ServeHTTP(w, r)
+		return
+	}
+	start := time.Now()
+	src, err := ioutil.ReadFile(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	doc, err := parser.Parse(name, string(src))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	page := *p.page
+	page.Foot = fmt.Sprintf(reloadScript, jsString(eventsPath), jsString(r.URL.Path))
+	var out bytes.Buffer
+	if err := page.RenderDocument(&out, doc); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	serverMetrics.rendered(time.Since(start))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(out.Bytes())
+}
+
+// jsString quotes s as a JavaScript string that's safe inside a <script>
+func jsString(s string) string {
+	b, _ := json.Marshal(s) // Escapes <, > and & too
+	return string(b)
+}
+
+// events sends an event each time the file the path query parameter
+// names changes, until the page is closed
+func (p *previewer) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	if !p.allowed(r.URL.Query().Get("path")) {
+		http.NotFound(w, r)
+		return
+	}
+	name := p.file(r.URL.Query().Get("path"))
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher.Flush()
+	serverMetrics.watchers.Add(1)
+	defer serverMetrics.watchers.Add(-1)
+	last := modified(name)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(pollInterval):
+		}
+		if t := modified(name); !t.Equal(last) {
+			last = t
+			fmt.Fprint(w, "data: reload\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// modified returns when name was last modified, or the zero Time if it
+// can't be read
+func modified(name string) time.Time {
+	info, err := os.Stat(name)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// noHiddenFiles is a file system without its hidden files, which can't be
+// opened or seen in listings of their directories
+type noHiddenFiles struct {
+	http.FileSystem
+}
+
+func (fs noHiddenFiles) Open(name string) (http.File, error) {
+	if isHidden(name) {
+		return nil, os.ErrNotExist
+	}
+	f, err := fs.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return noHiddenFile{f}, nil
+}
+
+// noHiddenFile is a file of noHiddenFiles, listing only the files in it,
+// if it's a directory, that aren't hidden
+type noHiddenFile struct {
+	http.File
+}
+
+func (f noHiddenFile) Readdir(n int) ([]os.FileInfo, error) {
+	infos, err := f.File.Readdir(n)
+	shown := infos[:0]
+	for _, info := range infos {
+		if !strings.HasPrefix(info.Name(), ".") {
+			shown = append(shown, info)
+		}
+	}
+	return shown, err
+}
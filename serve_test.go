@@ -0,0 +1,95 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"./render"
+)
+
+// get fetches path from srv, returning the status & body
+func get(t *testing.T, srv *httptest.Server, path string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(srv.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(b)
+}
+
+func TestServeFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"doc.md":       "# Doc\n\n![logo](img/logo.png) and [notes](notes.md).\n",
+		"img/logo.png": "png",
+		"notes.md":     "# Notes\n",
+		"secret.txt":   "secret",
+		"other.md":     "# Other\n",
+		".env":         "TOKEN=1",
+	})
+	page, err := newPageRenderer(render.ThemeGitHub, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &previewer{root: dir, index: "doc.md", page: page}
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+	for path, want := range map[string]int{
+		"/":             http.StatusOK,
+		"/doc.md":       http.StatusOK,
+		"/img/logo.png": http.StatusOK,
+		"/notes.md":     http.StatusOK,
+		"/secret.txt":   http.StatusNotFound,
+		"/other.md":     http.StatusNotFound,
+		"/.env":         http.StatusNotFound,
+		"/img/":         http.StatusNotFound,
+	} {
+		if code, _ := get(t, srv, path); code != want {
+			t.Errorf("GET %s: %d, want %d", path, code, want)
+		}
+	}
+	if _, body := get(t, srv, "/"); !strings.Contains(body, "<h1") || !strings.Contains(body, "Doc") {
+		t.Errorf("/ isn't the document rendered: %s", body)
+	}
+}
+
+func TestServeDirHidesDotFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.md":           "# A\n",
+		"files/b.txt":    "b",
+		"files/.secret":  "secret",
+		".git/config":    "[core]",
+		".hidden/doc.md": "# Hidden\n",
+	})
+	page, err := newPageRenderer(render.ThemeGitHub, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(&previewer{root: filepath.Clean(dir), page: page})
+	defer srv.Close()
+	for path, want := range map[string]int{
+		"/a.md":                 http.StatusOK,
+		"/files/b.txt":          http.StatusOK,
+		"/files/.secret":        http.StatusNotFound,
+		"/.git/config":          http.StatusNotFound,
+		"/.hidden/doc.md":       http.StatusNotFound,
+		"/files/../.git/config": http.StatusNotFound,
+	} {
+		if code, _ := get(t, srv, path); code != want {
+			t.Errorf("GET %s: %d, want %d", path, code, want)
+		}
+	}
+	if _, body := get(t, srv, "/files/"); !strings.Contains(body, "b.txt") || strings.Contains(body, ".secret") {
+		t.Errorf("listing of files/ shows hidden files or not the rest: %s", body)
+	}
+}
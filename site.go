@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"./parser"
+	"./render"
+)
+
+// sitePage is a page of a generated site
+type sitePage struct {
+	src   string // Markdown file the page is made from, "" for generated indexes
+	path  string // Where the page goes, relative to the site root, slash separated
+	title string
+	date  string
+	doc   *parser.Document
+}
+
+// siteNavLink is an entry in a site's navigation
+type siteNavLink struct {
+	Title   string
+	URL     string // Relative to the page it's on
+	Current bool   // Whether it's the page it's on, or the section that page is in
+}
+
+// sitePageData is what siteTemplate lays out a page with
+type sitePageData struct {
+	Title, Date, SiteTitle string
+	Root                   string // Relative URL of the site root from the page, ending in /
+	CSS                    template.CSS
+	Nav                    []siteNavLink
+	Outline                []*parser.Heading
+	Body                   template.HTML
+}
+
+// site builds a directory of markdown into a website: each markdown file
+// becomes a page, directories without an index get a page listing what's
+// in them, and every page has the site's navigation & its own outline
+func site(args []string) error {
+	fs := flag.NewFlagSet("site", flag.ExitOnError)
+	out := fs.String("o", "public", "directory to write the site to")
+	siteTitle := fs.String("title", "", "name of the site, shown on every page")
+	theme := fs.String("theme", render.ThemeGitHub, "theme to style pages with: "+themeNames())
+	base := fs.String("base", "", "URL the site is served from, such as https://example.com/docs/, to write a "+sitemapName+" of its pages")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s site [-o dir] [-title name] [-theme name] [-base url] dir or zip\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Builds the markdown under dir into a website, using the title & date in")
+		fmt.Fprintln(os.Stderr, "each file's front matter. index.md or README.md is a directory's index")
+		fmt.Fprintln(os.Stderr, "page. Other files are copied as they are. The same input always builds")
+		fmt.Fprintln(os.Stderr, "the same output, byte for byte. With -base a "+sitemapName+" is written too.")
+		fmt.Fprintln(os.Stderr, "A zip archive is read as the directory it holds. A line of {{path}} includes")
+		fmt.Fprintln(os.Stderr, "the file at path, from the directory of the one including it, in its place.")
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+	}
+	args = parseInterspersed(fs, args)
+	if len(args) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	css, ok := render.Themes[*theme]
+	if !ok {
+		return usagef("unknown theme %q", *theme)
+	}
+	dest := filepath.Clean(*out)
+	if filepath.Clean(args[0]) == dest {
+		return usagef("output would overwrite the input")
+	}
+	t, err := openTree(args[0])
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+	src := t.dir
+
+	p := parser.New(parser.WithHeadingIDs(), parser.WithExtensions(withDefaults(tocMarkers, linkRewriter(pagePath))...))
+	pages := make(map[string]*sitePage) // By path
+	dirs := []string{"."}
+	err = t.walk(func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if file == dest && t.fsys == nil || file != src && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, _ := filepath.Rel(src, file)
+		rel = filepath.ToSlash(rel)
+		switch {
+		case info.IsDir():
+			if rel != "." {
+				dirs = append(dirs, rel)
+			}
+			return os.MkdirAll(filepath.Join(dest, rel), 0755)
+		case isMarkdown(file):
+			doc, err := t.parse(p, file)
+			if err != nil {
+				return err
+			}
+			page := &sitePage{src: file, path: pagePath(rel), title: doc.Title(), date: doc.Meta["date"], doc: doc}
+			if other := pages[page.path]; other != nil {
+				return fmt.Errorf("%s and %s would both be %s", other.src, file, page.path)
+			}
+			pages[page.path] = page
+			return nil
+		case info.Mode().IsRegular():
+			return t.copyFile(file, filepath.Join(dest, rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		index := path.Join(dir, "index.html")
+		if pages[index] == nil {
+			title := path.Base(dir)
+			if dir == "." {
+				title = *siteTitle
+				if title == "" {
+					title = "Home"
+				}
+			}
+			pages[index] = &sitePage{path: index, title: title}
+		}
+	}
+	var urls []*sitemapURL
+	for _, page := range pages {
+		if err := writeSitePage(dest, *siteTitle, template.CSS(css), page, pages); err != nil {
+			return err
+		}
+		urls = append(urls, newSitemapURL(*base, page.path, page.date))
+	}
+	if *base != "" {
+		return writeSitemap(dest, urls)
+	}
+	return nil
+}
+
+// pagePath returns where the page for the markdown file at path goes: an
+// index page for index.md & README.md, or else an HTML file beside it
+func pagePath(file string) string {
+	name := strings.ToLower(path.Base(file))
+	if name == "readme.md" || name == "index.md" {
+		return path.Join(path.Dir(file), "index.html")
+	}
+	return render.ReplaceExt(".html")(file)
+}
+
+// writeSitePage lays out page, a generated index listing the pages in its
+// directory if it has no source, and writes it under dest
+func writeSitePage(dest, siteTitle string, css template.CSS, page *sitePage, pages map[string]*sitePage) error {
+	dir := path.Dir(page.path)
+	root := strings.Repeat("../", strings.Count(page.path, "/"))
+	data := &sitePageData{
+		Title:     page.title,
+		Date:      page.date,
+		SiteTitle: siteTitle,
+		Root:      root,
+		CSS:       css,
+	}
+	// The pages & sections at the top of the site make up the navigation
+	for _, p := range siteListing(".", pages) {
+		section := strings.TrimSuffix(p.path, "index.html")
+		data.Nav = append(data.Nav, siteNavLink{
+			Title:   p.title,
+			URL:     root + p.path,
+			Current: p == page || section != "" && strings.HasPrefix(page.path, section),
+		})
+	}
+	var body bytes.Buffer
+	if page.doc != nil {
+		data.Outline = page.doc.Outline()
+		if err := render.NewHTMLRenderer().Render(&body, page.doc.Root); err != nil {
+			return fmt.Errorf("%s: %v", page.src, err)
+		}
+	} else {
+		fmt.Fprintf(&body, "<h1>%s</h1>\n<ul>\n", template.HTMLEscapeString(page.title))
+		for _, p := range siteListing(dir, pages) {
+			rel := strings.TrimPrefix(p.path, strings.TrimPrefix(dir+"/", "./"))
+			fmt.Fprintf(&body, `<li><a href="%s">%s</a>`, template.HTMLEscapeString(rel), template.HTMLEscapeString(p.title))
+			if p.date != "" {
+				fmt.Fprintf(&body, ` <span class="date">%s</span>`, template.HTMLEscapeString(p.date))
+			}
+			body.WriteString("</li>\n")
+		}
+		body.WriteString("</ul>\n")
+	}
+	data.Body = template.HTML(body.String())
+	var out bytes.Buffer
+	if err := siteTemplate.Execute(&out, data); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dest, filepath.FromSlash(page.path)), out.Bytes(), 0644)
+}
+
+// siteListing returns the pages directly in dir and the index pages of the
+// directories directly beneath it, newest first for those with dates &
+// then by title
+func siteListing(dir string, pages map[string]*sitePage) []*sitePage {
+	var list []*sitePage
+	for _, p := range pages {
+		parent := path.Dir(p.path)
+		switch {
+		case p.path == path.Join(dir, "index.html"):
+		case parent == dir,
+			path.Base(p.path) == "index.html" && path.Dir(parent) == dir && parent != ".":
+			list = append(list, p)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.date != b.date {
+			return a.date > b.date
+		}
+		if a.title != b.title {
+			return a.title < b.title
+		}
+		return a.path < b.path
+	})
+	return list
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sitemapName is the file a built site's sitemap is written to
+const sitemapName = "sitemap.xml"
+
+// sitemapURL is a page in a sitemap
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"` // YYYY-MM-DD
+}
+
+// sitemap is the urlset of a sitemap.xml, as set out by sitemaps.org
+type sitemap struct {
+	XMLName xml.Name      `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []*sitemapURL `xml:"url"`
+}
+
+// newSitemapURL returns the entry for the page at path, slash separated &
+// relative to the site root found at base, dated date if that's a date
+func newSitemapURL(base, path, date string) *sitemapURL {
+	u := &sitemapURL{Loc: strings.TrimSuffix(base, "/") + "/" + (&url.URL{Path: path}).EscapedPath()}
+	if _, err := time.Parse("2006-01-02", date); err == nil {
+		u.LastMod = date
+	}
+	return u
+}
+
+// writeSitemap writes the sitemap of urls into the site at dest, in order
+// of location
+func writeSitemap(dest string, urls []*sitemapURL) error {
+	sort.Slice(urls, func(i, j int) bool { return urls[i].Loc < urls[j].Loc })
+	b, err := xml.MarshalIndent(&sitemap{URLs: urls}, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append([]byte(xml.Header), append(b, '\n')...)
+	return ioutil.WriteFile(filepath.Join(dest, sitemapName), b, 0644)
+}
@@ -0,0 +1,22 @@
+//go:build !unix
+
+package main
+
+import (
+	"net"
+	"os"
+)
+
+// listenUnix listens on a unix socket at path only its owner may connect
+// to, as far as the system's permissions allow
+func listenUnix(path string) (net.Listener, error) {
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
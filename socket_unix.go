@@ -0,0 +1,18 @@
+//go:build unix
+
+package main
+
+import (
+	"net"
+	"syscall"
+)
+
+// listenUnix listens on a unix socket at path only its owner may connect
+// to. The socket is created that way, under a narrowed umask, rather than
+// changed after, when others could already have connected. The umask is
+// the process's, so this must run before anything else creates files
+func listenUnix(path string) (net.Listener, error) {
+	old := syscall.Umask(0177)
+	defer syscall.Umask(old)
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,147 @@
+// Package spec holds the examples of the CommonMark spec, and of the
+// extensions the GitHub Flavored Markdown spec adds to it, from the files
+// vendored beside them, each a markdown → HTML case the parser & HTML
+// renderer are tested against
+package spec
+
+//go:generate curl -o commonmark-0.31.2.txt https://spec.commonmark.org/0.31.2/spec.txt
+//go:generate curl -o gfm.txt https://raw.githubusercontent.com/github/cmark-gfm/master/test/spec.txt
+
+import (
+	"bufio"
+	_ "embed"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const exampleFence = "````````````````````````````````"
+
+// Version is the version of the CommonMark spec Examples are from
+const Version = "0.31.2"
+
+var (
+	//go:embed commonmark-0.31.2.txt
+	commonMarkText string
+	//go:embed gfm.txt
+	gfmText string
+)
+
+// Examples are those of the CommonMark spec, in order
+var Examples []Example
+
+// GFMExamples are those of the extensions of the GFM spec, in order, each
+// tagged with the extension it's of. The GFM spec's CommonMark examples
+// are left to Examples
+var GFMExamples []Example
+
+func init() {
+	var err error
+	if Examples, err = Read(strings.NewReader(commonMarkText)); err != nil {
+		panic("spec: reading commonmark-" + Version + ".txt: " + err.Error())
+	}
+	all, err := Read(strings.NewReader(gfmText))
+	if err != nil {
+		panic("spec: reading gfm.txt: " + err.Error())
+	}
+	for _, ex := range all {
+		if len(ex.Exts) > 0 {
+			GFMExamples = append(GFMExamples, ex)
+		}
+	}
+}
+
+// Example is a single markdown → HTML case from a spec file
+type Example struct {
+	Number   int
+	Section  string
+	Line     int // Line of the spec file the example starts on
+	Markdown string
+	HTML     string
+	Exts     []string // Words after "example" in the opening fence
+}
+
+// Read splits a spec written in the CommonMark spec.txt format into its
+// examples. Tabs are written as → in the spec, & are put back
+func Read(r io.Reader) ([]Example, error) {
+	var (
+		examples []Example
+		section  string
+		cur      *Example
+		inHTML   bool
+		md, html strings.Builder
+	)
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for line := 1; s.Scan(); line++ {
+		t := s.Text()
+		switch {
+		case cur == nil && strings.HasPrefix(t, exampleFence+" example"):
+			cur = &Example{
+				Number:  len(examples) + 1,
+				Section: section,
+				Line:    line,
+				Exts:    strings.Fields(strings.TrimPrefix(t, exampleFence+" example")),
+			}
+			inHTML = false
+			md.Reset()
+			html.Reset()
+		case cur == nil:
+			if strings.HasPrefix(t, "#") {
+				section = strings.TrimSpace(strings.TrimLeft(t, "#"))
+			}
+		case t == exampleFence:
+			cur.Markdown = strings.Replace(md.String(), "→", "\t", -1)
+			cur.HTML = strings.Replace(html.String(), "→", "\t", -1)
+			examples = append(examples, *cur)
+			cur = nil
+		case t == "." && !inHTML:
+			inHTML = true
+		case inHTML:
+			html.WriteString(t + "\n")
+		default:
+			md.WriteString(t + "\n")
+		}
+	}
+	return examples, s.Err()
+}
+
+var (
+	blockTag   = `(?:p|h[1-6]|hr|ul|ol|li|pre|blockquote|table|thead|tbody|tr|th|td|div)`
+	aroundTags = regexp.MustCompile(`\s*(</?` + blockTag + `\b[^>]*>)\s*`)
+	spaces     = regexp.MustCompile(`\s+`)
+)
+
+// Normalize makes HTML that differs only in insignificant whitespace
+// compare equal, the way the spec's own test runner does. Whitespace
+// within <pre> is significant & is left alone
+func Normalize(html string) string {
+	var b strings.Builder
+	for html != "" {
+		i := strings.Index(html, "<pre")
+		if i < 0 {
+			i = len(html)
+		}
+		b.WriteString(normalizeRun(html[:i]))
+		html = html[i:]
+		if html == "" {
+			break
+		}
+		j := strings.Index(html, "</pre>")
+		if j < 0 {
+			j = len(html)
+		} else {
+			j += len("</pre>")
+		}
+		b.WriteString(html[:j])
+		html = html[j:]
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func normalizeRun(s string) string {
+	s = spaces.ReplaceAllString(s, " ")
+	s = aroundTags.ReplaceAllString(s, "$1")
+	s = strings.Replace(s, "<br />", "<br />\n", -1)
+	return s
+}
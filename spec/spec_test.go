@@ -0,0 +1,158 @@
+package spec
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"testing"
+
+	"../parser"
+	"../render"
+)
+
+var update = flag.Bool("update", false, "rewrite the lists of failing examples in testdata with those failing now")
+
+// gfmExtensions are the parser extensions implementing those the GFM spec
+// tags its examples with
+var gfmExtensions = map[string]parser.Extension{
+	"table":         parser.Tables,
+	"strikethrough": parser.Strikethrough,
+	"tasklist":      parser.TaskLists,
+}
+
+// TestSpec runs the CommonMark examples through the parser & HTML renderer
+func TestSpec(t *testing.T) {
+	p := parser.New(parser.WithDialect(parser.CommonMark))
+	runSuite(t, "CommonMark "+Version, Examples, "testdata/failing.txt", func(Example) *parser.Parser { return p })
+}
+
+// TestSpecStrict runs the CommonMark examples through a parser accepting
+// no mistakes, which follows the spec no less than one that does
+func TestSpecStrict(t *testing.T) {
+	p := parser.New(parser.WithDialect(parser.CommonMark), parser.WithRelaxations(parser.Strict))
+	runSuite(t, "CommonMark "+Version+" strictly", Examples, "testdata/failing.txt", func(Example) *parser.Parser { return p })
+}
+
+// TestGFM runs the examples of the GFM extensions through the parser, with
+// the extensions each is of on top of CommonMark, & HTML renderer
+func TestGFM(t *testing.T) {
+	parsers := make(map[string]*parser.Parser)
+	runSuite(t, "GFM", GFMExamples, "testdata/failing-gfm.txt", func(ex Example) *parser.Parser {
+		key := strings.Join(ex.Exts, " ")
+		if p := parsers[key]; p != nil {
+			return p
+		}
+		exts := parser.CommonMark.Extensions()
+		for _, name := range ex.Exts {
+			if ext := gfmExtensions[name]; ext != nil {
+				exts = append(exts, ext)
+			}
+		}
+		parsers[key] = parser.New(parser.WithExtensions(exts...))
+		return parsers[key]
+	})
+}
+
+// runSuite runs examples, each with the parser parserFor returns, through
+// the HTML renderer, logging how many of each section pass. Those known
+// to fail are listed in failingFile, so that the test catches both
+// examples that stop passing & those that start to, which should be taken
+// off the list. Run it with -update to rewrite the list
+func runSuite(t *testing.T, name string, examples []Example, failingFile string, parserFor func(Example) *parser.Parser) {
+	known, err := readFailing(failingFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := render.NewHTMLRenderer()
+	var failing []Example
+	var sections []string
+	passed, total := make(map[string]int), make(map[string]int)
+	for _, ex := range examples {
+		got, err := run(parserFor(ex), html, ex.Markdown)
+		pass := err == nil && Normalize(got) == Normalize(ex.HTML)
+		if total[ex.Section] == 0 {
+			sections = append(sections, ex.Section)
+		}
+		total[ex.Section]++
+		if pass {
+			passed[ex.Section]++
+		} else {
+			failing = append(failing, ex)
+		}
+		switch {
+		case *update:
+		case !pass && !known[ex.Number]:
+			t.Errorf("example %d (%s, line %d) fails\n--- markdown\n%s--- want\n%s--- got\n%s",
+				ex.Number, ex.Section, ex.Line, ex.Markdown, ex.HTML, got)
+			if err != nil {
+				t.Errorf("--- error\n%v", err)
+			}
+		case pass && known[ex.Number]:
+			t.Errorf("example %d (%s, line %d) passes now, take it off %s", ex.Number, ex.Section, ex.Line, failingFile)
+		}
+	}
+	for _, section := range sections {
+		t.Logf("%-40s %3d passed %3d failed", section, passed[section], total[section]-passed[section])
+	}
+	t.Logf("%-40s %3d passed %3d failed", name, len(examples)-len(failing), len(failing))
+	if *update {
+		if err := writeFailing(failingFile, name, failing); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// run parses & renders a single example, turning panics into errors so one
+// crashing example doesn't stop the rest
+func run(p *parser.Parser, html *render.HTMLRenderer, md string) (out string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	doc, err := p.Parse("example", md)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := html.Render(&b, doc.Root); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// readFailing returns the numbers of the examples in failingFile. Each line
+// starts with one, followed by its section; lines starting with # are
+// comments
+func readFailing(failingFile string) (map[int]bool, error) {
+	data, err := ioutil.ReadFile(failingFile)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[int]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		n, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", failingFile, err)
+		}
+		known[n] = true
+	}
+	return known, nil
+}
+
+func writeFailing(failingFile, name string, failing []Example) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# Examples of %s the parser & HTML renderer don't pass yet, by\n", name)
+	b.WriteString("# number. go test -update rewrites this\n")
+	for _, ex := range failing {
+		fmt.Fprintf(&b, "%d %s\n", ex.Number, ex.Section)
+	}
+	return ioutil.WriteFile(failingFile, b.Bytes(), 0644)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+
+	"./parser"
+)
+
+// wordsPerMinute is the reading speed reading times are estimated at
+const wordsPerMinute = 200
+
+// docStats counts what a document is made of
+type docStats struct {
+	File       string `json:"file"`
+	Words      int    `json:"words"`
+	Characters int    `json:"characters"`
+	Headings   [6]int `json:"headings"` // Counted by level, h1 first
+	CodeBlocks int    `json:"codeBlocks"`
+	Links      int    `json:"links"`
+	Images     int    `json:"images"`
+	Minutes    int    `json:"readingMinutes"` // Estimated time to read it, rounded up
+
+	text strings.Builder // The words counted, for splitting once complete
+}
+
+// add counts n and everything beneath it
+func (s *docStats) add(n *parser.Node) {
+	switch n.Kind {
+	case parser.NodeText, parser.NodeCodeSpan:
+		s.text.WriteString(n.Literal)
+		s.Characters += utf8.RuneCountInString(n.Literal)
+	case parser.NodeSoftBreak, parser.NodeHardBreak:
+		s.text.WriteByte(' ')
+	case parser.NodeHeading:
+		if n.Level >= 1 && n.Level <= 6 {
+			s.Headings[n.Level-1]++
+		}
+	case parser.NodeCodeBlock:
+		s.CodeBlocks++
+	case parser.NodeLink:
+		s.Links++
+	case parser.NodeImage:
+		s.Images++
+	}
+	for _, c := range n.Children {
+		s.add(c)
+	}
+	if n.IsBlock() {
+		s.text.WriteByte(' ') // Words don't run on from one block to the next
+	}
+}
+
+// sum adds the counts of o to s
+func (s *docStats) sum(o *docStats) {
+	s.Words += o.Words
+	s.Characters += o.Characters
+	for i := range s.Headings {
+		s.Headings[i] += o.Headings[i]
+	}
+	s.CodeBlocks += o.CodeBlocks
+	s.Links += o.Links
+	s.Images += o.Images
+}
+
+// count works out the word count & reading time once everything is added
+func (s *docStats) count() {
+	s.Words = len(strings.Fields(s.text.String()))
+	s.Minutes = readingMinutes(s.Words)
+}
+
+// readingMinutes estimates how long words take to read, rounded up
+func readingMinutes(words int) int {
+	return (words + wordsPerMinute - 1) / wordsPerMinute
+}
+
+// stats reports the size & makeup of markdown files, and their total
+func stats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the counts as JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s stats [-json] [file or pattern ...]\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Counts the words, characters, headings, code blocks, links and images in")
+		fmt.Fprintln(os.Stderr, "each markdown file, or standard input if none are given, and estimates")
+		fmt.Fprintf(os.Stderr, "how long each takes to read at %d words a minute. Code blocks aren't\n", wordsPerMinute)
+		fmt.Fprintln(os.Stderr, "counted as words.")
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+	}
+	args, err := expandArgs(parseInterspersed(fs, args))
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		args = []string{stdinName}
+	}
+	var files []*docStats
+	total := &docStats{File: "total"}
+	for _, path := range args {
+		name, src, err := readSource(path)
+		if err != nil {
+			return err
+		}
+		doc, err := parser.Parse(name, src)
+		if err != nil {
+			return err
+		}
+		s := &docStats{File: name}
+		s.add(doc.Root)
+		s.count()
+		total.sum(s)
+		files = append(files, s)
+	}
+	total.Minutes = readingMinutes(total.Words)
+
+	if *asJSON {
+		b, err := json.MarshalIndent(struct {
+			Files []*docStats `json:"files"`
+			Total *docStats   `json:"total"`
+		}{files, total}, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s\n", b)
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "words\tchars\th1\th2\th3\th4\th5\th6\tcode\tlinks\timages\tminutes\t\tfile")
+	if len(files) > 1 {
+		files = append(files, total)
+	}
+	for _, s := range files {
+		fmt.Fprintf(tw, "%d\t%d\t", s.Words, s.Characters)
+		for _, h := range s.Headings {
+			fmt.Fprintf(tw, "%d\t", h)
+		}
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%d\t\t%s\n", s.CodeBlocks, s.Links, s.Images, s.Minutes, s.File)
+	}
+	return tw.Flush()
+}
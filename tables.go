@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// delimiterRow matches the row under the header of a pipe table, setting
+// out how each column is aligned
+var delimiterRow = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+
+// Column alignments, as set out by a table's delimiter row
+const (
+	alignNone = iota
+	alignLeft
+	alignRight
+	alignCenter
+)
+
+// tablePlaceholder stands in for a table, by number, while the rest of a
+// document is formatted. It's a comment, which formatting keeps
+const tablePlaceholder = "<!-- gomd-table %d -->"
+
+// extractTables returns the markdown src with each pipe table replaced by
+// a placeholder, and the lines of the tables taken out. The parser reads
+// tables as paragraphs, so formatting them would lose the difference
+// between the pipes between cells and escaped ones within them; they're
+// found by their lines instead: a header row & a delimiter row, each with
+// a pipe in it, after a blank line & outside code blocks, then every line
+// up to the next blank one
+func extractTables(src string) (string, [][]string) {
+	lines := strings.Split(src, "\n")
+	var tables [][]string
+	fence, blockStart := "", true
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r")
+		if t := strings.TrimLeft(line, " "); fence == "" && (strings.HasPrefix(t, "```") || strings.HasPrefix(t, "~~~")) {
+			fence = t[:3]
+		} else if fence != "" && strings.HasPrefix(t, fence) {
+			fence = ""
+		}
+		if fence != "" || !blockStart || strings.HasPrefix(line, "    ") || !isTableStart(lines[i:]) {
+			blockStart = strings.TrimSpace(line) == ""
+			continue
+		}
+		end := i + 2
+		for end < len(lines) && strings.TrimSpace(lines[end]) != "" {
+			end++
+		}
+		table := make([]string, end-i)
+		for j := range table {
+			table[j] = strings.TrimRight(lines[i+j], "\r")
+		}
+		placeholder := []string{fmt.Sprintf(tablePlaceholder, len(tables)), ""}
+		tables = append(tables, table)
+		lines = append(lines[:i], append(placeholder, lines[end:]...)...)
+		i++ // Past the blank line after the placeholder
+		blockStart = true
+	}
+	return strings.Join(lines, "\n"), tables
+}
+
+// restoreTables returns the formatted markdown md with the placeholders
+// of tables replaced by them, reflowed so their pipes line up, with a
+// space either side of each cell and their delimiter rows written the same
+// way. Tables that would be wider than width characters once aligned are
+// written compactly instead, unless width is 0
+func restoreTables(md string, tables [][]string, width int) string {
+	if len(tables) == 0 {
+		return md
+	}
+	lines := strings.Split(md, "\n")
+	for i, line := range lines {
+		var n int
+		if _, err := fmt.Sscanf(line, tablePlaceholder, &n); err == nil && n < len(tables) && line == fmt.Sprintf(tablePlaceholder, n) {
+			lines[i] = strings.Join(formatTable(tables[n], width), "\n")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isTableStart reports whether lines start with the header & delimiter
+// rows of a table, with the same number of cells
+func isTableStart(lines []string) bool {
+	return len(lines) >= 2 && strings.Contains(lines[0], "|") && delimiterRow.MatchString(lines[1]) &&
+		len(splitRow(lines[0])) == len(splitRow(lines[1]))
+}
+
+// formatTable returns the lines of a table, aligned if that keeps it no
+// wider than width, or 0 means any width, and compact otherwise
+func formatTable(lines []string, width int) []string {
+	rows := make([][]string, len(lines))
+	cols := 0
+	for i, line := range lines {
+		rows[i] = splitRow(line)
+		if len(rows[i]) > cols {
+			cols = len(rows[i])
+		}
+	}
+	aligns := make([]int, cols)
+	for c, cell := range rows[1] {
+		left, right := strings.HasPrefix(cell, ":"), strings.HasSuffix(cell, ":")
+		switch {
+		case left && right:
+			aligns[c] = alignCenter
+		case left:
+			aligns[c] = alignLeft
+		case right:
+			aligns[c] = alignRight
+		}
+	}
+	widths := make([]int, cols)
+	total := 1
+	for c := range widths {
+		widths[c] = 3 // The shortest delimiter, ---
+		for r, row := range rows {
+			if r != 1 && c < len(row) && utf8.RuneCountInString(row[c]) > widths[c] {
+				widths[c] = utf8.RuneCountInString(row[c])
+			}
+		}
+		total += widths[c] + 3
+	}
+	compact := width > 0 && total > width
+	out := make([]string, len(rows))
+	for r, row := range rows {
+		var b strings.Builder
+		b.WriteString("|")
+		for c := 0; c < cols; c++ {
+			cell := ""
+			if c < len(row) {
+				cell = row[c]
+			}
+			w := widths[c]
+			if compact {
+				w = 0
+			}
+			if r == 1 {
+				cell = delimiter(aligns[c], w)
+			} else {
+				cell = pad(cell, aligns[c], w)
+			}
+			b.WriteString(" " + cell + " |")
+		}
+		out[r] = b.String()
+	}
+	return out
+}
+
+// splitRow returns the trimmed cells of a table row, split at the pipes
+// that aren't escaped or in code spans
+func splitRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+	var cells []string
+	start, code := 0, false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '`':
+			code = !code
+		case '|':
+			if !code {
+				cells = append(cells, strings.TrimSpace(line[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(cells, strings.TrimSpace(line[start:]))
+}
+
+// delimiter returns the delimiter row cell for a column aligned align,
+// width characters wide or as narrow as it can be
+func delimiter(align, width int) string {
+	switch align {
+	case alignLeft:
+		return ":" + strings.Repeat("-", max(width-1, 2))
+	case alignRight:
+		return strings.Repeat("-", max(width-1, 2)) + ":"
+	case alignCenter:
+		return ":" + strings.Repeat("-", max(width-2, 1)) + ":"
+	}
+	return strings.Repeat("-", max(width, 3))
+}
+
+// pad returns cell padded with spaces to width characters, aligned align
+func pad(cell string, align, width int) string {
+	n := width - utf8.RuneCountInString(cell)
+	if n <= 0 {
+		return cell
+	}
+	switch align {
+	case alignRight:
+		return strings.Repeat(" ", n) + cell
+	case alignCenter:
+		return strings.Repeat(" ", n/2) + cell + strings.Repeat(" ", n-n/2)
+	}
+	return cell + strings.Repeat(" ", n)
+}
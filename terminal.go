@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+
+	"./render"
+)
+
+// Values of the -color flag
+const (
+	colorAuto   = "auto"   // Color output to a terminal, unless $NO_COLOR is set
+	colorAlways = "always" // Color output wherever it's going, for pipes to less -R
+	colorNever  = "never"
+)
+
+// defaultFormat returns the format to write in when -to isn't given: ANSI
+// styled text when writing text to a terminal, or to any standard output
+// if color says so, plain text when writing to a terminal but color says
+// not to, and HTML otherwise. text is false when writing to files or when
+// HTML pages or fragments were asked for
+func defaultFormat(color string, text bool) (string, error) {
+	switch color {
+	case colorAlways:
+		if text {
+			return render.FormatANSI, nil
+		}
+	case colorNever:
+		if text && isTerminal(os.Stdout) {
+			return render.FormatText, nil
+		}
+	case colorAuto:
+		if text && isTerminal(os.Stdout) {
+			if _, ok := os.LookupEnv("NO_COLOR"); ok {
+				return render.FormatText, nil
+			}
+			return render.FormatANSI, nil
+		}
+	default:
+		return "", usagef("unknown -color %q, want %s, %s or %s", color, colorAuto, colorAlways, colorNever)
+	}
+	return render.FormatHTML, nil
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// startPager sends standard output through $PAGER, or less, returning a
+// func that waits for the reader to finish with it. Output is left as it
+// is if the pager can't be run
+func startPager() (stop func()) {
+	pager := strings.Fields(os.Getenv("PAGER"))
+	if len(pager) == 0 {
+		pager = []string{"less"}
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return func() {}
+	}
+	cmd := exec.Command(pager[0], pager[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = r, os.Stdout, os.Stderr
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		// Pass colors through, and don't page what fits on one screen
+		cmd.Env = append(os.Environ(), "LESS=FRX")
+	}
+	err = cmd.Start()
+	r.Close()
+	if err != nil {
+		w.Close()
+		return func() {}
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	return func() {
+		os.Stdout = stdout
+		w.Close()
+		cmd.Wait()
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"./parser"
+)
+
+// Markers delimiting the table of contents toc -write, fmt & build
+// refresh
+const (
+	tocStart = "<!-- toc -->"
+	tocStop  = "<!-- tocstop -->"
+)
+
+// tocEntry is a heading in the table of contents, as written by toc -json
+type tocEntry struct {
+	Level    int         `json:"level"`
+	Text     string      `json:"text"`
+	ID       string      `json:"id"`
+	Children []*tocEntry `json:"children,omitempty"`
+}
+
+// toc prints the table of contents of a markdown file, or refreshes the
+// one between the markers in each file given
+func toc(args []string) error {
+	fs := flag.NewFlagSet("toc", flag.ExitOnError)
+	depth := fs.Int("depth", 6, "deepest heading level to include")
+	asJSON := fs.Bool("json", false, "print the table of contents as JSON")
+	write := fs.Bool("write", false, "replace the contents between "+tocStart+" and "+tocStop+" in each file")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s toc [-depth n] [-json] file\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s toc [-depth n] -write file ...\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Prints a nested list linking to each heading in file, or with -write")
+		fmt.Fprintln(os.Stderr, "updates the list between the toc markers in each file.")
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+	}
+	args, err := expandArgs(parseInterspersed(fs, args))
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 || len(args) > 1 && !*write {
+		fs.Usage()
+		os.Exit(2)
+	}
+	for _, path := range args {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		doc, err := parser.Parse(path, string(src))
+		if err != nil {
+			return err
+		}
+		entries := tocEntries(doc.Outline(), *depth)
+		switch {
+		case *write:
+			out, err := insertTOC(string(src), tocMarkdown(entries, 0))
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			if out != string(src) {
+				if err := ioutil.WriteFile(path, []byte(out), 0644); err != nil {
+					return err
+				}
+			}
+		case *asJSON:
+			if entries == nil {
+				entries = []*tocEntry{}
+			}
+			b, err := json.MarshalIndent(entries, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s\n", b)
+		default:
+			fmt.Print(tocMarkdown(entries, 0))
+		}
+	}
+	return nil
+}
+
+// tocEntries converts the headings in outline no deeper than depth
+func tocEntries(outline []*parser.Heading, depth int) []*tocEntry {
+	var entries []*tocEntry
+	for _, h := range outline {
+		if h.Level > depth {
+			continue
+		}
+		entries = append(entries, &tocEntry{
+			Level:    h.Level,
+			Text:     h.Text,
+			ID:       h.ID,
+			Children: tocEntries(h.Children, depth),
+		})
+	}
+	return entries
+}
+
+// linkTextEscaper escapes the characters that would end link text early
+var linkTextEscaper = strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`)
+
+// tocMarkdown writes entries as a markdown list of links, nested indent
+// lists deep
+func tocMarkdown(entries []*tocEntry, indent int) string {
+	var b strings.Builder
+	for _, e := range entries {
+		fmt.Fprintf(&b, "%s- [%s](#%s)\n", strings.Repeat("  ", indent), linkTextEscaper.Replace(e.Text), e.ID)
+		b.WriteString(tocMarkdown(e.Children, indent+1))
+	}
+	return b.String()
+}
+
+// refreshTOC returns src, from the file name, with a fresh table of
+// contents between its toc markers, or src as it is if it hasn't a pair
+func refreshTOC(name, src string) (string, error) {
+	if markerLine(src, tocStart, 0) < 0 {
+		return src, nil
+	}
+	doc, err := parser.Parse(name, src)
+	if err != nil {
+		return "", err
+	}
+	out, err := insertTOC(src, tocMarkdown(tocEntries(doc.Outline(), 6), 0))
+	if err != nil {
+		return src, nil // Not a pair
+	}
+	return out, nil
+}
+
+// tocMarkers is an Extension replacing the toc markers of each document,
+// and whatever is between them, with a table of contents of its headings.
+// Parsers using it should give headings IDs for the entries to link to
+var tocMarkers = parser.ExtensionFunc(func(p *parser.Parser) {
+	p.AddTransformer(parser.TransformerFunc(func(doc *parser.Document) {
+		// Markers are read as paragraph text, and the stop marker may
+		// have run on from the block before it
+		blocks := doc.Root.Children
+		start, stop := -1, -1
+		for i, n := range blocks {
+			text := strings.TrimSpace(n.PlainText())
+			if start < 0 && n.Kind == parser.NodeParagraph && strings.HasPrefix(text, tocStart) {
+				start = i
+			}
+			if start >= 0 && strings.HasSuffix(text, tocStop) {
+				stop = i
+				break
+			}
+		}
+		if stop < 0 {
+			return
+		}
+		list := tocList(tocEntries(doc.Outline(), 6))
+		children := append([]*parser.Node{}, blocks[:start]...)
+		if list != nil {
+			list.Parent = doc.Root
+			children = append(children, list)
+		}
+		doc.Root.Children = append(children, blocks[stop+1:]...)
+	}))
+})
+
+// tocList returns entries as a list of links, with lists of their children
+// nested within, or nil if there are none
+func tocList(entries []*tocEntry) *parser.Node {
+	if len(entries) == 0 {
+		return nil
+	}
+	list := parser.NewNode(parser.NodeList)
+	for _, e := range entries {
+		text := parser.NewNode(parser.NodeText)
+		text.Literal = e.Text
+		link := parser.NewNode(parser.NodeLink)
+		link.Dest = "#" + e.ID
+		link.AppendChild(text)
+		item := parser.NewNode(parser.NodeListItem)
+		item.AppendChild(link)
+		if sub := tocList(e.Children); sub != nil {
+			item.AppendChild(sub)
+		}
+		list.AppendChild(item)
+	}
+	return list
+}
+
+// insertTOC replaces whatever is between the toc markers in src with toc
+func insertTOC(src, toc string) (string, error) {
+	start := markerLine(src, tocStart, 0)
+	if start < 0 {
+		return "", errors.New("no " + tocStart + " marker")
+	}
+	from := start + strings.IndexByte(src[start:]+"\n", '\n') + 1
+	stop := markerLine(src, tocStop, from)
+	if stop < 0 {
+		return "", errors.New("no " + tocStop + " marker after " + tocStart)
+	}
+	return src[:from] + "\n" + toc + "\n" + src[stop:], nil
+}
+
+// markerLine returns the offset of the first line in src from offset from
+// on that consists of marker, or -1 if there isn't one
+func markerLine(src, marker string, from int) int {
+	for i := from; i < len(src); {
+		end := strings.IndexByte(src[i:], '\n')
+		if end < 0 {
+			end = len(src) - i
+		}
+		if strings.TrimSpace(src[i:i+end]) == marker {
+			return i
+		}
+		i += end + 1
+	}
+	return -1
+}
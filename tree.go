@@ -0,0 +1,152 @@
+package main
+
+import (
+	"archive/zip"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"./parser"
+)
+
+// tree is the files a command reads: those in a directory, or in another
+// fs.FS such as a zip archive. Files are named by their path from dir
+type tree struct {
+	dir  string // "." in an fs.FS
+	fsys fs.FS  // nil for the OS's files
+
+	closer io.Closer // The archive, if it's in one
+}
+
+// osFiles is the OS's files, named as they are
+var osFiles = &tree{dir: "."}
+
+// openTree returns the tree of the files in path, a directory or a zip
+// archive, to be closed when done with
+func openTree(path string) (*tree, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &tree{dir: filepath.Clean(path)}, nil
+	}
+	if !strings.EqualFold(filepath.Ext(path), ".zip") {
+		return nil, usagef("%s is not a directory or zip archive", path)
+	}
+	z, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	return &tree{dir: ".", fsys: z, closer: z}, nil
+}
+
+// Close closes the archive the tree is in, if it is in one
+func (t *tree) Close() error {
+	if t.closer == nil {
+		return nil
+	}
+	return t.closer.Close()
+}
+
+// name returns the name of file in the fs.FS, which doesn't exist if it's
+// outside the tree
+func (t *tree) name(file string) (string, error) {
+	name := filepath.ToSlash(filepath.Clean(file))
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "open", Path: file, Err: fs.ErrNotExist}
+	}
+	return name, nil
+}
+
+// walk calls fn for each file in the tree, dir first, as filepath.Walk does
+func (t *tree) walk(fn filepath.WalkFunc) error {
+	if t.fsys == nil {
+		return filepath.Walk(t.dir, fn)
+	}
+	return fs.WalkDir(t.fsys, t.dir, func(name string, d fs.DirEntry, err error) error {
+		var info fs.FileInfo
+		if err == nil {
+			info, err = d.Info()
+		}
+		return fn(name, info, err)
+	})
+}
+
+// open opens file for reading
+func (t *tree) open(file string) (fs.File, error) {
+	if t.fsys == nil {
+		return os.Open(file)
+	}
+	name, err := t.name(file)
+	if err != nil {
+		return nil, err
+	}
+	return t.fsys.Open(name)
+}
+
+// readFile returns what file holds
+func (t *tree) readFile(file string) ([]byte, error) {
+	if t.fsys == nil {
+		return ioutil.ReadFile(file)
+	}
+	name, err := t.name(file)
+	if err != nil {
+		return nil, err
+	}
+	return fs.ReadFile(t.fsys, name)
+}
+
+// parse parses file with p, with the files it includes from the tree
+func (t *tree) parse(p *parser.Parser, file string) (*parser.Document, error) {
+	if t.fsys == nil {
+		return p.ParseFS(parser.OSFiles, filepath.ToSlash(file))
+	}
+	name, err := t.name(file)
+	if err != nil {
+		return nil, err
+	}
+	return p.ParseFS(t.fsys, name)
+}
+
+// stat describes file
+func (t *tree) stat(file string) (fs.FileInfo, error) {
+	if t.fsys == nil {
+		return os.Stat(file)
+	}
+	name, err := t.name(file)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Stat(t.fsys, name)
+}
+
+// http returns the tree as files for an http.FileServer
+func (t *tree) http() http.FileSystem {
+	if t.fsys == nil {
+		return http.Dir(t.dir)
+	}
+	return http.FS(t.fsys)
+}
+
+// copyFile copies file from the tree to dest, on the OS's files
+func (t *tree) copyFile(file, dest string) error {
+	in, err := t.open(file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
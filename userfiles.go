@@ -0,0 +1,99 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"./render"
+)
+
+//go:embed templates/*.html
+var templateFiles embed.FS
+
+// The templates site & wiki lay out pages with, from templates/ unless the
+// user has their own
+var (
+	siteTemplate *template.Template
+	wikiTemplate *template.Template
+)
+
+// userDir returns the directory of the user's own themes & templates,
+// which are laid over the bundled ones, or "" if they have none
+func userDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gomd")
+}
+
+// loadUserFiles loads the themes & templates, each from the user's
+// directory if it's there and bundled otherwise. Themes in themes/ of it
+// are added to the bundled ones, or replace those of the same name, and
+// site.html & wiki.html in templates/ replace the page layouts
+func loadUserFiles() error {
+	var themes, templates layers
+	if dir := userDir(); dir != "" {
+		themes = append(themes, os.DirFS(filepath.Join(dir, "themes")))
+		templates = append(templates, os.DirFS(filepath.Join(dir, "templates")))
+	}
+	bundled, _ := fs.Sub(templateFiles, "templates")
+	themes, templates = append(themes, render.ThemeFS), append(templates, bundled)
+	var err error
+	if render.Themes, err = render.LoadThemes(themes); err != nil {
+		return fmt.Errorf("loading themes: %v", err)
+	}
+	if siteTemplate, err = template.ParseFS(templates, "site.html"); err != nil {
+		return err
+	}
+	wikiTemplate, err = template.ParseFS(templates, "wiki.html")
+	return err
+}
+
+// layers is a file system made of others laid one over another: each file
+// is taken from the first that has it, and each directory lists the files
+// of all of them
+type layers []fs.FS
+
+func (l layers) Open(name string) (fs.File, error) {
+	for _, fsys := range l {
+		f, err := fsys.Open(name)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return f, err
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+func (l layers) ReadDir(name string) ([]fs.DirEntry, error) {
+	var entries []fs.DirEntry
+	seen := make(map[string]bool)
+	found := false
+	for _, fsys := range l {
+		list, err := fs.ReadDir(fsys, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found = true
+		for _, e := range list {
+			if !seen[e.Name()] {
+				seen[e.Name()] = true
+				entries = append(entries, e)
+			}
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
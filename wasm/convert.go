@@ -0,0 +1,66 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"../parser"
+	"../render"
+)
+
+// options are the settings Convert takes as JSON, such as
+// {"format": "html", "minify": true}
+type options struct {
+	Format      string `json:"format"`      // html (the default), slides, docbook, jira, asciidoc or bbcode
+	Plain       bool   `json:"plain"`       // Turn off the default extensions
+	MaxNesting  int    `json:"maxNesting"`  // How deeply inline elements may nest
+	Minify      bool   `json:"minify"`      // Drop whitespace between HTML tags
+	ClassPrefix string `json:"classPrefix"` // Prepended to every class
+	LineEnding  string `json:"lineEnding"`  // "\n" or "\r\n" for every line of output
+}
+
+// convert renders markdown as optionsJSON asks
+func convert(markdown, optionsJSON string) (string, error) {
+	opts := options{Format: render.FormatHTML}
+	if optionsJSON != "" {
+		if err := json.Unmarshal([]byte(optionsJSON), &opts); err != nil {
+			return "", fmt.Errorf("bad options: %v", err)
+		}
+	}
+	var popts []parser.Option
+	if opts.Plain {
+		popts = append(popts, parser.WithExtensions())
+	}
+	if opts.MaxNesting > 0 {
+		popts = append(popts, parser.WithMaxNesting(opts.MaxNesting))
+	}
+	var ropts []render.Option
+	if opts.Minify {
+		ropts = append(ropts, render.WithMinify())
+	}
+	if opts.ClassPrefix != "" {
+		ropts = append(ropts, render.WithClassPrefix(opts.ClassPrefix))
+	}
+	switch le := parser.LineEnding(opts.LineEnding); le {
+	case "":
+	case parser.LF, parser.CRLF:
+		ropts = append(ropts, render.WithLineEnding(le))
+	default:
+		return "", fmt.Errorf("unknown line ending %q", opts.LineEnding)
+	}
+
+	r, err := render.NewRenderer(opts.Format, ropts...)
+	if err != nil {
+		return "", err
+	}
+	doc, err := parser.New(popts...).Parse("input", markdown)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	err = render.RenderDocument(r, &out, doc)
+	return out.String(), err
+}
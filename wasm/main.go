@@ -0,0 +1,40 @@
+//go:build js && wasm
+
+// Command wasm exposes the converter to JavaScript, for live previews that
+// render in the browser with the same engine as the server. Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o gomd.wasm ./wasm
+//
+// and load gomd.wasm with the wasm_exec.js shipped in $(go env GOROOT)/lib/wasm.
+// Once running it defines a global function
+//
+//	gomd.Convert(markdown, optionsJSON) → string
+//
+// which returns the rendered output, or an Error describing why markdown
+// couldn't be converted; Go can't throw into JavaScript. optionsJSON may
+// be empty, see options for the fields it takes
+package main
+
+import (
+	"syscall/js"
+)
+
+func main() {
+	gomd := js.Global().Get("Object").New()
+	gomd.Set("Convert", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		var markdown, opts string
+		if len(args) > 0 {
+			markdown = args[0].String()
+		}
+		if len(args) > 1 && args[1].Type() == js.TypeString {
+			opts = args[1].String()
+		}
+		out, err := convert(markdown, opts)
+		if err != nil {
+			return js.Global().Get("Error").New(err.Error())
+		}
+		return out
+	}))
+	js.Global().Set("gomd", gomd)
+	select {} // Keep serving calls from JavaScript
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"./parser"
+	"./render"
+)
+
+// pollInterval is how often watched files are checked for changes, where
+// the OS can't say when they change
+const pollInterval = 250 * time.Millisecond
+
+// watch converts each markdown file in paths with p & r to the file dest names
+// for it, then again whenever it changes, until the process is killed.
+// Directories are watched for markdown files anywhere beneath them. Only
+// the files the OS says changed are looked at again, or on systems where
+// it can't, every file is polled for a new size or modification time
+func watch(p *parser.Parser, r render.Renderer, paths []string, dest func(string) string) error {
+	for _, path := range paths {
+		if path == stdinName {
+			return errors.New("standard input can't be watched")
+		}
+	}
+	w := newDirWatcher()
+	named := make(map[string]string) // Files named in paths, as given, by their cleaned path
+	seen := make(map[string]os.FileInfo)
+	beneath := make(map[string]bool) // Directories in paths & those beneath them
+	var lastErr string
+	var changed []string
+	for {
+		var files []string
+		if changed == nil {
+			var dirs []string
+			var err error
+			files, dirs, err = watchedFiles(paths, w)
+			if err != nil && err.Error() != lastErr { // Only once, not every poll
+				logger.Error(err.Error())
+			}
+			lastErr = fmt.Sprint(err)
+			current := make(map[string]bool, len(files))
+			for _, f := range files {
+				current[f] = true
+			}
+			for f := range seen {
+				if !current[f] {
+					delete(seen, f) // Converted again if it comes back
+				}
+			}
+			for _, d := range dirs {
+				beneath[d] = true
+			}
+			for _, path := range paths {
+				if info, err := os.Stat(path); err == nil && !info.IsDir() {
+					named[filepath.Clean(path)] = path
+					w.add(filepath.Dir(path))
+				}
+			}
+		} else {
+			files = changedFiles(changed, named, beneath, w)
+		}
+		for _, f := range files {
+			info, err := os.Stat(f)
+			if err != nil {
+				delete(seen, f) // Removed, converted again if it comes back
+				continue
+			}
+			if prev := seen[f]; prev != nil && prev.Size() == info.Size() && prev.ModTime().Equal(info.ModTime()) {
+				continue
+			}
+			seen[f] = info
+			start := time.Now()
+			out := dest(f)
+			if out == f {
+				err = errors.New("output would overwrite the input")
+			} else {
+				err = convertFile(p, r, f, out)
+			}
+			if err != nil {
+				logger.Error(fmt.Sprintf("%s: %v", f, err), "file", f, "err", err)
+				continue
+			}
+			d := time.Since(start)
+			logger.Info(fmt.Sprintf("%s -> %s (%v)", f, out, d.Round(time.Microsecond)), "file", f, "out", out, "duration", d)
+		}
+		changed = w.wait()
+	}
+}
+
+// changedFiles returns which of the paths the OS said changed are watched
+// files: those named, & markdown files in directories beneath those named.
+// New directories beneath them are watched too, with the files in them
+func changedFiles(changed []string, named map[string]string, beneath map[string]bool, w dirWatcher) []string {
+	var files []string
+	for _, path := range changed {
+		if f, ok := named[path]; ok {
+			files = append(files, f)
+			continue
+		}
+		if !beneath[filepath.Dir(path)] || strings.HasPrefix(filepath.Base(path), ".") {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil || !info.IsDir() {
+			if isMarkdown(path) {
+				files = append(files, path) // Forgotten if it's gone
+			}
+			continue
+		}
+		more, dirs, _ := watchedFiles([]string{path}, w)
+		for _, d := range dirs {
+			beneath[d] = true
+		}
+		files = append(files, more...)
+	}
+	return files
+}
+
+// watchedFiles lists the files in paths, replacing directories with the
+// markdown files beneath them, skipping hidden ones, & the directories
+// walked to find them, each added to w before it's read so no file created
+// in it is missed. Paths that can't be read are left out, and the first
+// error returned
+func watchedFiles(paths []string, w dirWatcher) (files, dirs []string, first error) {
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if p != path && strings.HasPrefix(info.Name(), ".") {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info.IsDir() {
+				w.add(p)
+				dirs = append(dirs, p)
+			} else if info.Mode().IsRegular() && isMarkdown(p) {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	return files, dirs, first
+}
+
+// A dirWatcher waits for the files in directories to change
+type dirWatcher interface {
+	add(dir string) // Watches the files directly in dir
+	// wait blocks until files change, returning their paths, or nil if
+	// any file could have
+	wait() []string
+}
+
+// poller is a dirWatcher for when the OS can't say which files change,
+// having every file checked again each pollInterval
+type poller struct{}
+
+func (poller) add(string) {}
+
+func (poller) wait() []string {
+	time.Sleep(pollInterval)
+	return nil
+}
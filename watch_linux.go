@@ -0,0 +1,75 @@
+//go:build linux
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// inotifyMask is the changes inotify is asked to report to the files in
+// watched directories
+const inotifyMask = syscall.IN_CLOSE_WRITE | syscall.IN_CREATE | syscall.IN_DELETE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ONLYDIR
+
+// inotify is a dirWatcher told of changes by Linux's inotify. If it fails,
+// as when out of watches, it polls instead
+type inotify struct {
+	fd     int
+	dirs   map[int32]string // Directories by their watch descriptor
+	buf    []byte
+	failed bool
+}
+
+// newDirWatcher returns an inotify, or a poller if it can't be created
+func newDirWatcher() dirWatcher {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return poller{}
+	}
+	return &inotify{fd: fd, dirs: make(map[int32]string), buf: make([]byte, 64<<10)}
+}
+
+func (n *inotify) add(dir string) {
+	wd, err := syscall.InotifyAddWatch(n.fd, dir, inotifyMask)
+	if err != nil {
+		n.failed = true
+		return
+	}
+	n.dirs[int32(wd)] = dir
+}
+
+func (n *inotify) wait() []string {
+	if n.failed {
+		return poller{}.wait()
+	}
+	for {
+		size, err := syscall.Read(n.fd, n.buf)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil || size <= 0 {
+			n.failed = true
+			return poller{}.wait()
+		}
+		var changed []string
+		for off := 0; off+syscall.SizeofInotifyEvent <= size; {
+			e := (*syscall.InotifyEvent)(unsafe.Pointer(&n.buf[off]))
+			name := strings.TrimRight(string(n.buf[off+syscall.SizeofInotifyEvent:off+syscall.SizeofInotifyEvent+int(e.Len)]), "\x00")
+			off += syscall.SizeofInotifyEvent + int(e.Len)
+			switch dir := n.dirs[e.Wd]; {
+			case e.Mask&syscall.IN_Q_OVERFLOW != 0:
+				return nil // Changes were missed
+			case e.Mask&syscall.IN_IGNORED != 0:
+				delete(n.dirs, e.Wd) // Removed
+			case dir != "" && name != "":
+				changed = append(changed, filepath.Join(dir, name))
+			}
+		}
+		if len(changed) > 0 {
+			return changed
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package main
+
+// newDirWatcher returns a poller, as only Linux's inotify is supported
+func newDirWatcher() dirWatcher {
+	return poller{}
+}
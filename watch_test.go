@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitForFile waits for the file at path to contain want
+func waitForFile(t *testing.T, path, want string) {
+	t.Helper()
+	var b []byte
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if b, _ = ioutil.ReadFile(path); strings.Contains(string(b), want) {
+			return
+		}
+	}
+	t.Fatalf("%s is %q, without %q", path, b, want)
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"docs/a.md":      "# First\n",
+		"docs/notes.txt": "not markdown",
+	})
+	cmd := exec.Command(gomdBin, "-watch", "docs")
+	cmd.Dir = dir
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+
+	waitForFile(t, filepath.Join(dir, "docs/a.html"), "First")
+	writeFiles(t, dir, map[string]string{"docs/a.md": "# Second\n"})
+	waitForFile(t, filepath.Join(dir, "docs/a.html"), "Second")
+	writeFiles(t, dir, map[string]string{"docs/new/b.md": "# New\n"})
+	waitForFile(t, filepath.Join(dir, "docs/new/b.html"), "New")
+	writeFiles(t, dir, map[string]string{"docs/.hidden.md": "# Hidden\n"})
+	time.Sleep(2 * pollInterval)
+	if _, err := os.Stat(filepath.Join(dir, "docs/.hidden.html")); err == nil {
+		t.Error("converted a hidden file")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "docs/notes.html")); err == nil {
+		t.Error("converted a file that isn't markdown")
+	}
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"./parser"
+	"./render"
+)
+
+// wikiSearchPath is where a wiki's search results are served
+const wikiSearchPath = "/_gomd/search"
+
+// wikiBacklinksPath is where the pages linking to each page are served, as
+// JSON
+const wikiBacklinksPath = "/_gomd/backlinks"
+
+// wikiPage is a markdown file in a wiki
+type wikiPage struct {
+	File  string // Path of the file
+	URL   string // Path the page is served at
+	Title string
+	Depth int // How many directories deep the page is
+	text  string
+	links pageLinks
+	mod   time.Time
+}
+
+// wikiIndex is every page of a wiki at some point in time
+type wikiIndex struct {
+	pages []*wikiPage // By URL
+	links *linkResolver
+}
+
+// wikiServer serves a directory of markdown as a wiki
+type wikiServer struct {
+	files      *tree
+	css        template.CSS // Theme to style pages with
+	stylesheet string       // URL of a stylesheet to style pages with
+	parser     *parser.Parser
+	scanner    *parser.Parser // Finding the links on pages
+
+	mu        sync.Mutex
+	cache     map[string]*wikiPage // By file, to skip reading unchanged files
+	index     *wikiIndex
+	ambiguous string // Names of several pages, last warned of
+}
+
+// wiki serves a directory of markdown as a wiki, with [[wiki links]]
+// between its pages and a search of their text
+func wiki(args []string) error {
+	fs := flag.NewFlagSet("wiki", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8080", "address to listen on")
+	theme := fs.String("theme", render.ThemeGitHub, "theme to style pages with: "+themeNames())
+	css := fs.String("css", "", "URL of a stylesheet to style pages with, instead of a theme")
+	withMetrics := fs.Bool("metrics", false, "serve Prometheus metrics at "+metricsPath)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s wiki [-addr host:port] [-theme name] [-css url] [-metrics] dir or zip\n\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Serves the markdown under dir as a wiki, each page listed beside it.")
+		fmt.Fprintln(os.Stderr, "[[Name]] links to the page whose file name or title is Name, ignoring")
+		fmt.Fprintln(os.Stderr, "case, and [[Name|text]] does so with other text. A name several pages")
+		fmt.Fprintln(os.Stderr, "have links to a list of them. Each page lists those linking to it,")
+		fmt.Fprintf(os.Stderr, "by wiki link or relative link, and %s serves them all as JSON.\n", wikiBacklinksPath)
+		fmt.Fprintln(os.Stderr, "Pages are read again as they change. A zip archive is served as the")
+		fmt.Fprintln(os.Stderr, "directory it holds.")
+		fmt.Fprintf(os.Stderr, "%s answers ok while the server is up, for health checks.\n", healthPath)
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+	}
+	args = parseInterspersed(fs, args)
+	if len(args) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	t, err := openTree(args[0])
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+	w := &wikiServer{files: t, stylesheet: *css, cache: make(map[string]*wikiPage)}
+	if *css == "" {
+		styles, ok := render.Themes[*theme]
+		if !ok {
+			return usagef("unknown theme %q", *theme)
+		}
+		w.css = template.CSS(styles)
+	}
+	w.parser = parser.New(parser.WithHeadingIDs(), parser.WithExtensions(withDefaults(wikiLinks(w.resolve), linkRewriter(wikiURL))...))
+	w.scanner = parser.New(parser.WithExtensions(withDefaults(wikiLinks(func(string) string { return "" }))...))
+	if _, err := w.refresh(); err != nil {
+		return err
+	}
+	http.Handle("/", w)
+	http.HandleFunc(wikiSearchPath, w.search)
+	http.HandleFunc(wikiBacklinksPath, w.backlinks)
+	handleService(*withMetrics)
+	logger.Info(fmt.Sprintf("serving %s at http://%s/", args[0], *addr), "path", args[0], "addr", *addr)
+	return http.ListenAndServe(*addr, instrument(http.DefaultServeMux))
+}
+
+// wikiLinks adds [[target]] & [[target|text]] links, pointed wherever
+// resolve says target is, marked with it as a wikiTarget
+func wikiLinks(resolve func(target string) string) parser.Extension {
+	return parser.ExtensionFunc(func(p *parser.Parser) {
+		p.AddInlineParser('[', parser.BeforeBuiltins, func(line string, pos int) (*parser.Node, int) {
+			if !strings.HasPrefix(line[pos:], "[[") {
+				return nil, 0
+			}
+			end := strings.Index(line[pos+2:], "]]")
+			if end < 0 {
+				return nil, 0
+			}
+			inner := line[pos+2 : pos+2+end]
+			if strings.ContainsAny(inner, "[]") || strings.TrimSpace(inner) == "" {
+				return nil, 0
+			}
+			target, text := inner, inner
+			if i := strings.IndexByte(inner, '|'); i >= 0 {
+				target, text = inner[:i], inner[i+1:]
+			}
+			link := parser.NewNode(parser.NodeLink)
+			link.Dest = resolve(strings.TrimSpace(target))
+			link.Data = wikiTarget(strings.TrimSpace(target))
+			t := parser.NewNode(parser.NodeText)
+			t.Literal = strings.TrimSpace(text)
+			link.AppendChild(t)
+			return link, pos + 2 + end + 2
+		})
+	})
+}
+
+// wikiName is how a page is named for wiki links: lower case, with dashes
+// & underscores as spaces
+func wikiName(s string) string {
+	s = strings.NewReplacer("-", " ", "_", " ").Replace(strings.ToLower(s))
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// wikiURL returns the path the markdown file, slash separated & relative to
+// the wiki's root, is served at: without its extension, or as its
+// directory for index.md & README.md
+func wikiURL(file string) string {
+	name := strings.ToLower(path.Base(file))
+	if name == "readme.md" || name == "index.md" {
+		if dir := path.Dir(file); dir != "." {
+			return dir + "/"
+		}
+		return "./"
+	}
+	return strings.TrimSuffix(file, path.Ext(file))
+}
+
+// resolve returns where the wiki link target points: the page it names,
+// keeping any #fragment, or a page of that name listing the pages it's
+// ambiguous between or that doesn't exist yet
+func (w *wikiServer) resolve(target string) string {
+	fragment := ""
+	if i := strings.IndexByte(target, '#'); i >= 0 {
+		target, fragment = target[:i], target[i:]
+	}
+	if target == "" {
+		return fragment
+	}
+	w.mu.Lock()
+	index := w.index
+	w.mu.Unlock()
+	if named := index.links.named(target); len(named) == 1 {
+		return named[0].URL + fragment
+	}
+	return "/" + (&url.URL{Path: target}).EscapedPath() + fragment
+}
+
+// refresh reads the pages added or changed since the last refresh, and
+// returns the index of every page
+func (w *wikiServer) refresh() (*wikiIndex, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	index := &wikiIndex{}
+	seen := make(map[string]bool)
+	text := render.NewTextRenderer()
+	err := w.files.walk(func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if file != w.files.dir && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() || !isMarkdown(file) {
+			return nil
+		}
+		seen[file] = true
+		page := w.cache[file]
+		if page != nil && page.mod.Equal(info.ModTime()) {
+			serverMetrics.cacheHits.Add(1)
+		} else {
+			serverMetrics.cacheMisses.Add(1)
+			doc, err := w.files.parse(w.scanner, file)
+			if err != nil {
+				return err
+			}
+			var b bytes.Buffer
+			if err := text.Render(&b, doc.Root); err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(w.files.dir, file)
+			rel = filepath.ToSlash(rel)
+			page = &wikiPage{
+				File:  file,
+				URL:   "/" + strings.TrimPrefix(wikiURL(rel), "./"),
+				Title: doc.Title(),
+				Depth: strings.Count(rel, "/"),
+				text:  b.String(),
+				links: linksOf(doc.Root),
+				mod:   info.ModTime(),
+			}
+			if page.Title == file {
+				page.Title = strings.TrimSuffix(info.Name(), filepath.Ext(file))
+			}
+			w.cache[file] = page
+		}
+		index.pages = append(index.pages, page)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for file := range w.cache {
+		if !seen[file] {
+			delete(w.cache, file)
+		}
+	}
+	sort.Slice(index.pages, func(i, j int) bool { return index.pages[i].URL < index.pages[j].URL })
+	index.links = newLinkResolver(index.pages)
+	if names := index.links.ambiguous(); strings.Join(names, "\n") != w.ambiguous {
+		w.ambiguous = strings.Join(names, "\n")
+		if len(names) > 0 {
+			logger.Warn(fmt.Sprintf("several pages are named %s", strings.Join(names, ", ")), "names", names)
+		}
+	}
+	w.index = index
+	return index, nil
+}
+
+// wikiPageData is what wikiTemplate lays out a page with
+type wikiPageData struct {
+	Title, URL, Query, SearchPath string
+	CSS                           template.CSS
+	Stylesheet                    string
+	Pages                         []*wikiPage
+	Backlinks                     []*wikiPage // Pages linking to this one
+	Body                          template.HTML
+}
+
+// respond lays out body as the page titled title at the URL path u
+func (w *wikiServer) respond(rw http.ResponseWriter, index *wikiIndex, status int, title, u, query string, body []byte) {
+	var backlinks []*wikiPage
+	if page := index.links.byURL[u]; page != nil {
+		backlinks = index.links.linkedFrom(page)
+	}
+	data := &wikiPageData{
+		Title:      title,
+		URL:        u,
+		Query:      query,
+		SearchPath: wikiSearchPath,
+		CSS:        w.css,
+		Stylesheet: w.stylesheet,
+		Pages:      index.pages,
+		Backlinks:  backlinks,
+		Body:       template.HTML(body),
+	}
+	var out bytes.Buffer
+	if err := wikiTemplate.Execute(&out, data); err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.WriteHeader(status)
+	rw.Write(out.Bytes())
+}
+
+func (w *wikiServer) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	name := path.Clean("/" + r.URL.Path)
+	if strings.HasSuffix(r.URL.Path, "/") && name != "/" {
+		name += "/"
+	}
+	if isMarkdown(name) { // Links to files rather than pages
+		http.Redirect(rw, r, "/"+strings.TrimPrefix(wikiURL(name[1:]), "./"), http.StatusMovedPermanently)
+		return
+	}
+	index, err := w.refresh()
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	page := index.links.byURL[name]
+	if page == nil {
+		if path.Ext(name) != "" {
+			http.FileServer(w.files.http()).ServeHTTP(rw, r)
+			return
+		}
+		if name == "/" {
+			w.respond(rw, index, http.StatusOK, "Pages", name, "", []byte(pageList(index.pages)))
+			return
+		}
+		title := strings.TrimPrefix(name, "/")
+		if named := index.links.named(title); len(named) > 1 {
+			body := fmt.Sprintf("<h1>%s</h1>\n<p>%s are named %[1]s:</p>\n%[3]s", template.HTMLEscapeString(title), plural(len(named), "page"), pageItems(named))
+			w.respond(rw, index, http.StatusMultipleChoices, title, name, "", []byte(body))
+			return
+		}
+		body := fmt.Sprintf("<h1>%s</h1>\n<p>There's no page named %[1]s yet.</p>\n", template.HTMLEscapeString(title))
+		w.respond(rw, index, http.StatusNotFound, title, name, "", []byte(body))
+		return
+	}
+	start := time.Now()
+	doc, err := w.files.parse(w.parser, page.File)
+	if errors.Is(err, os.ErrNotExist) {
+		http.NotFound(rw, r)
+		return
+	} else if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var body bytes.Buffer
+	if err := render.NewHTMLRenderer().Render(&body, doc.Root); err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	serverMetrics.rendered(time.Since(start))
+	w.respond(rw, index, http.StatusOK, page.Title, page.URL, "", body.Bytes())
+}
+
+// search lists the pages whose text contains every word of the q query
+// parameter, those mentioning them most first
+func (w *wikiServer) search(rw http.ResponseWriter, r *http.Request) {
+	index, err := w.refresh()
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	q := r.URL.Query().Get("q")
+	words := strings.Fields(strings.ToLower(q))
+	type hit struct {
+		page  *wikiPage
+		count int
+	}
+	var hits []hit
+	for _, page := range index.pages {
+		text := strings.ToLower(page.Title + "\n" + page.text)
+		count := 0
+		for _, word := range words {
+			n := strings.Count(text, word)
+			if n == 0 {
+				count = 0
+				break
+			}
+			count += n
+		}
+		if count > 0 {
+			hits = append(hits, hit{page, count})
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].count > hits[j].count })
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<h1>Search</h1>\n<p>%s for &ldquo;%s&rdquo;</p>\n", plural(len(hits), "page"), template.HTMLEscapeString(q))
+	if len(hits) > 0 {
+		b.WriteString("<ul>\n")
+		for _, h := range hits {
+			fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a><br>%s</li>\n",
+				template.HTMLEscapeString(h.page.URL), template.HTMLEscapeString(h.page.Title),
+				template.HTMLEscapeString(snippet(h.page.text, words[0])))
+		}
+		b.WriteString("</ul>\n")
+	}
+	w.respond(rw, index, http.StatusOK, "Search", wikiSearchPath, q, []byte(b.String()))
+}
+
+// pageList lists pages as HTML
+func pageList(pages []*wikiPage) string {
+	return "<h1>Pages</h1>\n" + pageItems(pages)
+}
+
+// pageItems links to each of pages in an HTML list
+func pageItems(pages []*wikiPage) string {
+	var b strings.Builder
+	b.WriteString("<ul>\n")
+	for _, page := range pages {
+		fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a></li>\n", template.HTMLEscapeString(page.URL), template.HTMLEscapeString(page.Title))
+	}
+	b.WriteString("</ul>\n")
+	return b.String()
+}
+
+// backlinks writes, as a JSON object, the URLs of the pages linking to
+// each page by its URL, or only to the page at the URL in the page query
+// parameter
+func (w *wikiServer) backlinks(rw http.ResponseWriter, r *http.Request) {
+	index, err := w.refresh()
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	pages := index.pages
+	if u := r.URL.Query().Get("page"); u != "" {
+		page := index.links.byURL[u]
+		if page == nil {
+			http.Error(rw, fmt.Sprintf("no page at %s", u), http.StatusNotFound)
+			return
+		}
+		pages = []*wikiPage{page}
+	}
+	backlinks := make(map[string][]string)
+	for _, page := range pages {
+		urls := []string{}
+		for _, from := range index.links.linkedFrom(page) {
+			urls = append(urls, from.URL)
+		}
+		backlinks[page.URL] = urls
+	}
+	writeJSON(rw, http.StatusOK, backlinks)
+}
+
+// snippetWidth is about how many bytes of text either side of a match a
+// snippet shows
+const snippetWidth = 60
+
+// snippet returns the text around the first mention of word, which is in
+// lower case, on one line
+func snippet(text, word string) string {
+	i := strings.Index(strings.ToLower(text), word)
+	if i < 0 {
+		return summary(text)
+	}
+	start, end := i-snippetWidth, i+len(word)+snippetWidth
+	prefix, suffix := "…", "…"
+	if start <= 0 {
+		start, prefix = 0, ""
+	}
+	if end >= len(text) {
+		end, suffix = len(text), ""
+	}
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+	return prefix + strings.Join(strings.Fields(text[start:end]), " ") + suffix
+}